@@ -80,6 +80,10 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&ZFSRestoreList{},
 		&ZFSNode{},
 		&ZFSNodeList{},
+		&ZFSSnapshotSchedule{},
+		&ZFSSnapshotScheduleList{},
+		&ZFSSnapshotGroup{},
+		&ZFSSnapshotGroupList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
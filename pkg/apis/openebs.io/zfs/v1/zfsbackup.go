@@ -17,6 +17,7 @@ limitations under the License.
 package v1
 
 import (
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -36,8 +37,10 @@ type ZFSBackup struct {
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 	Spec              ZFSBackupSpec `json:"spec"`
 	// +kubebuilder:validation:Required
-	// +kubebuilder:validation:Enum=Init;Done;Failed;Pending;InProgress;Invalid
+	// +kubebuilder:validation:Enum=Init;Done;Failed;Pending;InProgress;Invalid;Cancelled
 	Status ZFSBackupStatus `json:"status"`
+	// Transfer is the state of the backup stream transfer
+	Transfer TransferInfo `json:"transfer,omitempty"`
 }
 
 // ZFSBackupSpec is the spec for a ZFSBackup resource
@@ -60,11 +63,209 @@ type ZFSBackupSpec struct {
 	// PrevSnapName is the last completed-backup's snapshot name
 	PrevSnapName string `json:"prevSnapName,omitempty"`
 
-	// BackupDest is the remote address for backup transfer
+	// BackupDest is the remote address for backup transfer as host:port,
+	// [ipv6]:port or the in-cluster <service>.<namespace>:port, the
+	// file:///<dir> directory on the node where the backup is written,
+	// or the s3://<bucket>/<prefix> where the backup is uploaded
 	// +kubebuilder:validation:Required
 	// +kubebuilder:validation:MinLength=1
-	// +kubebuilder:validation:Pattern="^([0-9]+.[0-9]+.[0-9]+.[0-9]+:[0-9]+)$"
+	// +kubebuilder:validation:Pattern=`^(([a-zA-Z0-9]([-a-zA-Z0-9.]*[a-zA-Z0-9])?\.?|\[[0-9a-fA-F:.]+(%[-a-zA-Z0-9_.]+)?\]):[0-9]{1,5}|file:///.+|s3://.+)$`
 	BackupDest string `json:"backupDest"`
+
+	// TLS enables TLS for the backup stream sent to BackupDest
+	TLS *StreamTLS `json:"tls,omitempty"`
+
+	// S3 configures the object storage for the s3:// BackupDest
+	S3 *S3Target `json:"s3,omitempty"`
+
+	// Compression compresses the backup stream on the node before it is sent
+	// +kubebuilder:validation:Enum=none;gzip;zstd
+	Compression string `json:"compression,omitempty"`
+
+	// Encryption encrypts the backup stream on the node before it is sent
+	Encryption *StreamEncryption `json:"encryption,omitempty"`
+
+	// SendOptions are the zfs send options used for the backup stream
+	SendOptions *SendOptions `json:"sendOptions,omitempty"`
+
+	// DestroySnapshot destroys the backup snapshot once it has been
+	// transferred and bookmarked, so that it does not hold the space of the
+	// pool. The next incremental backup is sent from its bookmark.
+	DestroySnapshot bool `json:"destroySnapshot,omitempty"`
+
+	// Limits overrides the transfer limits of the node for the backup
+	Limits *TransferLimits `json:"limits,omitempty"`
+
+	// Cancel stops the backup, the send in flight is killed and the
+	// backup moves to Cancelled
+	Cancel bool `json:"cancel,omitempty"`
+
+	// Deadline is the time allowed for the backup including its retries,
+	// counted from the first attempt, e.g. "2h". The send is killed and
+	// the backup fails once it is exceeded.
+	Deadline *metav1.Duration `json:"deadline,omitempty"`
+}
+
+// TransferLimits limits the bandwidth and the concurrency of the backup
+// and the restore transfers
+type TransferLimits struct {
+	// MaxRate is the maximum rate of the stream in bytes per second, e.g.
+	// 50Mi. It can only lower the rate set for the node.
+	MaxRate *resource.Quantity `json:"maxRate,omitempty"`
+
+	// MaxConcurrent is the maximum number of the backup and the restore
+	// streams running on the node, including this one, for it to start.
+	// It waits as Pending until then. It can only lower the limit set for
+	// the node.
+	// +kubebuilder:validation:Minimum=1
+	MaxConcurrent int `json:"maxConcurrent,omitempty"`
+}
+
+// SendOptions are the zfs send options used for the backup stream
+type SendOptions struct {
+	// Raw sends the encrypted volume as it is on the disk (zfs send -w), so
+	// that it stays encrypted end to end and the key is not needed to send
+	// or receive it
+	Raw bool `json:"raw,omitempty"`
+
+	// Compressed sends the blocks compressed as they are on the disk (-c)
+	Compressed bool `json:"compressed,omitempty"`
+
+	// LargeBlock sends the blocks larger than 128KiB as they are (-L)
+	LargeBlock bool `json:"largeBlock,omitempty"`
+
+	// Embedded sends the data embedded in the block pointers as it is (-e)
+	Embedded bool `json:"embedded,omitempty"`
+
+	// Replicate sends the volume along with its properties and snapshots
+	// (-R), the incremental stream includes the intermediate snapshots
+	Replicate bool `json:"replicate,omitempty"`
+
+	// Props sends the properties of the volume along with it (-p)
+	Props bool `json:"props,omitempty"`
+}
+
+// StreamEncryption configures the client-side encryption of the backup stream
+type StreamEncryption struct {
+	// SecretName is the name of the secret in the openebs namespace holding
+	// the encryption key of at least 32 bytes as key
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	SecretName string `json:"secretName"`
+}
+
+// StreamTLS configures TLS for the backup and restore data streams
+type StreamTLS struct {
+	// SecretName is the name of the secret in the openebs namespace
+	// holding the CA certificate (ca.crt) used to verify the server and,
+	// for mutual TLS, the client certificate (tls.crt) and its key (tls.key)
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	SecretName string `json:"secretName"`
+
+	// ServerName is the name used to verify the server certificate,
+	// the host part of the address is used if it is not set
+	ServerName string `json:"serverName,omitempty"`
+}
+
+// S3Target configures the S3 compatible object storage used as the backup
+// destination or the restore source
+type S3Target struct {
+	// Endpoint is the host[:port] of the object storage service
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Endpoint string `json:"endpoint"`
+
+	// Region is the region of the bucket, it is looked up if not set
+	Region string `json:"region,omitempty"`
+
+	// SecretName is the name of the secret in the openebs namespace holding
+	// the credentials of the object storage as accessKeyID, secretAccessKey
+	// and the optional sessionToken
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	SecretName string `json:"secretName"`
+
+	// Insecure uses http instead of https to connect to the Endpoint
+	Insecure bool `json:"insecure,omitempty"`
+}
+
+// TransferInfo holds the state of the backup or the restore stream transfer
+type TransferInfo struct {
+	// ResumeToken is the receive_resume_token of the partially received
+	// stream. Only the local restore records it when the receive gets
+	// interrupted, and resumes the stream from it using zfs send -t on
+	// retry. The backups and the restores from the restore server, the file
+	// and the s3 targets keep no resumable state, their retry transfers the
+	// whole stream again.
+	ResumeToken string `json:"resumeToken,omitempty"`
+
+	// Retries is the number of times the failed transfer has been retried
+	Retries int `json:"retries,omitempty"`
+
+	// EstimatedSize is the estimated size of the stream in bytes
+	EstimatedSize int64 `json:"estimatedSize,omitempty"`
+
+	// BytesTransferred is the number of bytes of the stream transferred so far
+	BytesTransferred int64 `json:"bytesTransferred,omitempty"`
+
+	// Throughput is the average transfer rate of the stream in bytes per second
+	Throughput int64 `json:"throughput,omitempty"`
+
+	// StartTime is the time at which the last transfer attempt started
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is the time at which the transfer completed
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// LastError is the error due to which the last transfer attempt failed
+	LastError string `json:"lastError,omitempty"`
+
+	// Checksum is the sha256 of the stream as "sha256:<hex>", recorded once
+	// the whole stream has been transferred
+	Checksum string `json:"checksum,omitempty"`
+
+	// SnapGUID is the guid of the backup snapshot which has been sent or
+	// received, the received snapshot has the guid of the sent one
+	SnapGUID string `json:"snapGUID,omitempty"`
+
+	// IncrementalBase is what the incremental backup stream is sent from,
+	// the snapshot @<PrevSnapName> or its bookmark #<PrevSnapName>
+	IncrementalBase string `json:"incrementalBase,omitempty"`
+
+	// ChainReset is why the incremental chain from PrevSnapName has been
+	// found broken on the source or on the target, the backup has been
+	// sent as the full stream instead of the incremental one
+	ChainReset string `json:"chainReset,omitempty"`
+
+	// Deadline is the time by which the transfer has to complete, it is
+	// set on the first attempt as per the deadline in the spec
+	Deadline *metav1.Time `json:"deadline,omitempty"`
+
+	// StagingDataset is the hidden dataset the restore stream is received
+	// into, it is renamed to the volume once the restore has been verified
+	StagingDataset string `json:"stagingDataset,omitempty"`
+
+	// StagingOrigin is the most recent snapshot of the volume the staging
+	// dataset is cloned from, so that the incremental stream is received on
+	// top of it. The restored volume is promoted once it has been renamed,
+	// taking over the snapshots of the volume up to StagingOrigin.
+	StagingOrigin string `json:"stagingOrigin,omitempty"`
+}
+
+// BackupVerification is recorded by the ZFSBackup to verify its restore
+type BackupVerification struct {
+	// Checksum is the sha256 of the backup stream as "sha256:<hex>",
+	// the restore stream is verified against it while it is received
+	// +kubebuilder:validation:Pattern="^sha256:[0-9a-f]{64}$"
+	Checksum string `json:"checksum,omitempty"`
+
+	// SnapName is the name of the backup snapshot
+	SnapName string `json:"snapName,omitempty"`
+
+	// SnapGUID is the guid of the backup snapshot, the received
+	// snapshot SnapName is verified to have the same guid
+	SnapGUID string `json:"snapGUID,omitempty"`
 }
 
 // ZFSBackupStatus is to hold status of backup
@@ -89,6 +290,9 @@ const (
 
 	// BKPZFSStatusInvalid , backup operation is invalid.
 	BKPZFSStatusInvalid ZFSBackupStatus = "Invalid"
+
+	// BKPZFSStatusCancelled , backup is cancelled.
+	BKPZFSStatusCancelled ZFSBackupStatus = "Cancelled"
 )
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
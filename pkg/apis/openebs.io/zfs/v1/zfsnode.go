@@ -57,6 +57,11 @@ type Pool struct {
 	// Used specifies the used capacity of zfs pool.
 	// +kubebuilder:validation:Required
 	Used resource.Quantity `json:"used"`
+
+	// Health is the health of the zfs pool as reported by zpool,
+	// ONLINE if all of its devices are working.
+	// +optional
+	Health string `json:"health,omitempty"`
 }
 
 // ZFSNodeList is a collection of ZFSNode resources
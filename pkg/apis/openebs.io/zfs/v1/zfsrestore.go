@@ -31,8 +31,13 @@ type ZFSRestore struct {
 	Spec              ZFSRestoreSpec              `json:"spec"`
 	VolSpec           VolumeInfo                  `json:"volSpec,omitempty"`
 	// +kubebuilder:validation:Required
-	// +kubebuilder:validation:Enum=Init;Done;Failed;Pending;InProgress;Invalid
+	// +kubebuilder:validation:Enum=Init;Done;Failed;Pending;InProgress;Invalid;Cancelled
 	Status ZFSRestoreStatus `json:"status"`
+	// Transfer is the state of the restore stream transfer
+	Transfer TransferInfo `json:"transfer,omitempty"`
+	// Overridden are the properties of VolSpec which have been overridden
+	// as per spec.overrides, along with their backed up values
+	Overridden []OverriddenProperty `json:"overridden,omitempty"`
 }
 
 // ZFSRestoreSpec is the spec for a ZFSRestore resource
@@ -46,11 +51,104 @@ type ZFSRestoreSpec struct {
 	// +kubebuilder:validation:MinLength=1
 	OwnerNodeID string `json:"ownerNodeID"`
 
-	// it can be ip:port in case of restore from remote or volumeName in case of local restore
+	// it can be host:port, [ipv6]:port or the in-cluster <service>.<namespace>:port
+	// in case of restore from remote or volumeName in case of local restore,
+	// or the file:///<dir>/<volume>/<snapshot>.json manifest of the backup written to a file,
+	// or the s3://<bucket>/<prefix>/<volume>/<snapshot>.json manifest of the uploaded backup.
+	// The local restore can also be done from a snapshot of the volume as volumeName@snapName.
 	// +kubebuilder:validation:Required
 	// +kubebuilder:validation:MinLength=1
-	// +kubebuilder:validation:Pattern="^([0-9]+.[0-9]+.[0-9]+.[0-9]+:[0-9]+)$"
+	// +kubebuilder:validation:Pattern=`^(([a-zA-Z0-9]([-a-zA-Z0-9.]*[a-zA-Z0-9])?\.?|\[[0-9a-fA-F:.]+(%[-a-zA-Z0-9_.]+)?\]):[0-9]{1,5}|file:///.+|s3://.+|[a-z0-9]([-a-z0-9.]*[a-z0-9])?(@[-a-zA-Z0-9_.]+)?)$`
 	RestoreSrc string `json:"restoreSrc"`
+
+	// LocalMode is how the local restore is done, send receives a copy of
+	// the snapshot using zfs send | zfs recv, clone clones the snapshot,
+	// which is held as long as the restored volume depends on it. The
+	// snapshot is taken by the restore if the source is a volume. Defaults
+	// to send.
+	// +kubebuilder:validation:Enum=send;clone
+	LocalMode string `json:"localMode,omitempty"`
+
+	// TLS enables TLS for the restore stream received from RestoreSrc
+	TLS *StreamTLS `json:"tls,omitempty"`
+
+	// S3 configures the object storage for the s3:// RestoreSrc
+	S3 *S3Target `json:"s3,omitempty"`
+
+	// Encryption holds the key to decrypt the backup stream encrypted on the
+	// node, the compression and the encryption of the stream are detected
+	Encryption *StreamEncryption `json:"encryption,omitempty"`
+
+	// SendOptions are the zfs send options the backup stream has been sent
+	// with, which decide how it is received. They are taken from the
+	// manifest of the file and the s3 backups if not set.
+	SendOptions *SendOptions `json:"sendOptions,omitempty"`
+
+	// Limits overrides the transfer limits of the node for the restore
+	Limits *TransferLimits `json:"limits,omitempty"`
+
+	// Verify is the checksum and the snapshot guid recorded by the ZFSBackup
+	// in its transfer status, the restore fails if the received stream or
+	// snapshot does not match them. It is taken from the manifest of the
+	// file and the s3 backups if not set.
+	Verify *BackupVerification `json:"verify,omitempty"`
+
+	// Cancel stops the restore, the receive in flight is killed, its
+	// partially received state is discarded and the restore moves to
+	// Cancelled
+	Cancel bool `json:"cancel,omitempty"`
+
+	// Deadline is the time allowed for the restore including its retries,
+	// counted from the first attempt, e.g. "2h". The receive is killed and
+	// the restore fails once it is exceeded.
+	Deadline *metav1.Duration `json:"deadline,omitempty"`
+
+	// Overrides are the properties the volume is restored with instead of
+	// the ones of the backed up volume in VolSpec, e.g. for restoring into
+	// a cluster where the pools are named differently
+	Overrides *RestoreOverrides `json:"overrides,omitempty"`
+}
+
+// RestoreOverrides are the properties of the restored volume which override
+// the ones of the backed up volume, they are received using zfs recv -o and
+// the properties received with the stream contradicting them are excluded
+// using zfs recv -x
+type RestoreOverrides struct {
+	// PoolName is the pool the volume is restored into, the ZFSVolume of
+	// the volume is moved to it once the volume has been received
+	// +kubebuilder:validation:MinLength=1
+	PoolName string `json:"poolName,omitempty"`
+
+	// Compression is the compression of the restored volume, the blocks of
+	// the stream sent raw or compressed keep the compression they are sent with
+	// +kubebuilder:validation:Pattern="^(on|off|lzjb|zstd(?:-fast|-[1-9]|-1[0-9])?|gzip(?:-[1-9])?|zle|lz4)$"
+	Compression string `json:"compression,omitempty"`
+
+	// RecordSize is the recordsize of the restored dataset, the received
+	// files keep the recordsize they are sent with
+	// +kubebuilder:validation:MinLength=1
+	RecordSize string `json:"recordsize,omitempty"`
+
+	// QuotaType is the quota type of the restored dataset
+	// +kubebuilder:validation:Enum=quota;refquota
+	QuotaType string `json:"quotaType,omitempty"`
+
+	// ThinProvision is whether the space of the restored volume is reserved
+	// +kubebuilder:validation:Enum=yes;no
+	ThinProvision string `json:"thinProvision,omitempty"`
+}
+
+// OverriddenProperty is the property of the restored volume which has been
+// overridden
+type OverriddenProperty struct {
+	// Name is the name of the property in VolSpec
+	Name string `json:"name"`
+
+	// Value is the value the volume has been restored with
+	Value string `json:"value"`
+
+	// Original is the value of the backed up volume
+	Original string `json:"original,omitempty"`
 }
 
 // ZFSRestoreStatus is to hold result of action.
@@ -75,6 +173,9 @@ const (
 
 	// RSTZFSStatusInvalid , restore operation is invalid.
 	RSTZFSStatusInvalid ZFSRestoreStatus = "Invalid"
+
+	// RSTZFSStatusCancelled , restore operation is cancelled.
+	RSTZFSStatusCancelled ZFSRestoreStatus = "Cancelled"
 )
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -49,4 +49,9 @@ type ZFSSnapshotList struct {
 // SnapStatus string that reflects if the snapshot was created successfully
 type SnapStatus struct {
 	State string `json:"state,omitempty"`
+
+	// DeletionBlockedBy are the user holds placed on the snapshot by the
+	// backups and the clones using it, which block the destroy of the
+	// deleted snapshot until they are released
+	DeletionBlockedBy []string `json:"deletionBlockedBy,omitempty"`
 }
@@ -0,0 +1,86 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +resource:path=zfssnapshotgroup
+
+// ZFSSnapshotGroup represents the snapshots of multiple zfsvolumes taken at
+// the same instant. The node agent takes all of them in a single zfs
+// snapshot command and creates a ZFSSnapshot for each of them.
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced,shortName=zfssnapgrp
+// +kubebuilder:printcolumn:name="Node",type=string,JSONPath=`.spec.ownerNodeID`,description="Node where the volumes are present"
+// +kubebuilder:printcolumn:name="Pool",type=string,JSONPath=`.spec.poolName`,description="Pool where the volumes are present"
+// +kubebuilder:printcolumn:name="State",type=string,JSONPath=`.status.state`,description="State of the group snapshot"
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`,description="Age of the group snapshot"
+type ZFSSnapshotGroup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ZFSSnapshotGroupSpec   `json:"spec"`
+	Status ZFSSnapshotGroupStatus `json:"status,omitempty"`
+}
+
+// ZFSSnapshotGroupSpec is the spec for a ZFSSnapshotGroup resource
+type ZFSSnapshotGroupSpec struct {
+	// OwnerNodeID is the node where all the volumes are present
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	OwnerNodeID string `json:"ownerNodeID"`
+
+	// PoolName is the pool where all the volumes are present, the zfs
+	// snapshot command takes the snapshots atomically within a pool only
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	PoolName string `json:"poolName"`
+
+	// VolumeNames are the names of the ZFSVolumes to take the snapshots of
+	// +kubebuilder:validation:MinItems=1
+	VolumeNames []string `json:"volumeNames"`
+}
+
+// ZFSSnapshotGroupStatus is the status of the group snapshot
+type ZFSSnapshotGroupStatus struct {
+	// State is Pending until the snapshots have been taken, Ready once the
+	// ZFSSnapshot of each of them has been created, or Failed if they can
+	// not be taken
+	// +kubebuilder:validation:Enum=Pending;Ready;Failed
+	State string `json:"state,omitempty"`
+
+	// CreationTime is when the snapshots have been taken
+	CreationTime *metav1.Time `json:"creationTime,omitempty"`
+
+	// Error is the reason the snapshots could not be taken
+	Error string `json:"error,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +resource:path=zfssnapshotgroups
+
+// ZFSSnapshotGroupList is a list of ZFSSnapshotGroup resources
+type ZFSSnapshotGroupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []ZFSSnapshotGroup `json:"items"`
+}
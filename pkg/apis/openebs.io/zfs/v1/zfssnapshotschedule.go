@@ -0,0 +1,132 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +resource:path=zfssnapshotschedule
+
+// ZFSSnapshotSchedule takes the snapshots of the selected ZFSVolumes on the
+// schedules and prunes them as per their retention. The node agent owning
+// the volume creates and deletes the ZFSSnapshot objects of its snapshots,
+// which are deleted along with the ZFSSnapshotSchedule. Its name is the label
+// value of the snapshots, so it can not be longer than 63 characters.
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced,shortName=zfssnapsched
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="StorageClass",type=string,JSONPath=`.spec.storageClassName`,description="StorageClass of the selected volumes"
+// +kubebuilder:printcolumn:name="Suspend",type=boolean,JSONPath=`.spec.suspend`,description="Whether taking the snapshots is suspended"
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`,description="Age of the schedule"
+type ZFSSnapshotSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ZFSSnapshotScheduleSpec   `json:"spec"`
+	Status ZFSSnapshotScheduleStatus `json:"status,omitempty"`
+}
+
+// ZFSSnapshotScheduleSpec is the spec for a ZFSSnapshotSchedule resource
+type ZFSSnapshotScheduleSpec struct {
+	// Selector selects the ZFSVolumes by their labels
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// StorageClassName selects the ZFSVolumes provisioned using the
+	// storage class, along with the Selector if it is set
+	StorageClassName string `json:"storageClassName,omitempty"`
+
+	// Schedules are the schedules the snapshots are taken on, each of them
+	// keeps its own snapshots, e.g. hourly and daily
+	// +kubebuilder:validation:MinItems=1
+	Schedules []SnapshotSchedule `json:"schedules"`
+
+	// Suspend stops taking the snapshots, the snapshots already taken
+	// are still pruned as per the retention of their schedule
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// SnapshotSchedule takes a snapshot of each selected volume every interval
+type SnapshotSchedule struct {
+	// Name is the name of the schedule, which is a part of the names of
+	// its snapshots
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MaxLength=16
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// Interval is the time between the snapshots, e.g. "1h" or "24h". The
+	// snapshots are taken at the multiples of the interval, so the hourly
+	// ones are taken at the top of the hour and the daily ones at midnight
+	// UTC. It has to be at least a minute.
+	// +kubebuilder:validation:Required
+	Interval metav1.Duration `json:"interval"`
+
+	// Keep is the number of the most recent snapshots of the schedule to
+	// keep, the older ones are pruned
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Minimum=1
+	Keep int `json:"keep"`
+}
+
+// ZFSSnapshotScheduleStatus is the status of the schedule on the nodes
+type ZFSSnapshotScheduleStatus struct {
+	// Nodes is the status of the schedule on each of the nodes
+	// owning the selected volumes
+	Nodes []ScheduleNodeStatus `json:"nodes,omitempty"`
+}
+
+// ScheduleNodeStatus is the status of the last run of the schedule on the node
+type ScheduleNodeStatus struct {
+	// NodeID is the node the schedule has been run on
+	NodeID string `json:"nodeID"`
+
+	// LastRunTime is when the schedule has last been run on the node
+	LastRunTime metav1.Time `json:"lastRunTime,omitempty"`
+
+	// LastSnapshotTime is when the last snapshot has been taken on the node
+	LastSnapshotTime *metav1.Time `json:"lastSnapshotTime,omitempty"`
+
+	// Volumes is the number of the selected volumes on the node
+	Volumes int `json:"volumes"`
+
+	// Failures are the volumes the last run has failed to take or to
+	// prune the snapshots of
+	Failures []ScheduleFailure `json:"failures,omitempty"`
+}
+
+// ScheduleFailure is the error the schedule has failed with for the volume
+type ScheduleFailure struct {
+	// VolumeName is the name of the volume
+	VolumeName string `json:"volumeName"`
+
+	// Error is the error the schedule has failed with
+	Error string `json:"error"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +resource:path=zfssnapshotschedules
+
+// ZFSSnapshotScheduleList is a list of ZFSSnapshotSchedule resources
+type ZFSSnapshotScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []ZFSSnapshotSchedule `json:"items"`
+}
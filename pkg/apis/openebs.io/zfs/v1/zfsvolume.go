@@ -184,6 +184,26 @@ type VolumeInfo struct {
 	// +kubebuilder:validation:Required
 	// +kubebuilder:validation:Enum=yes;no
 	Shared string `json:"shared,omitempty"`
+
+	// BackupRetention decides which snapshots of the backups of the volume
+	// are kept on the pool, the node agent prunes the others once a backup
+	// of the volume is done. It is set from the backupkeeplast and the
+	// backupkeepwithin parameters of the storageclass.
+	// BackupRetention can be edited after the volume has been provisioned.
+	BackupRetention *BackupRetention `json:"backupRetention,omitempty"`
+}
+
+// BackupRetention is the retention policy of the backup snapshots of the
+// volume, a snapshot is kept if either of the rules keeps it. The snapshot
+// of the most recent backup is always kept, as the next incremental backup
+// is sent from it.
+type BackupRetention struct {
+	// KeepLast is the number of the most recent backup snapshots to keep
+	// +kubebuilder:validation:Minimum=1
+	KeepLast int `json:"keepLast,omitempty"`
+
+	// KeepWithin keeps the backup snapshots taken within the duration, e.g. "168h"
+	KeepWithin *metav1.Duration `json:"keepWithin,omitempty"`
 }
 
 // VolStatus string that specifies the current state of the volume provisioning request.
@@ -194,4 +214,19 @@ type VolStatus struct {
 	// and it is ready for the use.
 	// +kubebuilder:validation:Enum=Pending;Ready;Failed
 	State string `json:"state,omitempty"`
+
+	// PublishedPaths are the target paths the volume is published at,
+	// recorded by the node agent when it publishes and unpublishes the
+	// volume. The controller reports the nodes of these paths as the
+	// published nodes of the volume.
+	PublishedPaths []PublishedPath `json:"publishedPaths,omitempty"`
+}
+
+// PublishedPath is a target path the volume is published at
+type PublishedPath struct {
+	// NodeID is the id of the csi node the volume is published on
+	NodeID string `json:"nodeID"`
+
+	// TargetPath is the path the volume is published at on the node
+	TargetPath string `json:"targetPath"`
 }
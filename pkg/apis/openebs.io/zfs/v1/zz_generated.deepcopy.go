@@ -22,9 +22,63 @@ limitations under the License.
 package v1
 
 import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupRetention) DeepCopyInto(out *BackupRetention) {
+	*out = *in
+	if in.KeepWithin != nil {
+		in, out := &in.KeepWithin, &out.KeepWithin
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupRetention.
+func (in *BackupRetention) DeepCopy() *BackupRetention {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupRetention)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupVerification) DeepCopyInto(out *BackupVerification) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupVerification.
+func (in *BackupVerification) DeepCopy() *BackupVerification {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupVerification)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OverriddenProperty) DeepCopyInto(out *OverriddenProperty) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OverriddenProperty.
+func (in *OverriddenProperty) DeepCopy() *OverriddenProperty {
+	if in == nil {
+		return nil
+	}
+	out := new(OverriddenProperty)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Pool) DeepCopyInto(out *Pool) {
 	*out = *in
@@ -43,9 +97,120 @@ func (in *Pool) DeepCopy() *Pool {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PublishedPath) DeepCopyInto(out *PublishedPath) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PublishedPath.
+func (in *PublishedPath) DeepCopy() *PublishedPath {
+	if in == nil {
+		return nil
+	}
+	out := new(PublishedPath)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestoreOverrides) DeepCopyInto(out *RestoreOverrides) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestoreOverrides.
+func (in *RestoreOverrides) DeepCopy() *RestoreOverrides {
+	if in == nil {
+		return nil
+	}
+	out := new(RestoreOverrides)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *S3Target) DeepCopyInto(out *S3Target) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S3Target.
+func (in *S3Target) DeepCopy() *S3Target {
+	if in == nil {
+		return nil
+	}
+	out := new(S3Target)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScheduleFailure) DeepCopyInto(out *ScheduleFailure) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduleFailure.
+func (in *ScheduleFailure) DeepCopy() *ScheduleFailure {
+	if in == nil {
+		return nil
+	}
+	out := new(ScheduleFailure)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScheduleNodeStatus) DeepCopyInto(out *ScheduleNodeStatus) {
+	*out = *in
+	in.LastRunTime.DeepCopyInto(&out.LastRunTime)
+	if in.LastSnapshotTime != nil {
+		in, out := &in.LastSnapshotTime, &out.LastSnapshotTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Failures != nil {
+		in, out := &in.Failures, &out.Failures
+		*out = make([]ScheduleFailure, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduleNodeStatus.
+func (in *ScheduleNodeStatus) DeepCopy() *ScheduleNodeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ScheduleNodeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SendOptions) DeepCopyInto(out *SendOptions) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SendOptions.
+func (in *SendOptions) DeepCopy() *SendOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(SendOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SnapStatus) DeepCopyInto(out *SnapStatus) {
 	*out = *in
+	if in.DeletionBlockedBy != nil {
+		in, out := &in.DeletionBlockedBy, &out.DeletionBlockedBy
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -59,9 +224,112 @@ func (in *SnapStatus) DeepCopy() *SnapStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SnapshotSchedule) DeepCopyInto(out *SnapshotSchedule) {
+	*out = *in
+	out.Interval = in.Interval
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotSchedule.
+func (in *SnapshotSchedule) DeepCopy() *SnapshotSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(SnapshotSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StreamEncryption) DeepCopyInto(out *StreamEncryption) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StreamEncryption.
+func (in *StreamEncryption) DeepCopy() *StreamEncryption {
+	if in == nil {
+		return nil
+	}
+	out := new(StreamEncryption)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StreamTLS) DeepCopyInto(out *StreamTLS) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StreamTLS.
+func (in *StreamTLS) DeepCopy() *StreamTLS {
+	if in == nil {
+		return nil
+	}
+	out := new(StreamTLS)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TransferInfo) DeepCopyInto(out *TransferInfo) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Deadline != nil {
+		in, out := &in.Deadline, &out.Deadline
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TransferInfo.
+func (in *TransferInfo) DeepCopy() *TransferInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(TransferInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TransferLimits) DeepCopyInto(out *TransferLimits) {
+	*out = *in
+	if in.MaxRate != nil {
+		in, out := &in.MaxRate, &out.MaxRate
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TransferLimits.
+func (in *TransferLimits) DeepCopy() *TransferLimits {
+	if in == nil {
+		return nil
+	}
+	out := new(TransferLimits)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VolStatus) DeepCopyInto(out *VolStatus) {
 	*out = *in
+	if in.PublishedPaths != nil {
+		in, out := &in.PublishedPaths, &out.PublishedPaths
+		*out = make([]PublishedPath, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -78,6 +346,11 @@ func (in *VolStatus) DeepCopy() *VolStatus {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VolumeInfo) DeepCopyInto(out *VolumeInfo) {
 	*out = *in
+	if in.BackupRetention != nil {
+		in, out := &in.BackupRetention, &out.BackupRetention
+		*out = new(BackupRetention)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -96,7 +369,8 @@ func (in *ZFSBackup) DeepCopyInto(out *ZFSBackup) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Transfer.DeepCopyInto(&out.Transfer)
 	return
 }
 
@@ -154,6 +428,36 @@ func (in *ZFSBackupList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ZFSBackupSpec) DeepCopyInto(out *ZFSBackupSpec) {
 	*out = *in
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(StreamTLS)
+		**out = **in
+	}
+	if in.S3 != nil {
+		in, out := &in.S3, &out.S3
+		*out = new(S3Target)
+		**out = **in
+	}
+	if in.Encryption != nil {
+		in, out := &in.Encryption, &out.Encryption
+		*out = new(StreamEncryption)
+		**out = **in
+	}
+	if in.SendOptions != nil {
+		in, out := &in.SendOptions, &out.SendOptions
+		*out = new(SendOptions)
+		**out = **in
+	}
+	if in.Limits != nil {
+		in, out := &in.Limits, &out.Limits
+		*out = new(TransferLimits)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Deadline != nil {
+		in, out := &in.Deadline, &out.Deadline
+		*out = new(metav1.Duration)
+		**out = **in
+	}
 	return
 }
 
@@ -238,8 +542,14 @@ func (in *ZFSRestore) DeepCopyInto(out *ZFSRestore) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.VolSpec = in.VolSpec
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.VolSpec.DeepCopyInto(&out.VolSpec)
+	in.Transfer.DeepCopyInto(&out.Transfer)
+	if in.Overridden != nil {
+		in, out := &in.Overridden, &out.Overridden
+		*out = make([]OverriddenProperty, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -297,6 +607,46 @@ func (in *ZFSRestoreList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ZFSRestoreSpec) DeepCopyInto(out *ZFSRestoreSpec) {
 	*out = *in
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(StreamTLS)
+		**out = **in
+	}
+	if in.S3 != nil {
+		in, out := &in.S3, &out.S3
+		*out = new(S3Target)
+		**out = **in
+	}
+	if in.Encryption != nil {
+		in, out := &in.Encryption, &out.Encryption
+		*out = new(StreamEncryption)
+		**out = **in
+	}
+	if in.SendOptions != nil {
+		in, out := &in.SendOptions, &out.SendOptions
+		*out = new(SendOptions)
+		**out = **in
+	}
+	if in.Limits != nil {
+		in, out := &in.Limits, &out.Limits
+		*out = new(TransferLimits)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Verify != nil {
+		in, out := &in.Verify, &out.Verify
+		*out = new(BackupVerification)
+		**out = **in
+	}
+	if in.Deadline != nil {
+		in, out := &in.Deadline, &out.Deadline
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Overrides != nil {
+		in, out := &in.Overrides, &out.Overrides
+		*out = new(RestoreOverrides)
+		**out = **in
+	}
 	return
 }
 
@@ -315,8 +665,8 @@ func (in *ZFSSnapshot) DeepCopyInto(out *ZFSSnapshot) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -338,6 +688,108 @@ func (in *ZFSSnapshot) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZFSSnapshotGroup) DeepCopyInto(out *ZFSSnapshotGroup) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZFSSnapshotGroup.
+func (in *ZFSSnapshotGroup) DeepCopy() *ZFSSnapshotGroup {
+	if in == nil {
+		return nil
+	}
+	out := new(ZFSSnapshotGroup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ZFSSnapshotGroup) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZFSSnapshotGroupList) DeepCopyInto(out *ZFSSnapshotGroupList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ZFSSnapshotGroup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZFSSnapshotGroupList.
+func (in *ZFSSnapshotGroupList) DeepCopy() *ZFSSnapshotGroupList {
+	if in == nil {
+		return nil
+	}
+	out := new(ZFSSnapshotGroupList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ZFSSnapshotGroupList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZFSSnapshotGroupSpec) DeepCopyInto(out *ZFSSnapshotGroupSpec) {
+	*out = *in
+	if in.VolumeNames != nil {
+		in, out := &in.VolumeNames, &out.VolumeNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZFSSnapshotGroupSpec.
+func (in *ZFSSnapshotGroupSpec) DeepCopy() *ZFSSnapshotGroupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ZFSSnapshotGroupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZFSSnapshotGroupStatus) DeepCopyInto(out *ZFSSnapshotGroupStatus) {
+	*out = *in
+	if in.CreationTime != nil {
+		in, out := &in.CreationTime, &out.CreationTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZFSSnapshotGroupStatus.
+func (in *ZFSSnapshotGroupStatus) DeepCopy() *ZFSSnapshotGroupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ZFSSnapshotGroupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ZFSSnapshotList) DeepCopyInto(out *ZFSSnapshotList) {
 	*out = *in
@@ -371,13 +823,123 @@ func (in *ZFSSnapshotList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZFSSnapshotSchedule) DeepCopyInto(out *ZFSSnapshotSchedule) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZFSSnapshotSchedule.
+func (in *ZFSSnapshotSchedule) DeepCopy() *ZFSSnapshotSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(ZFSSnapshotSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ZFSSnapshotSchedule) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZFSSnapshotScheduleList) DeepCopyInto(out *ZFSSnapshotScheduleList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ZFSSnapshotSchedule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZFSSnapshotScheduleList.
+func (in *ZFSSnapshotScheduleList) DeepCopy() *ZFSSnapshotScheduleList {
+	if in == nil {
+		return nil
+	}
+	out := new(ZFSSnapshotScheduleList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ZFSSnapshotScheduleList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZFSSnapshotScheduleSpec) DeepCopyInto(out *ZFSSnapshotScheduleSpec) {
+	*out = *in
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Schedules != nil {
+		in, out := &in.Schedules, &out.Schedules
+		*out = make([]SnapshotSchedule, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZFSSnapshotScheduleSpec.
+func (in *ZFSSnapshotScheduleSpec) DeepCopy() *ZFSSnapshotScheduleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ZFSSnapshotScheduleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZFSSnapshotScheduleStatus) DeepCopyInto(out *ZFSSnapshotScheduleStatus) {
+	*out = *in
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]ScheduleNodeStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZFSSnapshotScheduleStatus.
+func (in *ZFSSnapshotScheduleStatus) DeepCopy() *ZFSSnapshotScheduleStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ZFSSnapshotScheduleStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ZFSVolume) DeepCopyInto(out *ZFSVolume) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
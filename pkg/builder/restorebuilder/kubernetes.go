@@ -23,6 +23,7 @@ import (
 	clientset "github.com/openebs/zfs-localpv/pkg/generated/clientset/internalclientset"
 	"github.com/pkg/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 // getClientsetFn is a typed function that
@@ -78,6 +79,16 @@ type updateFn func(
 	namespace string,
 ) (*apis.ZFSRestore, error)
 
+// patchFn is a typed function that abstracts
+// patching zfsrstr rstrume instance
+type patchFn func(
+	cs *clientset.Clientset,
+	name string,
+	pt types.PatchType,
+	data []byte,
+	namespace string,
+) (*apis.ZFSRestore, error)
+
 // Kubeclient enables kubernetes API operations
 // on zfsrstr rstrume instance
 type Kubeclient struct {
@@ -100,6 +111,7 @@ type Kubeclient struct {
 	del                 delFn
 	create              createFn
 	update              updateFn
+	patch               patchFn
 }
 
 // KubeclientBuildOption defines the abstraction
@@ -197,6 +209,20 @@ func defaultUpdate(
 		Update(context.TODO(), rstr, metav1.UpdateOptions{})
 }
 
+// defaultPatch is the default implementation to patch
+// a zfsrstr rstrume instance in kubernetes cluster
+func defaultPatch(
+	cli *clientset.Clientset,
+	name string,
+	pt types.PatchType,
+	data []byte,
+	namespace string,
+) (*apis.ZFSRestore, error) {
+	return cli.ZfsV1().
+		ZFSRestores(namespace).
+		Patch(context.TODO(), name, pt, data, metav1.PatchOptions{})
+}
+
 // withDefaults sets the default options
 // of kubeclient instance
 func (k *Kubeclient) withDefaults() {
@@ -221,6 +247,9 @@ func (k *Kubeclient) withDefaults() {
 	if k.update == nil {
 		k.update = defaultUpdate
 	}
+	if k.patch == nil {
+		k.patch = defaultPatch
+	}
 }
 
 // WithClientSet sets the kubernetes client against
@@ -426,3 +455,19 @@ func (k *Kubeclient) Update(rstr *apis.ZFSRestore) (*apis.ZFSRestore, error) {
 
 	return k.update(cs, rstr, k.namespace)
 }
+
+// Patch patches this zfsrstr rstrume instance
+// against kubernetes cluster
+func (k *Kubeclient) Patch(name string, pt types.PatchType, data []byte) (*apis.ZFSRestore, error) {
+	cs, err := k.getClientOrCached()
+	if err != nil {
+		return nil, errors.Wrapf(
+			err,
+			"failed to patch csirstrume {%s} in namespace {%s}",
+			name,
+			k.namespace,
+		)
+	}
+
+	return k.patch(cs, name, pt, data, k.namespace)
+}
@@ -0,0 +1,428 @@
+// Copyright © 2024 The OpenEBS Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapgroupbuilder
+
+import (
+	"context"
+	"encoding/json"
+
+	client "github.com/openebs/lib-csi/pkg/common/kubernetes/client"
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	clientset "github.com/openebs/zfs-localpv/pkg/generated/clientset/internalclientset"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// getClientsetFn is a typed function that
+// abstracts fetching of internal clientset
+type getClientsetFn func() (clientset *clientset.Clientset, err error)
+
+// getClientsetFromPathFn is a typed function that
+// abstracts fetching of clientset from kubeConfigPath
+type getClientsetForPathFn func(kubeConfigPath string) (
+	clientset *clientset.Clientset,
+	err error,
+)
+
+// createFn is a typed function that abstracts
+// creating zfs snapshot group instance
+type createFn func(
+	cs *clientset.Clientset,
+	upgradeResultObj *apis.ZFSSnapshotGroup,
+	namespace string,
+) (*apis.ZFSSnapshotGroup, error)
+
+// getFn is a typed function that abstracts
+// fetching a zfs snapshot group instance
+type getFn func(
+	cli *clientset.Clientset,
+	name,
+	namespace string,
+	opts metav1.GetOptions,
+) (*apis.ZFSSnapshotGroup, error)
+
+// listFn is a typed function that abstracts
+// listing of zfs snapshot group instances
+type listFn func(
+	cli *clientset.Clientset,
+	namespace string,
+	opts metav1.ListOptions,
+) (*apis.ZFSSnapshotGroupList, error)
+
+// delFn is a typed function that abstracts
+// deleting a zfs snapshot group instance
+type delFn func(
+	cli *clientset.Clientset,
+	name,
+	namespace string,
+	opts *metav1.DeleteOptions,
+) error
+
+// updateFn is a typed function that abstracts
+// updating zfs snapshot group instance
+type updateFn func(
+	cs *clientset.Clientset,
+	grp *apis.ZFSSnapshotGroup,
+	namespace string,
+) (*apis.ZFSSnapshotGroup, error)
+
+// Kubeclient enables kubernetes API operations
+// on zfs snapshot group instance
+type Kubeclient struct {
+	// clientset refers to zfs snapshot group's
+	// clientset that will be responsible to
+	// make kubernetes API calls
+	clientset *clientset.Clientset
+
+	kubeConfigPath string
+
+	// namespace holds the namespace on which
+	// kubeclient has to operate
+	namespace string
+
+	// functions useful during mocking
+	getClientset        getClientsetFn
+	getClientsetForPath getClientsetForPathFn
+	get                 getFn
+	list                listFn
+	del                 delFn
+	create              createFn
+	update              updateFn
+}
+
+// KubeclientBuildOption defines the abstraction
+// to build a kubeclient instance
+type KubeclientBuildOption func(*Kubeclient)
+
+// defaultGetClientset is the default implementation to
+// get kubernetes clientset instance
+func defaultGetClientset() (clients *clientset.Clientset, err error) {
+
+	config, err := client.GetConfig(client.New())
+	if err != nil {
+		return nil, err
+	}
+
+	return clientset.NewForConfig(config)
+
+}
+
+// defaultGetClientsetForPath is the default implementation to
+// get kubernetes clientset instance based on the given
+// kubeconfig path
+func defaultGetClientsetForPath(
+	kubeConfigPath string,
+) (clients *clientset.Clientset, err error) {
+	config, err := client.GetConfig(
+		client.New(client.WithKubeConfigPath(kubeConfigPath)))
+	if err != nil {
+		return nil, err
+	}
+
+	return clientset.NewForConfig(config)
+}
+
+// defaultGet is the default implementation to get
+// a zfs snapshot group instance in kubernetes cluster
+func defaultGet(
+	cli *clientset.Clientset,
+	name, namespace string,
+	opts metav1.GetOptions,
+) (*apis.ZFSSnapshotGroup, error) {
+	return cli.ZfsV1().
+		ZFSSnapshotGroups(namespace).
+		Get(context.TODO(), name, opts)
+}
+
+// defaultList is the default implementation to list
+// zfs snapshot group instances in kubernetes cluster
+func defaultList(
+	cli *clientset.Clientset,
+	namespace string,
+	opts metav1.ListOptions,
+) (*apis.ZFSSnapshotGroupList, error) {
+	return cli.ZfsV1().
+		ZFSSnapshotGroups(namespace).
+		List(context.TODO(), opts)
+}
+
+// defaultCreate is the default implementation to delete
+// a zfs snapshot group instance in kubernetes cluster
+func defaultDel(
+	cli *clientset.Clientset,
+	name, namespace string,
+	opts *metav1.DeleteOptions,
+) error {
+	deletePropagation := metav1.DeletePropagationForeground
+	opts.PropagationPolicy = &deletePropagation
+	err := cli.ZfsV1().
+		ZFSSnapshotGroups(namespace).
+		Delete(context.TODO(), name, *opts)
+	return err
+}
+
+// defaultCreate is the default implementation to create
+// a zfs snapshot group instance in kubernetes cluster
+func defaultCreate(
+	cli *clientset.Clientset,
+	grp *apis.ZFSSnapshotGroup,
+	namespace string,
+) (*apis.ZFSSnapshotGroup, error) {
+	return cli.ZfsV1().
+		ZFSSnapshotGroups(namespace).
+		Create(context.TODO(), grp, metav1.CreateOptions{})
+}
+
+// defaultUpdate is the default implementation to update
+// a zfs snapshot group instance in kubernetes cluster
+func defaultUpdate(
+	cli *clientset.Clientset,
+	grp *apis.ZFSSnapshotGroup,
+	namespace string,
+) (*apis.ZFSSnapshotGroup, error) {
+	return cli.ZfsV1().
+		ZFSSnapshotGroups(namespace).
+		Update(context.TODO(), grp, metav1.UpdateOptions{})
+}
+
+// withDefaults sets the default options
+// of kubeclient instance
+func (k *Kubeclient) withDefaults() {
+	if k.getClientset == nil {
+		k.getClientset = defaultGetClientset
+	}
+	if k.getClientsetForPath == nil {
+		k.getClientsetForPath = defaultGetClientsetForPath
+	}
+	if k.get == nil {
+		k.get = defaultGet
+	}
+	if k.list == nil {
+		k.list = defaultList
+	}
+	if k.del == nil {
+		k.del = defaultDel
+	}
+	if k.create == nil {
+		k.create = defaultCreate
+	}
+	if k.update == nil {
+		k.update = defaultUpdate
+	}
+}
+
+// WithClientSet sets the kubernetes client against
+// the kubeclient instance
+func WithClientSet(c *clientset.Clientset) KubeclientBuildOption {
+	return func(k *Kubeclient) {
+		k.clientset = c
+	}
+}
+
+// WithNamespace sets the kubernetes client against
+// the provided namespace
+func WithNamespace(namespace string) KubeclientBuildOption {
+	return func(k *Kubeclient) {
+		k.namespace = namespace
+	}
+}
+
+// WithNamespace sets the provided namespace
+// against this Kubeclient instance
+func (k *Kubeclient) WithNamespace(namespace string) *Kubeclient {
+	k.namespace = namespace
+	return k
+}
+
+// WithKubeConfigPath sets the kubernetes client
+// against the provided path
+func WithKubeConfigPath(path string) KubeclientBuildOption {
+	return func(k *Kubeclient) {
+		k.kubeConfigPath = path
+	}
+}
+
+// NewKubeclient returns a new instance of
+// kubeclient meant for zfs snapshot group operations
+func NewKubeclient(opts ...KubeclientBuildOption) *Kubeclient {
+	k := &Kubeclient{}
+	for _, o := range opts {
+		o(k)
+	}
+
+	k.withDefaults()
+	return k
+}
+
+func (k *Kubeclient) getClientsetForPathOrDirect() (
+	*clientset.Clientset,
+	error,
+) {
+	if k.kubeConfigPath != "" {
+		return k.getClientsetForPath(k.kubeConfigPath)
+	}
+
+	return k.getClientset()
+}
+
+// getClientOrCached returns either a new instance
+// of kubernetes client or its cached copy
+func (k *Kubeclient) getClientOrCached() (*clientset.Clientset, error) {
+	if k.clientset != nil {
+		return k.clientset, nil
+	}
+
+	c, err := k.getClientsetForPathOrDirect()
+	if err != nil {
+		return nil,
+			errors.Wrapf(
+				err,
+				"failed to get clientset",
+			)
+	}
+
+	k.clientset = c
+	return k.clientset, nil
+}
+
+// Create creates a zfs snapshot group instance
+// in kubernetes cluster
+func (k *Kubeclient) Create(grp *apis.ZFSSnapshotGroup) (*apis.ZFSSnapshotGroup, error) {
+	if grp == nil {
+		return nil,
+			errors.New(
+				"failed to create zfs snapshot group: nil snapshot group object",
+			)
+	}
+	cs, err := k.getClientOrCached()
+	if err != nil {
+		return nil, errors.Wrapf(
+			err,
+			"failed to create zfs snapshot group {%s} in namespace {%s}",
+			grp.Name,
+			k.namespace,
+		)
+	}
+
+	return k.create(cs, grp, k.namespace)
+}
+
+// Get returns zfs snapshot group object for given name
+func (k *Kubeclient) Get(
+	name string,
+	opts metav1.GetOptions,
+) (*apis.ZFSSnapshotGroup, error) {
+	if name == "" {
+		return nil,
+			errors.New(
+				"failed to get zfs snapshot group: missing zfs snapshot group name",
+			)
+	}
+
+	cli, err := k.getClientOrCached()
+	if err != nil {
+		return nil, errors.Wrapf(
+			err,
+			"failed to get zfs snapshot group {%s} in namespace {%s}",
+			name,
+			k.namespace,
+		)
+	}
+
+	return k.get(cli, name, k.namespace, opts)
+}
+
+// GetRaw returns zfs snapshot group instance
+// in bytes
+func (k *Kubeclient) GetRaw(
+	name string,
+	opts metav1.GetOptions,
+) ([]byte, error) {
+	if name == "" {
+		return nil, errors.New(
+			"failed to get raw zfs snapshot group: missing snapshot group name",
+		)
+	}
+	csiv, err := k.Get(name, opts)
+	if err != nil {
+		return nil, errors.Wrapf(
+			err,
+			"failed to get zfs snapshot group {%s} in namespace {%s}",
+			name,
+			k.namespace,
+		)
+	}
+
+	return json.Marshal(csiv)
+}
+
+// List returns a list of zfs snapshot group
+// instances present in kubernetes cluster
+func (k *Kubeclient) List(opts metav1.ListOptions) (*apis.ZFSSnapshotGroupList, error) {
+	cli, err := k.getClientOrCached()
+	if err != nil {
+		return nil, errors.Wrapf(
+			err,
+			"failed to list zfs snapshot groups in namespace {%s}",
+			k.namespace,
+		)
+	}
+
+	return k.list(cli, k.namespace, opts)
+}
+
+// Delete deletes the zfs snapshot group from
+// kubernetes
+func (k *Kubeclient) Delete(name string) error {
+	if name == "" {
+		return errors.New(
+			"failed to delete zfs snapshot group: missing snapshot group name",
+		)
+	}
+	cli, err := k.getClientOrCached()
+	if err != nil {
+		return errors.Wrapf(
+			err,
+			"failed to delete zfs snapshot group {%s} in namespace {%s}",
+			name,
+			k.namespace,
+		)
+	}
+
+	return k.del(cli, name, k.namespace, &metav1.DeleteOptions{})
+}
+
+// Update updates this zfs snapshot group instance
+// against kubernetes cluster
+func (k *Kubeclient) Update(grp *apis.ZFSSnapshotGroup) (*apis.ZFSSnapshotGroup, error) {
+	if grp == nil {
+		return nil,
+			errors.New(
+				"failed to update zfs snapshot group: nil snapshot group object",
+			)
+	}
+
+	cs, err := k.getClientOrCached()
+	if err != nil {
+		return nil, errors.Wrapf(
+			err,
+			"failed to update zfs snapshot group {%s} in namespace {%s}",
+			grp.Name,
+			grp.Namespace,
+		)
+	}
+
+	return k.update(cs, grp, k.namespace)
+}
@@ -0,0 +1,140 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapgroupbuilder
+
+import (
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	"github.com/pkg/errors"
+)
+
+// Builder is the builder object for ZFSSnapshotGroup
+type Builder struct {
+	grp  *ZFSSnapshotGroup
+	errs []error
+}
+
+// ZFSSnapshotGroup is a wrapper over
+// ZFSSnapshotGroup API instance
+type ZFSSnapshotGroup struct {
+	// ZFSSnapshotGroup object
+	Object *apis.ZFSSnapshotGroup
+}
+
+// NewBuilder returns new instance of Builder
+func NewBuilder() *Builder {
+	return &Builder{
+		grp: &ZFSSnapshotGroup{
+			Object: &apis.ZFSSnapshotGroup{},
+		},
+	}
+}
+
+// WithNamespace sets the namespace of ZFSSnapshotGroup
+func (b *Builder) WithNamespace(namespace string) *Builder {
+	if namespace == "" {
+		b.errs = append(
+			b.errs,
+			errors.New(
+				"failed to build zfs snapshot group object: missing namespace",
+			),
+		)
+		return b
+	}
+	b.grp.Object.Namespace = namespace
+	return b
+}
+
+// WithName sets the name of ZFSSnapshotGroup
+func (b *Builder) WithName(name string) *Builder {
+	if name == "" {
+		b.errs = append(
+			b.errs,
+			errors.New(
+				"failed to build zfs snapshot group object: missing name",
+			),
+		)
+		return b
+	}
+	b.grp.Object.Name = name
+	return b
+}
+
+// WithOwnerNodeID sets the node where the volumes of ZFSSnapshotGroup are present
+func (b *Builder) WithOwnerNodeID(host string) *Builder {
+	if host == "" {
+		b.errs = append(
+			b.errs,
+			errors.New(
+				"failed to build zfs snapshot group object: missing owner node",
+			),
+		)
+		return b
+	}
+	b.grp.Object.Spec.OwnerNodeID = host
+	return b
+}
+
+// WithPoolName sets the pool where the volumes of ZFSSnapshotGroup are present
+func (b *Builder) WithPoolName(pool string) *Builder {
+	if pool == "" {
+		b.errs = append(
+			b.errs,
+			errors.New(
+				"failed to build zfs snapshot group object: missing pool name",
+			),
+		)
+		return b
+	}
+	b.grp.Object.Spec.PoolName = pool
+	return b
+}
+
+// WithVolumeNames sets the volumes to take the snapshots of
+func (b *Builder) WithVolumeNames(vols []string) *Builder {
+	if len(vols) == 0 {
+		b.errs = append(
+			b.errs,
+			errors.New(
+				"failed to build zfs snapshot group object: missing volumes",
+			),
+		)
+		return b
+	}
+	b.grp.Object.Spec.VolumeNames = vols
+	return b
+}
+
+// WithState sets the state of ZFSSnapshotGroup
+func (b *Builder) WithState(state string) *Builder {
+	b.grp.Object.Status.State = state
+	return b
+}
+
+// WithFinalizer sets the finalizer of ZFSSnapshotGroup
+func (b *Builder) WithFinalizer(finalizer []string) *Builder {
+	b.grp.Object.Finalizers = append(b.grp.Object.Finalizers, finalizer...)
+	return b
+}
+
+// Build returns ZFSSnapshotGroup API object
+func (b *Builder) Build() (*apis.ZFSSnapshotGroup, error) {
+	if len(b.errs) > 0 {
+		return nil, errors.Errorf("%+v", b.errs)
+	}
+
+	return b.grp.Object, nil
+}
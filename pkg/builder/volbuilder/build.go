@@ -17,8 +17,12 @@ limitations under the License.
 package volbuilder
 
 import (
+	"strconv"
+	"time"
+
 	"github.com/openebs/lib-csi/pkg/common/errors"
 	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // Builder is the builder object for ZFSVolume
@@ -188,6 +192,43 @@ func (b *Builder) WithShared(shared string) *Builder {
 	return b
 }
 
+// WithBackupRetention sets the retention of the backup snapshots, keepLast
+// being the number of snapshots and keepWithin the duration to keep them
+func (b *Builder) WithBackupRetention(keepLast, keepWithin string) *Builder {
+	if keepLast == "" && keepWithin == "" {
+		return b
+	}
+	retention := &apis.BackupRetention{}
+	if keepLast != "" {
+		n, err := strconv.Atoi(keepLast)
+		if err != nil || n < 1 {
+			b.errs = append(
+				b.errs,
+				errors.Errorf(
+					"failed to build zfs volume object: invalid backupkeeplast %q", keepLast,
+				),
+			)
+			return b
+		}
+		retention.KeepLast = n
+	}
+	if keepWithin != "" {
+		d, err := time.ParseDuration(keepWithin)
+		if err != nil || d <= 0 {
+			b.errs = append(
+				b.errs,
+				errors.Errorf(
+					"failed to build zfs volume object: invalid backupkeepwithin %q", keepWithin,
+				),
+			)
+			return b
+		}
+		retention.KeepWithin = &metav1.Duration{Duration: d}
+	}
+	b.volume.Object.Spec.BackupRetention = retention
+	return b
+}
+
 // WithSnapshot sets Snapshot name for creating clone volume
 func (b *Builder) WithSnapshot(snap string) *Builder {
 	b.volume.Object.Spec.SnapName = snap
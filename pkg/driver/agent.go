@@ -17,6 +17,7 @@ limitations under the License.
 package driver
 
 import (
+	"fmt"
 	"os"
 	"strings"
 	"sync"
@@ -28,6 +29,8 @@ import (
 	"github.com/openebs/zfs-localpv/pkg/builder/volbuilder"
 	"github.com/openebs/zfs-localpv/pkg/mgmt/backup"
 	"github.com/openebs/zfs-localpv/pkg/mgmt/restore"
+	"github.com/openebs/zfs-localpv/pkg/mgmt/schedule"
+	"github.com/openebs/zfs-localpv/pkg/mgmt/snapgroup"
 	"github.com/openebs/zfs-localpv/pkg/mgmt/snapshot"
 	"github.com/openebs/zfs-localpv/pkg/mgmt/volume"
 	"github.com/openebs/zfs-localpv/pkg/mgmt/zfsnode"
@@ -44,6 +47,8 @@ import (
 // node is the server implementation
 // for CSI NodeServer
 type node struct {
+	csi.UnimplementedNodeServer
+
 	driver *CSIDriver
 }
 
@@ -79,6 +84,14 @@ func NewNode(d *CSIDriver) csi.NodeServer {
 		}
 	}()
 
+	// start the snapshot group watcher
+	go func() {
+		err := snapgroup.Start(&ControllerMutex, stopCh)
+		if err != nil {
+			klog.Fatalf("Failed to start ZFS snapshot group management controller: %s", err.Error())
+		}
+	}()
+
 	// start the backup controller
 	go func() {
 		err := backup.Start(&ControllerMutex, stopCh)
@@ -95,6 +108,14 @@ func NewNode(d *CSIDriver) csi.NodeServer {
 		}
 	}()
 
+	// start the snapshot schedule controller
+	go func() {
+		err := schedule.Start(&ControllerMutex, stopCh)
+		if err != nil {
+			klog.Fatalf("Failed to start ZFS snapshot schedule controller: %s", err.Error())
+		}
+	}()
+
 	return &node{
 		driver: d,
 	}
@@ -162,6 +183,13 @@ func (ns *node) NodePublishVolume(
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
+	// the controller reports the published nodes of the volume from it
+	err = zfs.SetVolumePublished(vol.Name, ns.driver.config.Nodename, mountInfo.MountPath, true)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal,
+			"unable to record the volume %s published at %s err : %s",
+			vol.Name, mountInfo.MountPath, err.Error())
+	}
 	return &csi.NodePublishVolumeResponse{}, nil
 }
 
@@ -202,6 +230,13 @@ func (ns *node) NodeUnpublishVolume(
 	klog.Infof("hostpath: volume %s path: %s has been unmounted.",
 		volumeID, targetPath)
 
+	err = zfs.SetVolumePublished(volumeID, ns.driver.config.Nodename, targetPath, false)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal,
+			"unable to record the volume %s unpublished from %s err : %s",
+			volumeID, targetPath, err.Error())
+	}
+
 	return &csi.NodeUnpublishVolumeResponse{}, nil
 }
 
@@ -294,6 +329,13 @@ func (ns *node) NodeGetCapabilities(
 					},
 				},
 			},
+			{
+				Type: &csi.NodeServiceCapability_Rpc{
+					Rpc: &csi.NodeServiceCapability_RPC{
+						Type: csi.NodeServiceCapability_RPC_VOLUME_CONDITION,
+					},
+				},
+			},
 		},
 	}, nil
 }
@@ -413,7 +455,15 @@ func (ns *node) NodeGetVolumeStats(
 
 	var sfs unix.Statfs_t
 	if err := unix.Statfs(path, &sfs); err != nil {
-		return nil, status.Errorf(codes.Internal, "statfs on %s failed: %v", path, err)
+		if !zfs.IsStaleMount(err) {
+			return nil, status.Errorf(codes.Internal, "statfs on %s failed: %v", path, err)
+		}
+		return &csi.NodeGetVolumeStatsResponse{
+			VolumeCondition: &csi.VolumeCondition{
+				Abnormal: true,
+				Message:  fmt.Sprintf("mount %s is stale: %v", path, err),
+			},
+		}, nil
 	}
 
 	var usage []*csi.VolumeUsage
@@ -430,7 +480,21 @@ func (ns *node) NodeGetVolumeStats(
 		Available: int64(sfs.Ffree),
 	})
 
-	return &csi.NodeGetVolumeStatsResponse{Usage: usage}, nil
+	// the usage is still reported if the condition can not be found out
+	vol, err := zfs.GetZFSVolume(volID)
+	if err != nil {
+		klog.Warningf("could not get the condition of volume %s: %v", volID, err)
+		return &csi.NodeGetVolumeStatsResponse{Usage: usage}, nil
+	}
+
+	abnormal, msg := zfs.VolumeCondition(vol)
+	return &csi.NodeGetVolumeStatsResponse{
+		Usage: usage,
+		VolumeCondition: &csi.VolumeCondition{
+			Abnormal: abnormal,
+			Message:  msg,
+		},
+	}, nil
 }
 
 func (ns *node) validateNodePublishReq(
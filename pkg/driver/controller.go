@@ -2,6 +2,8 @@ package driver
 
 import (
 	"fmt"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -15,6 +17,7 @@ import (
 	"golang.org/x/net/context"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	timestamp "google.golang.org/protobuf/types/known/timestamppb"
 	k8serror "k8s.io/apimachinery/pkg/api/errors"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/labels"
@@ -56,6 +59,8 @@ const (
 // controller is the server implementation
 // for CSI Controller
 type controller struct {
+	csi.UnimplementedControllerServer
+
 	driver       *CSIDriver
 	capabilities []*csi.ControllerServiceCapability
 
@@ -63,6 +68,8 @@ type controller struct {
 
 	k8sNodeInformer cache.SharedIndexInformer
 	zfsNodeInformer cache.SharedIndexInformer
+	zfsSnapInformer cache.SharedIndexInformer
+	zfsVolInformer  cache.SharedIndexInformer
 }
 
 // NewController returns a new instance
@@ -104,6 +111,8 @@ func (cs *controller) init() error {
 
 	cs.k8sNodeInformer = kubeInformerFactory.Core().V1().Nodes().Informer()
 	cs.zfsNodeInformer = openebsInformerfactory.Zfs().V1().ZFSNodes().Informer()
+	cs.zfsSnapInformer = openebsInformerfactory.Zfs().V1().ZFSSnapshots().Informer()
+	cs.zfsVolInformer = openebsInformerfactory.Zfs().V1().ZFSVolumes().Informer()
 
 	if err = cs.zfsNodeInformer.AddIndexers(map[string]cache.IndexFunc{
 		LabelIndexName(cs.indexedLabel): LabelIndexFunc(cs.indexedLabel),
@@ -111,8 +120,16 @@ func (cs *controller) init() error {
 		return errors.Wrapf(err, "failed to add index on label %v", cs.indexedLabel)
 	}
 
+	if err = cs.zfsSnapInformer.AddIndexers(map[string]cache.IndexFunc{
+		LabelIndexName(zfs.ZFSVolKey): LabelIndexFunc(zfs.ZFSVolKey),
+	}); err != nil {
+		return errors.Wrapf(err, "failed to add index on label %v", zfs.ZFSVolKey)
+	}
+
 	go cs.k8sNodeInformer.Run(stopCh)
 	go cs.zfsNodeInformer.Run(stopCh)
+	go cs.zfsSnapInformer.Run(stopCh)
+	go cs.zfsVolInformer.Run(stopCh)
 
 	if zfs.GoogleAnalyticsEnabled == "true" {
 		analytics.RegisterVersionGetter(version.GetVersionDetails)
@@ -122,11 +139,13 @@ func (cs *controller) init() error {
 	}
 
 	// wait for all the caches to be populated.
-	klog.Info("waiting for k8s & zfs node informer caches to be synced")
+	klog.Info("waiting for k8s & zfs node, zfs volume & snapshot informer caches to be synced")
 	cache.WaitForCacheSync(stopCh,
 		cs.k8sNodeInformer.HasSynced,
-		cs.zfsNodeInformer.HasSynced)
-	klog.Info("synced k8s & zfs node informer caches")
+		cs.zfsNodeInformer.HasSynced,
+		cs.zfsSnapInformer.HasSynced,
+		cs.zfsVolInformer.HasSynced)
+	klog.Info("synced k8s & zfs node, zfs volume & snapshot informer caches")
 	return nil
 }
 
@@ -222,6 +241,8 @@ func CreateZFSVolume(ctx context.Context, req *csi.CreateVolumeRequest) (string,
 	fstype := parameters["fstype"]
 	shared := parameters["shared"]
 	quotatype := parameters["quotatype"]
+	keeplast := parameters["backupkeeplast"]
+	keepwithin := parameters["backupkeepwithin"]
 
 	vtype := zfs.GetVolumeType(fstype)
 
@@ -282,6 +303,7 @@ func CreateZFSVolume(ctx context.Context, req *csi.CreateVolumeRequest) (string,
 		WithFsType(fstype).
 		WithQuotaType(quotatype).
 		WithShared(shared).
+		WithBackupRetention(keeplast, keepwithin).
 		WithCompression(compression).Build()
 
 	if err != nil {
@@ -823,7 +845,133 @@ func (cs *controller) ListSnapshots(
 	req *csi.ListSnapshotsRequest,
 ) (*csi.ListSnapshotsResponse, error) {
 
-	return nil, status.Error(codes.Unimplemented, "")
+	var (
+		objs []interface{}
+		err  error
+	)
+	switch {
+	case req.GetSnapshotId() != "":
+		// snapshodID is formed as <volname>@<snapname>
+		snapshotID := strings.Split(req.GetSnapshotId(), "@")
+		if len(snapshotID) != 2 {
+			return &csi.ListSnapshotsResponse{}, nil
+		}
+		obj, exists, err := cs.zfsSnapInformer.GetStore().GetByKey(
+			zfs.OpenEBSNamespace + "/" + snapshotID[1])
+		if err != nil {
+			return nil, status.Errorf(codes.Internal,
+				"ListSnapshots: failed to get snapshot %s: %v", req.GetSnapshotId(), err)
+		}
+		if exists {
+			objs = append(objs, obj)
+		}
+	case req.GetSourceVolumeId() != "":
+		objs, err = cs.zfsSnapInformer.GetIndexer().ByIndex(
+			LabelIndexName(zfs.ZFSVolKey), req.GetSourceVolumeId())
+		if err != nil {
+			return nil, status.Errorf(codes.Internal,
+				"ListSnapshots: failed to get snapshots of volume %s: %v", req.GetSourceVolumeId(), err)
+		}
+	default:
+		objs = cs.zfsSnapInformer.GetStore().List()
+	}
+
+	snaps := make([]*zfsapi.ZFSSnapshot, 0, len(objs))
+	for _, obj := range objs {
+		if snap, ok := obj.(*zfsapi.ZFSSnapshot); ok {
+			snaps = append(snaps, snap)
+		}
+	}
+	return listSnapshots(snaps, req)
+}
+
+// listSnapshots returns the csi snapshots of the ZFSSnapshots matching the
+// snapshot id and the source volume id of the request, sorted by their ids
+// and paginated as per the starting token and the max entries of the request
+func listSnapshots(
+	snaps []*zfsapi.ZFSSnapshot,
+	req *csi.ListSnapshotsRequest,
+) (*csi.ListSnapshotsResponse, error) {
+	var snapshots []*csi.Snapshot
+	for _, snap := range snaps {
+		volName := snap.Labels[zfs.ZFSVolKey]
+		// the deleted snapshots are kept only till their holds are released
+		if volName == "" || snap.DeletionTimestamp != nil {
+			continue
+		}
+		snapshot := newSnapshot(volName, snap)
+		if req.GetSnapshotId() != "" && req.GetSnapshotId() != snapshot.SnapshotId {
+			continue
+		}
+		if req.GetSourceVolumeId() != "" && req.GetSourceVolumeId() != volName {
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].SnapshotId < snapshots[j].SnapshotId
+	})
+
+	start, end, next, err := paginate(len(snapshots), req.GetStartingToken(), req.GetMaxEntries())
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*csi.ListSnapshotsResponse_Entry, 0, end-start)
+	for _, snapshot := range snapshots[start:end] {
+		entries = append(entries, &csi.ListSnapshotsResponse_Entry{Snapshot: snapshot})
+	}
+	return &csi.ListSnapshotsResponse{
+		Entries:   entries,
+		NextToken: next,
+	}, nil
+}
+
+// newSnapshot returns the csi snapshot of the ZFSSnapshot of the volume
+func newSnapshot(volName string, snap *zfsapi.ZFSSnapshot) *csi.Snapshot {
+	size, err := zfs.GetZFSSnapshotCapacity(snap)
+	if err != nil {
+		klog.Warningf("get zfssnapshot %s capacity failed: %v", snap.Name, err)
+	}
+	return &csi.Snapshot{
+		SnapshotId:      volName + "@" + snap.Name,
+		SourceVolumeId:  volName,
+		SizeBytes:       size,
+		CreationTime:    timestamp.New(snap.CreationTimestamp.Time),
+		ReadyToUse:      snap.Status.State == zfs.ZFSStatusReady,
+		GroupSnapshotId: snap.Labels[zfs.ZFSSnapGroupKey],
+	}
+}
+
+// paginate returns the range [start, end) of the total sorted entries to be
+// listed as per the starting token and the max entries of the list request,
+// along with the token to list the entries after the range. The token is the
+// index of the entry to start the listing from.
+func paginate(total int, token string, maxEntries int32) (int, int, string, error) {
+	if maxEntries < 0 {
+		return 0, 0, "", status.Errorf(codes.InvalidArgument,
+			"invalid max entries %d", maxEntries)
+	}
+
+	start := 0
+	if token != "" {
+		var err error
+		start, err = strconv.Atoi(token)
+		if err != nil || start < 0 || start > total {
+			return 0, 0, "", status.Errorf(codes.Aborted,
+				"invalid starting token %s", token)
+		}
+	}
+
+	end := total
+	if maxEntries > 0 && start+int(maxEntries) < total {
+		end = start + int(maxEntries)
+	}
+
+	next := ""
+	if end < total {
+		next = strconv.Itoa(end)
+	}
+	return start, end, next, nil
 }
 
 // ControllerUnpublishVolume removes a previously
@@ -982,7 +1130,181 @@ func (cs *controller) ListVolumes(
 	req *csi.ListVolumesRequest,
 ) (*csi.ListVolumesResponse, error) {
 
-	return nil, status.Error(codes.Unimplemented, "")
+	var vols []*zfsapi.ZFSVolume
+	for _, obj := range cs.zfsVolInformer.GetStore().List() {
+		if vol, ok := obj.(*zfsapi.ZFSVolume); ok {
+			vols = append(vols, vol)
+		}
+	}
+	var nodes []*zfsapi.ZFSNode
+	for _, obj := range cs.zfsNodeInformer.GetStore().List() {
+		if node, ok := obj.(*zfsapi.ZFSNode); ok {
+			nodes = append(nodes, node)
+		}
+	}
+	return listVolumes(vols, nodes, req)
+}
+
+// listVolumes returns the csi volumes of the ready ZFSVolumes sorted by
+// their ids and paginated as per the starting token and the max entries
+// of the request, along with the nodes they are published on.
+func listVolumes(
+	vols []*zfsapi.ZFSVolume,
+	nodes []*zfsapi.ZFSNode,
+	req *csi.ListVolumesRequest,
+) (*csi.ListVolumesResponse, error) {
+	nodesByName := make(map[string]*zfsapi.ZFSNode, len(nodes))
+	for _, node := range nodes {
+		nodesByName[node.Name] = node
+	}
+
+	var ready []*zfsapi.ZFSVolume
+	for _, vol := range vols {
+		if vol.DeletionTimestamp != nil || vol.Status.State != zfs.ZFSStatusReady {
+			continue
+		}
+		ready = append(ready, vol)
+	}
+	sort.Slice(ready, func(i, j int) bool {
+		return ready[i].Name < ready[j].Name
+	})
+
+	start, end, next, err := paginate(len(ready), req.GetStartingToken(), req.GetMaxEntries())
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*csi.ListVolumesResponse_Entry, 0, end-start)
+	for _, vol := range ready[start:end] {
+		entries = append(entries, &csi.ListVolumesResponse_Entry{
+			Volume: newVolume(vol),
+			Status: &csi.ListVolumesResponse_VolumeStatus{
+				PublishedNodeIds: publishedNodes(vol),
+				VolumeCondition:  volumeCondition(vol, nodesByName[vol.Spec.OwnerNodeID]),
+			},
+		})
+	}
+	return &csi.ListVolumesResponse{
+		Entries:   entries,
+		NextToken: next,
+	}, nil
+}
+
+// ControllerGetVolume returns the volume along with its condition
+// as per the health of its pool reported by the node owning it
+//
+// This implements csi.ControllerServer
+func (cs *controller) ControllerGetVolume(
+	ctx context.Context,
+	req *csi.ControllerGetVolumeRequest,
+) (*csi.ControllerGetVolumeResponse, error) {
+
+	volumeID := strings.ToLower(req.GetVolumeId())
+	if volumeID == "" {
+		return nil, status.Error(codes.InvalidArgument, "ControllerGetVolume: missing volume id")
+	}
+
+	obj, exists, err := cs.zfsVolInformer.GetStore().GetByKey(zfs.OpenEBSNamespace + "/" + volumeID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal,
+			"ControllerGetVolume: failed to get volume %s: %v", volumeID, err)
+	}
+	vol, ok := obj.(*zfsapi.ZFSVolume)
+	if !exists || !ok || vol.DeletionTimestamp != nil {
+		return nil, status.Errorf(codes.NotFound, "volume %s not found", volumeID)
+	}
+
+	var node *zfsapi.ZFSNode
+	obj, exists, err = cs.zfsNodeInformer.GetStore().GetByKey(zfs.OpenEBSNamespace + "/" + vol.Spec.OwnerNodeID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal,
+			"ControllerGetVolume: failed to get zfs node %s: %v", vol.Spec.OwnerNodeID, err)
+	}
+	if exists {
+		node, _ = obj.(*zfsapi.ZFSNode)
+	}
+
+	return &csi.ControllerGetVolumeResponse{
+		Volume: newVolume(vol),
+		Status: &csi.ControllerGetVolumeResponse_VolumeStatus{
+			PublishedNodeIds: publishedNodes(vol),
+			VolumeCondition:  volumeCondition(vol, node),
+		},
+	}, nil
+}
+
+// publishedNodes returns the sorted ids of the nodes the volume is
+// published on, as recorded on the ZFSVolume by the node agent. The
+// driver does not require the attach, so the node publish is the only
+// place the volume is known to be in use at.
+func publishedNodes(vol *zfsapi.ZFSVolume) []string {
+	var nodes []string
+	for _, p := range vol.Status.PublishedPaths {
+		if !slices.Contains(nodes, p.NodeID) {
+			nodes = append(nodes, p.NodeID)
+		}
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// volumeCondition returns the condition of the volume as per its state and
+// the health of its pool reported by the ZFSNode of the node owning it. The
+// presence of the dataset and its mounts are checked by the node agent
+// in NodeGetVolumeStats.
+func volumeCondition(vol *zfsapi.ZFSVolume, node *zfsapi.ZFSNode) *csi.VolumeCondition {
+	abnormal := func(msg string) *csi.VolumeCondition {
+		return &csi.VolumeCondition{Abnormal: true, Message: msg}
+	}
+	if vol.Status.State != zfs.ZFSStatusReady {
+		return abnormal(fmt.Sprintf("volume is not ready, state %q", vol.Status.State))
+	}
+	if node == nil {
+		return abnormal(fmt.Sprintf("zfs node %s is not present", vol.Spec.OwnerNodeID))
+	}
+	if reason := zfs.PoolCondition(node.Pools, vol.Spec.PoolName); reason != "" {
+		return abnormal(reason)
+	}
+	return &csi.VolumeCondition{Message: zfs.VolumeHealthy}
+}
+
+// newVolume returns the csi volume of the ZFSVolume, as it was
+// returned by the CreateVolume
+func newVolume(vol *zfsapi.ZFSVolume) *csi.Volume {
+	capacity, err := strconv.ParseInt(vol.Spec.Capacity, 10, 64)
+	if err != nil {
+		klog.Warningf("get zfsvolume %s capacity failed: %v", vol.Name, err)
+	}
+
+	var contentSource *csi.VolumeContentSource
+	// the clone of the volume is created from the snapshot <srcvol>@<volname>
+	if snapshotID := strings.Split(vol.Spec.SnapName, "@"); len(snapshotID) == 2 {
+		if snapshotID[1] == vol.Name {
+			contentSource = &csi.VolumeContentSource{
+				Type: &csi.VolumeContentSource_Volume{
+					Volume: &csi.VolumeContentSource_VolumeSource{VolumeId: snapshotID[0]},
+				},
+			}
+		} else {
+			contentSource = &csi.VolumeContentSource{
+				Type: &csi.VolumeContentSource_Snapshot{
+					Snapshot: &csi.VolumeContentSource_SnapshotSource{SnapshotId: vol.Spec.SnapName},
+				},
+			}
+		}
+	}
+
+	return &csi.Volume{
+		VolumeId:      vol.Name,
+		CapacityBytes: capacity,
+		VolumeContext: map[string]string{
+			zfs.PoolNameKey:       vol.Spec.PoolName,
+			zfs.OpenEBSCasTypeKey: zfs.ZFSCasTypeName,
+		},
+		ContentSource: contentSource,
+		AccessibleTopology: []*csi.Topology{
+			{Segments: map[string]string{zfs.ZFSTopologyKey: vol.Spec.OwnerNodeID}},
+		},
+	}
 }
 
 func (cs *controller) validateDeleteVolumeReq(req *csi.DeleteVolumeRequest) error {
@@ -1042,6 +1364,11 @@ func newControllerCapabilities() []*csi.ControllerServiceCapability {
 		csi.ControllerServiceCapability_RPC_CLONE_VOLUME,
 		csi.ControllerServiceCapability_RPC_EXPAND_VOLUME,
 		csi.ControllerServiceCapability_RPC_GET_CAPACITY,
+		csi.ControllerServiceCapability_RPC_LIST_SNAPSHOTS,
+		csi.ControllerServiceCapability_RPC_LIST_VOLUMES,
+		csi.ControllerServiceCapability_RPC_LIST_VOLUMES_PUBLISHED_NODES,
+		csi.ControllerServiceCapability_RPC_GET_VOLUME,
+		csi.ControllerServiceCapability_RPC_VOLUME_CONDITION,
 	} {
 		capabilities = append(capabilities, fromType(cap))
 	}
@@ -18,8 +18,16 @@ package driver
 
 import (
 	"testing"
+	"time"
 
+	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	zfsapi "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	"github.com/openebs/zfs-localpv/pkg/zfs"
 )
 
 func TestRoundOff(t *testing.T) {
@@ -43,3 +51,184 @@ func TestRoundOff(t *testing.T) {
 		})
 	}
 }
+
+func TestListSnapshots(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newSnap := func(vol, name, state string) *zfsapi.ZFSSnapshot {
+		snap := &zfsapi.ZFSSnapshot{}
+		snap.Name = name
+		snap.Labels = map[string]string{zfs.ZFSVolKey: vol}
+		snap.CreationTimestamp = metav1.NewTime(created)
+		snap.Spec.Capacity = "1073741824"
+		snap.Status.State = state
+		return snap
+	}
+	deleted := newSnap("pvc-1", "snap-4", zfs.ZFSStatusReady)
+	deleted.DeletionTimestamp = &metav1.Time{Time: created}
+	snaps := []*zfsapi.ZFSSnapshot{
+		newSnap("pvc-2", "snap-3", zfs.ZFSStatusPending),
+		newSnap("pvc-1", "snap-2", zfs.ZFSStatusReady),
+		newSnap("pvc-1", "snap-1", zfs.ZFSStatusReady),
+		deleted,
+	}
+
+	ids := func(resp *csi.ListSnapshotsResponse) []string {
+		var ids []string
+		for _, entry := range resp.Entries {
+			ids = append(ids, entry.Snapshot.SnapshotId)
+		}
+		return ids
+	}
+
+	resp, err := listSnapshots(snaps, &csi.ListSnapshotsRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pvc-1@snap-1", "pvc-1@snap-2", "pvc-2@snap-3"}, ids(resp))
+	assert.Empty(t, resp.NextToken)
+
+	snapshot := resp.Entries[2].Snapshot
+	assert.Equal(t, "pvc-2", snapshot.SourceVolumeId)
+	assert.Equal(t, int64(1073741824), snapshot.SizeBytes)
+	assert.Equal(t, created, snapshot.CreationTime.AsTime())
+	assert.False(t, snapshot.ReadyToUse)
+
+	resp, err = listSnapshots(snaps, &csi.ListSnapshotsRequest{SourceVolumeId: "pvc-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pvc-1@snap-1", "pvc-1@snap-2"}, ids(resp))
+
+	resp, err = listSnapshots(snaps, &csi.ListSnapshotsRequest{SnapshotId: "pvc-1@snap-2"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pvc-1@snap-2"}, ids(resp))
+
+	// the snapshot id has to match the source volume as well
+	resp, err = listSnapshots(snaps, &csi.ListSnapshotsRequest{SnapshotId: "pvc-2@snap-2"})
+	assert.NoError(t, err)
+	assert.Empty(t, resp.Entries)
+
+	resp, err = listSnapshots(snaps, &csi.ListSnapshotsRequest{MaxEntries: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pvc-1@snap-1", "pvc-1@snap-2"}, ids(resp))
+	assert.Equal(t, "2", resp.NextToken)
+
+	resp, err = listSnapshots(snaps, &csi.ListSnapshotsRequest{MaxEntries: 2, StartingToken: resp.NextToken})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pvc-2@snap-3"}, ids(resp))
+	assert.Empty(t, resp.NextToken)
+
+	for _, token := range []string{"invalid-token", "-1", "4"} {
+		_, err = listSnapshots(snaps, &csi.ListSnapshotsRequest{StartingToken: token})
+		assert.Equal(t, codes.Aborted, status.Code(err), "starting token %s", token)
+	}
+	_, err = listSnapshots(snaps, &csi.ListSnapshotsRequest{MaxEntries: -1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestListVolumes(t *testing.T) {
+	newVol := func(name, snapName, state string) *zfsapi.ZFSVolume {
+		vol := &zfsapi.ZFSVolume{}
+		vol.Name = name
+		vol.Spec.PoolName = "pool"
+		vol.Spec.OwnerNodeID = "node-1"
+		vol.Spec.Capacity = "1073741824"
+		vol.Spec.SnapName = snapName
+		vol.Status.State = state
+		return vol
+	}
+	deleted := newVol("pvc-5", "", zfs.ZFSStatusReady)
+	deleted.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	published := newVol("pvc-2", "pvc-1@pvc-2", zfs.ZFSStatusReady)
+	published.Status.PublishedPaths = []zfsapi.PublishedPath{
+		{NodeID: "node-1", TargetPath: "/var/lib/kubelet/pods/1/mount"},
+		{NodeID: "node-1", TargetPath: "/var/lib/kubelet/pods/2/mount"},
+	}
+	vols := []*zfsapi.ZFSVolume{
+		newVol("pvc-3", "pvc-1@snap-1", zfs.ZFSStatusReady),
+		published,
+		newVol("pvc-1", "", zfs.ZFSStatusReady),
+		newVol("pvc-4", "", zfs.ZFSStatusPending),
+		deleted,
+	}
+
+	node := &zfsapi.ZFSNode{}
+	node.Name = "node-1"
+	node.Pools = []zfsapi.Pool{{Name: "pool", Health: zfs.PoolHealthOnline}}
+	nodes := []*zfsapi.ZFSNode{node}
+
+	ids := func(resp *csi.ListVolumesResponse) []string {
+		var ids []string
+		for _, entry := range resp.Entries {
+			ids = append(ids, entry.Volume.VolumeId)
+		}
+		return ids
+	}
+
+	resp, err := listVolumes(vols, nodes, &csi.ListVolumesRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pvc-1", "pvc-2", "pvc-3"}, ids(resp))
+	assert.Empty(t, resp.NextToken)
+
+	volume := resp.Entries[0].Volume
+	assert.Equal(t, int64(1073741824), volume.CapacityBytes)
+	assert.Equal(t, "pool", volume.VolumeContext[zfs.PoolNameKey])
+	assert.Equal(t, "node-1", volume.AccessibleTopology[0].Segments[zfs.ZFSTopologyKey])
+	assert.Nil(t, volume.ContentSource)
+	assert.Empty(t, resp.Entries[0].Status.PublishedNodeIds)
+	assert.False(t, resp.Entries[0].Status.VolumeCondition.Abnormal)
+	assert.Equal(t, []string{"node-1"}, resp.Entries[1].Status.PublishedNodeIds)
+	assert.Equal(t, "pvc-1", resp.Entries[1].Volume.ContentSource.GetVolume().GetVolumeId())
+	assert.Equal(t, "pvc-1@snap-1", resp.Entries[2].Volume.ContentSource.GetSnapshot().GetSnapshotId())
+
+	resp, err = listVolumes(vols, nodes, &csi.ListVolumesRequest{MaxEntries: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pvc-1", "pvc-2"}, ids(resp))
+	assert.Equal(t, "2", resp.NextToken)
+
+	resp, err = listVolumes(vols, nodes, &csi.ListVolumesRequest{MaxEntries: 2, StartingToken: resp.NextToken})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pvc-3"}, ids(resp))
+	assert.Empty(t, resp.NextToken)
+
+	_, err = listVolumes(vols, nodes, &csi.ListVolumesRequest{StartingToken: "invalid-token"})
+	assert.Equal(t, codes.Aborted, status.Code(err))
+}
+
+func TestVolumeCondition(t *testing.T) {
+	vol := &zfsapi.ZFSVolume{}
+	vol.Spec.PoolName = "pool"
+	vol.Spec.OwnerNodeID = "node-1"
+	vol.Status.State = zfs.ZFSStatusReady
+
+	node := &zfsapi.ZFSNode{}
+	node.Name = "node-1"
+	node.Pools = []zfsapi.Pool{{Name: "pool", Health: zfs.PoolHealthOnline}}
+
+	tests := map[string]struct {
+		poolName string
+		state    string
+		node     *zfsapi.ZFSNode
+		health   string
+		abnormal bool
+		message  string
+	}{
+		"healthy volume":      {state: zfs.ZFSStatusReady, node: node, health: zfs.PoolHealthOnline, message: zfs.VolumeHealthy},
+		"unknown pool health": {state: zfs.ZFSStatusReady, node: node, message: zfs.VolumeHealthy},
+		"degraded pool":       {state: zfs.ZFSStatusReady, node: node, health: "DEGRADED", abnormal: true, message: "pool pool is DEGRADED"},
+		"child dataset":       {poolName: "pool/child", state: zfs.ZFSStatusReady, node: node, health: zfs.PoolHealthOnline, message: zfs.VolumeHealthy},
+		"degraded root pool":  {poolName: "pool/child", state: zfs.ZFSStatusReady, node: node, health: "DEGRADED", abnormal: true, message: "pool pool is DEGRADED"},
+		"missing node":        {state: zfs.ZFSStatusReady, abnormal: true, message: "zfs node node-1 is not present"},
+		"pending volume":      {state: zfs.ZFSStatusPending, node: node, abnormal: true, message: `volume is not ready, state "Pending"`},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			vol.Spec.PoolName = "pool"
+			if test.poolName != "" {
+				vol.Spec.PoolName = test.poolName
+			}
+			vol.Status.State = test.state
+			node.Pools[0].Health = test.health
+			cond := volumeCondition(vol, test.node)
+			assert.Equal(t, test.abnormal, cond.Abnormal)
+			assert.Equal(t, test.message, cond.Message)
+		})
+	}
+}
@@ -32,6 +32,7 @@ type CSIDriver struct {
 	ids    csi.IdentityServer
 	ns     csi.NodeServer
 	cs     csi.ControllerServer
+	gcs    csi.GroupControllerServer
 
 	cap []*csi.VolumeCapability_AccessMode
 }
@@ -65,6 +66,7 @@ func New(config *config.Config) *CSIDriver {
 	switch config.PluginType {
 	case "controller":
 		driver.cs = NewController(driver)
+		driver.gcs = NewGroupController(driver)
 
 	case "agent":
 		// Start monitor goroutine to monitor the
@@ -87,7 +89,7 @@ func New(config *config.Config) *CSIDriver {
 // over the given endpoint
 func (d *CSIDriver) Run() error {
 	// Initialize and start listening on grpc server
-	s := NewNonBlockingGRPCServer(d.config.Endpoint, d.ids, d.cs, d.ns)
+	s := NewNonBlockingGRPCServer(d.config.Endpoint, d.ids, d.cs, d.gcs, d.ns)
 
 	s.Start()
 	s.Wait()
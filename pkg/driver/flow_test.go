@@ -0,0 +1,209 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	clientset "github.com/openebs/zfs-localpv/pkg/generated/clientset/internalclientset"
+	"github.com/openebs/zfs-localpv/pkg/zfs"
+	"github.com/openebs/zfs-localpv/pkg/zfs/zfstest"
+)
+
+const testNode = "node-1"
+
+// startFakeNode points the driver at the API server and the zfs pool of
+// the node, it returns the in-memory zfs of the node and its clientset
+func startFakeNode(t *testing.T) (*zfstest.ZFS, clientset.Interface) {
+	fake := zfstest.NewZFS()
+	fake.AddPool("pool", 10*Gi)
+	old := zfs.SetCommandRunner(fake)
+	t.Cleanup(func() { zfs.SetCommandRunner(old) })
+
+	oldNode, oldNamespace := zfs.NodeID, zfs.OpenEBSNamespace
+	zfs.NodeID, zfs.OpenEBSNamespace = testNode, "openebs"
+	t.Cleanup(func() { zfs.NodeID, zfs.OpenEBSNamespace = oldNode, oldNamespace })
+
+	server := zfstest.NewAPIServer(t)
+	server.AddNode(testNode, nil)
+	cs := server.Clientset()
+
+	// the node agent provisions and destroys the volumes and the
+	// snapshots the controller asks for, as the mgmt controllers do
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	t.Cleanup(func() { cancel(); <-done })
+	go func() {
+		defer close(done)
+		for ctx.Err() == nil {
+			syncFakeNode(cs)
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	return fake, cs
+}
+
+// syncFakeNode converges the volumes and the snapshots of the node once
+func syncFakeNode(cs clientset.Interface) {
+	vols, err := cs.ZfsV1().ZFSVolumes(zfs.OpenEBSNamespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return
+	}
+	for i := range vols.Items {
+		vol := &vols.Items[i]
+		switch {
+		case vol.DeletionTimestamp != nil:
+			if zfs.DestroyVolume(vol) == nil {
+				_ = zfs.RemoveVolumeFinalizer(vol)
+			}
+		case vol.Status.State == zfs.ZFSStatusPending:
+			create := zfs.CreateVolume
+			if len(vol.Spec.SnapName) > 0 {
+				create = zfs.CreateClone
+			}
+			if create(vol) == nil {
+				_ = zfs.UpdateZvolInfo(vol, zfs.ZFSStatusReady)
+			} else {
+				_ = zfs.UpdateZvolInfo(vol, zfs.ZFSStatusFailed)
+			}
+		}
+	}
+
+	snaps, err := cs.ZfsV1().ZFSSnapshots(zfs.OpenEBSNamespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return
+	}
+	for i := range snaps.Items {
+		snap := &snaps.Items[i]
+		switch {
+		case snap.DeletionTimestamp != nil:
+			if zfs.DestroySnapshot(snap) == nil {
+				_ = zfs.RemoveSnapFinalizer(snap)
+			}
+		case snap.Status.State != zfs.ZFSStatusReady:
+			if zfs.CreateSnapshot(snap) == nil {
+				_ = zfs.UpdateSnapInfo(snap)
+			}
+		}
+	}
+}
+
+func createVolumeRequest(name string, size int64, source *csi.VolumeContentSource) *csi.CreateVolumeRequest {
+	return &csi.CreateVolumeRequest{
+		Name:          name,
+		CapacityRange: &csi.CapacityRange{RequiredBytes: size},
+		VolumeCapabilities: []*csi.VolumeCapability{{
+			AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+			AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+		}},
+		Parameters:          map[string]string{"poolname": "pool", "fstype": "zfs", "node": testNode},
+		VolumeContentSource: source,
+	}
+}
+
+func TestVolumeFlow(t *testing.T) {
+	fake, cs := startFakeNode(t)
+	ctrl := &controller{capabilities: newControllerCapabilities()}
+	ns := &node{}
+	ctx := context.Background()
+
+	// provision
+	resp, err := ctrl.CreateVolume(ctx, createVolumeRequest("pvc-1", Gi, nil))
+	require.NoError(t, err)
+	assert.Equal(t, int64(Gi), resp.Volume.CapacityBytes)
+	assert.Equal(t, map[string]string{zfs.ZFSTopologyKey: testNode}, resp.Volume.AccessibleTopology[0].Segments)
+	_, ok := fake.Dataset("pool/pvc-1")
+	assert.True(t, ok, "volume pvc-1 is not present")
+
+	// resize
+	expand, err := ctrl.ControllerExpandVolume(ctx, &csi.ControllerExpandVolumeRequest{
+		VolumeId:      "pvc-1",
+		CapacityRange: &csi.CapacityRange{RequiredBytes: 2 * Gi},
+	})
+	require.NoError(t, err)
+	assert.True(t, expand.NodeExpansionRequired)
+	vol, err := zfs.GetZFSVolume("pvc-1")
+	require.NoError(t, err)
+	assert.Equal(t, "2147483648", vol.Spec.Capacity)
+
+	// the path which is not a directory is not resized as a filesystem
+	path := filepath.Join(t.TempDir(), "pvc-1")
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+	_, err = ns.NodeExpandVolume(ctx, &csi.NodeExpandVolumeRequest{
+		VolumeId:      "pvc-1",
+		VolumePath:    path,
+		CapacityRange: &csi.CapacityRange{RequiredBytes: 2 * Gi},
+	})
+	require.NoError(t, err)
+	quota, err := zfs.GetVolumeProperty(vol, "quota")
+	require.NoError(t, err)
+	assert.Equal(t, "2147483648", quota)
+
+	// clone of the volume
+	_, err = ctrl.CreateVolume(ctx, createVolumeRequest("pvc-2", 2*Gi, &csi.VolumeContentSource{
+		Type: &csi.VolumeContentSource_Volume{Volume: &csi.VolumeContentSource_VolumeSource{VolumeId: "pvc-1"}},
+	}))
+	require.NoError(t, err)
+	clone, ok := fake.Dataset("pool/pvc-2")
+	assert.True(t, ok, "clone pvc-2 is not present")
+	assert.Equal(t, "pool/pvc-1@pvc-2", clone.Origin)
+
+	// clone of the snapshot
+	snap, err := ctrl.CreateSnapshot(ctx, &csi.CreateSnapshotRequest{
+		Name:           "snap-1",
+		SourceVolumeId: "pvc-1",
+		Parameters:     map[string]string{"wait": "true"},
+	})
+	require.NoError(t, err)
+	assert.True(t, snap.Snapshot.ReadyToUse)
+	_, err = ctrl.CreateVolume(ctx, createVolumeRequest("pvc-3", 2*Gi, &csi.VolumeContentSource{
+		Type: &csi.VolumeContentSource_Snapshot{Snapshot: &csi.VolumeContentSource_SnapshotSource{SnapshotId: snap.Snapshot.SnapshotId}},
+	}))
+	require.NoError(t, err)
+	clone, ok = fake.Dataset("pool/pvc-3")
+	assert.True(t, ok, "clone pvc-3 is not present")
+	assert.Equal(t, "pool/pvc-1@snap-1", clone.Origin)
+
+	// delete
+	for _, name := range []string{"pvc-3", "pvc-2"} {
+		_, err = ctrl.DeleteVolume(ctx, &csi.DeleteVolumeRequest{VolumeId: name})
+		require.NoError(t, err)
+		require.NoError(t, waitForVolDestroy(name))
+		_, ok = fake.Dataset("pool/" + name)
+		assert.False(t, ok, "deleted volume %s is present", name)
+	}
+	// deleting the deleted volume is not an error
+	_, err = ctrl.DeleteVolume(ctx, &csi.DeleteVolumeRequest{VolumeId: "pvc-2"})
+	assert.NoError(t, err)
+
+	// the volume the node could not provision is not left behind
+	req := createVolumeRequest("pvc-4", Gi, nil)
+	req.Parameters["poolname"] = "missing"
+	_, err = ctrl.CreateVolume(ctx, req)
+	assert.Error(t, err)
+	_, err = cs.ZfsV1().ZFSVolumes(zfs.OpenEBSNamespace).Get(ctx, "pvc-4", metav1.GetOptions{})
+	assert.Error(t, err, "volume pvc-4 failed to provision is present")
+}
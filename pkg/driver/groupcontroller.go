@@ -0,0 +1,354 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	timestamp "google.golang.org/protobuf/types/known/timestamppb"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+
+	"github.com/openebs/lib-csi/pkg/common/helpers"
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	"github.com/openebs/zfs-localpv/pkg/builder/snapgroupbuilder"
+	"github.com/openebs/zfs-localpv/pkg/zfs"
+)
+
+// groupController is the server implementation
+// for CSI GroupController
+type groupController struct {
+	csi.UnimplementedGroupControllerServer
+
+	driver       *CSIDriver
+	capabilities []*csi.GroupControllerServiceCapability
+}
+
+// NewGroupController returns a new instance
+// of CSI group controller
+func NewGroupController(d *CSIDriver) csi.GroupControllerServer {
+	return &groupController{
+		driver:       d,
+		capabilities: newGroupControllerCapabilities(),
+	}
+}
+
+// newGroupControllerCapabilities returns a list
+// of this group controller's capabilities
+func newGroupControllerCapabilities() []*csi.GroupControllerServiceCapability {
+	fromType := func(
+		cap csi.GroupControllerServiceCapability_RPC_Type,
+	) *csi.GroupControllerServiceCapability {
+		return &csi.GroupControllerServiceCapability{
+			Type: &csi.GroupControllerServiceCapability_Rpc{
+				Rpc: &csi.GroupControllerServiceCapability_RPC{
+					Type: cap,
+				},
+			},
+		}
+	}
+
+	var capabilities []*csi.GroupControllerServiceCapability
+	for _, cap := range []csi.GroupControllerServiceCapability_RPC_Type{
+		csi.GroupControllerServiceCapability_RPC_CREATE_DELETE_GET_VOLUME_GROUP_SNAPSHOT,
+	} {
+		capabilities = append(capabilities, fromType(cap))
+	}
+	return capabilities
+}
+
+// GroupControllerGetCapabilities fetches group controller capabilities
+//
+// This implements csi.GroupControllerServer
+func (gc *groupController) GroupControllerGetCapabilities(
+	ctx context.Context,
+	req *csi.GroupControllerGetCapabilitiesRequest,
+) (*csi.GroupControllerGetCapabilitiesResponse, error) {
+
+	resp := &csi.GroupControllerGetCapabilitiesResponse{
+		Capabilities: gc.capabilities,
+	}
+
+	return resp, nil
+}
+
+// verifyGroupSnapshotVolumes checks that all the volumes of the group
+// snapshot are present on the same node and in the same pool, as the zfs
+// snapshot command takes the snapshots atomically within a pool only
+func verifyGroupSnapshotVolumes(grpName string, vols []*apis.ZFSVolume) error {
+	for _, vol := range vols[1:] {
+		if vol.Spec.OwnerNodeID != vols[0].Spec.OwnerNodeID || vol.Spec.PoolName != vols[0].Spec.PoolName {
+			return status.Errorf(
+				codes.InvalidArgument,
+				"CreateVolumeGroupSnapshot error group %s: volume %s is on %s/%s while volume %s is on %s/%s",
+				grpName, vol.Name, vol.Spec.OwnerNodeID, vol.Spec.PoolName,
+				vols[0].Name, vols[0].Spec.OwnerNodeID, vols[0].Spec.PoolName,
+			)
+		}
+	}
+	return nil
+}
+
+// sameGroupVolumes returns true if the group snapshot has been
+// requested for the same volumes as the existing one
+func sameGroupVolumes(grp *apis.ZFSSnapshotGroup, volumeIDs []string) bool {
+	if len(grp.Spec.VolumeNames) != len(volumeIDs) {
+		return false
+	}
+	have := append([]string{}, grp.Spec.VolumeNames...)
+	want := append([]string{}, volumeIDs...)
+	sort.Strings(have)
+	sort.Strings(want)
+	for i := range have {
+		if have[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// CreateVolumeGroupSnapshot takes the snapshots of the given volumes at
+// the same instant
+//
+// This implements csi.GroupControllerServer
+func (gc *groupController) CreateVolumeGroupSnapshot(
+	ctx context.Context,
+	req *csi.CreateVolumeGroupSnapshotRequest,
+) (*csi.CreateVolumeGroupSnapshotResponse, error) {
+	grpName := strings.ToLower(req.GetName())
+	var volumeIDs []string
+	for _, id := range req.GetSourceVolumeIds() {
+		volumeIDs = append(volumeIDs, strings.ToLower(id))
+	}
+	klog.Infof("CreateVolumeGroupSnapshot group %s volumes %v", grpName, volumeIDs)
+
+	if grpName == "" || len(volumeIDs) == 0 {
+		return nil, status.Errorf(
+			codes.InvalidArgument,
+			"CreateVolumeGroupSnapshot error invalid request %s: %v",
+			grpName, volumeIDs,
+		)
+	}
+
+	grp, err := zfs.GetZFSSnapshotGroup(grpName)
+	if err == nil {
+		if !sameGroupVolumes(grp, volumeIDs) {
+			return nil, status.Errorf(
+				codes.AlreadyExists,
+				"CreateVolumeGroupSnapshot error group %s already exist for different volumes %v: %v",
+				grpName, grp.Spec.VolumeNames, volumeIDs,
+			)
+		}
+	} else if k8serror.IsNotFound(err) {
+		grp, err = provisionSnapshotGroup(grpName, volumeIDs)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, status.Errorf(
+			codes.Internal,
+			"CreateVolumeGroupSnapshot error group %s get failed : %s",
+			grpName, err.Error(),
+		)
+	}
+
+	originalParams := req.GetParameters()
+	parameters := helpers.GetCaseInsensitiveMap(&originalParams)
+	if _, ok := parameters["wait"]; ok {
+		if grp, err = waitForReadySnapshotGroup(ctx, grpName); err != nil {
+			return nil, err
+		}
+	}
+	if grp.Status.State == zfs.ZFSStatusFailed {
+		return nil, status.Errorf(
+			codes.Internal,
+			"CreateVolumeGroupSnapshot group %s failed : %s",
+			grpName, grp.Status.Error,
+		)
+	}
+
+	return &csi.CreateVolumeGroupSnapshotResponse{
+		GroupSnapshot: newVolumeGroupSnapshot(grp),
+	}, nil
+}
+
+// provisionSnapshotGroup creates the ZFSSnapshotGroup for the volumes,
+// the node agent owning them takes their snapshots
+func provisionSnapshotGroup(grpName string, volumeIDs []string) (*apis.ZFSSnapshotGroup, error) {
+	var vols []*apis.ZFSVolume
+	for _, id := range volumeIDs {
+		vol, err := zfs.GetZFSVolume(id)
+		if err != nil {
+			return nil, status.Errorf(
+				codes.NotFound,
+				"CreateVolumeGroupSnapshot not able to get volume %s: %s, {%s}",
+				id, grpName, err.Error(),
+			)
+		}
+		vols = append(vols, vol)
+	}
+	if err := verifyGroupSnapshotVolumes(grpName, vols); err != nil {
+		return nil, err
+	}
+
+	grp, err := snapgroupbuilder.NewBuilder().
+		WithName(grpName).
+		WithOwnerNodeID(vols[0].Spec.OwnerNodeID).
+		WithPoolName(vols[0].Spec.PoolName).
+		WithVolumeNames(volumeIDs).
+		WithFinalizer([]string{zfs.ZFSFinalizer}).
+		WithState(zfs.ZFSStatusPending).Build()
+	if err != nil {
+		return nil, status.Errorf(
+			codes.Internal,
+			"failed to create snapshot group object for %s: %s",
+			grpName, err.Error(),
+		)
+	}
+	if err := zfs.ProvisionSnapshotGroup(grp); err != nil {
+		return nil, status.Errorf(
+			codes.Internal,
+			"failed to handle CreateVolumeGroupSnapshot for %s, {%s}",
+			grpName, err.Error(),
+		)
+	}
+	return grp, nil
+}
+
+// waitForReadySnapshotGroup waits for the snapshots of the group to be
+// taken, it returns once the group is Ready or Failed. The group which is
+// not ready yet is returned once ctx is done, the caller polls it again.
+func waitForReadySnapshotGroup(ctx context.Context, grpName string) (*apis.ZFSSnapshotGroup, error) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		grp, err := zfs.GetZFSSnapshotGroup(grpName)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal,
+				"zfs: wait failed, not able to get the snapshot group %s %s", grpName, err.Error())
+		}
+
+		switch grp.Status.State {
+		case zfs.ZFSStatusReady, zfs.ZFSStatusFailed:
+			return grp, nil
+		}
+		select {
+		case <-ctx.Done():
+			klog.Infof("snapshot group %s is not ready yet, err: %v", grpName, ctx.Err())
+			return grp, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// newVolumeGroupSnapshot returns the csi group snapshot of the group, the
+// snapshot of each volume is ready once its ZFSSnapshot is Ready
+func newVolumeGroupSnapshot(grp *apis.ZFSSnapshotGroup) *csi.VolumeGroupSnapshot {
+	creationTime := grp.CreationTimestamp.Time
+	if grp.Status.CreationTime != nil {
+		creationTime = grp.Status.CreationTime.Time
+	}
+	ready := grp.Status.State == zfs.ZFSStatusReady
+
+	var snapshots []*csi.Snapshot
+	for _, volName := range grp.Spec.VolumeNames {
+		snapName := zfs.GroupSnapshotName(grp.Name, volName)
+		snapshot := &csi.Snapshot{
+			SnapshotId:      volName + "@" + snapName,
+			SourceVolumeId:  volName,
+			CreationTime:    timestamp.New(creationTime),
+			GroupSnapshotId: grp.Name,
+		}
+		if snap, err := zfs.GetZFSSnapshot(snapName); err == nil {
+			snapshot.ReadyToUse = snap.Status.State == zfs.ZFSStatusReady
+			snapshot.SizeBytes, _ = zfs.GetZFSSnapshotCapacity(snap)
+		}
+		ready = ready && snapshot.ReadyToUse
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return &csi.VolumeGroupSnapshot{
+		GroupSnapshotId: grp.Name,
+		Snapshots:       snapshots,
+		CreationTime:    timestamp.New(creationTime),
+		ReadyToUse:      ready,
+	}
+}
+
+// DeleteVolumeGroupSnapshot deletes the given group snapshot along
+// with the snapshots of its volumes
+//
+// This implements csi.GroupControllerServer
+func (gc *groupController) DeleteVolumeGroupSnapshot(
+	ctx context.Context,
+	req *csi.DeleteVolumeGroupSnapshotRequest,
+) (*csi.DeleteVolumeGroupSnapshotResponse, error) {
+
+	if req.GroupSnapshotId == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "DeleteVolumeGroupSnapshot: empty groupSnapshotID")
+	}
+
+	klog.Infof("DeleteVolumeGroupSnapshot request for %s", req.GroupSnapshotId)
+
+	// the node agent deletes the ZFSSnapshots of the group before
+	// the ZFSSnapshotGroup is gone
+	if err := zfs.DeleteSnapshotGroup(req.GroupSnapshotId); err != nil && !k8serror.IsNotFound(err) {
+		return nil, status.Errorf(
+			codes.Internal,
+			"failed to handle DeleteVolumeGroupSnapshot for %s, {%s}",
+			req.GroupSnapshotId,
+			err.Error(),
+		)
+	}
+	return &csi.DeleteVolumeGroupSnapshotResponse{}, nil
+}
+
+// GetVolumeGroupSnapshot returns the given group snapshot
+//
+// This implements csi.GroupControllerServer
+func (gc *groupController) GetVolumeGroupSnapshot(
+	ctx context.Context,
+	req *csi.GetVolumeGroupSnapshotRequest,
+) (*csi.GetVolumeGroupSnapshotResponse, error) {
+
+	if req.GroupSnapshotId == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "GetVolumeGroupSnapshot: empty groupSnapshotID")
+	}
+
+	grp, err := zfs.GetZFSSnapshotGroup(req.GroupSnapshotId)
+	if k8serror.IsNotFound(err) {
+		return nil, status.Errorf(codes.NotFound, "GetVolumeGroupSnapshot: group %s not found", req.GroupSnapshotId)
+	}
+	if err != nil {
+		return nil, status.Errorf(
+			codes.Internal,
+			"failed to handle GetVolumeGroupSnapshot for %s, {%s}",
+			req.GroupSnapshotId,
+			err.Error(),
+		)
+	}
+	return &csi.GetVolumeGroupSnapshotResponse{
+		GroupSnapshot: newVolumeGroupSnapshot(grp),
+	}, nil
+}
@@ -0,0 +1,75 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+)
+
+func TestVerifyGroupSnapshotVolumes(t *testing.T) {
+	newVol := func(name, node, pool string) *apis.ZFSVolume {
+		vol := &apis.ZFSVolume{}
+		vol.Name = name
+		vol.Spec.OwnerNodeID = node
+		vol.Spec.PoolName = pool
+		return vol
+	}
+
+	tests := map[string]struct {
+		vols []*apis.ZFSVolume
+		code codes.Code
+	}{
+		"single volume": {
+			vols: []*apis.ZFSVolume{newVol("pvc-1", "node-1", "pool")},
+			code: codes.OK,
+		},
+		"same node and pool": {
+			vols: []*apis.ZFSVolume{newVol("pvc-1", "node-1", "pool"), newVol("pvc-2", "node-1", "pool")},
+			code: codes.OK,
+		},
+		"different pool": {
+			vols: []*apis.ZFSVolume{newVol("pvc-1", "node-1", "pool"), newVol("pvc-2", "node-1", "other")},
+			code: codes.InvalidArgument,
+		},
+		"different node": {
+			vols: []*apis.ZFSVolume{newVol("pvc-1", "node-1", "pool"), newVol("pvc-2", "node-2", "pool")},
+			code: codes.InvalidArgument,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := verifyGroupSnapshotVolumes("grp-1", test.vols)
+			assert.Equal(t, test.code, status.Code(err))
+		})
+	}
+}
+
+func TestSameGroupVolumes(t *testing.T) {
+	grp := &apis.ZFSSnapshotGroup{}
+	grp.Spec.VolumeNames = []string{"pvc-data", "pvc-wal"}
+
+	assert.True(t, sameGroupVolumes(grp, []string{"pvc-wal", "pvc-data"}))
+	assert.False(t, sameGroupVolumes(grp, []string{"pvc-data"}))
+	assert.False(t, sameGroupVolumes(grp, []string{"pvc-data", "pvc-log"}))
+}
@@ -44,6 +44,14 @@ func (c *FakeZfsV1) ZFSSnapshots(namespace string) v1.ZFSSnapshotInterface {
 	return &FakeZFSSnapshots{c, namespace}
 }
 
+func (c *FakeZfsV1) ZFSSnapshotGroups(namespace string) v1.ZFSSnapshotGroupInterface {
+	return &FakeZFSSnapshotGroups{c, namespace}
+}
+
+func (c *FakeZfsV1) ZFSSnapshotSchedules(namespace string) v1.ZFSSnapshotScheduleInterface {
+	return &FakeZFSSnapshotSchedules{c, namespace}
+}
+
 func (c *FakeZfsV1) ZFSVolumes(namespace string) v1.ZFSVolumeInterface {
 	return &FakeZFSVolumes{c, namespace}
 }
@@ -0,0 +1,141 @@
+/*
+Copyright 2019 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+
+	v1 "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeZFSSnapshotGroups implements ZFSSnapshotGroupInterface
+type FakeZFSSnapshotGroups struct {
+	Fake *FakeZfsV1
+	ns   string
+}
+
+var zfssnapshotgroupsResource = v1.SchemeGroupVersion.WithResource("zfssnapshotgroups")
+
+var zfssnapshotgroupsKind = v1.SchemeGroupVersion.WithKind("ZFSSnapshotGroup")
+
+// Get takes name of the zFSSnapshotGroup, and returns the corresponding zFSSnapshotGroup object, and an error if there is any.
+func (c *FakeZFSSnapshotGroups) Get(ctx context.Context, name string, options metav1.GetOptions) (result *v1.ZFSSnapshotGroup, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewGetAction(zfssnapshotgroupsResource, c.ns, name), &v1.ZFSSnapshotGroup{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1.ZFSSnapshotGroup), err
+}
+
+// List takes label and field selectors, and returns the list of ZFSSnapshotGroups that match those selectors.
+func (c *FakeZFSSnapshotGroups) List(ctx context.Context, opts metav1.ListOptions) (result *v1.ZFSSnapshotGroupList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewListAction(zfssnapshotgroupsResource, zfssnapshotgroupsKind, c.ns, opts), &v1.ZFSSnapshotGroupList{})
+
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1.ZFSSnapshotGroupList{ListMeta: obj.(*v1.ZFSSnapshotGroupList).ListMeta}
+	for _, item := range obj.(*v1.ZFSSnapshotGroupList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested zFSSnapshotGroups.
+func (c *FakeZFSSnapshotGroups) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewWatchAction(zfssnapshotgroupsResource, c.ns, opts))
+
+}
+
+// Create takes the representation of a zFSSnapshotGroup and creates it.  Returns the server's representation of the zFSSnapshotGroup, and an error, if there is any.
+func (c *FakeZFSSnapshotGroups) Create(ctx context.Context, zFSSnapshotGroup *v1.ZFSSnapshotGroup, opts metav1.CreateOptions) (result *v1.ZFSSnapshotGroup, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewCreateAction(zfssnapshotgroupsResource, c.ns, zFSSnapshotGroup), &v1.ZFSSnapshotGroup{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1.ZFSSnapshotGroup), err
+}
+
+// Update takes the representation of a zFSSnapshotGroup and updates it. Returns the server's representation of the zFSSnapshotGroup, and an error, if there is any.
+func (c *FakeZFSSnapshotGroups) Update(ctx context.Context, zFSSnapshotGroup *v1.ZFSSnapshotGroup, opts metav1.UpdateOptions) (result *v1.ZFSSnapshotGroup, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateAction(zfssnapshotgroupsResource, c.ns, zFSSnapshotGroup), &v1.ZFSSnapshotGroup{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1.ZFSSnapshotGroup), err
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeZFSSnapshotGroups) UpdateStatus(ctx context.Context, zFSSnapshotGroup *v1.ZFSSnapshotGroup, opts metav1.UpdateOptions) (*v1.ZFSSnapshotGroup, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(zfssnapshotgroupsResource, "status", c.ns, zFSSnapshotGroup), &v1.ZFSSnapshotGroup{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1.ZFSSnapshotGroup), err
+}
+
+// Delete takes name of the zFSSnapshotGroup and deletes it. Returns an error if one occurs.
+func (c *FakeZFSSnapshotGroups) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewDeleteActionWithOptions(zfssnapshotgroupsResource, c.ns, name, opts), &v1.ZFSSnapshotGroup{})
+
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeZFSSnapshotGroups) DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error {
+	action := testing.NewDeleteCollectionAction(zfssnapshotgroupsResource, c.ns, listOpts)
+
+	_, err := c.Fake.Invokes(action, &v1.ZFSSnapshotGroupList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched zFSSnapshotGroup.
+func (c *FakeZFSSnapshotGroups) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (result *v1.ZFSSnapshotGroup, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceAction(zfssnapshotgroupsResource, c.ns, name, pt, data, subresources...), &v1.ZFSSnapshotGroup{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1.ZFSSnapshotGroup), err
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2019 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+
+	v1 "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeZFSSnapshotSchedules implements ZFSSnapshotScheduleInterface
+type FakeZFSSnapshotSchedules struct {
+	Fake *FakeZfsV1
+	ns   string
+}
+
+var zfssnapshotschedulesResource = v1.SchemeGroupVersion.WithResource("zfssnapshotschedules")
+
+var zfssnapshotschedulesKind = v1.SchemeGroupVersion.WithKind("ZFSSnapshotSchedule")
+
+// Get takes name of the zFSSnapshotSchedule, and returns the corresponding zFSSnapshotSchedule object, and an error if there is any.
+func (c *FakeZFSSnapshotSchedules) Get(ctx context.Context, name string, options metav1.GetOptions) (result *v1.ZFSSnapshotSchedule, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewGetAction(zfssnapshotschedulesResource, c.ns, name), &v1.ZFSSnapshotSchedule{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1.ZFSSnapshotSchedule), err
+}
+
+// List takes label and field selectors, and returns the list of ZFSSnapshotSchedules that match those selectors.
+func (c *FakeZFSSnapshotSchedules) List(ctx context.Context, opts metav1.ListOptions) (result *v1.ZFSSnapshotScheduleList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewListAction(zfssnapshotschedulesResource, zfssnapshotschedulesKind, c.ns, opts), &v1.ZFSSnapshotScheduleList{})
+
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1.ZFSSnapshotScheduleList{ListMeta: obj.(*v1.ZFSSnapshotScheduleList).ListMeta}
+	for _, item := range obj.(*v1.ZFSSnapshotScheduleList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested zFSSnapshotSchedules.
+func (c *FakeZFSSnapshotSchedules) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewWatchAction(zfssnapshotschedulesResource, c.ns, opts))
+
+}
+
+// Create takes the representation of a zFSSnapshotSchedule and creates it.  Returns the server's representation of the zFSSnapshotSchedule, and an error, if there is any.
+func (c *FakeZFSSnapshotSchedules) Create(ctx context.Context, zFSSnapshotSchedule *v1.ZFSSnapshotSchedule, opts metav1.CreateOptions) (result *v1.ZFSSnapshotSchedule, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewCreateAction(zfssnapshotschedulesResource, c.ns, zFSSnapshotSchedule), &v1.ZFSSnapshotSchedule{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1.ZFSSnapshotSchedule), err
+}
+
+// Update takes the representation of a zFSSnapshotSchedule and updates it. Returns the server's representation of the zFSSnapshotSchedule, and an error, if there is any.
+func (c *FakeZFSSnapshotSchedules) Update(ctx context.Context, zFSSnapshotSchedule *v1.ZFSSnapshotSchedule, opts metav1.UpdateOptions) (result *v1.ZFSSnapshotSchedule, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateAction(zfssnapshotschedulesResource, c.ns, zFSSnapshotSchedule), &v1.ZFSSnapshotSchedule{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1.ZFSSnapshotSchedule), err
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeZFSSnapshotSchedules) UpdateStatus(ctx context.Context, zFSSnapshotSchedule *v1.ZFSSnapshotSchedule, opts metav1.UpdateOptions) (*v1.ZFSSnapshotSchedule, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(zfssnapshotschedulesResource, "status", c.ns, zFSSnapshotSchedule), &v1.ZFSSnapshotSchedule{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1.ZFSSnapshotSchedule), err
+}
+
+// Delete takes name of the zFSSnapshotSchedule and deletes it. Returns an error if one occurs.
+func (c *FakeZFSSnapshotSchedules) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewDeleteActionWithOptions(zfssnapshotschedulesResource, c.ns, name, opts), &v1.ZFSSnapshotSchedule{})
+
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeZFSSnapshotSchedules) DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error {
+	action := testing.NewDeleteCollectionAction(zfssnapshotschedulesResource, c.ns, listOpts)
+
+	_, err := c.Fake.Invokes(action, &v1.ZFSSnapshotScheduleList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched zFSSnapshotSchedule.
+func (c *FakeZFSSnapshotSchedules) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (result *v1.ZFSSnapshotSchedule, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceAction(zfssnapshotschedulesResource, c.ns, name, pt, data, subresources...), &v1.ZFSSnapshotSchedule{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1.ZFSSnapshotSchedule), err
+}
@@ -26,4 +26,8 @@ type ZFSRestoreExpansion interface{}
 
 type ZFSSnapshotExpansion interface{}
 
+type ZFSSnapshotGroupExpansion interface{}
+
+type ZFSSnapshotScheduleExpansion interface{}
+
 type ZFSVolumeExpansion interface{}
@@ -32,6 +32,8 @@ type ZfsV1Interface interface {
 	ZFSNodesGetter
 	ZFSRestoresGetter
 	ZFSSnapshotsGetter
+	ZFSSnapshotGroupsGetter
+	ZFSSnapshotSchedulesGetter
 	ZFSVolumesGetter
 }
 
@@ -56,6 +58,14 @@ func (c *ZfsV1Client) ZFSSnapshots(namespace string) ZFSSnapshotInterface {
 	return newZFSSnapshots(c, namespace)
 }
 
+func (c *ZfsV1Client) ZFSSnapshotGroups(namespace string) ZFSSnapshotGroupInterface {
+	return newZFSSnapshotGroups(c, namespace)
+}
+
+func (c *ZfsV1Client) ZFSSnapshotSchedules(namespace string) ZFSSnapshotScheduleInterface {
+	return newZFSSnapshotSchedules(c, namespace)
+}
+
 func (c *ZfsV1Client) ZFSVolumes(namespace string) ZFSVolumeInterface {
 	return newZFSVolumes(c, namespace)
 }
@@ -0,0 +1,195 @@
+/*
+Copyright 2019 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	"context"
+	"time"
+
+	v1 "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	scheme "github.com/openebs/zfs-localpv/pkg/generated/clientset/internalclientset/scheme"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// ZFSSnapshotGroupsGetter has a method to return a ZFSSnapshotGroupInterface.
+// A group's client should implement this interface.
+type ZFSSnapshotGroupsGetter interface {
+	ZFSSnapshotGroups(namespace string) ZFSSnapshotGroupInterface
+}
+
+// ZFSSnapshotGroupInterface has methods to work with ZFSSnapshotGroup resources.
+type ZFSSnapshotGroupInterface interface {
+	Create(ctx context.Context, zFSSnapshotGroup *v1.ZFSSnapshotGroup, opts metav1.CreateOptions) (*v1.ZFSSnapshotGroup, error)
+	Update(ctx context.Context, zFSSnapshotGroup *v1.ZFSSnapshotGroup, opts metav1.UpdateOptions) (*v1.ZFSSnapshotGroup, error)
+	UpdateStatus(ctx context.Context, zFSSnapshotGroup *v1.ZFSSnapshotGroup, opts metav1.UpdateOptions) (*v1.ZFSSnapshotGroup, error)
+	Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (*v1.ZFSSnapshotGroup, error)
+	List(ctx context.Context, opts metav1.ListOptions) (*v1.ZFSSnapshotGroupList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (result *v1.ZFSSnapshotGroup, err error)
+	ZFSSnapshotGroupExpansion
+}
+
+// zFSSnapshotGroups implements ZFSSnapshotGroupInterface
+type zFSSnapshotGroups struct {
+	client rest.Interface
+	ns     string
+}
+
+// newZFSSnapshotGroups returns a ZFSSnapshotGroups
+func newZFSSnapshotGroups(c *ZfsV1Client, namespace string) *zFSSnapshotGroups {
+	return &zFSSnapshotGroups{
+		client: c.RESTClient(),
+		ns:     namespace,
+	}
+}
+
+// Get takes name of the zFSSnapshotGroup, and returns the corresponding zFSSnapshotGroup object, and an error if there is any.
+func (c *zFSSnapshotGroups) Get(ctx context.Context, name string, options metav1.GetOptions) (result *v1.ZFSSnapshotGroup, err error) {
+	result = &v1.ZFSSnapshotGroup{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("zfssnapshotgroups").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of ZFSSnapshotGroups that match those selectors.
+func (c *zFSSnapshotGroups) List(ctx context.Context, opts metav1.ListOptions) (result *v1.ZFSSnapshotGroupList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1.ZFSSnapshotGroupList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("zfssnapshotgroups").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested zFSSnapshotGroups.
+func (c *zFSSnapshotGroups) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("zfssnapshotgroups").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch(ctx)
+}
+
+// Create takes the representation of a zFSSnapshotGroup and creates it.  Returns the server's representation of the zFSSnapshotGroup, and an error, if there is any.
+func (c *zFSSnapshotGroups) Create(ctx context.Context, zFSSnapshotGroup *v1.ZFSSnapshotGroup, opts metav1.CreateOptions) (result *v1.ZFSSnapshotGroup, err error) {
+	result = &v1.ZFSSnapshotGroup{}
+	err = c.client.Post().
+		Namespace(c.ns).
+		Resource("zfssnapshotgroups").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(zFSSnapshotGroup).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Update takes the representation of a zFSSnapshotGroup and updates it. Returns the server's representation of the zFSSnapshotGroup, and an error, if there is any.
+func (c *zFSSnapshotGroups) Update(ctx context.Context, zFSSnapshotGroup *v1.ZFSSnapshotGroup, opts metav1.UpdateOptions) (result *v1.ZFSSnapshotGroup, err error) {
+	result = &v1.ZFSSnapshotGroup{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("zfssnapshotgroups").
+		Name(zFSSnapshotGroup.Name).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(zFSSnapshotGroup).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *zFSSnapshotGroups) UpdateStatus(ctx context.Context, zFSSnapshotGroup *v1.ZFSSnapshotGroup, opts metav1.UpdateOptions) (result *v1.ZFSSnapshotGroup, err error) {
+	result = &v1.ZFSSnapshotGroup{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("zfssnapshotgroups").
+		Name(zFSSnapshotGroup.Name).
+		SubResource("status").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(zFSSnapshotGroup).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Delete takes name of the zFSSnapshotGroup and deletes it. Returns an error if one occurs.
+func (c *zFSSnapshotGroups) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("zfssnapshotgroups").
+		Name(name).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *zFSSnapshotGroups) DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error {
+	var timeout time.Duration
+	if listOpts.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOpts.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("zfssnapshotgroups").
+		VersionedParams(&listOpts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// Patch applies the patch and returns the patched zFSSnapshotGroup.
+func (c *zFSSnapshotGroups) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (result *v1.ZFSSnapshotGroup, err error) {
+	result = &v1.ZFSSnapshotGroup{}
+	err = c.client.Patch(pt).
+		Namespace(c.ns).
+		Resource("zfssnapshotgroups").
+		Name(name).
+		SubResource(subresources...).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(data).
+		Do(ctx).
+		Into(result)
+	return
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2019 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	"context"
+	"time"
+
+	v1 "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	scheme "github.com/openebs/zfs-localpv/pkg/generated/clientset/internalclientset/scheme"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// ZFSSnapshotSchedulesGetter has a method to return a ZFSSnapshotScheduleInterface.
+// A group's client should implement this interface.
+type ZFSSnapshotSchedulesGetter interface {
+	ZFSSnapshotSchedules(namespace string) ZFSSnapshotScheduleInterface
+}
+
+// ZFSSnapshotScheduleInterface has methods to work with ZFSSnapshotSchedule resources.
+type ZFSSnapshotScheduleInterface interface {
+	Create(ctx context.Context, zFSSnapshotSchedule *v1.ZFSSnapshotSchedule, opts metav1.CreateOptions) (*v1.ZFSSnapshotSchedule, error)
+	Update(ctx context.Context, zFSSnapshotSchedule *v1.ZFSSnapshotSchedule, opts metav1.UpdateOptions) (*v1.ZFSSnapshotSchedule, error)
+	UpdateStatus(ctx context.Context, zFSSnapshotSchedule *v1.ZFSSnapshotSchedule, opts metav1.UpdateOptions) (*v1.ZFSSnapshotSchedule, error)
+	Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (*v1.ZFSSnapshotSchedule, error)
+	List(ctx context.Context, opts metav1.ListOptions) (*v1.ZFSSnapshotScheduleList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (result *v1.ZFSSnapshotSchedule, err error)
+	ZFSSnapshotScheduleExpansion
+}
+
+// zFSSnapshotSchedules implements ZFSSnapshotScheduleInterface
+type zFSSnapshotSchedules struct {
+	client rest.Interface
+	ns     string
+}
+
+// newZFSSnapshotSchedules returns a ZFSSnapshotSchedules
+func newZFSSnapshotSchedules(c *ZfsV1Client, namespace string) *zFSSnapshotSchedules {
+	return &zFSSnapshotSchedules{
+		client: c.RESTClient(),
+		ns:     namespace,
+	}
+}
+
+// Get takes name of the zFSSnapshotSchedule, and returns the corresponding zFSSnapshotSchedule object, and an error if there is any.
+func (c *zFSSnapshotSchedules) Get(ctx context.Context, name string, options metav1.GetOptions) (result *v1.ZFSSnapshotSchedule, err error) {
+	result = &v1.ZFSSnapshotSchedule{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("zfssnapshotschedules").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of ZFSSnapshotSchedules that match those selectors.
+func (c *zFSSnapshotSchedules) List(ctx context.Context, opts metav1.ListOptions) (result *v1.ZFSSnapshotScheduleList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1.ZFSSnapshotScheduleList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("zfssnapshotschedules").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested zFSSnapshotSchedules.
+func (c *zFSSnapshotSchedules) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("zfssnapshotschedules").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch(ctx)
+}
+
+// Create takes the representation of a zFSSnapshotSchedule and creates it.  Returns the server's representation of the zFSSnapshotSchedule, and an error, if there is any.
+func (c *zFSSnapshotSchedules) Create(ctx context.Context, zFSSnapshotSchedule *v1.ZFSSnapshotSchedule, opts metav1.CreateOptions) (result *v1.ZFSSnapshotSchedule, err error) {
+	result = &v1.ZFSSnapshotSchedule{}
+	err = c.client.Post().
+		Namespace(c.ns).
+		Resource("zfssnapshotschedules").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(zFSSnapshotSchedule).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Update takes the representation of a zFSSnapshotSchedule and updates it. Returns the server's representation of the zFSSnapshotSchedule, and an error, if there is any.
+func (c *zFSSnapshotSchedules) Update(ctx context.Context, zFSSnapshotSchedule *v1.ZFSSnapshotSchedule, opts metav1.UpdateOptions) (result *v1.ZFSSnapshotSchedule, err error) {
+	result = &v1.ZFSSnapshotSchedule{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("zfssnapshotschedules").
+		Name(zFSSnapshotSchedule.Name).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(zFSSnapshotSchedule).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *zFSSnapshotSchedules) UpdateStatus(ctx context.Context, zFSSnapshotSchedule *v1.ZFSSnapshotSchedule, opts metav1.UpdateOptions) (result *v1.ZFSSnapshotSchedule, err error) {
+	result = &v1.ZFSSnapshotSchedule{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("zfssnapshotschedules").
+		Name(zFSSnapshotSchedule.Name).
+		SubResource("status").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(zFSSnapshotSchedule).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Delete takes name of the zFSSnapshotSchedule and deletes it. Returns an error if one occurs.
+func (c *zFSSnapshotSchedules) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("zfssnapshotschedules").
+		Name(name).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *zFSSnapshotSchedules) DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error {
+	var timeout time.Duration
+	if listOpts.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOpts.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("zfssnapshotschedules").
+		VersionedParams(&listOpts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// Patch applies the patch and returns the patched zFSSnapshotSchedule.
+func (c *zFSSnapshotSchedules) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (result *v1.ZFSSnapshotSchedule, err error) {
+	result = &v1.ZFSSnapshotSchedule{}
+	err = c.client.Patch(pt).
+		Namespace(c.ns).
+		Resource("zfssnapshotschedules").
+		Name(name).
+		SubResource(subresources...).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(data).
+		Do(ctx).
+		Into(result)
+	return
+}
@@ -61,6 +61,10 @@ func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Zfs().V1().ZFSRestores().Informer()}, nil
 	case v1.SchemeGroupVersion.WithResource("zfssnapshots"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Zfs().V1().ZFSSnapshots().Informer()}, nil
+	case v1.SchemeGroupVersion.WithResource("zfssnapshotgroups"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Zfs().V1().ZFSSnapshotGroups().Informer()}, nil
+	case v1.SchemeGroupVersion.WithResource("zfssnapshotschedules"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Zfs().V1().ZFSSnapshotSchedules().Informer()}, nil
 	case v1.SchemeGroupVersion.WithResource("zfsvolumes"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Zfs().V1().ZFSVolumes().Informer()}, nil
 
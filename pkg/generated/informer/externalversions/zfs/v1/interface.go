@@ -32,6 +32,10 @@ type Interface interface {
 	ZFSRestores() ZFSRestoreInformer
 	// ZFSSnapshots returns a ZFSSnapshotInformer.
 	ZFSSnapshots() ZFSSnapshotInformer
+	// ZFSSnapshotGroups returns a ZFSSnapshotGroupInformer.
+	ZFSSnapshotGroups() ZFSSnapshotGroupInformer
+	// ZFSSnapshotSchedules returns a ZFSSnapshotScheduleInformer.
+	ZFSSnapshotSchedules() ZFSSnapshotScheduleInformer
 	// ZFSVolumes returns a ZFSVolumeInformer.
 	ZFSVolumes() ZFSVolumeInformer
 }
@@ -67,6 +71,16 @@ func (v *version) ZFSSnapshots() ZFSSnapshotInformer {
 	return &zFSSnapshotInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
 
+// ZFSSnapshotGroups returns a ZFSSnapshotGroupInformer.
+func (v *version) ZFSSnapshotGroups() ZFSSnapshotGroupInformer {
+	return &zFSSnapshotGroupInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// ZFSSnapshotSchedules returns a ZFSSnapshotScheduleInformer.
+func (v *version) ZFSSnapshotSchedules() ZFSSnapshotScheduleInformer {
+	return &zFSSnapshotScheduleInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
 // ZFSVolumes returns a ZFSVolumeInformer.
 func (v *version) ZFSVolumes() ZFSVolumeInformer {
 	return &zFSVolumeInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
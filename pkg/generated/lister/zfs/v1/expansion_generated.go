@@ -50,6 +50,22 @@ type ZFSSnapshotListerExpansion interface{}
 // ZFSSnapshotNamespaceLister.
 type ZFSSnapshotNamespaceListerExpansion interface{}
 
+// ZFSSnapshotGroupListerExpansion allows custom methods to be added to
+// ZFSSnapshotGroupLister.
+type ZFSSnapshotGroupListerExpansion interface{}
+
+// ZFSSnapshotGroupNamespaceListerExpansion allows custom methods to be added to
+// ZFSSnapshotGroupNamespaceLister.
+type ZFSSnapshotGroupNamespaceListerExpansion interface{}
+
+// ZFSSnapshotScheduleListerExpansion allows custom methods to be added to
+// ZFSSnapshotScheduleLister.
+type ZFSSnapshotScheduleListerExpansion interface{}
+
+// ZFSSnapshotScheduleNamespaceListerExpansion allows custom methods to be added to
+// ZFSSnapshotScheduleNamespaceLister.
+type ZFSSnapshotScheduleNamespaceListerExpansion interface{}
+
 // ZFSVolumeListerExpansion allows custom methods to be added to
 // ZFSVolumeLister.
 type ZFSVolumeListerExpansion interface{}
@@ -17,6 +17,7 @@ limitations under the License.
 package backup
 
 import (
+	"errors"
 	"fmt"
 	"k8s.io/klog/v2"
 	"time"
@@ -24,6 +25,7 @@ import (
 	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
 	zfs "github.com/openebs/zfs-localpv/pkg/zfs"
 	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/tools/cache"
@@ -79,25 +81,132 @@ func (c *BkpController) syncBkp(bkp *apis.ZFSBackup) error {
 	if c.isDeletionCandidate(bkp) {
 		// reconcile for the Destroy error
 		err = zfs.DestoryBackup(bkp)
+		var held *zfs.SnapshotHeldError
+		if errors.As(err, &held) {
+			return c.blockBkpDeletion(bkp, held)
+		}
 		if err == nil {
 			err = zfs.RemoveBkpFinalizer(bkp)
 		}
 	} else {
-		// if status is init then it means we are creating the zfs backup.
-		if bkp.Status == apis.BKPZFSStatusInit {
+		// if status is init then it means we are creating the zfs backup,
+		// it is pending if it is waiting for the other transfers to finish.
+		if bkp.Status == apis.BKPZFSStatusInit || bkp.Status == apis.BKPZFSStatusPending {
 			err = zfs.CreateBackup(bkp)
+			if errors.Is(err, zfs.ErrTransferQueued) {
+				return c.queueBkp(bkp)
+			}
+			if zfs.TransferStopped(err) {
+				return c.stopBkp(bkp, err)
+			}
 			if err == nil {
 				klog.Infof("backup %s done %s@%s prevsnap [%s]", bkp.Name, bkp.Spec.VolumeName, bkp.Spec.SnapName, bkp.Spec.PrevSnapName)
+				bkp.Transfer.LastError = ""
 				err = zfs.UpdateBkpInfo(bkp, apis.BKPZFSStatusDone)
+				if err == nil {
+					c.pruneBkpSnapshots(bkp)
+				}
 			} else {
-				klog.Errorf("backup %s failed %s@%s err %v", bkp.Name, bkp.Spec.VolumeName, bkp.Spec.SnapName, err)
-				err = zfs.UpdateBkpInfo(bkp, apis.BKPZFSStatusFailed)
+				bkp.Transfer.LastError = err.Error()
+				if zfs.RetryBackup(bkp) {
+					klog.Warningf("backup %s failed %s@%s, retry %d err %v", bkp.Name,
+						bkp.Spec.VolumeName, bkp.Spec.SnapName, bkp.Transfer.Retries, err)
+					if err = zfs.UpdateBkpInfo(bkp, bkp.Status); err == nil {
+						// requeue the backup to retry the transfer
+						err = fmt.Errorf("backup %s will be retried", bkp.Name)
+					}
+				} else {
+					klog.Errorf("backup %s failed %s@%s err %v", bkp.Name, bkp.Spec.VolumeName, bkp.Spec.SnapName, err)
+					if err := zfs.ReleaseBackup(bkp); err != nil {
+						klog.Errorf("backup %s could not release its snapshots err %v", bkp.Name, err)
+					}
+					err = zfs.UpdateBkpInfo(bkp, apis.BKPZFSStatusFailed)
+				}
 			}
 		}
 	}
 	return err
 }
 
+// stopBkp moves the backup which has been cancelled to Cancelled, or the
+// one which has exceeded its deadline to Failed, with the reason recorded
+// as the last error. It is not retried.
+func (c *BkpController) stopBkp(bkp *apis.ZFSBackup, reason error) error {
+	status := apis.BKPZFSStatusFailed
+	if errors.Is(reason, zfs.ErrTransferCancelled) {
+		status = apis.BKPZFSStatusCancelled
+	}
+	klog.Warningf("backup %s %s %s@%s err %v", bkp.Name, status, bkp.Spec.VolumeName, bkp.Spec.SnapName, reason)
+	if err := zfs.ReleaseBackup(bkp); err != nil {
+		klog.Errorf("backup %s could not release its snapshots err %v", bkp.Name, err)
+	}
+	bkp.Transfer.LastError = reason.Error()
+	return zfs.UpdateBkpInfo(bkp, status)
+}
+
+// queueBkp marks the backup as Pending while the maximum number of transfers
+// are running on the node, and requeues it to be retried after a while
+func (c *BkpController) queueBkp(bkp *apis.ZFSBackup) error {
+	if bkp.Status != apis.BKPZFSStatusPending {
+		klog.Infof("backup %s is pending %s@%s, waiting for the running transfers", bkp.Name,
+			bkp.Spec.VolumeName, bkp.Spec.SnapName)
+		if err := zfs.UpdateBkpInfo(bkp, apis.BKPZFSStatusPending); err != nil {
+			return err
+		}
+	}
+	key, err := cache.MetaNamespaceKeyFunc(bkp)
+	if err != nil {
+		return err
+	}
+	c.workqueue.AddAfter(key, zfs.TransferQueueInterval)
+	return nil
+}
+
+// pruneBkpSnapshots prunes the backup snapshots of the volume of the done
+// backup as per the backup retention of the volume, the failure to prune
+// them does not fail the backup
+func (c *BkpController) pruneBkpSnapshots(bkp *apis.ZFSBackup) {
+	vol, err := zfs.GetZFSVolume(bkp.Spec.VolumeName)
+	if err != nil || vol.Spec.BackupRetention == nil {
+		return
+	}
+	list, err := c.bkpLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("backup %s could not list the backups to prune err %v", bkp.Name, err)
+		return
+	}
+	// the lister may not have seen the backup done yet
+	bkps := []*apis.ZFSBackup{bkp}
+	for _, b := range list {
+		if b.UID != bkp.UID {
+			bkps = append(bkps, b)
+		}
+	}
+	pruned, err := zfs.PruneBackupSnapshots(vol, bkps)
+	if len(pruned) > 0 {
+		klog.Infof("backup %s pruned the backup snapshots %v of %s", bkp.Name, pruned, vol.Name)
+	}
+	if err != nil {
+		klog.Errorf("backup %s could not prune the backup snapshots of %s err %v", bkp.Name, vol.Name, err)
+	}
+}
+
+// blockBkpDeletion records the user holds which block the destroy of the
+// backup snapshot in the backup transfer info, and requeues it to be retried
+// after a while instead of failing in a loop until the holds are released
+func (c *BkpController) blockBkpDeletion(bkp *apis.ZFSBackup, held *zfs.SnapshotHeldError) error {
+	klog.Infof("backup %s can not be deleted yet, %v", bkp.Name, held)
+	if err := zfs.UpdateBkpDeletionBlocked(bkp, held); err != nil {
+		return err
+	}
+	key, err := cache.MetaNamespaceKeyFunc(bkp)
+	if err != nil {
+		return err
+	}
+	c.workqueue.AddAfter(key, zfs.SnapshotHeldRetryInterval)
+	return nil
+}
+
 // addBkp is the add event handler for ZFSBackup
 func (c *BkpController) addBkp(obj interface{}) {
 	bkp, ok := obj.(*apis.ZFSBackup)
@@ -126,8 +235,10 @@ func (c *BkpController) updateBkp(oldObj, newObj interface{}) {
 		return
 	}
 
-	if c.isDeletionCandidate(newBkp) {
+	if c.isDeletionCandidate(newBkp) || newBkp.Spec.Cancel {
 		klog.Infof("Got update event for Bkp %s snap %s@%s", newBkp.Name, newBkp.Spec.VolumeName, newBkp.Spec.SnapName)
+		// stop the send in flight, the worker running it is blocked on it
+		zfs.CancelTransfer(newBkp.UID)
 		c.enqueueBkp(newBkp)
 	}
 }
@@ -153,6 +264,7 @@ func (c *BkpController) deleteBkp(obj interface{}) {
 	}
 
 	klog.Infof("Got delete event for Bkp %s snap %s@%s", bkp.Name, bkp.Spec.VolumeName, bkp.Spec.SnapName)
+	zfs.CancelTransfer(bkp.UID)
 	c.enqueueBkp(bkp)
 }
 
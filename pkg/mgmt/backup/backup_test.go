@@ -0,0 +1,234 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	clientset "github.com/openebs/zfs-localpv/pkg/generated/clientset/internalclientset"
+	informers "github.com/openebs/zfs-localpv/pkg/generated/informer/externalversions"
+	"github.com/openebs/zfs-localpv/pkg/zfs"
+	"github.com/openebs/zfs-localpv/pkg/zfs/zfstest"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	testNamespace = "openebs"
+	testNode      = "node-1"
+)
+
+// testEnv is the node the backup controller runs on, with its zfs pool,
+// the kubernetes API server and the file target directory
+type testEnv struct {
+	fake    *zfstest.ZFS
+	cs      clientset.Interface
+	dir     string
+	c       *BkpController
+	indexer cache.Indexer
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	env := &testEnv{fake: zfstest.NewZFS(), dir: t.TempDir()}
+	env.fake.AddPool("pool", 10<<30)
+	old := zfs.SetCommandRunner(env.fake)
+	t.Cleanup(func() { zfs.SetCommandRunner(old) })
+
+	oldDir, oldNode, oldNamespace := zfs.FileTargetDir, zfs.NodeID, zfs.OpenEBSNamespace
+	zfs.FileTargetDir, zfs.NodeID, zfs.OpenEBSNamespace = env.dir, testNode, testNamespace
+	t.Cleanup(func() {
+		zfs.FileTargetDir, zfs.NodeID, zfs.OpenEBSNamespace = oldDir, oldNode, oldNamespace
+	})
+
+	env.cs = zfstest.NewAPIServer(t).Clientset()
+	factory := informers.NewSharedInformerFactory(env.cs, 0)
+	c, err := NewBkpControllerBuilder().
+		withOpenEBSClient(env.cs).
+		withBkpLister(factory).
+		withWorkqueueRateLimiting().
+		Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	t.Cleanup(c.workqueue.ShutDown)
+	env.c = c
+	env.indexer = factory.Zfs().V1().ZFSBackups().Informer().GetIndexer()
+	return env
+}
+
+// createVolume creates the volume along with its ZFSVolume
+func (env *testEnv) createVolume(t *testing.T, name string) *apis.ZFSVolume {
+	t.Helper()
+	vol := &apis.ZFSVolume{}
+	vol.Name = name
+	vol.Namespace = testNamespace
+	vol.Spec.OwnerNodeID = testNode
+	vol.Spec.PoolName = "pool"
+	vol.Spec.VolumeType = zfs.VolTypeDataset
+	vol.Spec.Capacity = "1073741824"
+	vol.Spec.FsType = "zfs"
+	vol.Spec.QuotaType = "refquota"
+	if err := zfs.CreateVolume(vol); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	vol, err := env.cs.ZfsV1().ZFSVolumes(testNamespace).Create(context.TODO(), vol, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("Create(%s) error = %v", name, err)
+	}
+	return vol
+}
+
+// createBackup creates the ZFSBackup of the volume to the file target
+func (env *testEnv) createBackup(t *testing.T, name, volName, dest string) {
+	t.Helper()
+	bkp := &apis.ZFSBackup{}
+	bkp.Name = name
+	bkp.Namespace = testNamespace
+	bkp.Spec.VolumeName = volName
+	bkp.Spec.SnapName = name
+	bkp.Spec.OwnerNodeID = testNode
+	bkp.Spec.BackupDest = dest
+	bkp.Status = apis.BKPZFSStatusInit
+	if _, err := env.cs.ZfsV1().ZFSBackups(testNamespace).Create(context.TODO(), bkp, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Create(%s) error = %v", name, err)
+	}
+}
+
+// sync runs the controller on the backup as the informer has last seen it
+func (env *testEnv) sync(t *testing.T, name string) error {
+	t.Helper()
+	bkp, err := env.cs.ZfsV1().ZFSBackups(testNamespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get(%s) error = %v", name, err)
+	}
+	if err := env.indexer.Update(bkp); err != nil {
+		t.Fatalf("Update(%s) error = %v", name, err)
+	}
+	return env.c.syncHandler(testNamespace + "/" + name)
+}
+
+func (env *testEnv) getBackup(t *testing.T, name string) *apis.ZFSBackup {
+	t.Helper()
+	bkp, err := env.cs.ZfsV1().ZFSBackups(testNamespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get(%s) error = %v", name, err)
+	}
+	return bkp
+}
+
+func TestSyncBkp(t *testing.T) {
+	env := newTestEnv(t)
+	env.createVolume(t, "pvc-1")
+	if err := env.fake.Write("pool/pvc-1", 4096); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	env.createBackup(t, "bkp-1", "pvc-1", "file://"+env.dir)
+	if err := env.sync(t, "bkp-1"); err != nil {
+		t.Fatalf("syncHandler(bkp-1) error = %v", err)
+	}
+	bkp := env.getBackup(t, "bkp-1")
+	if bkp.Status != apis.BKPZFSStatusDone {
+		t.Errorf("backup status = %s, want %s", bkp.Status, apis.BKPZFSStatusDone)
+	}
+	if len(bkp.Finalizers) != 1 || bkp.Finalizers[0] != zfs.ZFSFinalizer {
+		t.Errorf("backup finalizers = %v, want [%s]", bkp.Finalizers, zfs.ZFSFinalizer)
+	}
+	if bkp.Transfer.BytesTransferred == 0 {
+		t.Errorf("backup progress has not been recorded")
+	}
+	for _, file := range []string{"pvc-1/bkp-1.json", "pvc-1/bkp-1.zstream"} {
+		if _, err := os.Stat(filepath.Join(env.dir, file)); err != nil {
+			t.Errorf("backup file %s error = %v", file, err)
+		}
+	}
+	if _, ok := env.fake.Dataset("pool/pvc-1@bkp-1"); !ok {
+		t.Errorf("backup snapshot bkp-1 is not present")
+	}
+
+	// the done backup is not sent again
+	if err := os.Remove(filepath.Join(env.dir, "pvc-1/bkp-1.zstream")); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if err := env.sync(t, "bkp-1"); err != nil {
+		t.Fatalf("syncHandler(bkp-1) of done backup error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(env.dir, "pvc-1/bkp-1.zstream")); err == nil {
+		t.Errorf("done backup has been sent again")
+	}
+
+	// the deleted backup destroys its snapshot and goes away
+	if err := env.cs.ZfsV1().ZFSBackups(testNamespace).Delete(context.TODO(), "bkp-1", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Delete(bkp-1) error = %v", err)
+	}
+	if err := env.sync(t, "bkp-1"); err != nil {
+		t.Fatalf("syncHandler(bkp-1) of deleted backup error = %v", err)
+	}
+	if _, err := env.cs.ZfsV1().ZFSBackups(testNamespace).Get(context.TODO(), "bkp-1", metav1.GetOptions{}); !k8serror.IsNotFound(err) {
+		t.Errorf("Get(bkp-1) of deleted backup error = %v, want not found", err)
+	}
+	if _, ok := env.fake.Dataset("pool/pvc-1@bkp-1"); ok {
+		t.Errorf("snapshot of the deleted backup is present")
+	}
+}
+
+func TestSyncBkpFailed(t *testing.T) {
+	env := newTestEnv(t)
+	env.createVolume(t, "pvc-1")
+
+	// the file target outside of the target directory can never be written
+	env.createBackup(t, "bkp-1", "pvc-1", "file:///etc")
+	for retry := 1; retry <= zfs.TransferMaxRetries; retry++ {
+		if err := env.sync(t, "bkp-1"); err == nil {
+			t.Fatalf("syncHandler(bkp-1) retry %d should fail", retry)
+		}
+		bkp := env.getBackup(t, "bkp-1")
+		if bkp.Status != apis.BKPZFSStatusInit || bkp.Transfer.Retries != retry || len(bkp.Transfer.LastError) == 0 {
+			t.Fatalf("backup after retry %d status = %s retries = %d error = %q",
+				retry, bkp.Status, bkp.Transfer.Retries, bkp.Transfer.LastError)
+		}
+	}
+	if err := env.sync(t, "bkp-1"); err != nil {
+		t.Fatalf("syncHandler(bkp-1) of last retry error = %v", err)
+	}
+	if bkp := env.getBackup(t, "bkp-1"); bkp.Status != apis.BKPZFSStatusFailed {
+		t.Errorf("backup status = %s, want %s", bkp.Status, apis.BKPZFSStatusFailed)
+	}
+}
+
+func TestSyncBkpOtherNode(t *testing.T) {
+	env := newTestEnv(t)
+
+	bkp := &apis.ZFSBackup{}
+	bkp.Name = "bkp-1"
+	bkp.Namespace = testNamespace
+	bkp.Spec.OwnerNodeID = "node-2"
+	env.c.addBkp(bkp)
+	if n := env.c.workqueue.Len(); n != 0 {
+		t.Errorf("workqueue has %d backups of the other node", n)
+	}
+	bkp.Spec.OwnerNodeID = testNode
+	env.c.addBkp(bkp)
+	if n := env.c.workqueue.Len(); n != 1 {
+		t.Errorf("workqueue has %d backups, want 1", n)
+	}
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package restore
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
@@ -78,21 +79,72 @@ func (c *RstrController) syncRestore(rstr *apis.ZFSRestore) error {
 	var err error = nil
 	// ZFSRestore should not be deleted. Check if deletion timestamp is set
 	if !c.isDeletionCandidate(rstr) {
-		// if status is Init, then only do the restore
-		if rstr.Status == apis.RSTZFSStatusInit {
+		// if status is Init, then only do the restore, it is
+		// pending if it is waiting for the other transfers to finish
+		if rstr.Status == apis.RSTZFSStatusInit || rstr.Status == apis.RSTZFSStatusPending {
 			err = zfs.CreateRestore(rstr)
+			if errors.Is(err, zfs.ErrTransferQueued) {
+				return c.queueRestore(rstr)
+			}
+			if zfs.TransferStopped(err) {
+				return c.stopRestore(rstr, err)
+			}
 			if err == nil {
 				klog.Infof("restore %s done %s", rstr.Name, rstr.Spec.VolumeName)
+				rstr.Transfer.LastError = ""
 				err = zfs.UpdateRestoreInfo(rstr, apis.RSTZFSStatusDone)
 			} else {
-				klog.Errorf("restore %s failed %s err %v", rstr.Name, rstr.Spec.VolumeName, err)
-				err = zfs.UpdateRestoreInfo(rstr, apis.RSTZFSStatusFailed)
+				rstr.Transfer.LastError = err.Error()
+				if zfs.RetryRestore(rstr) {
+					klog.Warningf("restore %s failed %s, retry %d resume token [%s] err %v", rstr.Name,
+						rstr.Spec.VolumeName, rstr.Transfer.Retries, rstr.Transfer.ResumeToken, err)
+					if err = zfs.UpdateRestoreInfo(rstr, rstr.Status); err == nil {
+						// requeue the restore to retry the transfer
+						err = fmt.Errorf("restore %s will be retried", rstr.Name)
+					}
+				} else {
+					klog.Errorf("restore %s failed %s err %v", rstr.Name, rstr.Spec.VolumeName, err)
+					if aerr := zfs.AbortRestore(rstr); aerr != nil {
+						klog.Errorf("restore %s could not discard the partial state of %s err %v", rstr.Name, rstr.Spec.VolumeName, aerr)
+					}
+					err = zfs.UpdateRestoreInfo(rstr, apis.RSTZFSStatusFailed)
+				}
 			}
 		}
 	}
 	return err
 }
 
+// stopRestore moves the restore which has been cancelled to Cancelled, or
+// the one which has exceeded its deadline to Failed, with the reason
+// recorded as the last error. It is not retried.
+func (c *RstrController) stopRestore(rstr *apis.ZFSRestore, reason error) error {
+	status := apis.RSTZFSStatusFailed
+	if errors.Is(reason, zfs.ErrTransferCancelled) {
+		status = apis.RSTZFSStatusCancelled
+	}
+	klog.Warningf("restore %s %s %s err %v", rstr.Name, status, rstr.Spec.VolumeName, reason)
+	rstr.Transfer.LastError = reason.Error()
+	return zfs.UpdateRestoreInfo(rstr, status)
+}
+
+// queueRestore marks the restore as Pending while the maximum number of
+// transfers are running on the node, and requeues it to be retried after a while
+func (c *RstrController) queueRestore(rstr *apis.ZFSRestore) error {
+	if rstr.Status != apis.RSTZFSStatusPending {
+		klog.Infof("restore %s is pending %s, waiting for the running transfers", rstr.Name, rstr.Spec.VolumeName)
+		if err := zfs.UpdateRestoreInfo(rstr, apis.RSTZFSStatusPending); err != nil {
+			return err
+		}
+	}
+	key, err := cache.MetaNamespaceKeyFunc(rstr)
+	if err != nil {
+		return err
+	}
+	c.workqueue.AddAfter(key, zfs.TransferQueueInterval)
+	return nil
+}
+
 // addRestore is the add event handler for ZFSRestore
 func (c *RstrController) addRestore(obj interface{}) {
 	rstr, ok := obj.(*apis.ZFSRestore)
@@ -121,8 +173,10 @@ func (c *RstrController) updateRestore(oldObj, newObj interface{}) {
 		return
 	}
 
-	if c.isDeletionCandidate(newRstr) {
+	if c.isDeletionCandidate(newRstr) || newRstr.Spec.Cancel {
 		klog.Infof("Got update event for Restore %s vol %s", newRstr.Name, newRstr.Spec.VolumeName)
+		// stop the receive in flight, the worker running it is blocked on it
+		zfs.CancelTransfer(newRstr.UID)
 		c.enqueueRestore(newRstr)
 	}
 }
@@ -148,6 +202,7 @@ func (c *RstrController) deleteRestore(obj interface{}) {
 	}
 
 	klog.Infof("Got delete event for Restore %s", rstr.Spec.VolumeName)
+	zfs.CancelTransfer(rstr.UID)
 	c.enqueueRestore(rstr)
 }
 
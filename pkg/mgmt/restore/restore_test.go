@@ -0,0 +1,267 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restore
+
+import (
+	"context"
+	"testing"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	clientset "github.com/openebs/zfs-localpv/pkg/generated/clientset/internalclientset"
+	informers "github.com/openebs/zfs-localpv/pkg/generated/informer/externalversions"
+	"github.com/openebs/zfs-localpv/pkg/zfs"
+	"github.com/openebs/zfs-localpv/pkg/zfs/zfstest"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	testNamespace = "openebs"
+	testNode      = "node-1"
+)
+
+// testEnv is the node the restore controller runs on, with its zfs pool,
+// the kubernetes API server and the file target directory
+type testEnv struct {
+	fake    *zfstest.ZFS
+	cs      clientset.Interface
+	dir     string
+	c       *RstrController
+	indexer cache.Indexer
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	env := &testEnv{fake: zfstest.NewZFS(), dir: t.TempDir()}
+	env.fake.AddPool("pool", 10<<30)
+	old := zfs.SetCommandRunner(env.fake)
+	t.Cleanup(func() { zfs.SetCommandRunner(old) })
+
+	oldDir, oldNode, oldNamespace := zfs.FileTargetDir, zfs.NodeID, zfs.OpenEBSNamespace
+	zfs.FileTargetDir, zfs.NodeID, zfs.OpenEBSNamespace = env.dir, testNode, testNamespace
+	t.Cleanup(func() {
+		zfs.FileTargetDir, zfs.NodeID, zfs.OpenEBSNamespace = oldDir, oldNode, oldNamespace
+	})
+
+	env.cs = zfstest.NewAPIServer(t).Clientset()
+	factory := informers.NewSharedInformerFactory(env.cs, 0)
+	c, err := NewRstrControllerBuilder().
+		withOpenEBSClient(env.cs).
+		withRestoreLister(factory).
+		withWorkqueueRateLimiting().
+		Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	t.Cleanup(c.workqueue.ShutDown)
+	env.c = c
+	env.indexer = factory.Zfs().V1().ZFSRestores().Informer().GetIndexer()
+	return env
+}
+
+// backupVolume creates the volume and backs it up to the file target, it
+// returns the volume and the restore source of its backup
+func (env *testEnv) backupVolume(t *testing.T, name string, written int64) (*apis.ZFSVolume, string) {
+	t.Helper()
+	vol := &apis.ZFSVolume{}
+	vol.Name = name
+	vol.Namespace = testNamespace
+	vol.Spec.OwnerNodeID = testNode
+	vol.Spec.PoolName = "pool"
+	vol.Spec.VolumeType = zfs.VolTypeDataset
+	vol.Spec.Capacity = "1073741824"
+	vol.Spec.FsType = "zfs"
+	vol.Spec.QuotaType = "refquota"
+	if err := zfs.CreateVolume(vol); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	if err := env.fake.Write("pool/"+name, written); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	vol, err := env.cs.ZfsV1().ZFSVolumes(testNamespace).Create(context.TODO(), vol, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("Create(%s) error = %v", name, err)
+	}
+
+	bkp := &apis.ZFSBackup{}
+	bkp.Name = "bkp-1"
+	bkp.Namespace = testNamespace
+	bkp.Spec.VolumeName = name
+	bkp.Spec.SnapName = "bkp-1"
+	bkp.Spec.OwnerNodeID = testNode
+	bkp.Spec.BackupDest = "file://" + env.dir
+	bkp, err = env.cs.ZfsV1().ZFSBackups(testNamespace).Create(context.TODO(), bkp, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("Create(bkp-1) error = %v", err)
+	}
+	if err := zfs.CreateBackup(bkp); err != nil {
+		t.Fatalf("CreateBackup() error = %v", err)
+	}
+	return vol, "file://" + env.dir + "/" + name + "/bkp-1.json"
+}
+
+// createRestore creates the ZFSRestore of the volume from the source
+func (env *testEnv) createRestore(t *testing.T, name, volName, src string, volSpec apis.VolumeInfo) {
+	t.Helper()
+	rstr := &apis.ZFSRestore{}
+	rstr.Name = name
+	rstr.Namespace = testNamespace
+	rstr.Spec.VolumeName = volName
+	rstr.Spec.OwnerNodeID = testNode
+	rstr.Spec.RestoreSrc = src
+	rstr.VolSpec = volSpec
+	rstr.Status = apis.RSTZFSStatusInit
+	if _, err := env.cs.ZfsV1().ZFSRestores(testNamespace).Create(context.TODO(), rstr, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Create(%s) error = %v", name, err)
+	}
+}
+
+// sync runs the controller on the restore as the informer has last seen it
+func (env *testEnv) sync(t *testing.T, name string) error {
+	t.Helper()
+	if err := env.indexer.Update(env.getRestore(t, name)); err != nil {
+		t.Fatalf("Update(%s) error = %v", name, err)
+	}
+	return env.c.syncHandler(testNamespace + "/" + name)
+}
+
+func (env *testEnv) getRestore(t *testing.T, name string) *apis.ZFSRestore {
+	t.Helper()
+	rstr, err := env.cs.ZfsV1().ZFSRestores(testNamespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get(%s) error = %v", name, err)
+	}
+	return rstr
+}
+
+func TestSyncRestore(t *testing.T) {
+	env := newTestEnv(t)
+	vol, src := env.backupVolume(t, "pvc-1", 4096)
+
+	env.createRestore(t, "rstr-1", "pvc-2", src, vol.Spec)
+	if err := env.sync(t, "rstr-1"); err != nil {
+		t.Fatalf("syncHandler(rstr-1) error = %v", err)
+	}
+	rstr := env.getRestore(t, "rstr-1")
+	if rstr.Status != apis.RSTZFSStatusDone {
+		t.Errorf("restore status = %s, want %s", rstr.Status, apis.RSTZFSStatusDone)
+	}
+	if rstr.Transfer.BytesTransferred == 0 {
+		t.Errorf("restore progress has not been recorded")
+	}
+	if ds, ok := env.fake.Dataset("pool/pvc-2"); !ok || ds.Written != 4096 {
+		t.Errorf("restored volume = %+v present %v, want 4096 bytes written", ds, ok)
+	}
+	if _, ok := env.fake.Dataset("pool/pvc-2@bkp-1"); !ok {
+		t.Errorf("restored snapshot bkp-1 is not present")
+	}
+
+	// the done restore is not received again
+	if err := env.fake.Write("pool/pvc-2", 4096); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := env.sync(t, "rstr-1"); err != nil {
+		t.Fatalf("syncHandler(rstr-1) of done restore error = %v", err)
+	}
+	if ds, _ := env.fake.Dataset("pool/pvc-2"); ds.Written != 8192 {
+		t.Errorf("done restore has been received again")
+	}
+}
+
+func TestSyncRestoreFailed(t *testing.T) {
+	env := newTestEnv(t)
+	vol, _ := env.backupVolume(t, "pvc-1", 4096)
+
+	env.createRestore(t, "rstr-1", "pvc-2", "file://"+env.dir+"/pvc-1/missing.json", vol.Spec)
+	for retry := 1; retry <= zfs.TransferMaxRetries; retry++ {
+		if err := env.sync(t, "rstr-1"); err == nil {
+			t.Fatalf("syncHandler(rstr-1) retry %d should fail", retry)
+		}
+		rstr := env.getRestore(t, "rstr-1")
+		if rstr.Status != apis.RSTZFSStatusInit || rstr.Transfer.Retries != retry || len(rstr.Transfer.LastError) == 0 {
+			t.Fatalf("restore after retry %d status = %s retries = %d error = %q",
+				retry, rstr.Status, rstr.Transfer.Retries, rstr.Transfer.LastError)
+		}
+	}
+	if err := env.sync(t, "rstr-1"); err != nil {
+		t.Fatalf("syncHandler(rstr-1) of last retry error = %v", err)
+	}
+	if rstr := env.getRestore(t, "rstr-1"); rstr.Status != apis.RSTZFSStatusFailed {
+		t.Errorf("restore status = %s, want %s", rstr.Status, apis.RSTZFSStatusFailed)
+	}
+	if _, ok := env.fake.Dataset("pool/pvc-2"); ok {
+		t.Errorf("failed restore left the volume behind")
+	}
+}
+
+func TestSyncRestoreCancelled(t *testing.T) {
+	env := newTestEnv(t)
+	vol, src := env.backupVolume(t, "pvc-1", 4096)
+
+	env.createRestore(t, "rstr-1", "pvc-2", src, vol.Spec)
+	rstr := env.getRestore(t, "rstr-1")
+	rstr.Spec.Cancel = true
+	if _, err := env.cs.ZfsV1().ZFSRestores(testNamespace).Update(context.TODO(), rstr, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Update(rstr-1) error = %v", err)
+	}
+	if err := env.sync(t, "rstr-1"); err != nil {
+		t.Fatalf("syncHandler(rstr-1) error = %v", err)
+	}
+	if rstr := env.getRestore(t, "rstr-1"); rstr.Status != apis.RSTZFSStatusCancelled {
+		t.Errorf("restore status = %s, want %s", rstr.Status, apis.RSTZFSStatusCancelled)
+	}
+	if _, ok := env.fake.Dataset("pool/pvc-2"); ok {
+		t.Errorf("cancelled restore has been received")
+	}
+}
+
+func TestSyncRestoreOverridePool(t *testing.T) {
+	env := newTestEnv(t)
+	env.fake.AddPool("dr-pool", 10<<30)
+	vol, src := env.backupVolume(t, "pvc-1", 4096)
+
+	target := vol.DeepCopy()
+	target.ObjectMeta = metav1.ObjectMeta{Name: "pvc-2", Namespace: testNamespace}
+	target.Status = apis.VolStatus{}
+	if _, err := env.cs.ZfsV1().ZFSVolumes(testNamespace).Create(context.TODO(), target, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Create(pvc-2) error = %v", err)
+	}
+	env.createRestore(t, "rstr-1", "pvc-2", src, vol.Spec)
+	rstr := env.getRestore(t, "rstr-1")
+	rstr.Spec.Overrides = &apis.RestoreOverrides{PoolName: "dr-pool"}
+	if _, err := env.cs.ZfsV1().ZFSRestores(testNamespace).Update(context.TODO(), rstr, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Update(rstr-1) error = %v", err)
+	}
+	if err := env.sync(t, "rstr-1"); err != nil {
+		t.Fatalf("syncHandler(rstr-1) error = %v", err)
+	}
+	if rstr := env.getRestore(t, "rstr-1"); rstr.Status != apis.RSTZFSStatusDone {
+		t.Errorf("restore status = %s, want %s", rstr.Status, apis.RSTZFSStatusDone)
+	}
+	if _, ok := env.fake.Dataset("dr-pool/pvc-2"); !ok {
+		t.Errorf("volume has not been restored into dr-pool")
+	}
+	if _, ok := env.fake.Dataset("pool/pvc-2"); ok {
+		t.Errorf("volume has been restored into the pool of its ZFSVolume")
+	}
+	got, err := env.cs.ZfsV1().ZFSVolumes(testNamespace).Get(context.TODO(), "pvc-2", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get(pvc-2) error = %v", err)
+	}
+	if got.Spec.PoolName != "dr-pool" {
+		t.Errorf("ZFSVolume pool = %s, want dr-pool", got.Spec.PoolName)
+	}
+}
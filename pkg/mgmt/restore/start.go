@@ -26,6 +26,7 @@ import (
 
 	clientset "github.com/openebs/zfs-localpv/pkg/generated/clientset/internalclientset"
 	informers "github.com/openebs/zfs-localpv/pkg/generated/informer/externalversions"
+	"github.com/openebs/zfs-localpv/pkg/zfs"
 	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -86,8 +87,9 @@ func Start(controllerMtx *sync.RWMutex, stopCh <-chan struct{}) error {
 	go kubeInformerFactory.Start(stopCh)
 	go bkpInformerFactory.Start(stopCh)
 
-	// Threadiness defines the number of workers to be launched in Run function
-	return controller.Run(2, stopCh)
+	// Threadiness defines the number of workers to be launched in Run function,
+	// enough to run the number of transfers allowed on the node
+	return controller.Run(zfs.TransferWorkers(), stopCh)
 }
 
 // GetClusterConfig return the config for k8s.
@@ -0,0 +1,157 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"k8s.io/klog/v2"
+
+	clientset "github.com/openebs/zfs-localpv/pkg/generated/clientset/internalclientset"
+	openebsScheme "github.com/openebs/zfs-localpv/pkg/generated/clientset/internalclientset/scheme"
+	informers "github.com/openebs/zfs-localpv/pkg/generated/informer/externalversions"
+	listers "github.com/openebs/zfs-localpv/pkg/generated/lister/zfs/v1"
+	corev1 "k8s.io/api/core/v1"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const controllerAgentName = "zfssnapshotschedule-controller"
+
+// SchedController is the controller implementation for ZFSSnapshotSchedule resources
+type SchedController struct {
+	// kubeclientset is a standard kubernetes clientset
+	kubeclientset kubernetes.Interface
+
+	// clientset is a openebs custom resource package generated for custom API group.
+	clientset clientset.Interface
+
+	schedLister listers.ZFSSnapshotScheduleLister
+
+	// volLister and snapLister list the volumes the schedules select
+	// and the snapshots they have taken
+	volLister  listers.ZFSVolumeLister
+	snapLister listers.ZFSSnapshotLister
+
+	// pvLister gets the storage class of the volumes
+	pvLister corelisters.PersistentVolumeLister
+
+	// schedSynced is used for caches sync to get populated
+	schedSynced cache.InformerSynced
+	volSynced   cache.InformerSynced
+	snapSynced  cache.InformerSynced
+	pvSynced    cache.InformerSynced
+
+	// workqueue is a rate limited work queue. This is used to queue work to be
+	// processed instead of performing it as soon as a change happens. This
+	// means we can ensure we only process a fixed amount of resources at a
+	// time, and makes it easy to ensure we are never processing the same item
+	// simultaneously in two different workers.
+	workqueue workqueue.RateLimitingInterface
+
+	// recorder is an event recorder for recording Event resources to the
+	// Kubernetes API.
+	recorder record.EventRecorder
+}
+
+// SchedControllerBuilder is the builder object for controller.
+type SchedControllerBuilder struct {
+	SchedController *SchedController
+}
+
+// NewSchedControllerBuilder returns an empty instance of controller builder.
+func NewSchedControllerBuilder() *SchedControllerBuilder {
+	return &SchedControllerBuilder{
+		SchedController: &SchedController{},
+	}
+}
+
+// withKubeClient fills kube client to controller object.
+func (cb *SchedControllerBuilder) withKubeClient(ks kubernetes.Interface) *SchedControllerBuilder {
+	cb.SchedController.kubeclientset = ks
+	return cb
+}
+
+// withOpenEBSClient fills openebs client to controller object.
+func (cb *SchedControllerBuilder) withOpenEBSClient(cs clientset.Interface) *SchedControllerBuilder {
+	cb.SchedController.clientset = cs
+	return cb
+}
+
+// withSchedLister fills the schedule, volume and snapshot listers to controller object.
+func (cb *SchedControllerBuilder) withSchedLister(sl informers.SharedInformerFactory) *SchedControllerBuilder {
+	cb.SchedController.schedLister = sl.Zfs().V1().ZFSSnapshotSchedules().Lister()
+	cb.SchedController.volLister = sl.Zfs().V1().ZFSVolumes().Lister()
+	cb.SchedController.snapLister = sl.Zfs().V1().ZFSSnapshots().Lister()
+	return cb
+}
+
+// withPVLister fills pv lister to controller object.
+func (cb *SchedControllerBuilder) withPVLister(kl kubeinformers.SharedInformerFactory) *SchedControllerBuilder {
+	cb.SchedController.pvLister = kl.Core().V1().PersistentVolumes().Lister()
+	return cb
+}
+
+// withSchedSynced adds object sync information in cache to controller object.
+func (cb *SchedControllerBuilder) withSchedSynced(sl informers.SharedInformerFactory, kl kubeinformers.SharedInformerFactory) *SchedControllerBuilder {
+	cb.SchedController.schedSynced = sl.Zfs().V1().ZFSSnapshotSchedules().Informer().HasSynced
+	cb.SchedController.volSynced = sl.Zfs().V1().ZFSVolumes().Informer().HasSynced
+	cb.SchedController.snapSynced = sl.Zfs().V1().ZFSSnapshots().Informer().HasSynced
+	cb.SchedController.pvSynced = kl.Core().V1().PersistentVolumes().Informer().HasSynced
+	return cb
+}
+
+// withWorkqueue adds workqueue to controller object.
+func (cb *SchedControllerBuilder) withWorkqueueRateLimiting() *SchedControllerBuilder {
+	cb.SchedController.workqueue = workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "Sched")
+	return cb
+}
+
+// withRecorder adds recorder to controller object.
+func (cb *SchedControllerBuilder) withRecorder(ks kubernetes.Interface) *SchedControllerBuilder {
+	klog.Infof("Creating event broadcaster")
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(klog.Infof)
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: ks.CoreV1().Events("")})
+	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: controllerAgentName})
+	cb.SchedController.recorder = recorder
+	return cb
+}
+
+// withEventHandler adds event handlers controller object.
+func (cb *SchedControllerBuilder) withEventHandler(schedInformerFactory informers.SharedInformerFactory) *SchedControllerBuilder {
+	schedInformer := schedInformerFactory.Zfs().V1().ZFSSnapshotSchedules()
+	// Set up an event handler for when ZFSSnapshotSchedule resources change
+	schedInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    cb.SchedController.addSched,
+		UpdateFunc: cb.SchedController.updateSched,
+	})
+	return cb
+}
+
+// Build returns a controller instance.
+func (cb *SchedControllerBuilder) Build() (*SchedController, error) {
+	err := openebsScheme.AddToScheme(scheme.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	return cb.SchedController, nil
+}
@@ -0,0 +1,484 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	zfs "github.com/openebs/zfs-localpv/pkg/zfs"
+	corev1 "k8s.io/api/core/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+)
+
+// snapshotReadyTimeout is the time after which the scheduled snapshot
+// which is not Ready yet is reported as a failure of the schedule
+const snapshotReadyTimeout = time.Minute
+
+// syncHandler compares the actual state with the desired, and attempts to
+// converge the two.
+func (c *SchedController) syncHandler(key string) error {
+	// Convert the namespace/name string into a distinct namespace and name
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		runtime.HandleError(fmt.Errorf("invalid resource key: %s", key))
+		return nil
+	}
+
+	// Get the schedule resource with this namespace/name
+	sched, err := c.schedLister.ZFSSnapshotSchedules(namespace).Get(name)
+	if k8serror.IsNotFound(err) {
+		runtime.HandleError(fmt.Errorf("zfs snapshot schedule '%s' has been deleted", key))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	schedCopy := sched.DeepCopy()
+	err = c.syncSched(schedCopy)
+	return err
+}
+
+// enqueueSched takes a ZFSSnapshotSchedule resource and converts it into a
+// namespace/name string which is then put onto the work queue. This method
+// should *not* be passed resources of any type other than ZFSSnapshotSchedule.
+func (c *SchedController) enqueueSched(obj interface{}) {
+	var key string
+	var err error
+	if key, err = cache.MetaNamespaceKeyFunc(obj); err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	c.workqueue.Add(key)
+}
+
+// syncSched takes the snapshots which are due of the volumes on the node
+// selected by the schedule, prunes the ones beyond the retention of their
+// schedule and the ones which are not scheduled anymore, records the run in
+// the status of the node and requeues the schedule for its next run. The
+// deleted schedule deletes all of its snapshots before it goes away.
+func (c *SchedController) syncSched(sched *apis.ZFSSnapshotSchedule) error {
+	if sched.DeletionTimestamp != nil {
+		return c.deleteSched(sched)
+	}
+	if err := c.addSchedFinalizer(sched); err != nil {
+		return err
+	}
+	if err := zfs.ValidateSnapshotSchedule(sched); err != nil {
+		klog.Errorf("snapshot schedule %s is invalid: %v", sched.Name, err)
+		c.recorder.Event(sched, corev1.EventTypeWarning, "InvalidSchedule", err.Error())
+		return nil
+	}
+
+	now := time.Now()
+	vols, err := c.selectedVolumes(sched)
+	if err != nil {
+		return err
+	}
+	taken, err := c.snapLister.ZFSSnapshots(zfs.OpenEBSNamespace).List(
+		labels.SelectorFromSet(labels.Set{zfs.ZFSSnapScheduleKey: sched.Name}))
+	if err != nil {
+		return err
+	}
+
+	status := apis.ScheduleNodeStatus{
+		NodeID:      zfs.NodeID,
+		LastRunTime: metav1.Time{Time: now},
+		Volumes:     len(vols),
+	}
+	var failed bool
+	for _, vol := range vols {
+		var errs []string
+		for _, s := range sched.Spec.Schedules {
+			var snaps []*apis.ZFSSnapshot
+			for _, snap := range taken {
+				if snap.Labels[zfs.ZFSVolKey] == vol.Name && snap.Labels[zfs.ZFSSnapScheduleNameKey] == s.Name {
+					snaps = append(snaps, snap)
+				}
+			}
+			snaps, err := c.takeSnapshot(sched, s, vol, snaps, now, &status)
+			if err != nil {
+				errs = append(errs, err.Error())
+				failed = true
+			}
+			errs = append(errs, c.pruneSnapshots(s, snaps, now)...)
+		}
+		if len(errs) > 0 {
+			status.Failures = append(status.Failures, apis.ScheduleFailure{
+				VolumeName: vol.Name,
+				Error:      strings.Join(errs, "; "),
+			})
+		}
+	}
+
+	if err := c.deleteUnscheduledSnapshots(sched, taken); err != nil {
+		klog.Errorf("snapshot schedule %s could not delete the snapshots not scheduled anymore err %v", sched.Name, err)
+		failed = true
+	}
+
+	if err := c.updateNodeStatus(sched, status); err != nil {
+		return err
+	}
+	key, err := cache.MetaNamespaceKeyFunc(sched)
+	if err != nil {
+		return err
+	}
+	c.workqueue.AddAfter(key, zfs.NextScheduleRun(sched, now))
+	if failed {
+		// retry taking the snapshots which are due
+		return fmt.Errorf("snapshot schedule %s could not take the snapshots of all the volumes", sched.Name)
+	}
+	return nil
+}
+
+// selectedVolumes returns the Ready volumes owned by the node
+// which are selected by the schedule
+func (c *SchedController) selectedVolumes(sched *apis.ZFSSnapshotSchedule) ([]*apis.ZFSVolume, error) {
+	list, err := c.volLister.ZFSVolumes(zfs.OpenEBSNamespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	var vols []*apis.ZFSVolume
+	for _, vol := range list {
+		if vol.Spec.OwnerNodeID != zfs.NodeID || vol.DeletionTimestamp != nil || !zfs.IsVolumeReady(vol) {
+			continue
+		}
+		if c.selectsVolume(sched, vol) {
+			vols = append(vols, vol)
+		}
+	}
+	return vols, nil
+}
+
+// selectsVolume returns true if the schedule selects the volume
+func (c *SchedController) selectsVolume(sched *apis.ZFSSnapshotSchedule, vol *apis.ZFSVolume) bool {
+	// the pv is named after the volume
+	var storageClass string
+	if pv, err := c.pvLister.Get(vol.Name); err == nil {
+		storageClass = pv.Spec.StorageClassName
+	}
+	return zfs.ScheduleSelectsVolume(sched, vol, storageClass)
+}
+
+// takeSnapshot creates the ZFSSnapshot of the volume for the slot of the
+// schedule which is due unless it has already been taken, and returns the
+// snapshots of the schedule along with it
+func (c *SchedController) takeSnapshot(sched *apis.ZFSSnapshotSchedule, s apis.SnapshotSchedule,
+	vol *apis.ZFSVolume, snaps []*apis.ZFSSnapshot, now time.Time, status *apis.ScheduleNodeStatus) ([]*apis.ZFSSnapshot, error) {
+	if sched.Spec.Suspend {
+		return snaps, nil
+	}
+	snap := zfs.NewScheduledSnapshot(sched, s, vol, zfs.ScheduleSlot(s, now))
+	for _, taken := range snaps {
+		if taken.Name == snap.Name {
+			return snaps, nil
+		}
+	}
+	if err := zfs.ProvisionSnapshot(snap); err != nil && !k8serror.IsAlreadyExists(err) {
+		klog.Errorf("snapshot schedule %s could not take snapshot %s of %s err %v", sched.Name, snap.Name, vol.Name, err)
+		return snaps, fmt.Errorf("snapshot %s: %v", snap.Name, err)
+	}
+	status.LastSnapshotTime = &metav1.Time{Time: now}
+	// the new snapshot is not in the lister yet
+	snap.CreationTimestamp = metav1.Time{Time: now}
+	return append(snaps, snap), nil
+}
+
+// pruneSnapshots deletes the snapshots of the schedule beyond its retention,
+// and returns the errors for the snapshots which could not be deleted or
+// have not become Ready in time
+func (c *SchedController) pruneSnapshots(s apis.SnapshotSchedule, snaps []*apis.ZFSSnapshot, now time.Time) []string {
+	var errs []string
+	for _, snap := range zfs.ScheduledSnapshotsToPrune(s, snaps) {
+		if err := zfs.DeleteSnapshot(snap.Name); err != nil && !k8serror.IsNotFound(err) {
+			klog.Errorf("could not prune scheduled snapshot %s err %v", snap.Name, err)
+			errs = append(errs, fmt.Sprintf("prune snapshot %s: %v", snap.Name, err))
+		}
+	}
+	for _, snap := range snaps {
+		if snap.DeletionTimestamp == nil && snap.Status.State != zfs.ZFSStatusReady &&
+			now.Sub(snap.CreationTimestamp.Time) > snapshotReadyTimeout {
+			errs = append(errs, fmt.Sprintf("snapshot %s is %s", snap.Name, snap.Status.State))
+		}
+	}
+	return errs
+}
+
+// deleteUnscheduledSnapshots deletes the snapshots taken by the schedule on
+// the node whose volume is gone, is being deleted or is not selected by the
+// schedule anymore, and the ones of the schedules removed from its spec, as
+// they would never be pruned
+func (c *SchedController) deleteUnscheduledSnapshots(sched *apis.ZFSSnapshotSchedule, taken []*apis.ZFSSnapshot) error {
+	vols, err := c.volLister.ZFSVolumes(zfs.OpenEBSNamespace).List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	selected := make(map[string]bool, len(vols))
+	for _, vol := range vols {
+		if vol.DeletionTimestamp == nil && c.selectsVolume(sched, vol) {
+			selected[vol.Name] = true
+		}
+	}
+	var errs []string
+	for _, snap := range zfs.UnscheduledSnapshots(sched, taken, selected) {
+		klog.Infof("deleting snapshot %s of %s not scheduled by %s/%s anymore",
+			snap.Name, snap.Labels[zfs.ZFSVolKey], sched.Name, snap.Labels[zfs.ZFSSnapScheduleNameKey])
+		if err := zfs.DeleteSnapshot(snap.Name); err != nil && !k8serror.IsNotFound(err) {
+			errs = append(errs, fmt.Sprintf("delete snapshot %s: %v", snap.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// addSchedFinalizer adds the finalizer to the schedule, so that its
+// snapshots are deleted along with it
+func (c *SchedController) addSchedFinalizer(sched *apis.ZFSSnapshotSchedule) error {
+	if slices.Contains(sched.Finalizers, zfs.ZFSFinalizer) {
+		return nil
+	}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest, err := c.clientset.ZfsV1().ZFSSnapshotSchedules(sched.Namespace).
+			Get(context.TODO(), sched.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if slices.Contains(latest.Finalizers, zfs.ZFSFinalizer) {
+			return nil
+		}
+		latest.Finalizers = append(latest.Finalizers, zfs.ZFSFinalizer)
+		_, err = c.clientset.ZfsV1().ZFSSnapshotSchedules(sched.Namespace).
+			Update(context.TODO(), latest, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// deleteSched deletes the snapshots taken by the deleted schedule on all
+// the nodes, their node agents destroy them, and removes its finalizer. The
+// snapshots are listed from the API server, as the snapshot just taken by
+// another node may not be in the lister yet.
+func (c *SchedController) deleteSched(sched *apis.ZFSSnapshotSchedule) error {
+	if !slices.Contains(sched.Finalizers, zfs.ZFSFinalizer) {
+		return nil
+	}
+	snaps, err := c.clientset.ZfsV1().ZFSSnapshots(zfs.OpenEBSNamespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{zfs.ZFSSnapScheduleKey: sched.Name}).String(),
+	})
+	if err != nil {
+		return err
+	}
+	for _, snap := range snaps.Items {
+		if snap.DeletionTimestamp != nil {
+			continue
+		}
+		klog.Infof("deleting snapshot %s of the deleted snapshot schedule %s", snap.Name, sched.Name)
+		if err := zfs.DeleteSnapshot(snap.Name); err != nil && !k8serror.IsNotFound(err) {
+			return fmt.Errorf("snapshot schedule %s could not delete snapshot %s: %v", sched.Name, snap.Name, err)
+		}
+	}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest, err := c.clientset.ZfsV1().ZFSSnapshotSchedules(sched.Namespace).
+			Get(context.TODO(), sched.Name, metav1.GetOptions{})
+		if k8serror.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		latest.Finalizers = slices.DeleteFunc(latest.Finalizers, func(f string) bool { return f == zfs.ZFSFinalizer })
+		_, err = c.clientset.ZfsV1().ZFSSnapshotSchedules(sched.Namespace).
+			Update(context.TODO(), latest, metav1.UpdateOptions{})
+		if k8serror.IsNotFound(err) {
+			return nil
+		}
+		return err
+	})
+}
+
+// updateNodeStatus records the status of the run on the node in the
+// schedule through its status subresource, the nodes update their own
+// status of the same schedule so the update is retried on the conflict
+func (c *SchedController) updateNodeStatus(sched *apis.ZFSSnapshotSchedule, status apis.ScheduleNodeStatus) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest, err := c.clientset.ZfsV1().ZFSSnapshotSchedules(sched.Namespace).
+			Get(context.TODO(), sched.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		i := 0
+		for ; i < len(latest.Status.Nodes); i++ {
+			if latest.Status.Nodes[i].NodeID == status.NodeID {
+				break
+			}
+		}
+		if i == len(latest.Status.Nodes) {
+			// the nodes with no selected volumes are not reported
+			if status.Volumes == 0 {
+				return nil
+			}
+			latest.Status.Nodes = append(latest.Status.Nodes, apis.ScheduleNodeStatus{NodeID: status.NodeID})
+		}
+		if status.LastSnapshotTime == nil {
+			status.LastSnapshotTime = latest.Status.Nodes[i].LastSnapshotTime
+		}
+		latest.Status.Nodes[i] = status
+		_, err = c.clientset.ZfsV1().ZFSSnapshotSchedules(sched.Namespace).
+			UpdateStatus(context.TODO(), latest, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// addSched is the add event handler for ZFSSnapshotSchedule
+func (c *SchedController) addSched(obj interface{}) {
+	sched, ok := obj.(*apis.ZFSSnapshotSchedule)
+	if !ok {
+		runtime.HandleError(fmt.Errorf("Couldn't get snapshot schedule object %#v", obj))
+		return
+	}
+
+	klog.Infof("Got add event for snapshot schedule %s", sched.Name)
+	c.enqueueSched(sched)
+}
+
+// updateSched is the update event handler for ZFSSnapshotSchedule, the
+// schedule is synced again only if its spec has changed or it is being
+// deleted, as the nodes update its status after every run
+func (c *SchedController) updateSched(oldObj, newObj interface{}) {
+	oldSched, ok := oldObj.(*apis.ZFSSnapshotSchedule)
+	if !ok {
+		runtime.HandleError(fmt.Errorf("Couldn't get snapshot schedule object %#v", oldObj))
+		return
+	}
+	newSched, ok := newObj.(*apis.ZFSSnapshotSchedule)
+	if !ok {
+		runtime.HandleError(fmt.Errorf("Couldn't get snapshot schedule object %#v", newObj))
+		return
+	}
+
+	if !reflect.DeepEqual(oldSched.Spec, newSched.Spec) || newSched.DeletionTimestamp != nil {
+		klog.Infof("Got update event for snapshot schedule %s", newSched.Name)
+		c.enqueueSched(newSched)
+	}
+}
+
+// Run will set up the event handlers for types we are interested in, as well
+// as syncing informer caches and starting workers. It will block until stopCh
+// is closed, at which point it will shutdown the workqueue and wait for
+// workers to finish processing their current work items.
+func (c *SchedController) Run(threadiness int, stopCh <-chan struct{}) error {
+	defer runtime.HandleCrash()
+	defer c.workqueue.ShutDown()
+
+	// Start the informer factories to begin populating the informer caches
+	klog.Info("Starting snapshot schedule controller")
+
+	// Wait for the k8s caches to be synced before starting workers
+	klog.Info("Waiting for informer caches to sync")
+	if ok := cache.WaitForCacheSync(stopCh, c.schedSynced, c.volSynced, c.snapSynced, c.pvSynced); !ok {
+		return fmt.Errorf("failed to wait for caches to sync")
+	}
+	klog.Info("Starting snapshot schedule workers")
+	// Launch worker to process ZFSSnapshotSchedule resources
+	// Threadiness will decide the number of workers you want to launch to process work items from queue
+	for i := 0; i < threadiness; i++ {
+		go wait.Until(c.runWorker, time.Second, stopCh)
+	}
+
+	klog.Info("Started snapshot schedule workers")
+	<-stopCh
+	klog.Info("Shutting down snapshot schedule workers")
+
+	return nil
+}
+
+// runWorker is a long-running function that will continually call the
+// processNextWorkItem function in order to read and process a message on the
+// workqueue.
+func (c *SchedController) runWorker() {
+	for c.processNextWorkItem() {
+	}
+}
+
+// processNextWorkItem will read a single work item off the workqueue and
+// attempt to process it, by calling the syncHandler.
+func (c *SchedController) processNextWorkItem() bool {
+	obj, shutdown := c.workqueue.Get()
+
+	if shutdown {
+		return false
+	}
+
+	// We wrap this block in a func so we can defer c.workqueue.Done.
+	err := func(obj interface{}) error {
+		// We call Done here so the workqueue knows we have finished
+		// processing this item. We also must remember to call Forget if we
+		// do not want this work item being re-queued. For example, we do
+		// not call Forget if a transient error occurs, instead the item is
+		// put back on the workqueue and attempted again after a back-off
+		// period.
+		defer c.workqueue.Done(obj)
+		var key string
+		var ok bool
+		// We expect strings to come off the workqueue. These are of the
+		// form namespace/name. We do this as the delayed nature of the
+		// workqueue means the items in the informer cache may actually be
+		// more up to date that when the item was initially put onto the
+		// workqueue.
+		if key, ok = obj.(string); !ok {
+			// As the item in the workqueue is actually invalid, we call
+			// Forget here else we'd go into a loop of attempting to
+			// process a work item that is invalid.
+			c.workqueue.Forget(obj)
+			runtime.HandleError(fmt.Errorf("expected string in workqueue but got %#v", obj))
+			return nil
+		}
+		// Run the syncHandler, passing it the namespace/name string of the
+		// ZFSSnapshotSchedule resource to be synced.
+		if err := c.syncHandler(key); err != nil {
+			// Put the item back on the workqueue to handle any transient errors.
+			c.workqueue.AddRateLimited(key)
+			return fmt.Errorf("error syncing '%s': %s, requeuing", key, err.Error())
+		}
+		// Finally, if no error occurs we Forget this item so it does not
+		// get queued again until another change happens.
+		c.workqueue.Forget(obj)
+		klog.Infof("Successfully synced '%s'", key)
+		return nil
+	}(obj)
+
+	if err != nil {
+		runtime.HandleError(err)
+		return true
+	}
+
+	return true
+}
@@ -0,0 +1,242 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	clientset "github.com/openebs/zfs-localpv/pkg/generated/clientset/internalclientset"
+	informers "github.com/openebs/zfs-localpv/pkg/generated/informer/externalversions"
+	"github.com/openebs/zfs-localpv/pkg/zfs"
+	"github.com/openebs/zfs-localpv/pkg/zfs/zfstest"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeinformers "k8s.io/client-go/informers"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	testNamespace = "openebs"
+	testNode      = "node-1"
+)
+
+// testEnv is the node the schedule controller runs on
+// along with the kubernetes API server
+type testEnv struct {
+	cs      clientset.Interface
+	c       *SchedController
+	factory informers.SharedInformerFactory
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	env := &testEnv{}
+	oldNode, oldNamespace := zfs.NodeID, zfs.OpenEBSNamespace
+	zfs.NodeID, zfs.OpenEBSNamespace = testNode, testNamespace
+	t.Cleanup(func() { zfs.NodeID, zfs.OpenEBSNamespace = oldNode, oldNamespace })
+
+	env.cs = zfstest.NewAPIServer(t).Clientset()
+	env.factory = informers.NewSharedInformerFactory(env.cs, 0)
+	c, err := NewSchedControllerBuilder().
+		withOpenEBSClient(env.cs).
+		withSchedLister(env.factory).
+		withPVLister(kubeinformers.NewSharedInformerFactory(k8sfake.NewSimpleClientset(), 0)).
+		withWorkqueueRateLimiting().
+		Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	c.recorder = record.NewFakeRecorder(10)
+	t.Cleanup(c.workqueue.ShutDown)
+	env.c = c
+	return env
+}
+
+// createVolume creates the Ready ZFSVolume of the node with the labels
+func (env *testEnv) createVolume(t *testing.T, name string, labels map[string]string) {
+	t.Helper()
+	vol := &apis.ZFSVolume{}
+	vol.Name = name
+	vol.Namespace = testNamespace
+	vol.Labels = labels
+	vol.Spec.OwnerNodeID = testNode
+	vol.Spec.PoolName = "pool"
+	vol.Spec.Capacity = "1073741824"
+	vol.Status.State = zfs.ZFSStatusReady
+	if _, err := env.cs.ZfsV1().ZFSVolumes(testNamespace).Create(context.TODO(), vol, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Create(%s) error = %v", name, err)
+	}
+}
+
+// sync runs the controller on the schedule with the listers
+// up to date with the API server
+func (env *testEnv) sync(t *testing.T) error {
+	t.Helper()
+	ctx := context.TODO()
+	var objs []interface{}
+	scheds, err := env.cs.ZfsV1().ZFSSnapshotSchedules(testNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	for i := range scheds.Items {
+		objs = append(objs, &scheds.Items[i])
+	}
+	if err := env.factory.Zfs().V1().ZFSSnapshotSchedules().Informer().GetIndexer().Replace(objs, ""); err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+	objs = nil
+	vols, err := env.cs.ZfsV1().ZFSVolumes(testNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	for i := range vols.Items {
+		objs = append(objs, &vols.Items[i])
+	}
+	if err := env.factory.Zfs().V1().ZFSVolumes().Informer().GetIndexer().Replace(objs, ""); err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+	objs = nil
+	snaps, err := env.cs.ZfsV1().ZFSSnapshots(testNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	for i := range snaps.Items {
+		objs = append(objs, &snaps.Items[i])
+	}
+	if err := env.factory.Zfs().V1().ZFSSnapshots().Informer().GetIndexer().Replace(objs, ""); err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+	return env.c.syncHandler(testNamespace + "/default")
+}
+
+// snapshots returns the volume/schedule of the snapshots present
+func (env *testEnv) snapshots(t *testing.T) []string {
+	t.Helper()
+	snaps, err := env.cs.ZfsV1().ZFSSnapshots(testNamespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	var names []string
+	for _, snap := range snaps.Items {
+		names = append(names, snap.Labels[zfs.ZFSVolKey]+"/"+snap.Labels[zfs.ZFSSnapScheduleNameKey])
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (env *testEnv) getSchedule(t *testing.T) *apis.ZFSSnapshotSchedule {
+	t.Helper()
+	sched, err := env.cs.ZfsV1().ZFSSnapshotSchedules(testNamespace).Get(context.TODO(), "default", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get(default) error = %v", err)
+	}
+	return sched
+}
+
+func (env *testEnv) updateSchedule(t *testing.T, sched *apis.ZFSSnapshotSchedule) {
+	t.Helper()
+	if _, err := env.cs.ZfsV1().ZFSSnapshotSchedules(testNamespace).Update(context.TODO(), sched, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Update(default) error = %v", err)
+	}
+}
+
+func TestSyncSched(t *testing.T) {
+	env := newTestEnv(t)
+	env.createVolume(t, "pvc-1", map[string]string{"app": "db"})
+	env.createVolume(t, "pvc-2", map[string]string{"app": "web"})
+
+	sched := &apis.ZFSSnapshotSchedule{}
+	sched.Name = "default"
+	sched.Namespace = testNamespace
+	sched.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}
+	sched.Spec.Schedules = []apis.SnapshotSchedule{
+		{Name: "hourly", Interval: metav1.Duration{Duration: time.Hour}, Keep: 2},
+		{Name: "daily", Interval: metav1.Duration{Duration: 24 * time.Hour}, Keep: 1},
+	}
+	if _, err := env.cs.ZfsV1().ZFSSnapshotSchedules(testNamespace).Create(context.TODO(), sched, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Create(default) error = %v", err)
+	}
+
+	// the snapshots of the selected volume are taken
+	if err := env.sync(t); err != nil {
+		t.Fatalf("syncHandler(default) error = %v", err)
+	}
+	if got, want := env.snapshots(t), []string{"pvc-1/daily", "pvc-1/hourly"}; !slices.Equal(got, want) {
+		t.Errorf("snapshots = %v, want %v", got, want)
+	}
+	sched = env.getSchedule(t)
+	if len(sched.Finalizers) != 1 || sched.Finalizers[0] != zfs.ZFSFinalizer {
+		t.Errorf("schedule finalizers = %v, want [%s]", sched.Finalizers, zfs.ZFSFinalizer)
+	}
+	if len(sched.Status.Nodes) != 1 || sched.Status.Nodes[0].NodeID != testNode || sched.Status.Nodes[0].Volumes != 1 {
+		t.Errorf("schedule status = %+v, want 1 volume on %s", sched.Status, testNode)
+	}
+
+	// the snapshots of the schedule removed from the spec are pruned
+	sched.Spec.Schedules = sched.Spec.Schedules[:1]
+	env.updateSchedule(t, sched)
+	if err := env.sync(t); err != nil {
+		t.Fatalf("syncHandler(default) of removed schedule error = %v", err)
+	}
+	if got, want := env.snapshots(t), []string{"pvc-1/hourly"}; !slices.Equal(got, want) {
+		t.Errorf("snapshots after removing the schedule = %v, want %v", got, want)
+	}
+
+	// the snapshots of the volume which is no longer selected are pruned
+	vol, err := env.cs.ZfsV1().ZFSVolumes(testNamespace).Get(context.TODO(), "pvc-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get(pvc-1) error = %v", err)
+	}
+	vol.Labels["app"] = "web"
+	if _, err := env.cs.ZfsV1().ZFSVolumes(testNamespace).Update(context.TODO(), vol, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Update(pvc-1) error = %v", err)
+	}
+	if err := env.sync(t); err != nil {
+		t.Fatalf("syncHandler(default) of unselected volume error = %v", err)
+	}
+	if got := env.snapshots(t); len(got) != 0 {
+		t.Errorf("snapshots of the unselected volume = %v, want none", got)
+	}
+
+	// the deleted schedule deletes all of its snapshots
+	sched = env.getSchedule(t)
+	sched.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+	env.updateSchedule(t, sched)
+	if err := env.sync(t); err != nil {
+		t.Fatalf("syncHandler(default) error = %v", err)
+	}
+	if got, want := env.snapshots(t), []string{"pvc-1/hourly", "pvc-2/hourly"}; !slices.Equal(got, want) {
+		t.Errorf("snapshots = %v, want %v", got, want)
+	}
+	if err := env.cs.ZfsV1().ZFSSnapshotSchedules(testNamespace).Delete(context.TODO(), "default", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Delete(default) error = %v", err)
+	}
+	if err := env.sync(t); err != nil {
+		t.Fatalf("syncHandler(default) of deleted schedule error = %v", err)
+	}
+	if got := env.snapshots(t); len(got) != 0 {
+		t.Errorf("snapshots of the deleted schedule = %v, want none", got)
+	}
+	if _, err := env.cs.ZfsV1().ZFSSnapshotSchedules(testNamespace).Get(context.TODO(), "default", metav1.GetOptions{}); !k8serror.IsNotFound(err) {
+		t.Errorf("Get(default) of deleted schedule error = %v, want not found", err)
+	}
+}
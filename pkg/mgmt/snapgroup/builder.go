@@ -0,0 +1,134 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapgroup
+
+import (
+	clientset "github.com/openebs/zfs-localpv/pkg/generated/clientset/internalclientset"
+	openebsScheme "github.com/openebs/zfs-localpv/pkg/generated/clientset/internalclientset/scheme"
+	informers "github.com/openebs/zfs-localpv/pkg/generated/informer/externalversions"
+	listers "github.com/openebs/zfs-localpv/pkg/generated/lister/zfs/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+)
+
+const controllerAgentName = "zfssnapgroup-controller"
+
+// GrpController is the controller implementation for ZFSSnapshotGroup resources
+type GrpController struct {
+	// kubeclientset is a standard kubernetes clientset
+	kubeclientset kubernetes.Interface
+
+	// clientset is a openebs custom resource package generated for custom API group.
+	clientset clientset.Interface
+
+	grpLister listers.ZFSSnapshotGroupLister
+
+	// grpSynced is used for caches sync to get populated
+	grpSynced cache.InformerSynced
+
+	// workqueue is a rate limited work queue. This is used to queue work to be
+	// processed instead of performing it as soon as a change happens. This
+	// means we can ensure we only process a fixed amount of resources at a
+	// time, and makes it easy to ensure we are never processing the same item
+	// simultaneously in two different workers.
+	workqueue workqueue.RateLimitingInterface
+
+	// recorder is an event recorder for recording Event resources to the
+	// Kubernetes API.
+	recorder record.EventRecorder
+}
+
+// GrpControllerBuilder is the builder object for controller.
+type GrpControllerBuilder struct {
+	GrpController *GrpController
+}
+
+// NewGrpControllerBuilder returns an empty instance of controller builder.
+func NewGrpControllerBuilder() *GrpControllerBuilder {
+	return &GrpControllerBuilder{
+		GrpController: &GrpController{},
+	}
+}
+
+// withKubeClient fills kube client to controller object.
+func (cb *GrpControllerBuilder) withKubeClient(ks kubernetes.Interface) *GrpControllerBuilder {
+	cb.GrpController.kubeclientset = ks
+	return cb
+}
+
+// withOpenEBSClient fills openebs client to controller object.
+func (cb *GrpControllerBuilder) withOpenEBSClient(cs clientset.Interface) *GrpControllerBuilder {
+	cb.GrpController.clientset = cs
+	return cb
+}
+
+// withGrpLister fills snapshot group lister to controller object.
+func (cb *GrpControllerBuilder) withGrpLister(sl informers.SharedInformerFactory) *GrpControllerBuilder {
+	grpInformer := sl.Zfs().V1().ZFSSnapshotGroups()
+	cb.GrpController.grpLister = grpInformer.Lister()
+	return cb
+}
+
+// withGrpSynced adds object sync information in cache to controller object.
+func (cb *GrpControllerBuilder) withGrpSynced(sl informers.SharedInformerFactory) *GrpControllerBuilder {
+	grpInformer := sl.Zfs().V1().ZFSSnapshotGroups()
+	cb.GrpController.grpSynced = grpInformer.Informer().HasSynced
+	return cb
+}
+
+// withWorkqueue adds workqueue to controller object.
+func (cb *GrpControllerBuilder) withWorkqueueRateLimiting() *GrpControllerBuilder {
+	cb.GrpController.workqueue = workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "Grp")
+	return cb
+}
+
+// withRecorder adds recorder to controller object.
+func (cb *GrpControllerBuilder) withRecorder(ks kubernetes.Interface) *GrpControllerBuilder {
+	klog.Infof("Creating event broadcaster")
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(klog.Infof)
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: ks.CoreV1().Events("")})
+	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: controllerAgentName})
+	cb.GrpController.recorder = recorder
+	return cb
+}
+
+// withEventHandler adds event handlers controller object.
+func (cb *GrpControllerBuilder) withEventHandler(cvcInformerFactory informers.SharedInformerFactory) *GrpControllerBuilder {
+	cvcInformer := cvcInformerFactory.Zfs().V1().ZFSSnapshotGroups()
+	// Set up an event handler for when ZFSSnapshotGroup resources change
+	cvcInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    cb.GrpController.addGrp,
+		UpdateFunc: cb.GrpController.updateGrp,
+	})
+	return cb
+}
+
+// Build returns a controller instance.
+func (cb *GrpControllerBuilder) Build() (*GrpController, error) {
+	err := openebsScheme.AddToScheme(scheme.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	return cb.GrpController, nil
+}
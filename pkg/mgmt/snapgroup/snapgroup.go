@@ -0,0 +1,228 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapgroup
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	zfs "github.com/openebs/zfs-localpv/pkg/zfs"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// isDeletionCandidate checks if a zfs snapshot group is a deletion candidate.
+func (c *GrpController) isDeletionCandidate(grp *apis.ZFSSnapshotGroup) bool {
+	return grp.ObjectMeta.DeletionTimestamp != nil
+}
+
+// syncHandler compares the actual state with the desired, and attempts to
+// converge the two.
+func (c *GrpController) syncHandler(key string) error {
+	// Convert the namespace/name string into a distinct namespace and name
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		runtime.HandleError(fmt.Errorf("invalid resource key: %s", key))
+		return nil
+	}
+
+	// Get the grp resource with this namespace/name
+	grp, err := c.grpLister.ZFSSnapshotGroups(namespace).Get(name)
+	if k8serror.IsNotFound(err) {
+		runtime.HandleError(fmt.Errorf("zfs snapshot group '%s' has been deleted", key))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	grpCopy := grp.DeepCopy()
+	err = c.syncGrp(grpCopy)
+	return err
+}
+
+// enqueueGrp takes a ZFSSnapshotGroup resource and converts it into a namespace/name
+// string which is then put onto the work queue. This method should *not* be
+// passed resources of any type other than ZFSSnapshotGroup.
+func (c *GrpController) enqueueGrp(obj interface{}) {
+	var key string
+	var err error
+	if key, err = cache.MetaNamespaceKeyFunc(obj); err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	c.workqueue.Add(key)
+}
+
+// syncGrp is the function which tries to converge to a desired state for the
+// ZFSSnapshotGroup
+func (c *GrpController) syncGrp(grp *apis.ZFSSnapshotGroup) error {
+	var err error
+	// ZFSSnapshotGroup should be deleted. Check if deletion timestamp is set
+	if c.isDeletionCandidate(grp) {
+		// the snapshots are destroyed along with their ZFSSnapshots
+		err = zfs.DeleteGroupSnapshots(grp)
+		if err == nil {
+			err = zfs.RemoveSnapGroupFinalizer(grp)
+		}
+	} else if grp.Status.State != zfs.ZFSStatusReady && grp.Status.State != zfs.ZFSStatusFailed {
+		// if status is not Ready or Failed then it means we are
+		// taking the snapshots of the group.
+		err = zfs.CreateSnapshotGroup(grp)
+		if errors.Is(err, zfs.ErrSnapshotGroupInvalid) {
+			klog.Errorf("snapshot group %s failed err %v", grp.Name, err)
+			return zfs.UpdateSnapGroupInfo(grp, zfs.ZFSStatusFailed, err)
+		}
+		if err == nil {
+			klog.Infof("snapshot group %s done %s %v", grp.Name, grp.Spec.PoolName, grp.Spec.VolumeNames)
+			err = zfs.UpdateSnapGroupInfo(grp, zfs.ZFSStatusReady, nil)
+		}
+	}
+	return err
+}
+
+// addGrp is the add event handler for ZFSSnapshotGroup
+func (c *GrpController) addGrp(obj interface{}) {
+	grp, ok := obj.(*apis.ZFSSnapshotGroup)
+	if !ok {
+		runtime.HandleError(fmt.Errorf("Couldn't get snapshot group object %#v", obj))
+		return
+	}
+
+	if zfs.NodeID != grp.Spec.OwnerNodeID {
+		return
+	}
+	klog.Infof("Got add event for snapshot group %s %s %v", grp.Name, grp.Spec.PoolName, grp.Spec.VolumeNames)
+	c.enqueueGrp(grp)
+}
+
+// updateGrp is the update event handler for ZFSSnapshotGroup
+func (c *GrpController) updateGrp(oldObj, newObj interface{}) {
+
+	newGrp, ok := newObj.(*apis.ZFSSnapshotGroup)
+	if !ok {
+		runtime.HandleError(fmt.Errorf("Couldn't get snapshot group object %#v", newGrp))
+		return
+	}
+
+	if zfs.NodeID != newGrp.Spec.OwnerNodeID {
+		return
+	}
+
+	// update on snapshot group CR does not make sense unless it is a deletion candidate
+	if c.isDeletionCandidate(newGrp) {
+		klog.Infof("Got update event for snapshot group %s %s %v", newGrp.Name, newGrp.Spec.PoolName, newGrp.Spec.VolumeNames)
+		c.enqueueGrp(newGrp)
+	}
+}
+
+// Run will set up the event handlers for types we are interested in, as well
+// as syncing informer caches and starting workers. It will block until stopCh
+// is closed, at which point it will shutdown the workqueue and wait for
+// workers to finish processing their current work items.
+func (c *GrpController) Run(threadiness int, stopCh <-chan struct{}) error {
+	defer runtime.HandleCrash()
+	defer c.workqueue.ShutDown()
+
+	// Start the informer factories to begin populating the informer caches
+	klog.Info("Starting snapshot group controller")
+
+	// Wait for the k8s caches to be synced before starting workers
+	klog.Info("Waiting for informer caches to sync")
+	if ok := cache.WaitForCacheSync(stopCh, c.grpSynced); !ok {
+		return fmt.Errorf("failed to wait for caches to sync")
+	}
+	klog.Info("Starting snapshot group workers")
+	// Launch worker to process ZFSSnapshotGroup resources
+	// Threadiness will decide the number of workers you want to launch to process work items from queue
+	for i := 0; i < threadiness; i++ {
+		go wait.Until(c.runWorker, time.Second, stopCh)
+	}
+
+	klog.Info("Started snapshot group workers")
+	<-stopCh
+	klog.Info("Shutting down snapshot group workers")
+
+	return nil
+}
+
+// runWorker is a long-running function that will continually call the
+// processNextWorkItem function in order to read and process a message on the
+// workqueue.
+func (c *GrpController) runWorker() {
+	for c.processNextWorkItem() {
+	}
+}
+
+// processNextWorkItem will read a single work item off the workqueue and
+// attempt to process it, by calling the syncHandler.
+func (c *GrpController) processNextWorkItem() bool {
+	obj, shutdown := c.workqueue.Get()
+
+	if shutdown {
+		return false
+	}
+
+	// We wrap this block in a func so we can defer c.workqueue.Done.
+	err := func(obj interface{}) error {
+		// We call Done here so the workqueue knows we have finished
+		// processing this item. We also must remember to call Forget if we
+		// do not want this work item being re-queued. For example, we do
+		// not call Forget if a transient error occurs, instead the item is
+		// put back on the workqueue and attempted again after a back-off
+		// period.
+		defer c.workqueue.Done(obj)
+		var key string
+		var ok bool
+		// We expect strings to come off the workqueue. These are of the
+		// form namespace/name. We do this as the delayed nature of the
+		// workqueue means the items in the informer cache may actually be
+		// more up to date that when the item was initially put onto the
+		// workqueue.
+		if key, ok = obj.(string); !ok {
+			// As the item in the workqueue is actually invalid, we call
+			// Forget here else we'd go into a loop of attempting to
+			// process a work item that is invalid.
+			c.workqueue.Forget(obj)
+			runtime.HandleError(fmt.Errorf("expected string in workqueue but got %#v", obj))
+			return nil
+		}
+		// Run the syncHandler, passing it the namespace/name string of the
+		// ZFSSnapshotGroup resource to be synced.
+		if err := c.syncHandler(key); err != nil {
+			// Put the item back on the workqueue to handle any transient errors.
+			c.workqueue.AddRateLimited(key)
+			return fmt.Errorf("error syncing '%s': %s, requeuing", key, err.Error())
+		}
+		// Finally, if no error occurs we Forget this item so it does not
+		// get queued again until another change happens.
+		c.workqueue.Forget(obj)
+		klog.Infof("Successfully synced '%s'", key)
+		return nil
+	}(obj)
+
+	if err != nil {
+		runtime.HandleError(err)
+		return true
+	}
+
+	return true
+}
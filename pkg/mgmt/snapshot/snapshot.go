@@ -17,6 +17,7 @@ limitations under the License.
 package snapshot
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
@@ -81,6 +82,10 @@ func (c *SnapController) syncSnap(snap *apis.ZFSSnapshot) error {
 		if len(userFin) == 0 {
 			// destroy only if other finalizers have been removed
 			err = zfs.DestroySnapshot(snap)
+			var held *zfs.SnapshotHeldError
+			if errors.As(err, &held) {
+				return c.blockSnapDeletion(snap, held)
+			}
 			if err == nil {
 				err = zfs.RemoveSnapFinalizer(snap)
 			}
@@ -100,6 +105,22 @@ func (c *SnapController) syncSnap(snap *apis.ZFSSnapshot) error {
 	return err
 }
 
+// blockSnapDeletion records the user holds which block the destroy of the
+// snapshot in its status, and requeues it to be retried after a while
+// instead of failing in a loop until the holds are released
+func (c *SnapController) blockSnapDeletion(snap *apis.ZFSSnapshot, held *zfs.SnapshotHeldError) error {
+	klog.Infof("snapshot %s can not be destroyed yet, %v", snap.Name, held)
+	if err := zfs.UpdateSnapDeletionBlocked(snap, held.Holds); err != nil {
+		return err
+	}
+	key, err := cache.MetaNamespaceKeyFunc(snap)
+	if err != nil {
+		return err
+	}
+	c.workqueue.AddAfter(key, zfs.SnapshotHeldRetryInterval)
+	return nil
+}
+
 // addSnap is the add event handler for ZFSSnapshot
 func (c *SnapController) addSnap(obj interface{}) {
 	snap, ok := obj.(*apis.ZFSSnapshot)
@@ -0,0 +1,198 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	clientset "github.com/openebs/zfs-localpv/pkg/generated/clientset/internalclientset"
+	informers "github.com/openebs/zfs-localpv/pkg/generated/informer/externalversions"
+	"github.com/openebs/zfs-localpv/pkg/zfs"
+	"github.com/openebs/zfs-localpv/pkg/zfs/zfstest"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	testNamespace = "openebs"
+	testNode      = "node-1"
+)
+
+// testEnv is the node the snapshot controller runs on, with its zfs pool
+// and the kubernetes API server
+type testEnv struct {
+	fake    *zfstest.ZFS
+	cs      clientset.Interface
+	c       *SnapController
+	indexer cache.Indexer
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	env := &testEnv{fake: zfstest.NewZFS()}
+	env.fake.AddPool("pool", 10<<30)
+	old := zfs.SetCommandRunner(env.fake)
+	t.Cleanup(func() { zfs.SetCommandRunner(old) })
+
+	oldNode, oldNamespace := zfs.NodeID, zfs.OpenEBSNamespace
+	zfs.NodeID, zfs.OpenEBSNamespace = testNode, testNamespace
+	t.Cleanup(func() { zfs.NodeID, zfs.OpenEBSNamespace = oldNode, oldNamespace })
+
+	env.cs = zfstest.NewAPIServer(t).Clientset()
+	factory := informers.NewSharedInformerFactory(env.cs, 0)
+	c, err := NewSnapControllerBuilder().
+		withOpenEBSClient(env.cs).
+		withSnapLister(factory).
+		withWorkqueueRateLimiting().
+		Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	t.Cleanup(c.workqueue.ShutDown)
+	env.c = c
+	env.indexer = factory.Zfs().V1().ZFSSnapshots().Informer().GetIndexer()
+
+	if _, err := env.fake.Output("zfs", "create", "pool/pvc-1"); err != nil {
+		t.Fatalf("zfs create error = %v", err)
+	}
+	return env
+}
+
+// createSnapshot creates the ZFSSnapshot of the volume pvc-1
+func (env *testEnv) createSnapshot(t *testing.T, name string) {
+	t.Helper()
+	snap := &apis.ZFSSnapshot{}
+	snap.Name = name
+	snap.Namespace = testNamespace
+	snap.Labels = map[string]string{zfs.ZFSVolKey: "pvc-1"}
+	snap.Spec.OwnerNodeID = testNode
+	snap.Spec.PoolName = "pool"
+	snap.Status.State = zfs.ZFSStatusPending
+	if _, err := env.cs.ZfsV1().ZFSSnapshots(testNamespace).Create(context.TODO(), snap, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Create(%s) error = %v", name, err)
+	}
+}
+
+// sync runs the controller on the snapshot as the informer has last seen it
+func (env *testEnv) sync(t *testing.T, name string) error {
+	t.Helper()
+	if err := env.indexer.Update(env.getSnapshot(t, name)); err != nil {
+		t.Fatalf("Update(%s) error = %v", name, err)
+	}
+	return env.c.syncHandler(testNamespace + "/" + name)
+}
+
+func (env *testEnv) getSnapshot(t *testing.T, name string) *apis.ZFSSnapshot {
+	t.Helper()
+	snap, err := env.cs.ZfsV1().ZFSSnapshots(testNamespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get(%s) error = %v", name, err)
+	}
+	return snap
+}
+
+func TestSyncSnap(t *testing.T) {
+	env := newTestEnv(t)
+
+	env.createSnapshot(t, "snap-1")
+	if err := env.sync(t, "snap-1"); err != nil {
+		t.Fatalf("syncHandler(snap-1) error = %v", err)
+	}
+	snap := env.getSnapshot(t, "snap-1")
+	if snap.Status.State != zfs.ZFSStatusReady {
+		t.Errorf("snapshot state = %s, want %s", snap.Status.State, zfs.ZFSStatusReady)
+	}
+	if len(snap.Finalizers) != 1 || snap.Finalizers[0] != zfs.ZFSFinalizer {
+		t.Errorf("snapshot finalizers = %v, want [%s]", snap.Finalizers, zfs.ZFSFinalizer)
+	}
+	if _, ok := env.fake.Dataset("pool/pvc-1@snap-1"); !ok {
+		t.Errorf("snapshot snap-1 is not present")
+	}
+
+	// the deleted snapshot is destroyed and goes away
+	if err := env.cs.ZfsV1().ZFSSnapshots(testNamespace).Delete(context.TODO(), "snap-1", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Delete(snap-1) error = %v", err)
+	}
+	if err := env.sync(t, "snap-1"); err != nil {
+		t.Fatalf("syncHandler(snap-1) of deleted snapshot error = %v", err)
+	}
+	if _, ok := env.fake.Dataset("pool/pvc-1@snap-1"); ok {
+		t.Errorf("deleted snapshot is present")
+	}
+	if _, err := env.cs.ZfsV1().ZFSSnapshots(testNamespace).Get(context.TODO(), "snap-1", metav1.GetOptions{}); !k8serror.IsNotFound(err) {
+		t.Errorf("Get(snap-1) of deleted snapshot error = %v, want not found", err)
+	}
+}
+
+func TestSyncSnapHeld(t *testing.T) {
+	env := newTestEnv(t)
+
+	env.createSnapshot(t, "snap-1")
+	if err := env.sync(t, "snap-1"); err != nil {
+		t.Fatalf("syncHandler(snap-1) error = %v", err)
+	}
+	if _, err := env.fake.Output("zfs", "hold", "keep", "pool/pvc-1@snap-1"); err != nil {
+		t.Fatalf("zfs hold error = %v", err)
+	}
+	if err := env.cs.ZfsV1().ZFSSnapshots(testNamespace).Delete(context.TODO(), "snap-1", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Delete(snap-1) error = %v", err)
+	}
+
+	// the held snapshot is kept with its holds recorded
+	if err := env.sync(t, "snap-1"); err != nil {
+		t.Fatalf("syncHandler(snap-1) of held snapshot error = %v", err)
+	}
+	snap := env.getSnapshot(t, "snap-1")
+	if !reflect.DeepEqual(snap.Status.DeletionBlockedBy, []string{"keep"}) {
+		t.Errorf("snapshot deletion blocked by %v, want [keep]", snap.Status.DeletionBlockedBy)
+	}
+	if _, ok := env.fake.Dataset("pool/pvc-1@snap-1"); !ok {
+		t.Errorf("held snapshot has been destroyed")
+	}
+
+	// it goes away once the hold has been released
+	if _, err := env.fake.Output("zfs", "release", "keep", "pool/pvc-1@snap-1"); err != nil {
+		t.Fatalf("zfs release error = %v", err)
+	}
+	if err := env.sync(t, "snap-1"); err != nil {
+		t.Fatalf("syncHandler(snap-1) of released snapshot error = %v", err)
+	}
+	if _, ok := env.fake.Dataset("pool/pvc-1@snap-1"); ok {
+		t.Errorf("released snapshot is present")
+	}
+}
+
+func TestAddSnapOtherNode(t *testing.T) {
+	env := newTestEnv(t)
+
+	snap := &apis.ZFSSnapshot{}
+	snap.Name = "snap-1"
+	snap.Namespace = testNamespace
+	snap.Spec.OwnerNodeID = "node-2"
+	env.c.addSnap(snap)
+	if n := env.c.workqueue.Len(); n != 0 {
+		t.Errorf("workqueue has %d snapshots of the other node", n)
+	}
+	snap.Spec.OwnerNodeID = testNode
+	env.c.addSnap(snap)
+	if n := env.c.workqueue.Len(); n != 1 {
+		t.Errorf("workqueue has %d snapshots, want 1", n)
+	}
+}
@@ -0,0 +1,265 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volume
+
+import (
+	"context"
+	"testing"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	clientset "github.com/openebs/zfs-localpv/pkg/generated/clientset/internalclientset"
+	informers "github.com/openebs/zfs-localpv/pkg/generated/informer/externalversions"
+	"github.com/openebs/zfs-localpv/pkg/zfs"
+	"github.com/openebs/zfs-localpv/pkg/zfs/zfstest"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	testNamespace = "openebs"
+	testNode      = "node-1"
+)
+
+// testEnv is the node the volume controller runs on, with its zfs pool
+// and the kubernetes API server
+type testEnv struct {
+	fake    *zfstest.ZFS
+	cs      clientset.Interface
+	c       *ZVController
+	indexer cache.Indexer
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	env := &testEnv{fake: zfstest.NewZFS()}
+	env.fake.AddPool("pool", 10<<30)
+	old := zfs.SetCommandRunner(env.fake)
+	t.Cleanup(func() { zfs.SetCommandRunner(old) })
+
+	oldNode, oldNamespace := zfs.NodeID, zfs.OpenEBSNamespace
+	zfs.NodeID, zfs.OpenEBSNamespace = testNode, testNamespace
+	t.Cleanup(func() { zfs.NodeID, zfs.OpenEBSNamespace = oldNode, oldNamespace })
+
+	env.cs = zfstest.NewAPIServer(t).Clientset()
+	factory := informers.NewSharedInformerFactory(env.cs, 0)
+	c, err := NewZVControllerBuilder().
+		withOpenEBSClient(env.cs).
+		withZVLister(factory).
+		withWorkqueueRateLimiting().
+		Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	t.Cleanup(c.workqueue.ShutDown)
+	env.c = c
+	env.indexer = factory.Zfs().V1().ZFSVolumes().Informer().GetIndexer()
+	return env
+}
+
+// createVolume creates the ZFSVolume pending to be provisioned on the node
+func (env *testEnv) createVolume(t *testing.T, name, snapName string) {
+	t.Helper()
+	vol := &apis.ZFSVolume{}
+	vol.Name = name
+	vol.Namespace = testNamespace
+	vol.Spec.OwnerNodeID = testNode
+	vol.Spec.PoolName = "pool"
+	vol.Spec.VolumeType = zfs.VolTypeDataset
+	vol.Spec.Capacity = "1073741824"
+	vol.Spec.FsType = "zfs"
+	vol.Spec.QuotaType = "refquota"
+	vol.Spec.Compression = "lz4"
+	vol.Spec.SnapName = snapName
+	vol.Status.State = zfs.ZFSStatusPending
+	if _, err := env.cs.ZfsV1().ZFSVolumes(testNamespace).Create(context.TODO(), vol, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Create(%s) error = %v", name, err)
+	}
+}
+
+// sync runs the controller on the volume as the informer has last seen it
+func (env *testEnv) sync(t *testing.T, name string) error {
+	t.Helper()
+	if err := env.indexer.Update(env.getVolume(t, name)); err != nil {
+		t.Fatalf("Update(%s) error = %v", name, err)
+	}
+	return env.c.syncHandler(testNamespace + "/" + name)
+}
+
+func (env *testEnv) getVolume(t *testing.T, name string) *apis.ZFSVolume {
+	t.Helper()
+	vol, err := env.cs.ZfsV1().ZFSVolumes(testNamespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get(%s) error = %v", name, err)
+	}
+	return vol
+}
+
+func (env *testEnv) property(t *testing.T, name, prop string) string {
+	t.Helper()
+	ds, ok := env.fake.Dataset(name)
+	if !ok {
+		t.Fatalf("dataset %s is not present", name)
+	}
+	return ds.Props[prop]
+}
+
+func TestSyncZV(t *testing.T) {
+	env := newTestEnv(t)
+
+	env.createVolume(t, "pvc-1", "")
+	if err := env.sync(t, "pvc-1"); err != nil {
+		t.Fatalf("syncHandler(pvc-1) error = %v", err)
+	}
+	vol := env.getVolume(t, "pvc-1")
+	if vol.Status.State != zfs.ZFSStatusReady {
+		t.Errorf("volume state = %s, want %s", vol.Status.State, zfs.ZFSStatusReady)
+	}
+	if len(vol.Finalizers) != 1 || vol.Finalizers[0] != zfs.ZFSFinalizer {
+		t.Errorf("volume finalizers = %v, want [%s]", vol.Finalizers, zfs.ZFSFinalizer)
+	}
+	if vol.Labels[zfs.ZFSNodeKey] != testNode {
+		t.Errorf("volume node label = %q, want %s", vol.Labels[zfs.ZFSNodeKey], testNode)
+	}
+	if got := env.property(t, "pool/pvc-1", "compression"); got != "lz4" {
+		t.Errorf("compression = %q, want lz4", got)
+	}
+
+	// the property changed on the ready volume is set on it
+	vol.Spec.Compression = "zstd"
+	if _, err := env.cs.ZfsV1().ZFSVolumes(testNamespace).Update(context.TODO(), vol, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Update(pvc-1) error = %v", err)
+	}
+	if err := env.sync(t, "pvc-1"); err != nil {
+		t.Fatalf("syncHandler(pvc-1) of changed volume error = %v", err)
+	}
+	if got := env.property(t, "pool/pvc-1", "compression"); got != "zstd" {
+		t.Errorf("compression after change = %q, want zstd", got)
+	}
+
+	// the deleted volume is destroyed and goes away
+	if err := env.cs.ZfsV1().ZFSVolumes(testNamespace).Delete(context.TODO(), "pvc-1", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Delete(pvc-1) error = %v", err)
+	}
+	if err := env.sync(t, "pvc-1"); err != nil {
+		t.Fatalf("syncHandler(pvc-1) of deleted volume error = %v", err)
+	}
+	if _, ok := env.fake.Dataset("pool/pvc-1"); ok {
+		t.Errorf("deleted volume is present")
+	}
+	if _, err := env.cs.ZfsV1().ZFSVolumes(testNamespace).Get(context.TODO(), "pvc-1", metav1.GetOptions{}); !k8serror.IsNotFound(err) {
+		t.Errorf("Get(pvc-1) of deleted volume error = %v, want not found", err)
+	}
+}
+
+func TestSyncZVClone(t *testing.T) {
+	env := newTestEnv(t)
+
+	env.createVolume(t, "pvc-1", "")
+	if err := env.sync(t, "pvc-1"); err != nil {
+		t.Fatalf("syncHandler(pvc-1) error = %v", err)
+	}
+	if _, err := env.fake.Output("zfs", "snapshot", "pool/pvc-1@snap-1"); err != nil {
+		t.Fatalf("zfs snapshot error = %v", err)
+	}
+
+	env.createVolume(t, "pvc-2", "pvc-1@snap-1")
+	if err := env.sync(t, "pvc-2"); err != nil {
+		t.Fatalf("syncHandler(pvc-2) error = %v", err)
+	}
+	if vol := env.getVolume(t, "pvc-2"); vol.Status.State != zfs.ZFSStatusReady {
+		t.Errorf("clone state = %s, want %s", vol.Status.State, zfs.ZFSStatusReady)
+	}
+	if ds, ok := env.fake.Dataset("pool/pvc-2"); !ok || ds.Origin != "pool/pvc-1@snap-1" {
+		t.Errorf("clone = %+v present %v, want origin pool/pvc-1@snap-1", ds, ok)
+	}
+
+	// the volume with a clone can not be destroyed until the clone is
+	if err := env.cs.ZfsV1().ZFSVolumes(testNamespace).Delete(context.TODO(), "pvc-2", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Delete(pvc-2) error = %v", err)
+	}
+	if err := env.sync(t, "pvc-2"); err != nil {
+		t.Fatalf("syncHandler(pvc-2) of deleted clone error = %v", err)
+	}
+	if _, ok := env.fake.Dataset("pool/pvc-2"); ok {
+		t.Errorf("deleted clone is present")
+	}
+	if _, ok := env.fake.Dataset("pool/pvc-1@snap-1"); !ok {
+		t.Errorf("snapshot of the deleted clone has been destroyed")
+	}
+}
+
+func TestSyncZVFailed(t *testing.T) {
+	env := newTestEnv(t)
+
+	env.createVolume(t, "pvc-1", "")
+	vol := env.getVolume(t, "pvc-1")
+	vol.Spec.PoolName = "missing"
+	if _, err := env.cs.ZfsV1().ZFSVolumes(testNamespace).Update(context.TODO(), vol, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Update(pvc-1) error = %v", err)
+	}
+	if err := env.sync(t, "pvc-1"); err != nil {
+		t.Fatalf("syncHandler(pvc-1) error = %v", err)
+	}
+	vol = env.getVolume(t, "pvc-1")
+	if vol.Status.State != zfs.ZFSStatusFailed {
+		t.Errorf("volume state = %s, want %s", vol.Status.State, zfs.ZFSStatusFailed)
+	}
+	if len(vol.Finalizers) != 0 {
+		t.Errorf("failed volume finalizers = %v, want none", vol.Finalizers)
+	}
+}
+
+func TestSyncZVUserFinalizer(t *testing.T) {
+	env := newTestEnv(t)
+
+	env.createVolume(t, "pvc-1", "")
+	if err := env.sync(t, "pvc-1"); err != nil {
+		t.Fatalf("syncHandler(pvc-1) error = %v", err)
+	}
+	vol := env.getVolume(t, "pvc-1")
+	vol.Finalizers = append(vol.Finalizers, "example.com/protect")
+	if _, err := env.cs.ZfsV1().ZFSVolumes(testNamespace).Update(context.TODO(), vol, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Update(pvc-1) error = %v", err)
+	}
+	if err := env.cs.ZfsV1().ZFSVolumes(testNamespace).Delete(context.TODO(), "pvc-1", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Delete(pvc-1) error = %v", err)
+	}
+	if err := env.sync(t, "pvc-1"); err == nil {
+		t.Errorf("syncHandler(pvc-1) with user finalizer should fail")
+	}
+	if _, ok := env.fake.Dataset("pool/pvc-1"); !ok {
+		t.Errorf("volume with user finalizer has been destroyed")
+	}
+}
+
+func TestAddZVOtherNode(t *testing.T) {
+	env := newTestEnv(t)
+
+	vol := &apis.ZFSVolume{}
+	vol.Name = "pvc-1"
+	vol.Namespace = testNamespace
+	vol.Spec.OwnerNodeID = "node-2"
+	env.c.addZV(vol)
+	if n := env.c.workqueue.Len(); n != 0 {
+		t.Errorf("workqueue has %d volumes of the other node", n)
+	}
+	vol.Spec.OwnerNodeID = testNode
+	env.c.addZV(vol)
+	if n := env.c.workqueue.Len(); n != 1 {
+		t.Errorf("workqueue has %d volumes, want 1", n)
+	}
+}
@@ -0,0 +1,159 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfsnode
+
+import (
+	"context"
+	"testing"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	clientset "github.com/openebs/zfs-localpv/pkg/generated/clientset/internalclientset"
+	informers "github.com/openebs/zfs-localpv/pkg/generated/informer/externalversions"
+	"github.com/openebs/zfs-localpv/pkg/zfs"
+	"github.com/openebs/zfs-localpv/pkg/zfs/zfstest"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	testNamespace = "openebs"
+	testNode      = "node-1"
+)
+
+// testEnv is the node the zfsnode controller runs on, with its zfs pool
+// and the kubernetes API server
+type testEnv struct {
+	fake    *zfstest.ZFS
+	cs      clientset.Interface
+	c       *NodeController
+	indexer cache.Indexer
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	env := &testEnv{fake: zfstest.NewZFS()}
+	env.fake.AddPool("pool", 10<<30)
+	old := zfs.SetCommandRunner(env.fake)
+	t.Cleanup(func() { zfs.SetCommandRunner(old) })
+
+	oldNode, oldNamespace := zfs.NodeID, zfs.OpenEBSNamespace
+	zfs.NodeID, zfs.OpenEBSNamespace = testNode, testNamespace
+	t.Cleanup(func() { zfs.NodeID, zfs.OpenEBSNamespace = oldNode, oldNamespace })
+
+	env.cs = zfstest.NewAPIServer(t).Clientset()
+	factory := informers.NewSharedInformerFactory(env.cs, 0)
+	c, err := NewNodeControllerBuilder().
+		withOpenEBSClient(env.cs).
+		withNodeLister(factory).
+		withWorkqueueRateLimiting().
+		withOwnerReference(metav1.OwnerReference{APIVersion: "v1", Kind: "Node", Name: testNode, UID: "uid-1"}).
+		Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	t.Cleanup(c.workqueue.ShutDown)
+	env.c = c
+	env.indexer = factory.Zfs().V1().ZFSNodes().Informer().GetIndexer()
+	return env
+}
+
+// sync runs the controller on the node as the informer has last seen it
+func (env *testEnv) sync(t *testing.T) error {
+	t.Helper()
+	if node, err := env.cs.ZfsV1().ZFSNodes(testNamespace).Get(context.TODO(), testNode, metav1.GetOptions{}); err == nil {
+		if err := env.indexer.Update(node); err != nil {
+			t.Fatalf("Update(%s) error = %v", testNode, err)
+		}
+	}
+	return env.c.syncHandler(testNamespace + "/" + testNode)
+}
+
+func (env *testEnv) getPools(t *testing.T) []apis.Pool {
+	t.Helper()
+	node, err := env.cs.ZfsV1().ZFSNodes(testNamespace).Get(context.TODO(), testNode, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get(%s) error = %v", testNode, err)
+	}
+	return node.Pools
+}
+
+func TestSyncNode(t *testing.T) {
+	env := newTestEnv(t)
+
+	// the node is created along with its pools
+	if err := env.sync(t); err != nil {
+		t.Fatalf("syncHandler() error = %v", err)
+	}
+	node, err := env.cs.ZfsV1().ZFSNodes(testNamespace).Get(context.TODO(), testNode, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get(%s) error = %v", testNode, err)
+	}
+	if len(node.OwnerReferences) != 1 || node.OwnerReferences[0].Name != testNode {
+		t.Errorf("node owner references = %v, want node %s", node.OwnerReferences, testNode)
+	}
+	pools := env.getPools(t)
+	if len(pools) != 1 || pools[0].Name != "pool" || pools[0].Free.Value() != 10<<30 {
+		t.Fatalf("node pools = %+v, want pool with 10Gi free", pools)
+	}
+
+	// the space used by the volumes is reported
+	if _, err := env.fake.Output("zfs", "create", "-o", "refreservation=1073741824", "pool/pvc-1"); err != nil {
+		t.Fatalf("zfs create error = %v", err)
+	}
+	if err := env.sync(t); err != nil {
+		t.Fatalf("syncHandler() of used pool error = %v", err)
+	}
+	if pools := env.getPools(t); pools[0].Free.Value() != 9<<30 {
+		t.Errorf("pool free = %d, want %d", pools[0].Free.Value(), int64(9<<30))
+	}
+
+	// the pools added and the change of their health are reported
+	env.fake.AddPool("dr-pool", 10<<30)
+	env.fake.SetPoolHealth("pool", "DEGRADED")
+	if err := env.sync(t); err != nil {
+		t.Fatalf("syncHandler() of added pool error = %v", err)
+	}
+	pools = env.getPools(t)
+	if len(pools) != 2 {
+		t.Fatalf("node pools = %+v, want 2 pools", pools)
+	}
+	for _, pool := range pools {
+		want := "ONLINE"
+		if pool.Name == "pool" {
+			want = "DEGRADED"
+		}
+		if pool.Health != want {
+			t.Errorf("pool %s health = %q, want %s", pool.Name, pool.Health, want)
+		}
+	}
+}
+
+func TestEnqueueNodeOtherNode(t *testing.T) {
+	env := newTestEnv(t)
+
+	node := &apis.ZFSNode{}
+	node.Name = "node-2"
+	node.Namespace = testNamespace
+	env.c.addNode(node)
+	if n := env.c.workqueue.Len(); n != 0 {
+		t.Errorf("workqueue has %d other nodes", n)
+	}
+	node.Name = testNode
+	env.c.addNode(node)
+	if n := env.c.workqueue.Len(); n != 1 {
+		t.Errorf("workqueue has %d nodes, want 1", n)
+	}
+}
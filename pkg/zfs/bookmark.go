@@ -0,0 +1,114 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"fmt"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	"k8s.io/klog/v2"
+)
+
+// backupBookmark returns the bookmark <pool>/<volume>#<snapname>
+// of the backup snapshot of the volume
+func backupBookmark(vol *apis.ZFSVolume, snapName string) string {
+	return vol.Spec.PoolName + "/" + vol.Name + "#" + snapName
+}
+
+// bookmarkExists returns true if the bookmark is present, it is looked up
+// using zfs get as zfs list needs the bookmark type to list it
+func bookmarkExists(name string) bool {
+	_, err := backend.GetProperty(name, "guid")
+	return err == nil
+}
+
+// setIncrementalBase records what the incremental backup is sent from, the
+// snapshot of PrevSnapName if it is present, else its bookmark. The stream
+// which replicates the intermediate snapshots can only be sent from the
+// snapshot. The chain is reset to send the full stream if neither is present.
+func setIncrementalBase(bkp *apis.ZFSBackup, vol *apis.ZFSVolume) {
+	bkp.Transfer.IncrementalBase = ""
+	bkp.Transfer.ChainReset = ""
+	if len(bkp.Spec.PrevSnapName) == 0 {
+		return
+	}
+
+	snapshot := vol.Spec.PoolName + "/" + vol.Name + "@" + bkp.Spec.PrevSnapName
+	if err := backend.GetDataset(snapshot); err == nil {
+		bkp.Transfer.IncrementalBase = "@" + bkp.Spec.PrevSnapName
+		return
+	}
+	bookmark := backupBookmark(vol, bkp.Spec.PrevSnapName)
+	if !bookmarkExists(bookmark) {
+		resetChain(bkp, vol, fmt.Sprintf("neither the snapshot %s nor the bookmark %s is present", snapshot, bookmark))
+		return
+	}
+	if opts := bkp.Spec.SendOptions; opts != nil && opts.Replicate {
+		resetChain(bkp, vol, fmt.Sprintf("replicated stream needs the snapshot %s, only the bookmark %s is present",
+			snapshot, bookmark))
+		return
+	}
+	bkp.Transfer.IncrementalBase = "#" + bkp.Spec.PrevSnapName
+}
+
+// bookmarkBackup bookmarks the transferred backup snapshot, so that the next
+// incremental backup can be sent from it, and destroys the snapshot if
+// bkp.Spec.DestroySnapshot is set. The snapshot is kept if it could not be
+// bookmarked, the backup itself has been done.
+func bookmarkBackup(bkp *apis.ZFSBackup, vol *apis.ZFSVolume) {
+	snapshot := vol.Spec.PoolName + "/" + vol.Name + "@" + bkp.Spec.SnapName
+	bookmark := backupBookmark(vol, bkp.Spec.SnapName)
+	if !bookmarkExists(bookmark) {
+		if err := backend.CreateBookmark(snapshot, bookmark); err != nil {
+			klog.Warningf("zfs: could not bookmark the backup snapshot %s, err: %v", snapshot, err)
+			return
+		}
+		klog.Infof("created bookmark %s", bookmark)
+	}
+	if !bkp.Spec.DestroySnapshot {
+		return
+	}
+
+	snap := &apis.ZFSSnapshot{}
+	snap.Name = bkp.Spec.SnapName
+	snap.Spec.PoolName = vol.Spec.PoolName
+	snap.Labels = map[string]string{ZFSVolKey: vol.Name}
+	if err := DestroySnapshot(snap); err != nil {
+		klog.Warningf("zfs: could not destroy the bookmarked backup snapshot %s, err: %v", snapshot, err)
+	}
+}
+
+// backupBookmarked returns true if the backup snapshot has been destroyed
+// once it was transferred and bookmarked, so the backup has been done
+func backupBookmarked(bkp *apis.ZFSBackup, vol *apis.ZFSVolume) bool {
+	snapshot := vol.Spec.PoolName + "/" + vol.Name + "@" + bkp.Spec.SnapName
+	return bkp.Spec.DestroySnapshot && backend.GetDataset(snapshot) != nil &&
+		bookmarkExists(backupBookmark(vol, bkp.Spec.SnapName))
+}
+
+// destroyBackupBookmark destroys the bookmark of the backup snapshot
+func destroyBackupBookmark(bkp *apis.ZFSBackup, vol *apis.ZFSVolume) error {
+	bookmark := backupBookmark(vol, bkp.Spec.SnapName)
+	if !bookmarkExists(bookmark) {
+		return nil
+	}
+	if err := backend.DestroyBookmark(bookmark); err != nil {
+		return err
+	}
+	klog.Infof("deleted bookmark %s", bookmark)
+	return nil
+}
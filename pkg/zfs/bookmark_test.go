@@ -0,0 +1,112 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"context"
+	"testing"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+)
+
+func TestBookmarkIncrementalBackup(t *testing.T) {
+	fake := useFakeZFS(t)
+
+	vol := testVolume("pvc-1", VolTypeDataset, "1073741824", "yes")
+	if err := CreateVolume(vol); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+
+	rstr := &apis.ZFSRestore{}
+	rstr.Spec.VolumeName = "pvc-2"
+	rstr.VolSpec = vol.Spec
+
+	backup := func(snapName, prevSnapName string, written int64) *apis.ZFSBackup {
+		t.Helper()
+		if err := fake.Write("pool/pvc-1", written); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		snap := &apis.ZFSSnapshot{}
+		snap.Name = snapName
+		snap.Spec.PoolName = vol.Spec.PoolName
+		snap.Labels = map[string]string{ZFSVolKey: vol.Name}
+		if err := CreateSnapshot(snap); err != nil {
+			t.Fatalf("CreateSnapshot() error = %v", err)
+		}
+
+		bkp := &apis.ZFSBackup{}
+		bkp.Name = snapName
+		bkp.Spec.VolumeName = vol.Name
+		bkp.Spec.SnapName = snapName
+		bkp.Spec.PrevSnapName = prevSnapName
+		bkp.Spec.DestroySnapshot = true
+		setIncrementalBase(bkp, vol)
+		addr, recvd := backupServer(t, nil)
+		bkp.Spec.BackupDest = addr
+		if err := sendStream(context.Background(), bkp, vol, nil, nil, nil); err != nil {
+			t.Fatalf("sendStream(%s) error = %v", snapName, err)
+		}
+		bookmarkBackup(bkp, vol)
+
+		rstr.Spec.RestoreSrc = restoreServer(t, nil, <-recvd)
+		if err := recvStream(context.Background(), rstr, nil, nil, nil); err != nil {
+			t.Fatalf("recvStream(%s) error = %v", snapName, err)
+		}
+		return bkp
+	}
+
+	bkp := backup("bkp-1", "", 4096)
+	if _, ok := fake.Dataset("pool/pvc-1@bkp-1"); ok {
+		t.Errorf("bookmarked backup snapshot has not been destroyed")
+	}
+	if !bookmarkExists("pool/pvc-1#bkp-1") {
+		t.Errorf("backup snapshot has not been bookmarked")
+	}
+	if !backupBookmarked(bkp, vol) {
+		t.Errorf("backupBookmarked() = false, want the backup to be done")
+	}
+
+	// the incremental backup is sent from the bookmark
+	bkp = backup("bkp-2", "bkp-1", 8192)
+	if bkp.Transfer.IncrementalBase != "#bkp-1" {
+		t.Errorf("incremental base = %q, want #bkp-1", bkp.Transfer.IncrementalBase)
+	}
+	if ds, _ := fake.Dataset("pool/pvc-2"); ds.Written != 12288 {
+		t.Errorf("restored volume written = %d, want 12288", ds.Written)
+	}
+
+	// the replicated stream needs the snapshot, the full stream is sent
+	bkp = &apis.ZFSBackup{}
+	bkp.Spec.SnapName = "bkp-3"
+	bkp.Spec.PrevSnapName = "bkp-2"
+	bkp.Spec.SendOptions = &apis.SendOptions{Replicate: true}
+	setIncrementalBase(bkp, vol)
+	if len(bkp.Transfer.ChainReset) == 0 || len(incrementalFrom(bkp)) > 0 {
+		t.Errorf("replicated stream from bookmark has not been reset to the full stream")
+	}
+	bkp.Spec.PrevSnapName = "bkp-x"
+	bkp.Spec.SendOptions = nil
+	setIncrementalBase(bkp, vol)
+	if len(bkp.Transfer.ChainReset) == 0 || len(incrementalFrom(bkp)) > 0 {
+		t.Errorf("backup without snapshot and bookmark has not been reset to the full stream")
+	}
+
+	bkp.Spec.SnapName = "bkp-1"
+	if err := destroyBackupBookmark(bkp, vol); err != nil || bookmarkExists("pool/pvc-1#bkp-1") {
+		t.Errorf("destroyBackupBookmark() error = %v, want the bookmark destroyed", err)
+	}
+}
@@ -0,0 +1,127 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// transferKillWait is the time to wait for the stream copied to or from
+// the killed zfs send or recv to stop before giving up on it
+const transferKillWait = 10 * time.Second
+
+var (
+	// ErrTransferCancelled is returned when the backup or the restore
+	// has been cancelled, it is not retried
+	ErrTransferCancelled = errors.New("zfs: transfer has been cancelled")
+
+	// ErrTransferDeadline is returned when the backup or the restore has
+	// not completed within its deadline, it is not retried
+	ErrTransferDeadline = errors.New("zfs: transfer deadline exceeded")
+)
+
+// running keeps the cancel func of the transfers in flight on the node
+var running struct {
+	sync.Mutex
+	cancel map[types.UID]context.CancelCauseFunc
+}
+
+func init() {
+	running.cancel = map[types.UID]context.CancelCauseFunc{}
+}
+
+// transferContext returns the context of the transfer of the backup or the
+// restore with the uid. It is cancelled by CancelTransfer, or once the
+// deadline recorded in the transfer info is exceeded, which is set on the
+// first attempt as per the deadline in the spec. The returned func has to
+// be called once the transfer is over.
+func transferContext(uid types.UID, info *apis.TransferInfo, cancelled bool, deadline *metav1.Duration) (context.Context, func(), error) {
+	if cancelled {
+		return nil, nil, ErrTransferCancelled
+	}
+	if deadline != nil && info.Deadline == nil {
+		info.Deadline = &metav1.Time{Time: time.Now().Add(deadline.Duration)}
+	}
+	if info.Deadline != nil && !time.Now().Before(info.Deadline.Time) {
+		return nil, nil, ErrTransferDeadline
+	}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	stop := func() {}
+	if info.Deadline != nil {
+		ctx, stop = context.WithDeadlineCause(ctx, info.Deadline.Time, ErrTransferDeadline)
+	}
+	if len(uid) > 0 {
+		running.Lock()
+		running.cancel[uid] = cancel
+		running.Unlock()
+	}
+
+	done := func() {
+		if len(uid) > 0 {
+			running.Lock()
+			delete(running.cancel, uid)
+			running.Unlock()
+		}
+		stop()
+		cancel(nil)
+	}
+	return ctx, done, nil
+}
+
+// CancelTransfer cancels the transfer in flight of the backup or the restore
+// with the uid, the send or the receive is killed. It returns false if the
+// transfer is not running on the node.
+func CancelTransfer(uid types.UID) bool {
+	running.Lock()
+	defer running.Unlock()
+
+	cancel, ok := running.cancel[uid]
+	if ok {
+		klog.Infof("cancelling the transfer of %s", uid)
+		cancel(ErrTransferCancelled)
+	}
+	return ok
+}
+
+// transferError returns the reason the transfer has been stopped for, if it
+// has been cancelled or its deadline has been exceeded, along with err
+func transferError(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+	cause := context.Cause(ctx)
+	if errors.Is(err, cause) {
+		return err
+	}
+	return fmt.Errorf("%w, %v", cause, err)
+}
+
+// TransferStopped returns true if the transfer has failed as it has been
+// cancelled or its deadline has been exceeded, it is not to be retried
+func TransferStopped(err error) bool {
+	return errors.Is(err, ErrTransferCancelled) || errors.Is(err, ErrTransferDeadline)
+}
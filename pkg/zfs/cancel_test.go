@@ -0,0 +1,137 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// stalledServer accepts one connection, writes the first line of the
+// stream to it and stalls until the test is over
+func stalledServer(t *testing.T, data []byte) string {
+	l := streamListener(t, nil)
+	stop := make(chan struct{})
+	t.Cleanup(func() { close(stop) })
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write(data[:bytes.IndexByte(data, '\n')+1])
+		<-stop
+	}()
+	return l.Addr().String()
+}
+
+func TestTransferCancel(t *testing.T) {
+	fake := useFakeZFS(t)
+
+	vol := testVolume("pvc-1", VolTypeDataset, "1073741824", "yes")
+	if err := CreateVolume(vol); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	snap := &apis.ZFSSnapshot{}
+	snap.Name = "bkp-1"
+	snap.Spec.PoolName = vol.Spec.PoolName
+	snap.Labels = map[string]string{ZFSVolKey: vol.Name}
+	if err := CreateSnapshot(snap); err != nil {
+		t.Fatalf("CreateSnapshot() error = %v", err)
+	}
+	bkp := &apis.ZFSBackup{}
+	bkp.Spec.VolumeName = vol.Name
+	bkp.Spec.SnapName = snap.Name
+	addr, recvd := backupServer(t, nil)
+	bkp.Spec.BackupDest = addr
+	if err := sendBackup(bkp, vol); err != nil {
+		t.Fatalf("sendBackup() error = %v", err)
+	}
+	data := <-recvd
+
+	// the receive blocked on the stalled stream is killed
+	rstr := &apis.ZFSRestore{}
+	rstr.UID = "rstr-1"
+	rstr.Spec.VolumeName = "pvc-2"
+	rstr.Spec.RestoreSrc = stalledServer(t, data)
+	rstr.VolSpec = vol.Spec
+	errs := make(chan error, 1)
+	go func() { errs <- recvRestore(rstr) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !CancelTransfer(rstr.UID) {
+		if time.Now().After(deadline) {
+			t.Fatalf("restore transfer has not started")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrTransferCancelled) {
+			t.Errorf("recvRestore() error = %v, want %v", err, ErrTransferCancelled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("recvRestore() is still running after the cancel")
+	}
+	if _, ok := fake.Dataset("pool/pvc-2"); ok {
+		t.Errorf("partially received volume is present after the cancel")
+	}
+	if CancelTransfer(rstr.UID) {
+		t.Errorf("CancelTransfer() of the stopped restore = true")
+	}
+
+	// the cancelled restore does not start
+	rstr.Spec.Cancel = true
+	rstr.Spec.RestoreSrc = restoreServer(t, nil, data)
+	if err := recvRestore(rstr); !errors.Is(err, ErrTransferCancelled) || !TransferStopped(err) {
+		t.Errorf("recvRestore() of cancelled restore error = %v, want %v", err, ErrTransferCancelled)
+	}
+}
+
+func TestTransferDeadline(t *testing.T) {
+	useFakeZFS(t)
+
+	vol := testVolume("pvc-1", VolTypeDataset, "1073741824", "yes")
+	rstr := &apis.ZFSRestore{}
+	rstr.Spec.VolumeName = "pvc-2"
+	rstr.Spec.RestoreSrc = stalledServer(t, []byte("{}\n"))
+	rstr.Spec.Deadline = &metav1.Duration{Duration: 200 * time.Millisecond}
+	rstr.VolSpec = vol.Spec
+
+	err := recvRestore(rstr)
+	if !errors.Is(err, ErrTransferDeadline) {
+		t.Errorf("recvRestore() error = %v, want %v", err, ErrTransferDeadline)
+	}
+	if rstr.Transfer.Deadline == nil {
+		t.Fatalf("transfer deadline is not recorded")
+	}
+
+	// the deadline counts from the first attempt
+	recorded := rstr.Transfer.Deadline.Time
+	if err := recvRestore(rstr); !errors.Is(err, ErrTransferDeadline) {
+		t.Errorf("recvRestore() retry error = %v, want %v", err, ErrTransferDeadline)
+	}
+	if !rstr.Transfer.Deadline.Time.Equal(recorded) {
+		t.Errorf("transfer deadline = %v, want %v", rstr.Transfer.Deadline.Time, recorded)
+	}
+}
@@ -0,0 +1,76 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"fmt"
+	"strings"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	"k8s.io/klog/v2"
+)
+
+// incrementalFrom returns the snapshot the backup stream is incremental
+// from, none if the incremental chain from PrevSnapName has been reset
+func incrementalFrom(bkp *apis.ZFSBackup) string {
+	if len(bkp.Transfer.ChainReset) > 0 {
+		return ""
+	}
+	return bkp.Spec.PrevSnapName
+}
+
+// resetChain records why the incremental chain from PrevSnapName is broken,
+// the backup is then sent as the full stream. The hold placed on the
+// snapshot of the incremental base is released as it is not needed anymore.
+func resetChain(bkp *apis.ZFSBackup, vol *apis.ZFSVolume, reason string) {
+	klog.Warningf("zfs: sending backup %s as the full stream, %s", bkp.Name, reason)
+	if strings.HasPrefix(bkp.Transfer.IncrementalBase, "@") {
+		snapshot := vol.Spec.PoolName + "/" + vol.Name + bkp.Transfer.IncrementalBase
+		if err := releaseSnapshot(snapshot, BackupHoldPrefix+bkp.Name); err != nil {
+			klog.Warningf("zfs: could not release the snapshot %s, err: %v", snapshot, err)
+		}
+	}
+	bkp.Transfer.IncrementalBase = ""
+	bkp.Transfer.ChainReset = reason
+}
+
+// checkTargetChain resets the incremental chain if the target does not have
+// the backup of PrevSnapName the stream can be received on top of. prev is
+// the manifest of that backup read from the location, nil if not present,
+// it is returned if the backup is still incremental from it.
+func checkTargetChain(bkp *apis.ZFSBackup, vol *apis.ZFSVolume, prev *BackupManifest, location string) *BackupManifest {
+	if len(incrementalFrom(bkp)) == 0 {
+		return nil
+	}
+	if prev == nil {
+		resetChain(bkp, vol, fmt.Sprintf("backup of %s is not present in %s", bkp.Spec.PrevSnapName, location))
+		return nil
+	}
+	base := bkp.Transfer.IncrementalBase
+	if len(base) == 0 {
+		base = "@" + bkp.Spec.PrevSnapName
+	}
+	guid, err := backend.GetProperty(vol.Spec.PoolName+"/"+vol.Name+base, "guid")
+	if err != nil || len(prev.SnapGUID) == 0 || guid == prev.SnapGUID {
+		// the source has already been checked, the guid of the
+		// backups done before it was recorded can not be compared
+		return prev
+	}
+	resetChain(bkp, vol, fmt.Sprintf("backup of %s in %s has the guid %s, the incremental base %s has %s",
+		bkp.Spec.PrevSnapName, location, prev.SnapGUID, base, guid))
+	return nil
+}
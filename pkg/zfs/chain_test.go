@@ -0,0 +1,110 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+)
+
+func TestIncrementalChainReset(t *testing.T) {
+	fake := useFakeZFS(t)
+	dir := useFileTargetDir(t)
+
+	vol := testVolume("pvc-1", VolTypeDataset, "1073741824", "yes")
+	if err := CreateVolume(vol); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+
+	backup := func(snapName, prevSnapName string) *BackupManifest {
+		t.Helper()
+		if err := fake.Write("pool/pvc-1", 4096); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		snap := &apis.ZFSSnapshot{}
+		snap.Name = snapName
+		snap.Spec.PoolName = vol.Spec.PoolName
+		snap.Labels = map[string]string{ZFSVolKey: vol.Name}
+		if err := CreateSnapshot(snap); err != nil {
+			t.Fatalf("CreateSnapshot() error = %v", err)
+		}
+		bkp := &apis.ZFSBackup{}
+		bkp.Name = snapName
+		bkp.Spec.VolumeName = vol.Name
+		bkp.Spec.SnapName = snapName
+		bkp.Spec.PrevSnapName = prevSnapName
+		bkp.Spec.BackupDest = "file://" + dir + "/backups"
+		setIncrementalBase(bkp, vol)
+		if err := sendToFile(context.Background(), bkp, vol, nil, nil); err != nil {
+			t.Fatalf("sendToFile(%s) error = %v", snapName, err)
+		}
+		m, err := readBackupManifest(filepath.Join(dir, "backups/pvc-1", snapName+backupManifestExt))
+		if err != nil {
+			t.Fatalf("readBackupManifest() error = %v", err)
+		}
+		if (len(bkp.Transfer.ChainReset) > 0) != (len(m.PrevSnapName) == 0 && len(prevSnapName) > 0) {
+			t.Errorf("backup %s chain reset %q, manifest from %q", snapName, bkp.Transfer.ChainReset, m.PrevSnapName)
+		}
+		return m
+	}
+	backup("bkp-1", "")
+
+	// the backup of the incremental base is not present on the target
+	if err := os.Remove(filepath.Join(dir, "backups/pvc-1/bkp-1.json")); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if m := backup("bkp-2", "bkp-1"); len(m.PrevSnapName) > 0 || !reflect.DeepEqual(m.Chain, []string{"bkp-2"}) {
+		t.Errorf("backup without the base on the target is from %q chain %v, want the full stream", m.PrevSnapName, m.Chain)
+	}
+
+	// the backup on the target is not of the incremental base
+	path := filepath.Join(dir, "backups/pvc-1/bkp-2.json")
+	m, _ := readBackupManifest(path)
+	m.SnapGUID = "1"
+	if err := writeFileAtomic(path, func(w io.Writer) error { return writeBackupManifest(w, m) }); err != nil {
+		t.Fatalf("writeBackupManifest() error = %v", err)
+	}
+	if m := backup("bkp-3", "bkp-2"); len(m.PrevSnapName) > 0 {
+		t.Errorf("backup with the guid mismatch is from %q, want the full stream", m.PrevSnapName)
+	}
+
+	// the intact chain stays incremental
+	if m := backup("bkp-4", "bkp-3"); m.PrevSnapName != "bkp-3" || !reflect.DeepEqual(m.Chain, []string{"bkp-3", "bkp-4"}) {
+		t.Errorf("backup of intact chain is from %q chain %v, want from bkp-3", m.PrevSnapName, m.Chain)
+	}
+
+	// the reset backup is restored on its own
+	rstr := &apis.ZFSRestore{}
+	rstr.Spec.VolumeName = "pvc-2"
+	rstr.VolSpec = vol.Spec
+	for _, snapName := range []string{"bkp-3", "bkp-4"} {
+		rstr.Spec.RestoreSrc = "file://" + dir + "/backups/pvc-1/" + snapName + ".json"
+		rstr.Spec.Verify = nil
+		if err := recvFromFile(context.Background(), rstr, nil, nil); err != nil {
+			t.Fatalf("recvFromFile(%s) error = %v", snapName, err)
+		}
+	}
+	if _, ok := fake.Dataset("pool/pvc-2@bkp-4"); !ok {
+		t.Errorf("restored snapshot bkp-4 is not present")
+	}
+}
@@ -0,0 +1,477 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// StreamCompressionNone, StreamCompressionGzip and StreamCompressionZstd
+	// are the compressions of the backup stream done on the node
+	StreamCompressionNone = "none"
+	StreamCompressionGzip = "gzip"
+	StreamCompressionZstd = "zstd"
+
+	// StreamEncryptionAES256GCM is the encryption of the backup stream
+	// done on the node, in chunks sealed using AES-256-GCM
+	StreamEncryptionAES256GCM = "aes-256-gcm"
+
+	// StreamEncryptionKeyKey is the key of the encryption key in the secret
+	StreamEncryptionKeyKey = "key"
+
+	// streamKeyMinLen is the minimum length of the encryption key
+	streamKeyMinLen = 32
+	// streamSaltLen is the length of the salt used to derive the key of
+	// the stream from the encryption key, so that every stream has its own
+	streamSaltLen = 32
+	// streamChunkSize is the size of the plaintext sealed in every chunk
+	streamChunkSize = 64 * 1024
+	// streamFinalChunk marks the length of the last chunk of the stream
+	streamFinalChunk = 1 << 31
+)
+
+// streamMagic starts the header of the encoded backup stream, which can not
+// be mistaken for the begin record which starts the zfs send stream
+var streamMagic = []byte("OEBKSTRM")
+
+// stream header values of the compression and the encryption
+var (
+	streamCompressions = []string{StreamCompressionNone, StreamCompressionGzip, StreamCompressionZstd}
+	streamEncryptions  = []string{"", StreamEncryptionAES256GCM}
+)
+
+// streamCodec holds the transforms applied to the backup stream on the
+// node, the stream is compressed first and then encrypted. The encoded
+// stream starts with a header recording them, so that the restore can
+// reverse them without any other information.
+type streamCodec struct {
+	compression string
+	// key is the encryption key, the stream is not encrypted if it is not set
+	key []byte
+}
+
+// getStreamKey returns the encryption key from the secret of the spec
+func getStreamKey(spec *apis.StreamEncryption) ([]byte, error) {
+	secret, err := getStreamSecret(spec.SecretName)
+	if err != nil {
+		return nil, fmt.Errorf("zfs: could not get the encryption secret %s: %v", spec.SecretName, err)
+	}
+	key := secret.Data[StreamEncryptionKeyKey]
+	if len(key) < streamKeyMinLen {
+		return nil, fmt.Errorf("zfs: encryption secret %s needs %s of at least %d bytes",
+			spec.SecretName, StreamEncryptionKeyKey, streamKeyMinLen)
+	}
+	return key, nil
+}
+
+// backupCodec returns the codec for the compression and the encryption set
+// on the backup, it returns nil if the stream is sent as it is
+func backupCodec(bkp *apis.ZFSBackup) (*streamCodec, error) {
+	codec := &streamCodec{compression: bkp.Spec.Compression}
+	if len(codec.compression) == 0 {
+		codec.compression = StreamCompressionNone
+	}
+	if indexOf(streamCompressions, codec.compression) < 0 {
+		return nil, fmt.Errorf("zfs: invalid compression %s for the backup stream", codec.compression)
+	}
+	if bkp.Spec.Encryption != nil {
+		key, err := getStreamKey(bkp.Spec.Encryption)
+		if err != nil {
+			return nil, err
+		}
+		codec.key = key
+	}
+	if codec.compression == StreamCompressionNone && codec.key == nil {
+		return nil, nil
+	}
+	return codec, nil
+}
+
+// restoreCodec returns the codec holding the key to decrypt the restore stream
+func restoreCodec(rstr *apis.ZFSRestore) (*streamCodec, error) {
+	if rstr.Spec.Encryption == nil {
+		return nil, nil
+	}
+	key, err := getStreamKey(rstr.Spec.Encryption)
+	if err != nil {
+		return nil, err
+	}
+	return &streamCodec{key: key}, nil
+}
+
+// encryption returns the encryption of the stream encoded by the codec
+func (c *streamCodec) encryption() string {
+	if c == nil || c.key == nil {
+		return ""
+	}
+	return StreamEncryptionAES256GCM
+}
+
+func indexOf(list []string, val string) int {
+	for i := range list {
+		if list[i] == val {
+			return i
+		}
+	}
+	return -1
+}
+
+// streamAEAD returns the cipher for the stream with the header, the key of
+// the stream is derived from the encryption key, the salt and the header
+func streamAEAD(key, salt, header []byte) (cipher.AEAD, error) {
+	streamKey := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, salt, header), streamKey); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(streamKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encoder writes the header of the encoded stream to w and returns the
+// writer which encodes the stream, it has to be closed to complete it
+func (c *streamCodec) encoder(w io.Writer) (io.WriteCloser, error) {
+	header := append(append([]byte{}, streamMagic...), 1,
+		byte(indexOf(streamCompressions, c.compression)),
+		byte(indexOf(streamEncryptions, c.encryption())))
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	var closers []io.Closer
+	if c.key != nil {
+		salt := make([]byte, streamSaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(salt); err != nil {
+			return nil, err
+		}
+		aead, err := streamAEAD(c.key, salt, header)
+		if err != nil {
+			return nil, err
+		}
+		sw := &sealWriter{w: w, aead: aead}
+		closers = append(closers, sw)
+		w = sw
+	}
+
+	switch c.compression {
+	case StreamCompressionGzip:
+		zw := gzip.NewWriter(w)
+		closers = append(closers, zw)
+		w = zw
+	case StreamCompressionZstd:
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, err
+		}
+		closers = append(closers, zw)
+		w = zw
+	}
+	return &encodeWriter{Writer: w, closers: closers}, nil
+}
+
+// encodeWriter closes the transforms of the stream, from the outermost
+type encodeWriter struct {
+	io.Writer
+	closers []io.Closer
+}
+
+func (e *encodeWriter) Close() error {
+	for i := len(e.closers) - 1; i >= 0; i-- {
+		if err := e.closers[i].Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decoder returns the reader which decodes the stream read from r, and
+// whether the stream has been encoded. The stream is returned as it is
+// if it does not start with the header.
+func (c *streamCodec) decoder(r io.Reader) (io.ReadCloser, bool, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(len(streamMagic)); !bytes.Equal(magic, streamMagic) {
+		return io.NopCloser(br), false, nil
+	}
+	dec, err := c.decodeHeader(br)
+	return dec, true, err
+}
+
+// decodeHeader reads the header of the encoded stream and returns the
+// reader which reverses the transforms recorded in it
+func (c *streamCodec) decodeHeader(br *bufio.Reader) (io.ReadCloser, error) {
+	header := make([]byte, len(streamMagic)+3)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("zfs: could not read the backup stream header: %v", err)
+	}
+	version, compression, encryption := int(header[len(header)-3]), int(header[len(header)-2]), int(header[len(header)-1])
+	if version != 1 || compression >= len(streamCompressions) || encryption >= len(streamEncryptions) {
+		return nil, fmt.Errorf("zfs: unsupported backup stream header %x", header[len(streamMagic):])
+	}
+
+	var dec io.Reader = br
+	if len(streamEncryptions[encryption]) > 0 {
+		if c == nil || c.key == nil {
+			return nil, fmt.Errorf("zfs: backup stream is encrypted with %s, the encryption key is required",
+				streamEncryptions[encryption])
+		}
+		salt := make([]byte, streamSaltLen)
+		if _, err := io.ReadFull(br, salt); err != nil {
+			return nil, fmt.Errorf("zfs: could not read the backup stream header: %v", err)
+		}
+		aead, err := streamAEAD(c.key, salt, header)
+		if err != nil {
+			return nil, err
+		}
+		dec = &openReader{r: br, aead: aead}
+	}
+
+	switch streamCompressions[compression] {
+	case StreamCompressionGzip:
+		zr, err := gzip.NewReader(dec)
+		if err != nil {
+			return nil, fmt.Errorf("zfs: could not decompress the backup stream: %v", err)
+		}
+		return zr, nil
+	case StreamCompressionZstd:
+		zr, err := zstd.NewReader(dec, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("zfs: could not decompress the backup stream: %v", err)
+		}
+		return zr.IOReadCloser(), nil
+	}
+	return io.NopCloser(dec), nil
+}
+
+// streamNonce returns the nonce of the chunk, the key is used for one stream only
+func streamNonce(aead cipher.AEAD, chunk uint64) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], chunk)
+	return nonce
+}
+
+// streamChunkAD returns the additional data of the chunk, which
+// authenticates whether it is the last chunk of the stream
+func streamChunkAD(final bool) []byte {
+	if final {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// sealWriter encrypts the stream in chunks of streamChunkSize, every chunk is
+// written as its length followed by the sealed chunk. The last chunk is
+// marked, so that the truncated stream can not be decrypted.
+type sealWriter struct {
+	w     io.Writer
+	aead  cipher.AEAD
+	buf   []byte
+	chunk uint64
+}
+
+func (s *sealWriter) Write(p []byte) (int, error) {
+	if s.buf == nil {
+		s.buf = make([]byte, 0, streamChunkSize)
+	}
+	n := len(p)
+	for len(p) > 0 {
+		m := copy(s.buf[len(s.buf):cap(s.buf)], p)
+		s.buf = s.buf[:len(s.buf)+m]
+		p = p[m:]
+		if len(s.buf) == streamChunkSize {
+			if err := s.seal(false); err != nil {
+				return n - len(p), err
+			}
+		}
+	}
+	return n, nil
+}
+
+func (s *sealWriter) seal(final bool) error {
+	sealed := s.aead.Seal(nil, streamNonce(s.aead, s.chunk), s.buf, streamChunkAD(final))
+	s.chunk++
+	s.buf = s.buf[:0]
+
+	length := uint32(len(sealed))
+	if final {
+		length |= streamFinalChunk
+	}
+	var hdr [4]byte
+	binary.BigEndian.PutUint32(hdr[:], length)
+	if _, err := s.w.Write(hdr[:]); err != nil {
+		return err
+	}
+	_, err := s.w.Write(sealed)
+	return err
+}
+
+// Close writes the last chunk of the stream
+func (s *sealWriter) Close() error {
+	return s.seal(true)
+}
+
+// openReader decrypts the stream written by the sealWriter
+type openReader struct {
+	r     io.Reader
+	aead  cipher.AEAD
+	buf   []byte
+	chunk uint64
+	final bool
+}
+
+func (o *openReader) Read(p []byte) (int, error) {
+	for len(o.buf) == 0 {
+		if o.final {
+			if n, _ := o.r.Read(make([]byte, 1)); n > 0 {
+				return 0, errors.New("zfs: backup stream has data after the last chunk")
+			}
+			return 0, io.EOF
+		}
+		if err := o.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, o.buf)
+	o.buf = o.buf[n:]
+	return n, nil
+}
+
+func (o *openReader) open() error {
+	var hdr [4]byte
+	if _, err := io.ReadFull(o.r, hdr[:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("zfs: backup stream is truncated: %v", err)
+	}
+	length := binary.BigEndian.Uint32(hdr[:])
+	o.final = length&streamFinalChunk != 0
+	length &^= streamFinalChunk
+	if length > streamChunkSize+uint32(o.aead.Overhead()) {
+		return fmt.Errorf("zfs: backup stream chunk of %d bytes is too large", length)
+	}
+
+	sealed := make([]byte, length)
+	if _, err := io.ReadFull(o.r, sealed); err != nil {
+		return fmt.Errorf("zfs: backup stream is truncated: %v", err)
+	}
+	buf, err := o.aead.Open(sealed[:0], streamNonce(o.aead, o.chunk), sealed, streamChunkAD(o.final))
+	if err != nil {
+		return fmt.Errorf("zfs: could not decrypt the backup stream: %v", err)
+	}
+	o.chunk++
+	o.buf = buf
+	return nil
+}
+
+// sendEncoded sends the backup snapshot to w, encoded by the codec and
+// limited to the transfer rate. The checksum of the stream written to w
+// and the guid of the snapshot are recorded in the transfer status.
+func sendEncoded(ctx context.Context, bkp *apis.ZFSBackup, vol *apis.ZFSVolume, w io.Writer, codec *streamCodec) error {
+	guid, err := snapshotGUID(bkp, vol)
+	if err != nil {
+		return err
+	}
+	bkp.Transfer.SnapGUID = guid
+	bkp.Transfer.Checksum = ""
+
+	sum := sha256.New()
+	w = throttledWriter(ctx, io.MultiWriter(w, sum), bkp.Spec.Limits)
+	if codec == nil {
+		err = backend.Send(ctx, bkp, vol, w)
+	} else {
+		var enc io.WriteCloser
+		enc, err = codec.encoder(w)
+		if err != nil {
+			return fmt.Errorf("zfs: could not encode the backup stream: %v", err)
+		}
+		if err = backend.Send(ctx, bkp, vol, enc); err == nil {
+			err = enc.Close()
+		}
+	}
+	if err == nil {
+		bkp.Transfer.Checksum = streamChecksum(sum)
+	}
+	return err
+}
+
+// recvDecoded receives the volume from the stream read from r, decoded by
+// the codec and limited to the transfer rate. The rest of the encoded stream
+// is read once it has been received, so that the last chunk of the encrypted
+// stream is verified, and so is the rest of the stream to be verified against
+// the checksum recorded at backup. The received volume is discarded if it
+// does not match the backup.
+func recvDecoded(ctx context.Context, rstr *apis.ZFSRestore, r io.Reader, codec *streamCodec) error {
+	name := rstr.Spec.VolumeName
+	if rstr.Spec.Verify != nil && len(rstr.Spec.Verify.SnapName) > 0 {
+		name = rstr.Spec.Verify.SnapName
+	}
+	checksum := restoreChecksum(rstr)
+	verifier := newChecksumReader(r, name, -1, checksum)
+	dec, encoded, err := codec.decoder(throttledReader(ctx, verifier, rstr.Spec.Limits))
+	if err != nil {
+		return err
+	}
+	defer dec.Close()
+
+	existed := backend.GetDataset(recvDataset(rstr)) == nil
+	if err := backend.Recv(ctx, rstr, dec); err != nil {
+		// the stream which does not match the backup is not to be resumed
+		if verifier.mismatch {
+			discardRestore(rstr, existed)
+		}
+		return err
+	}
+	if encoded {
+		if _, err := io.Copy(io.Discard, dec); err != nil {
+			discardRestore(rstr, existed)
+			return err
+		}
+	}
+	if len(checksum) > 0 {
+		if _, err := io.Copy(io.Discard, verifier); err != nil {
+			discardRestore(rstr, existed)
+			return err
+		}
+		rstr.Transfer.Checksum = verifier.sum()
+	}
+	if err := verifyRestoreSnapshot(rstr); err != nil {
+		discardRestore(rstr, existed)
+		return err
+	}
+	return nil
+}
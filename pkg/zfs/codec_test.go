@@ -0,0 +1,188 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+)
+
+var testStreamKey = []byte("0123456789abcdef0123456789abcdef")
+
+func encodeStream(t *testing.T, codec *streamCodec, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	enc, err := codec.encoder(&buf)
+	if err != nil {
+		t.Fatalf("encoder() error = %v", err)
+	}
+	if _, err := enc.Write(data); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func decodeStream(codec *streamCodec, data []byte) ([]byte, error) {
+	dec, _, err := codec.decoder(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer dec.Close()
+	return io.ReadAll(dec)
+}
+
+func TestStreamCodec(t *testing.T) {
+	data := bytes.Repeat([]byte("zfs send stream "), 3*streamChunkSize/16+100)
+
+	for _, compression := range streamCompressions {
+		for _, key := range [][]byte{nil, testStreamKey} {
+			codec := &streamCodec{compression: compression, key: key}
+			encoded := encodeStream(t, codec, data)
+			if compression != StreamCompressionNone && len(encoded) >= len(data) {
+				t.Errorf("%s stream is %d bytes, want less than %d", compression, len(encoded), len(data))
+			}
+			if key != nil && bytes.Contains(encoded, []byte("zfs send stream")) {
+				t.Errorf("%s encrypted stream has the plaintext", compression)
+			}
+
+			decoded, err := decodeStream(&streamCodec{key: key}, encoded)
+			if err != nil || !bytes.Equal(decoded, data) {
+				t.Errorf("decode %s encrypted %v = %d bytes, %v, want %d bytes",
+					compression, key != nil, len(decoded), err, len(data))
+			}
+		}
+	}
+
+	// the stream which has not been encoded is received as it is
+	if decoded, err := decodeStream(nil, data); err != nil || !bytes.Equal(decoded, data) {
+		t.Errorf("decode plain stream = %d bytes, %v", len(decoded), err)
+	}
+
+	encoded := encodeStream(t, &streamCodec{compression: StreamCompressionNone, key: testStreamKey}, data)
+	if _, err := decodeStream(nil, encoded); err == nil || !strings.Contains(err.Error(), "key is required") {
+		t.Errorf("decode without the key error = %v", err)
+	}
+	otherKey := bytes.Repeat([]byte("k"), 32)
+	if _, err := decodeStream(&streamCodec{key: otherKey}, encoded); err == nil {
+		t.Errorf("decode with the wrong key should fail")
+	}
+
+	tampered := append([]byte{}, encoded...)
+	tampered[len(tampered)/2] ^= 0x01
+	if _, err := decodeStream(&streamCodec{key: testStreamKey}, tampered); err == nil {
+		t.Errorf("decode of tampered stream should fail")
+	}
+
+	// the header is authenticated along with the chunks
+	tampered = append([]byte{}, encoded...)
+	tampered[len(streamMagic)+1] = 1
+	if _, err := decodeStream(&streamCodec{key: testStreamKey}, tampered); err == nil {
+		t.Errorf("decode of stream with tampered header should fail")
+	}
+
+	// the stream truncated at a chunk boundary must not be accepted
+	chunk := 4 + streamChunkSize + 16
+	truncated := encoded[:len(encoded)-(len(encoded)-len(streamMagic)-3-streamSaltLen)%chunk]
+	if _, err := decodeStream(&streamCodec{key: testStreamKey}, truncated); err == nil {
+		t.Errorf("decode of truncated stream should fail")
+	}
+}
+
+func TestEncodedBackupRestore(t *testing.T) {
+	fake := useFakeZFS(t)
+	dir := useFileTargetDir(t)
+
+	vol := testVolume("pvc-1", VolTypeDataset, "1073741824", "yes")
+	if err := CreateVolume(vol); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	if err := fake.Write("pool/pvc-1", 4096); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	snap := &apis.ZFSSnapshot{}
+	snap.Name = "bkp-1"
+	snap.Spec.PoolName = vol.Spec.PoolName
+	snap.Labels = map[string]string{ZFSVolKey: vol.Name}
+	if err := CreateSnapshot(snap); err != nil {
+		t.Fatalf("CreateSnapshot() error = %v", err)
+	}
+
+	bkp := &apis.ZFSBackup{}
+	bkp.Name = "bkp-1"
+	bkp.Spec.VolumeName = vol.Name
+	bkp.Spec.SnapName = "bkp-1"
+	codec := &streamCodec{compression: StreamCompressionZstd, key: testStreamKey}
+
+	addr, recvd := backupServer(t, nil)
+	bkp.Spec.BackupDest = addr
+	if err := sendStream(context.Background(), bkp, vol, nil, codec, nil); err != nil {
+		t.Fatalf("sendStream() error = %v", err)
+	}
+	data := <-recvd
+	if !bytes.HasPrefix(data, streamMagic) || bytes.Contains(data, []byte("pool/pvc-1")) {
+		t.Errorf("backup stream has not been encoded")
+	}
+
+	rstr := &apis.ZFSRestore{}
+	rstr.Spec.VolumeName = "pvc-2"
+	rstr.Spec.RestoreSrc = restoreServer(t, nil, data)
+	rstr.VolSpec = vol.Spec
+	if err := recvStream(context.Background(), rstr, nil, nil, nil); err == nil {
+		t.Errorf("recvStream() of encrypted stream without the key should fail")
+	}
+	rstr.Spec.RestoreSrc = restoreServer(t, nil, data)
+	if err := recvStream(context.Background(), rstr, nil, &streamCodec{key: testStreamKey}, nil); err != nil {
+		t.Fatalf("recvStream() error = %v", err)
+	}
+	if ds, _ := fake.Dataset("pool/pvc-2"); ds.Written != 4096 {
+		t.Errorf("restored volume written = %d, want 4096", ds.Written)
+	}
+
+	// the file target records the transforms in the manifest
+	bkp.Spec.BackupDest = "file://" + dir
+	if err := sendToFile(context.Background(), bkp, vol, codec, nil); err != nil {
+		t.Fatalf("sendToFile() error = %v", err)
+	}
+	m, err := readBackupManifest(filepath.Join(dir, "pvc-1/bkp-1.json"))
+	if err != nil {
+		t.Fatalf("readBackupManifest() error = %v", err)
+	}
+	if m.Compression != StreamCompressionZstd || m.Encryption != StreamEncryptionAES256GCM {
+		t.Errorf("manifest compression %q encryption %q, want zstd aes-256-gcm", m.Compression, m.Encryption)
+	}
+
+	rstr.Spec.VolumeName = "pvc-3"
+	rstr.Spec.RestoreSrc = "file://" + dir + "/pvc-1/bkp-1.json"
+	if err := recvFromFile(context.Background(), rstr, nil, nil); err == nil {
+		t.Errorf("recvFromFile() of encrypted backup without the key should fail")
+	}
+	if err := recvFromFile(context.Background(), rstr, &streamCodec{key: testStreamKey}, nil); err != nil {
+		t.Fatalf("recvFromFile() error = %v", err)
+	}
+	if _, ok := fake.Dataset("pool/pvc-3@bkp-1"); !ok {
+		t.Errorf("restored snapshot is not present")
+	}
+}
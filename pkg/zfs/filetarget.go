@@ -0,0 +1,341 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// FileTargetScheme is the scheme of the backup destination and the
+	// restore source which are files on the node instead of a server
+	FileTargetScheme = "file://"
+
+	// FileTargetDirKey is the environment variable to set the directory on
+	// the node under which the backups can be written as files
+	FileTargetDirKey string = "OPENEBS_IO_BACKUP_FILE_DIR"
+
+	// BackupManifestVersion is the version of the backup manifest format
+	BackupManifestVersion = 1
+
+	// backupStreamExt and backupManifestExt are the extensions of the
+	// stream and the manifest files written for the backup snapshot
+	backupStreamExt   = ".zstream"
+	backupManifestExt = ".json"
+)
+
+// FileTargetDir is the directory under which the backups can be written as
+// files, the file targets are not allowed if it is not set
+var FileTargetDir string
+
+func init() {
+	FileTargetDir = os.Getenv(FileTargetDirKey)
+}
+
+// BackupManifest describes the backup stream written to a file target. It
+// is written next to the stream as <volume>/<snapshot>.json, so that the
+// backup can be restored without anything other than the two files.
+type BackupManifest struct {
+	Version    int    `json:"version"`
+	BackupName string `json:"backupName"`
+	VolumeName string `json:"volumeName"`
+	SnapName   string `json:"snapName"`
+	// SnapGUID is the guid of the backup snapshot
+	SnapGUID string `json:"snapGUID,omitempty"`
+	// PrevSnapName is the snapshot the stream is incremental from
+	PrevSnapName string `json:"prevSnapName,omitempty"`
+	// Chain is the list of the backup snapshots, starting from the full
+	// backup, which have to be restored in order to restore this one
+	Chain []string `json:"chain"`
+	// VolSpec is the spec of the volume which has been backed up
+	VolSpec apis.VolumeInfo `json:"volSpec"`
+	// SendOptions are the zfs send options the stream has been sent with
+	SendOptions *apis.SendOptions `json:"sendOptions,omitempty"`
+	// StreamFile is the name of the stream file in the manifest directory
+	StreamFile string `json:"streamFile"`
+	// Size is the size of the stream file in bytes
+	Size int64 `json:"size"`
+	// Checksum is the sha256 of the stream file as "sha256:<hex>"
+	Checksum string `json:"checksum"`
+	// Compression and Encryption are the transforms applied to the
+	// stream on the node, which are reversed when it is restored
+	Compression  string      `json:"compression,omitempty"`
+	Encryption   string      `json:"encryption,omitempty"`
+	CreationTime metav1.Time `json:"creationTime"`
+}
+
+// isFileTarget returns true if the address is a file:// target
+func isFileTarget(addr string) bool {
+	return strings.HasPrefix(addr, FileTargetScheme)
+}
+
+// fileTargetPath returns the path on the node for the file:// address, the
+// path has to be present under the FileTargetDir
+func fileTargetPath(addr string) (string, error) {
+	if len(FileTargetDir) == 0 {
+		return "", fmt.Errorf("zfs: file targets are not enabled, %s is not set", FileTargetDirKey)
+	}
+	path := filepath.Clean(strings.TrimPrefix(addr, FileTargetScheme))
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("zfs: file target %s is not an absolute path", addr)
+	}
+	rel, err := filepath.Rel(filepath.Clean(FileTargetDir), path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("zfs: file target %s is not under %s", addr, FileTargetDir)
+	}
+	return path, nil
+}
+
+// readBackupManifest reads the backup manifest from the path
+func readBackupManifest(path string) (*BackupManifest, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("zfs: could not read the backup manifest: %v", err)
+	}
+	return parseBackupManifest(data, path)
+}
+
+// parseBackupManifest decodes the backup manifest read from the location
+func parseBackupManifest(data []byte, location string) (*BackupManifest, error) {
+	m := &BackupManifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("zfs: invalid backup manifest %s: %v", location, err)
+	}
+	if m.Version != BackupManifestVersion {
+		return nil, fmt.Errorf("zfs: unsupported backup manifest version %d in %s", m.Version, location)
+	}
+	return m, nil
+}
+
+// newBackupManifest returns the manifest for the backup encoded by the codec,
+// prev is the manifest of the backup of bkp.Spec.PrevSnapName for the
+// incremental one, nil if the full stream is sent
+func newBackupManifest(bkp *apis.ZFSBackup, vol *apis.ZFSVolume, prev *BackupManifest, codec *streamCodec) *BackupManifest {
+	m := &BackupManifest{
+		Version:      BackupManifestVersion,
+		BackupName:   bkp.Name,
+		VolumeName:   vol.Name,
+		SnapName:     bkp.Spec.SnapName,
+		PrevSnapName: incrementalFrom(bkp),
+		VolSpec:      vol.Spec,
+		SendOptions:  bkp.Spec.SendOptions,
+		StreamFile:   bkp.Spec.SnapName + backupStreamExt,
+		Encryption:   codec.encryption(),
+	}
+	if codec != nil {
+		m.Compression = codec.compression
+	}
+	if prev != nil {
+		m.Chain = append(m.Chain, prev.Chain...)
+	}
+	m.Chain = append(m.Chain, m.SnapName)
+	return m
+}
+
+// writeBackupManifest encodes the manifest to w, the manifest always has
+// the checksum of the whole stream the restore is verified against
+func writeBackupManifest(w io.Writer, m *BackupManifest) error {
+	if len(m.Checksum) == 0 {
+		return fmt.Errorf("zfs: backup stream of %s has no checksum", m.SnapName)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m)
+}
+
+// writeFileAtomic writes the file by writing it to a temporary
+// file first and renaming it once it has been synced to the disk
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".partial")
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// sendToFile writes the backup snapshot as the stream file along with its
+// manifest in the <volume> directory under the bkp.Spec.BackupDest directory
+func sendToFile(ctx context.Context, bkp *apis.ZFSBackup, vol *apis.ZFSVolume, codec *streamCodec, report progressReporter) error {
+	dir, err := fileTargetPath(bkp.Spec.BackupDest)
+	if err != nil {
+		return err
+	}
+	dir = filepath.Join(dir, vol.Name)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return fmt.Errorf("zfs: could not create the backup directory %s: %v", dir, err)
+	}
+
+	var prev *BackupManifest
+	if len(incrementalFrom(bkp)) > 0 {
+		path := filepath.Join(dir, bkp.Spec.PrevSnapName+backupManifestExt)
+		if _, err := os.Stat(path); err == nil {
+			prev, err = readBackupManifest(path)
+			if err != nil {
+				return fmt.Errorf("zfs: incremental backup needs the backup of %s in %s: %v", bkp.Spec.PrevSnapName, dir, err)
+			}
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("zfs: could not check the backup of %s in %s: %v", bkp.Spec.PrevSnapName, dir, err)
+		}
+		prev = checkTargetChain(bkp, vol, prev, dir)
+	}
+	m := newBackupManifest(bkp, vol, prev, codec)
+
+	size, err := backend.EstimateSend(bkp, vol)
+	if err != nil {
+		klog.Warningf("zfs: could not estimate the size of backup %s, err: %v", bkp.Name, err)
+	}
+	bkp.Transfer.EstimatedSize = size
+
+	err = writeFileAtomic(filepath.Join(dir, m.StreamFile), func(w io.Writer) error {
+		counter := &countWriter{w: w}
+		progress := startProgress(&bkp.Transfer, counter.count, report)
+		err := sendEncoded(ctx, bkp, vol, counter, codec)
+		progress.finish(err)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("zfs: could not write the backup stream to %s: %v", dir, err)
+	}
+
+	m.Size = bkp.Transfer.BytesTransferred
+	m.Checksum = bkp.Transfer.Checksum
+	m.SnapGUID = bkp.Transfer.SnapGUID
+	m.CreationTime = metav1.Now()
+	err = writeFileAtomic(filepath.Join(dir, m.SnapName+backupManifestExt), func(w io.Writer) error {
+		return writeBackupManifest(w, m)
+	})
+	if err != nil {
+		return fmt.Errorf("zfs: could not write the backup manifest to %s: %v", dir, err)
+	}
+	return nil
+}
+
+// verifyStreamFile checks the size and the checksum of the stream file
+func verifyStreamFile(path string, m *BackupManifest) error {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return fmt.Errorf("zfs: could not open the backup stream: %v", err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	n, err := io.Copy(hash, f)
+	if err != nil {
+		return fmt.Errorf("zfs: could not read the backup stream %s: %v", path, err)
+	}
+	if n != m.Size {
+		return fmt.Errorf("zfs: backup stream %s is %d bytes, expected %d", path, n, m.Size)
+	}
+	if sum := streamChecksum(hash); sum != m.Checksum {
+		return fmt.Errorf("zfs: backup stream %s checksum %s does not match %s", path, sum, m.Checksum)
+	}
+	return nil
+}
+
+// checkBackupManifest checks that the backup described by the manifest can
+// be decoded by the codec, and that the backup its incremental stream is
+// based on has already been restored. It returns the copy of the restore
+// to be received, with the send options and the verification taken from
+// the manifest if they are not set, the spec of rstr is left as it is.
+func checkBackupManifest(rstr *apis.ZFSRestore, m *BackupManifest, codec *streamCodec) (*apis.ZFSRestore, error) {
+	if len(m.Encryption) > 0 && len(codec.encryption()) == 0 {
+		return nil, fmt.Errorf("zfs: backup %s is encrypted with %s, the encryption key is required",
+			m.SnapName, m.Encryption)
+	}
+	recv := rstr.DeepCopy()
+	if recv.Spec.SendOptions == nil {
+		recv.Spec.SendOptions = m.SendOptions.DeepCopy()
+	}
+	if recv.Spec.Verify == nil {
+		recv.Spec.Verify = &apis.BackupVerification{
+			Checksum: m.Checksum,
+			SnapName: m.SnapName,
+			SnapGUID: m.SnapGUID,
+		}
+	}
+	if len(m.PrevSnapName) == 0 {
+		return recv, nil
+	}
+	volume := rstr.VolSpec.PoolName + "/" + rstr.Spec.VolumeName
+	if err := backend.GetDataset(volume + "@" + m.PrevSnapName); err != nil {
+		return nil, fmt.Errorf("zfs: restore of %s needs the backups %v to be restored in order, %s@%s not found",
+			m.SnapName, m.Chain, volume, m.PrevSnapName)
+	}
+	return recv, nil
+}
+
+// recvFromFile receives the volume from the stream file described by
+// the manifest at rstr.Spec.RestoreSrc, once the stream has been verified
+func recvFromFile(ctx context.Context, rstr *apis.ZFSRestore, codec *streamCodec, report progressReporter) error {
+	path, err := fileTargetPath(rstr.Spec.RestoreSrc)
+	if err != nil {
+		return err
+	}
+	m, err := readBackupManifest(path)
+	if err != nil {
+		return err
+	}
+
+	recv, err := checkBackupManifest(rstr, m, codec)
+	if err != nil {
+		return err
+	}
+
+	stream := filepath.Join(filepath.Dir(path), m.StreamFile)
+	if err := verifyStreamFile(stream, m); err != nil {
+		return err
+	}
+
+	f, err := os.Open(filepath.Clean(stream))
+	if err != nil {
+		return fmt.Errorf("zfs: could not open the backup stream: %v", err)
+	}
+	defer f.Close()
+
+	recv.Transfer.EstimatedSize = m.Size
+	counter := &countReader{r: f}
+	progress := startProgress(&recv.Transfer, counter.count, report)
+	err = recvDecoded(ctx, recv, counter, codec)
+	progress.finish(err)
+	rstr.Transfer = recv.Transfer
+	return err
+}
@@ -0,0 +1,214 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+)
+
+func useFileTargetDir(t *testing.T) string {
+	dir := t.TempDir()
+	old := FileTargetDir
+	FileTargetDir = dir
+	t.Cleanup(func() { FileTargetDir = old })
+	return dir
+}
+
+func TestFileTargetPath(t *testing.T) {
+	dir := useFileTargetDir(t)
+
+	tests := map[string]bool{
+		"file://" + dir:                     true,
+		"file://" + dir + "/nfs/backups":    true,
+		"file://" + dir + "/../etc":         false,
+		"file://" + dir + "/a/../../etc":    false,
+		"file://relative/path":              false,
+		"file:///somewhere/else":            false,
+		"file://" + filepath.Dir(dir) + "x": false,
+	}
+	for addr, valid := range tests {
+		if _, err := fileTargetPath(addr); (err == nil) != valid {
+			t.Errorf("fileTargetPath(%s) error = %v, want valid %v", addr, err, valid)
+		}
+	}
+
+	FileTargetDir = ""
+	if _, err := fileTargetPath("file://" + dir); err == nil {
+		t.Errorf("fileTargetPath() should fail when the file targets are not enabled")
+	}
+}
+
+func TestFileTargetBackupRestore(t *testing.T) {
+	fake := useFakeZFS(t)
+	dir := useFileTargetDir(t)
+
+	vol := testVolume("pvc-1", VolTypeDataset, "1073741824", "yes")
+	if err := CreateVolume(vol); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+
+	backup := func(snapName, prevSnapName string, written int64) {
+		t.Helper()
+		if err := fake.Write("pool/pvc-1", written); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		snap := &apis.ZFSSnapshot{}
+		snap.Name = snapName
+		snap.Spec.PoolName = vol.Spec.PoolName
+		snap.Labels = map[string]string{ZFSVolKey: vol.Name}
+		if err := CreateSnapshot(snap); err != nil {
+			t.Fatalf("CreateSnapshot() error = %v", err)
+		}
+		bkp := &apis.ZFSBackup{}
+		bkp.Name = snapName
+		bkp.Spec.VolumeName = vol.Name
+		bkp.Spec.SnapName = snapName
+		bkp.Spec.PrevSnapName = prevSnapName
+		bkp.Spec.BackupDest = "file://" + dir + "/backups"
+		if err := sendToFile(context.Background(), bkp, vol, nil, nil); err != nil {
+			t.Fatalf("sendToFile(%s) error = %v", snapName, err)
+		}
+	}
+	backup("bkp-1", "", 4096)
+	backup("bkp-2", "bkp-1", 8192)
+
+	m, err := readBackupManifest(filepath.Join(dir, "backups/pvc-1/bkp-2.json"))
+	if err != nil {
+		t.Fatalf("readBackupManifest() error = %v", err)
+	}
+	if !reflect.DeepEqual(m.Chain, []string{"bkp-1", "bkp-2"}) || m.PrevSnapName != "bkp-1" {
+		t.Errorf("manifest chain %v prev %s, want [bkp-1 bkp-2] from bkp-1", m.Chain, m.PrevSnapName)
+	}
+	if m.VolSpec.PoolName != "pool" || m.StreamFile != "bkp-2.zstream" || m.Size == 0 {
+		t.Errorf("manifest = %+v", m)
+	}
+
+	if err := writeBackupManifest(io.Discard, &BackupManifest{SnapName: "bkp-3"}); err == nil {
+		t.Errorf("writeBackupManifest() without the checksum should fail")
+	}
+
+	rstr := &apis.ZFSRestore{}
+	rstr.Spec.VolumeName = "pvc-2"
+	rstr.VolSpec = vol.Spec
+
+	// the incremental backup can not be restored before the full one
+	rstr.Spec.RestoreSrc = "file://" + dir + "/backups/pvc-1/bkp-2.json"
+	if err := recvFromFile(context.Background(), rstr, nil, nil); err == nil {
+		t.Errorf("recvFromFile() of incremental backup before the full one should fail")
+	}
+
+	for _, snapName := range []string{"bkp-1", "bkp-2"} {
+		rstr.Spec.RestoreSrc = "file://" + dir + "/backups/pvc-1/" + snapName + ".json"
+		rstr.Spec.Verify = nil
+		if err := recvFromFile(context.Background(), rstr, nil, nil); err != nil {
+			t.Fatalf("recvFromFile(%s) error = %v", snapName, err)
+		}
+	}
+	if ds, _ := fake.Dataset("pool/pvc-2"); ds.Written != 12288 {
+		t.Errorf("restored volume written = %d, want 12288", ds.Written)
+	}
+	if _, ok := fake.Dataset("pool/pvc-2@bkp-2"); !ok {
+		t.Errorf("restored snapshot bkp-2 is not present")
+	}
+
+	// a corrupted stream must not be received
+	stream := filepath.Join(dir, "backups/pvc-1/bkp-1.zstream")
+	data, _ := os.ReadFile(stream)
+	data[len(data)-2] ^= 0xff
+	if err := os.WriteFile(stream, data, 0600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	rstr.Spec.VolumeName = "pvc-3"
+	rstr.Spec.RestoreSrc = "file://" + dir + "/backups/pvc-1/bkp-1.json"
+	rstr.Spec.Verify = nil
+	if err := recvFromFile(context.Background(), rstr, nil, nil); err == nil {
+		t.Errorf("recvFromFile() of corrupted stream should fail")
+	}
+	if _, ok := fake.Dataset("pool/pvc-3"); ok {
+		t.Errorf("corrupted stream has been received")
+	}
+}
+
+func TestFileTargetSendOptions(t *testing.T) {
+	fake := useFakeZFS(t)
+	dir := useFileTargetDir(t)
+
+	vol := testVolume("pvc-1", VolTypeDataset, "1073741824", "yes")
+	vol.Spec.Encryption = "aes-256-gcm"
+	vol.Spec.KeyFormat = "raw"
+	vol.Spec.KeyLocation = "file:///key"
+	if err := CreateVolume(vol); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	for _, snapName := range []string{"s1", "s2", "s3", "s4"} {
+		snap := &apis.ZFSSnapshot{}
+		snap.Name = snapName
+		snap.Spec.PoolName = vol.Spec.PoolName
+		snap.Labels = map[string]string{ZFSVolKey: vol.Name}
+		if err := CreateSnapshot(snap); err != nil {
+			t.Fatalf("CreateSnapshot() error = %v", err)
+		}
+	}
+
+	opts := &apis.SendOptions{Raw: true, Replicate: true}
+	for _, snaps := range [][2]string{{"s2", ""}, {"s4", "s2"}} {
+		bkp := &apis.ZFSBackup{}
+		bkp.Name = snaps[0]
+		bkp.Spec.SnapName = snaps[0]
+		bkp.Spec.PrevSnapName = snaps[1]
+		bkp.Spec.BackupDest = "file://" + dir
+		bkp.Spec.SendOptions = opts
+		if err := sendToFile(context.Background(), bkp, vol, nil, nil); err != nil {
+			t.Fatalf("sendToFile(%s) error = %v", snaps[0], err)
+		}
+	}
+
+	rstr := &apis.ZFSRestore{}
+	rstr.Spec.VolumeName = "pvc-2"
+	rstr.VolSpec = vol.Spec
+	for _, snapName := range []string{"s2", "s4"} {
+		rstr.Spec.RestoreSrc = "file://" + dir + "/pvc-1/" + snapName + ".json"
+		rstr.Spec.Verify = nil
+		if err := recvFromFile(context.Background(), rstr, nil, nil); err != nil {
+			t.Fatalf("recvFromFile(%s) error = %v", snapName, err)
+		}
+	}
+	// the send options and the verification of the manifest are not set
+	// on the spec of the restore
+	if rstr.Spec.SendOptions != nil || rstr.Spec.Verify != nil {
+		t.Errorf("restore spec = %+v, want the send options and the verification unset", rstr.Spec)
+	}
+	if len(rstr.Transfer.Checksum) == 0 || len(rstr.Transfer.SnapGUID) == 0 {
+		t.Errorf("restore transfer = %+v, want the verified checksum and guid", rstr.Transfer)
+	}
+	// the intermediate snapshots are replicated with the raw stream
+	for _, snapName := range []string{"s1", "s2", "s3", "s4"} {
+		if _, ok := fake.Dataset("pool/pvc-2@" + snapName); !ok {
+			t.Errorf("replicated snapshot %s is not present", snapName)
+		}
+	}
+	if ds, _ := fake.Dataset("pool/pvc-2"); ds.Props["encryption"] != "aes-256-gcm" || ds.Props["keylocation"] != "file:///key" {
+		t.Errorf("restored volume props = %v, want the received encryption properties", ds.Props)
+	}
+}
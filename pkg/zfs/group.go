@@ -0,0 +1,153 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"errors"
+	"fmt"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	"github.com/openebs/zfs-localpv/pkg/builder/snapbuilder"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+)
+
+// ZFSSnapGroupKey is the label of the ZFSSnapshot taken by the
+// ZFSSnapshotGroup, set to the name of the ZFSSnapshotGroup
+const ZFSSnapGroupKey string = "openebs.io/snapshot-group"
+
+// ErrSnapshotGroupInvalid is returned when the snapshots of the group can
+// not be taken as its volumes are not present on its node and pool, or as
+// some of its snapshots are already present, it is not retried
+var ErrSnapshotGroupInvalid = errors.New("zfs: invalid snapshot group")
+
+// GroupSnapshotName returns the name of the snapshot of the volume taken by
+// the group snapshot. The names of the ZFSSnapshots have to be unique, so
+// each volume has its own snapshot name within the group.
+func GroupSnapshotName(grpName, volName string) string {
+	return grpName + "-" + volName
+}
+
+// NewGroupSnapshot returns the ZFSSnapshot of the volume taken by the group
+// snapshot
+func NewGroupSnapshot(grp *apis.ZFSSnapshotGroup, vol *apis.ZFSVolume) *apis.ZFSSnapshot {
+	snap := &apis.ZFSSnapshot{}
+	snap.Name = GroupSnapshotName(grp.Name, vol.Name)
+	snap.Labels = map[string]string{
+		ZFSVolKey:       vol.Name,
+		ZFSSnapGroupKey: grp.Name,
+	}
+	snap.Spec = vol.Spec
+	snap.Status.State = ZFSStatusPending
+	return snap
+}
+
+// groupSnapshots returns the snapshots of the volumes taken by the group
+// snapshot, all the volumes have to be on the node and the pool of the group
+// as the zfs snapshot command is atomic only within a pool
+func groupSnapshots(grp *apis.ZFSSnapshotGroup, vols []*apis.ZFSVolume) ([]*apis.ZFSSnapshot, error) {
+	var snaps []*apis.ZFSSnapshot
+	for _, vol := range vols {
+		if vol.Spec.OwnerNodeID != grp.Spec.OwnerNodeID || vol.Spec.PoolName != grp.Spec.PoolName {
+			return nil, fmt.Errorf("%w %s, volume %s is on %s/%s instead of %s/%s", ErrSnapshotGroupInvalid, grp.Name,
+				vol.Name, vol.Spec.OwnerNodeID, vol.Spec.PoolName, grp.Spec.OwnerNodeID, grp.Spec.PoolName)
+		}
+		snaps = append(snaps, NewGroupSnapshot(grp, vol))
+	}
+	return snaps, nil
+}
+
+// takeGroupSnapshots takes all the snapshots in a single zfs snapshot
+// command, it is a no-op if they have already been taken
+func takeGroupSnapshots(snaps []*apis.ZFSSnapshot) error {
+	var present []string
+	for _, snap := range snaps {
+		snapDataset := snap.Spec.PoolName + "/" + snap.Labels[ZFSVolKey] + "@" + snap.Name
+		if err := getVolume(snapDataset); err == nil {
+			present = append(present, snapDataset)
+		}
+	}
+	if len(present) == len(snaps) {
+		klog.Infof("group snapshots already there %v", present)
+		return nil
+	}
+	// the snapshots are taken atomically, some of them being present means
+	// they have not been taken together, the rest of them can never be
+	// taken at the same instant
+	if len(present) > 0 {
+		return fmt.Errorf("%w, snapshots %v are already present, can not take the rest of them at the same instant",
+			ErrSnapshotGroupInvalid, present)
+	}
+	if err := backend.CreateSnapshots(snaps); err != nil {
+		return err
+	}
+	klog.Infof("created group snapshots %v", buildZFSSnapGroupCreateArgs(snaps)[1:])
+	return nil
+}
+
+// CreateSnapshotGroup takes the snapshots of all the volumes of the group
+// at the same instant, and creates the ZFSSnapshot of each of them
+func CreateSnapshotGroup(grp *apis.ZFSSnapshotGroup) error {
+	var vols []*apis.ZFSVolume
+	for _, name := range grp.Spec.VolumeNames {
+		vol, err := GetZFSVolume(name)
+		if k8serror.IsNotFound(err) {
+			return fmt.Errorf("%w %s, volume %s not found", ErrSnapshotGroupInvalid, grp.Name, name)
+		}
+		if err != nil {
+			return err
+		}
+		vols = append(vols, vol)
+	}
+	snaps, err := groupSnapshots(grp, vols)
+	if err != nil {
+		return err
+	}
+	if err := takeGroupSnapshots(snaps); err != nil {
+		return err
+	}
+	// the ZFSSnapshots find their snapshots already taken and become Ready
+	for _, snap := range snaps {
+		if err := ProvisionSnapshot(snap); err != nil && !k8serror.IsAlreadyExists(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteGroupSnapshots deletes the ZFSSnapshots taken by the group, their
+// snapshots are destroyed by the node agent like any other ZFSSnapshot
+func DeleteGroupSnapshots(grp *apis.ZFSSnapshotGroup) error {
+	listOptions := metav1.ListOptions{
+		LabelSelector: labels.Set{ZFSSnapGroupKey: grp.Name}.String(),
+	}
+	snaps, err := snapbuilder.NewKubeclient().WithNamespace(OpenEBSNamespace).List(listOptions)
+	if err != nil {
+		return err
+	}
+	for _, snap := range snaps.Items {
+		if snap.DeletionTimestamp != nil {
+			continue
+		}
+		if err := DeleteSnapshot(snap.Name); err != nil && !k8serror.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+)
+
+func TestGroupSnapshots(t *testing.T) {
+	fake := useFakeZFS(t)
+
+	data := testVolume("pvc-data", VolTypeDataset, "1073741824", "yes")
+	wal := testVolume("pvc-wal", VolTypeZVol, "1073741824", "yes")
+	for _, vol := range []*apis.ZFSVolume{data, wal} {
+		if err := CreateVolume(vol); err != nil {
+			t.Fatalf("CreateVolume(%s) error = %v", vol.Name, err)
+		}
+	}
+	grp := &apis.ZFSSnapshotGroup{}
+	grp.Name = "grp-1"
+	grp.Spec.PoolName = "pool"
+	grp.Spec.VolumeNames = []string{data.Name, wal.Name}
+
+	snaps, err := groupSnapshots(grp, []*apis.ZFSVolume{data, wal})
+	if err != nil {
+		t.Fatalf("groupSnapshots() error = %v", err)
+	}
+	args := buildZFSSnapGroupCreateArgs(snaps)
+	want := []string{"snapshot", "pool/pvc-data@grp-1-pvc-data", "pool/pvc-wal@grp-1-pvc-wal"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("buildZFSSnapGroupCreateArgs() = %v, want %v", args, want)
+	}
+	if snaps[1].Labels[ZFSSnapGroupKey] != grp.Name || snaps[1].Labels[ZFSVolKey] != wal.Name ||
+		snaps[1].Spec.VolumeType != VolTypeZVol {
+		t.Errorf("group snapshot of %s = %v %v", wal.Name, snaps[1].Labels, snaps[1].Spec)
+	}
+
+	// all the snapshots are taken in the same txg
+	if err := takeGroupSnapshots(snaps); err != nil {
+		t.Fatalf("takeGroupSnapshots() error = %v", err)
+	}
+	ds1, ok1 := fake.Dataset("pool/pvc-data@grp-1-pvc-data")
+	ds2, ok2 := fake.Dataset("pool/pvc-wal@grp-1-pvc-wal")
+	if !ok1 || !ok2 {
+		t.Fatalf("group snapshots are not present")
+	}
+	if ds1.CreateTxg != ds2.CreateTxg {
+		t.Errorf("group snapshots txg = %d and %d, want the same", ds1.CreateTxg, ds2.CreateTxg)
+	}
+	if err := takeGroupSnapshots(snaps); err != nil {
+		t.Errorf("takeGroupSnapshots() retry error = %v", err)
+	}
+
+	// the rest of the snapshots can not be taken at the same instant
+	if err := DestroySnapshot(snaps[1]); err != nil {
+		t.Fatalf("DestroySnapshot() error = %v", err)
+	}
+	if err := takeGroupSnapshots(snaps); !errors.Is(err, ErrSnapshotGroupInvalid) {
+		t.Errorf("takeGroupSnapshots() with partially present snapshots error = %v, want %v", err, ErrSnapshotGroupInvalid)
+	}
+	if _, ok := fake.Dataset("pool/pvc-wal@grp-1-pvc-wal"); ok {
+		t.Errorf("snapshot is taken separately from the rest of the group")
+	}
+
+	// none of the snapshots are taken if any of them can not be
+	grp.Name = "grp-2"
+	missing := testVolume("pvc-missing", VolTypeDataset, "1073741824", "yes")
+	snaps, err = groupSnapshots(grp, []*apis.ZFSVolume{data, missing})
+	if err != nil {
+		t.Fatalf("groupSnapshots() error = %v", err)
+	}
+	if err := takeGroupSnapshots(snaps); err == nil {
+		t.Errorf("takeGroupSnapshots() of missing volume error = nil")
+	}
+	if _, ok := fake.Dataset("pool/pvc-data@grp-2-pvc-data"); ok {
+		t.Errorf("snapshot is taken while the group snapshot has failed")
+	}
+
+	// the volumes have to be in the pool of the group
+	wal.Spec.PoolName = "other"
+	if _, err := groupSnapshots(grp, []*apis.ZFSVolume{data, wal}); !errors.Is(err, ErrSnapshotGroupInvalid) {
+		t.Errorf("groupSnapshots() across pools error = %v, want %v", err, ErrSnapshotGroupInvalid)
+	}
+}
@@ -0,0 +1,126 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+)
+
+// PoolHealthOnline is the health of the pool with all its devices working,
+// the pool is DEGRADED, FAULTED, OFFLINE, UNAVAIL, REMOVED or SUSPENDED
+// otherwise
+const PoolHealthOnline = "ONLINE"
+
+// VolumeHealthy is the message of the condition of the healthy volume
+const VolumeHealthy = "volume is healthy"
+
+// PoolCondition returns the reason the volumes in the pool are abnormal
+// for as per the health of the pool among the pools, empty if the pool is
+// healthy. The pool of unknown health is considered to be healthy. The
+// pool name may be a dataset in the pool, like zfspv-pool/child, in which
+// case the health is the one of its root pool.
+func PoolCondition(pools []apis.Pool, poolName string) string {
+	root := rootPool(poolName)
+	for _, pool := range pools {
+		if pool.Name != root {
+			continue
+		}
+		if pool.Health != "" && pool.Health != PoolHealthOnline {
+			return fmt.Sprintf("pool %s is %s", root, pool.Health)
+		}
+		return ""
+	}
+	return fmt.Sprintf("pool %s is not present", root)
+}
+
+// rootPool returns the pool the dataset is in
+func rootPool(name string) string {
+	root, _, _ := strings.Cut(name, "/")
+	return root
+}
+
+// poolHealthTTL is the time the pools listed by VolumeCondition are
+// cached for, as the condition is polled for every volume on the node
+var poolHealthTTL = 30 * time.Second
+
+// poolHealthCache caches the pools listed by VolumeCondition
+var poolHealthCache struct {
+	sync.Mutex
+	pools   []apis.Pool
+	expires time.Time
+}
+
+// cachedPools returns the pools on the node, listing them
+// only once the ones listed before have expired
+func cachedPools() ([]apis.Pool, error) {
+	poolHealthCache.Lock()
+	defer poolHealthCache.Unlock()
+	if time.Now().Before(poolHealthCache.expires) {
+		return poolHealthCache.pools, nil
+	}
+	pools, err := ListZFSPool()
+	if err != nil {
+		return nil, err
+	}
+	poolHealthCache.pools = pools
+	poolHealthCache.expires = time.Now().Add(poolHealthTTL)
+	return pools, nil
+}
+
+// VolumeCondition returns whether the volume is abnormal along with the
+// message describing its condition. The volume is abnormal if its pool is
+// not healthy, the parent dataset of the volumes in the pool is not present
+// or the volume is not present. The health of the pool is cached for
+// poolHealthTTL.
+func VolumeCondition(vol *apis.ZFSVolume) (bool, string) {
+	pools, err := cachedPools()
+	if err != nil {
+		return true, fmt.Sprintf("failed to list the pools: %v", err)
+	}
+	if reason := PoolCondition(pools, vol.Spec.PoolName); reason != "" {
+		return true, reason
+	}
+	if rootPool(vol.Spec.PoolName) != vol.Spec.PoolName {
+		if err := backend.GetDataset(vol.Spec.PoolName); err != nil {
+			return true, fmt.Sprintf("dataset %s is not present", vol.Spec.PoolName)
+		}
+	}
+
+	volume := vol.Spec.PoolName + "/" + vol.Name
+	if err := backend.GetDataset(volume); err != nil {
+		return true, fmt.Sprintf("volume %s is not present", volume)
+	}
+	return false, VolumeHealthy
+}
+
+// IsStaleMount returns true if the error returned by the access to the
+// mount path is due to its mount having gone stale, the device or the
+// dataset underneath has gone away
+func IsStaleMount(err error) bool {
+	return errors.Is(err, syscall.ESTALE) ||
+		errors.Is(err, syscall.ENOTCONN) ||
+		errors.Is(err, syscall.EIO) ||
+		errors.Is(err, syscall.ENODEV) ||
+		errors.Is(err, syscall.ENXIO)
+}
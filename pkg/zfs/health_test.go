@@ -0,0 +1,110 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// resetPoolHealthCache drops the pools cached by VolumeCondition
+func resetPoolHealthCache() {
+	poolHealthCache.Lock()
+	defer poolHealthCache.Unlock()
+	poolHealthCache.pools = nil
+	poolHealthCache.expires = time.Time{}
+}
+
+func TestVolumeCondition(t *testing.T) {
+	fake := useFakeZFS(t)
+
+	vol := testVolume("pvc-1", VolTypeDataset, "1073741824", "yes")
+	if abnormal, msg := VolumeCondition(vol); !abnormal || msg != "volume pool/pvc-1 is not present" {
+		t.Errorf("VolumeCondition() of missing volume = %v, %q", abnormal, msg)
+	}
+
+	if err := CreateVolume(vol); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	if abnormal, msg := VolumeCondition(vol); abnormal || msg != VolumeHealthy {
+		t.Errorf("VolumeCondition() of healthy volume = %v, %q", abnormal, msg)
+	}
+
+	// the health of the pool is cached until it expires
+	fake.SetPoolHealth("pool", "DEGRADED")
+	if abnormal, msg := VolumeCondition(vol); abnormal || msg != VolumeHealthy {
+		t.Errorf("VolumeCondition() with cached pool health = %v, %q", abnormal, msg)
+	}
+	resetPoolHealthCache()
+	if abnormal, msg := VolumeCondition(vol); !abnormal || msg != "pool pool is DEGRADED" {
+		t.Errorf("VolumeCondition() in degraded pool = %v, %q", abnormal, msg)
+	}
+
+	vol.Spec.PoolName = "tank"
+	if abnormal, msg := VolumeCondition(vol); !abnormal || msg != "pool tank is not present" {
+		t.Errorf("VolumeCondition() in missing pool = %v, %q", abnormal, msg)
+	}
+}
+
+func TestVolumeConditionChildDataset(t *testing.T) {
+	fake := useFakeZFS(t)
+
+	vol := testVolume("pvc-1", VolTypeDataset, "1073741824", "yes")
+	vol.Spec.PoolName = "pool/child"
+	if abnormal, msg := VolumeCondition(vol); !abnormal || msg != "dataset pool/child is not present" {
+		t.Errorf("VolumeCondition() in missing dataset = %v, %q", abnormal, msg)
+	}
+
+	if _, err := fake.Output(ZFSVolCmd, "create", "pool/child"); err != nil {
+		t.Fatalf("create pool/child error = %v", err)
+	}
+	if err := CreateVolume(vol); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	if abnormal, msg := VolumeCondition(vol); abnormal || msg != VolumeHealthy {
+		t.Errorf("VolumeCondition() of healthy volume in dataset = %v, %q", abnormal, msg)
+	}
+
+	// the health is the one of the root pool
+	fake.SetPoolHealth("pool", "FAULTED")
+	resetPoolHealthCache()
+	if abnormal, msg := VolumeCondition(vol); !abnormal || msg != "pool pool is FAULTED" {
+		t.Errorf("VolumeCondition() in faulted pool = %v, %q", abnormal, msg)
+	}
+}
+
+func TestDecodeHealthOutput(t *testing.T) {
+	out := []byte("zfspv-pool\tONLINE\ntank\tFAULTED\n")
+	want := map[string]string{"zfspv-pool": "ONLINE", "tank": "FAULTED"}
+	if got := decodeHealthOutput(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("decodeHealthOutput() = %v, want %v", got, want)
+	}
+}
+
+func TestIsStaleMount(t *testing.T) {
+	stale := &os.PathError{Op: "statfs", Path: "/mnt", Err: syscall.ESTALE}
+	if !IsStaleMount(fmt.Errorf("statfs failed: %w", stale)) {
+		t.Errorf("IsStaleMount(%v) = false", stale)
+	}
+	if IsStaleMount(syscall.ENOENT) {
+		t.Errorf("IsStaleMount(%v) = true", syscall.ENOENT)
+	}
+}
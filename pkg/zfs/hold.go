@@ -0,0 +1,152 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// BackupHoldPrefix is the prefix of the user hold tag placed on the
+	// snapshots used by the backup while it is being transferred
+	BackupHoldPrefix = "openebs-backup-"
+
+	// CloneHoldPrefix is the prefix of the user hold tag placed on the
+	// origin snapshot of the clone volume as long as the clone is present
+	CloneHoldPrefix = "openebs-clone-"
+)
+
+// SnapshotHeldRetryInterval is the interval after which the destroy
+// of the snapshot blocked by the user holds is retried
+var SnapshotHeldRetryInterval = 30 * time.Second
+
+// SnapshotHeldError is returned when the snapshot can not be destroyed as
+// the backups or the clones using it have placed the user holds on it
+type SnapshotHeldError struct {
+	Snapshot string
+	Holds    []string
+}
+
+func (e *SnapshotHeldError) Error() string {
+	return fmt.Sprintf("zfs: snapshot %s is held by %s", e.Snapshot, strings.Join(e.Holds, ", "))
+}
+
+// holdSnapshot places the user hold with the tag on the snapshot,
+// it is a no-op if the snapshot has already been held with the tag
+func holdSnapshot(snapshot, tag string) error {
+	holds, err := backend.Holds(snapshot)
+	if err != nil {
+		return err
+	}
+	for _, h := range holds {
+		if h == tag {
+			return nil
+		}
+	}
+	if err := backend.Hold(snapshot, tag); err != nil {
+		return err
+	}
+	klog.Infof("placed hold %s on snapshot %s", tag, snapshot)
+	return nil
+}
+
+// releaseSnapshot releases the user hold with the tag from the snapshot,
+// it is a no-op if the snapshot is not present or is not held with the tag
+func releaseSnapshot(snapshot, tag string) error {
+	holds, err := backend.Holds(snapshot)
+	if err != nil {
+		if backend.GetDataset(snapshot) != nil {
+			return nil
+		}
+		return err
+	}
+	for _, h := range holds {
+		if h == tag {
+			if err := backend.Release(snapshot, tag); err != nil {
+				return err
+			}
+			klog.Infof("released hold %s from snapshot %s", tag, snapshot)
+			return nil
+		}
+	}
+	return nil
+}
+
+// backupHoldSnapshots returns the snapshots of the volume the backup is sent
+// from, the backup snapshot and the snapshot of the incremental base if any
+func backupHoldSnapshots(bkp *apis.ZFSBackup, vol *apis.ZFSVolume) []string {
+	volume := vol.Spec.PoolName + "/" + vol.Name
+	snapshots := []string{volume + "@" + bkp.Spec.SnapName}
+	if strings.HasPrefix(bkp.Transfer.IncrementalBase, "@") {
+		snapshots = append(snapshots, volume+bkp.Transfer.IncrementalBase)
+	}
+	return snapshots
+}
+
+// holdBackup holds the snapshots the backup is sent from, so that they
+// are not destroyed while the backup is being transferred
+func holdBackup(bkp *apis.ZFSBackup, vol *apis.ZFSVolume) error {
+	for _, snapshot := range backupHoldSnapshots(bkp, vol) {
+		if err := holdSnapshot(snapshot, BackupHoldPrefix+bkp.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseBackup releases the holds placed on the snapshots by the backup
+func releaseBackup(bkp *apis.ZFSBackup, vol *apis.ZFSVolume) error {
+	for _, snapshot := range backupHoldSnapshots(bkp, vol) {
+		if err := releaseSnapshot(snapshot, BackupHoldPrefix+bkp.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReleaseBackup releases the holds placed on the snapshots by the backup
+// which has failed and will not be retried anymore
+func ReleaseBackup(bkp *apis.ZFSBackup) error {
+	vol, err := GetZFSVolume(bkp.Spec.VolumeName)
+	if err != nil {
+		return err
+	}
+	return releaseBackup(bkp, vol)
+}
+
+// cloneOrigin returns the origin snapshot of the clone volume
+func cloneOrigin(vol *apis.ZFSVolume) string {
+	return vol.Spec.PoolName + "/" + vol.Spec.SnapName
+}
+
+// checkSnapshotHolds returns the SnapshotHeldError if the snapshot
+// has the user holds on it which block its destroy
+func checkSnapshotHolds(snapshot string) error {
+	holds, err := backend.Holds(snapshot)
+	if err != nil {
+		return err
+	}
+	if len(holds) > 0 {
+		return &SnapshotHeldError{Snapshot: snapshot, Holds: holds}
+	}
+	return nil
+}
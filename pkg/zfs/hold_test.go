@@ -0,0 +1,96 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+)
+
+func TestSnapshotHolds(t *testing.T) {
+	useFakeZFS(t)
+
+	src := testVolume("pvc-1", VolTypeDataset, "1073741824", "yes")
+	if err := CreateVolume(src); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	newSnap := func(name string) *apis.ZFSSnapshot {
+		snap := &apis.ZFSSnapshot{}
+		snap.Name = name
+		snap.Spec = src.Spec
+		snap.Labels = map[string]string{ZFSVolKey: src.Name}
+		if err := CreateSnapshot(snap); err != nil {
+			t.Fatalf("CreateSnapshot(%s) error = %v", name, err)
+		}
+		return snap
+	}
+	snap1 := newSnap("snap-1")
+	snap2 := newSnap("snap-2")
+
+	// the clone holds its origin snapshot
+	clone := testVolume("pvc-2", VolTypeDataset, "1073741824", "yes")
+	clone.Spec.SnapName = "pvc-1@snap-1"
+	if err := CreateClone(clone); err != nil {
+		t.Fatalf("CreateClone() error = %v", err)
+	}
+	if err := CreateClone(clone); err != nil {
+		t.Fatalf("CreateClone() retry error = %v", err)
+	}
+	if holds, _ := backend.Holds("pool/pvc-1@snap-1"); !reflect.DeepEqual(holds, []string{"openebs-clone-pvc-2"}) {
+		t.Errorf("origin snapshot holds = %v, want [openebs-clone-pvc-2]", holds)
+	}
+
+	// the backup holds the backup snapshot and the incremental base
+	bkp := &apis.ZFSBackup{}
+	bkp.Name = "bkp-1"
+	bkp.Spec.SnapName = "snap-2"
+	bkp.Transfer.IncrementalBase = "@snap-1"
+	if err := holdBackup(bkp, src); err != nil {
+		t.Fatalf("holdBackup() error = %v", err)
+	}
+	if holds, _ := backend.Holds("pool/pvc-1@snap-1"); len(holds) != 2 {
+		t.Errorf("incremental base holds = %v, want the clone and the backup", holds)
+	}
+
+	var held *SnapshotHeldError
+	if err := DestroySnapshot(snap2); !errors.As(err, &held) || held.Holds[0] != "openebs-backup-bkp-1" {
+		t.Fatalf("DestroySnapshot() of held snapshot error = %v, want SnapshotHeldError", err)
+	}
+	if err := releaseBackup(bkp, src); err != nil {
+		t.Fatalf("releaseBackup() error = %v", err)
+	}
+	if err := releaseBackup(bkp, src); err != nil {
+		t.Errorf("releaseBackup() retry error = %v", err)
+	}
+	if err := DestroySnapshot(snap2); err != nil {
+		t.Errorf("DestroySnapshot() of released snapshot error = %v", err)
+	}
+
+	// the origin snapshot is released once the clone is destroyed
+	if err := DestroySnapshot(snap1); !errors.As(err, &held) {
+		t.Errorf("DestroySnapshot() of clone origin error = %v, want SnapshotHeldError", err)
+	}
+	if err := DestroyVolume(clone); err != nil {
+		t.Fatalf("DestroyVolume() clone error = %v", err)
+	}
+	if err := DestroySnapshot(snap1); err != nil {
+		t.Errorf("DestroySnapshot() after destroying the clone error = %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	k8serror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// LocalRestoreSend and LocalRestoreClone are the modes of the restore
+	// from a volume present on the same node. The send mode receives a copy
+	// of the snapshot using zfs send | zfs recv, the clone mode clones the
+	// snapshot, the clone depends on the source volume.
+	LocalRestoreSend  = "send"
+	LocalRestoreClone = "clone"
+)
+
+// isLocalSource returns true if the restore source is <volume> or
+// <volume>@<snapshot>, the remote sources all have a scheme or a port
+func isLocalSource(addr string) bool {
+	return len(addr) > 0 && !strings.Contains(addr, ":")
+}
+
+// parseLocalSource returns the volume and the snapshot of the local
+// restore source, the snapshot is empty if the source is a volume
+func parseLocalSource(addr string) (string, string) {
+	volName, snapName, _ := strings.Cut(addr, "@")
+	return volName, snapName
+}
+
+// localSourceSnapshot returns the snapshot of the source volume to be
+// restored. The snapshot named after the restore is created if the source
+// is a volume, the returned bool is true if it has been created.
+func localSourceSnapshot(rstr *apis.ZFSRestore, src *apis.ZFSVolume) (string, bool, error) {
+	_, snapName := parseLocalSource(rstr.Spec.RestoreSrc)
+	if len(snapName) > 0 {
+		snapshot := src.Spec.PoolName + "/" + src.Name + "@" + snapName
+		if err := backend.GetDataset(snapshot); err != nil {
+			return "", false, fmt.Errorf("zfs: restore source snapshot %s not found", snapshot)
+		}
+		return snapName, false, nil
+	}
+
+	snap := &apis.ZFSSnapshot{}
+	snap.Name = rstr.Name
+	snap.Spec.PoolName = src.Spec.PoolName
+	snap.Labels = map[string]string{ZFSVolKey: src.Name}
+	if err := CreateSnapshot(snap); err != nil {
+		return "", false, fmt.Errorf("zfs: could not snapshot the restore source %s: %v", src.Name, err)
+	}
+	return snap.Name, true, nil
+}
+
+// recvLocal restores the volume from the source volume or its snapshot
+// present on the same node, as per rstr.Spec.LocalMode
+func recvLocal(ctx context.Context, rstr *apis.ZFSRestore, src *apis.ZFSVolume, report progressReporter) error {
+	if len(src.Spec.OwnerNodeID) > 0 && src.Spec.OwnerNodeID != rstr.Spec.OwnerNodeID {
+		return fmt.Errorf("zfs: restore source %s is present on the node %s, not on %s",
+			src.Name, src.Spec.OwnerNodeID, rstr.Spec.OwnerNodeID)
+	}
+	if rstr.Spec.LocalMode == LocalRestoreClone {
+		return cloneLocal(rstr, src)
+	}
+
+	snapName, created, err := localSourceSnapshot(rstr, src)
+	if err != nil {
+		return err
+	}
+
+	// the local stream is sent by the same node agent
+	bkp := &apis.ZFSBackup{}
+	bkp.Name = rstr.Name
+	bkp.Spec.VolumeName = src.Name
+	bkp.Spec.SnapName = snapName
+	bkp.Spec.SendOptions = rstr.Spec.SendOptions
+	bkp.Transfer.ResumeToken = rstr.Transfer.ResumeToken
+
+	size, err := backend.EstimateSend(bkp, src)
+	if err != nil {
+		klog.Warningf("zfs: could not estimate the size of local restore %s, err: %v", rstr.Name, err)
+	}
+	rstr.Transfer.EstimatedSize = size
+
+	pr, pw := io.Pipe()
+	counter := &countWriter{w: pw}
+	progress := startProgress(&rstr.Transfer, counter.count, report)
+	go func() {
+		pw.CloseWithError(backend.Send(ctx, bkp, src, throttledWriter(ctx, counter, rstr.Spec.Limits)))
+	}()
+	err = backend.Recv(ctx, rstr, pr)
+	// unblock the send if the receive has failed
+	pr.CloseWithError(err)
+	progress.finish(err)
+	if err != nil {
+		return err
+	}
+
+	if created {
+		if err := destroyLocalSnapshot(rstr, src); err != nil {
+			klog.Warningf("zfs: could not destroy the snapshot %s@%s of local restore %s, err: %v",
+				src.Name, snapName, rstr.Name, err)
+		}
+	}
+	return nil
+}
+
+// cloneLocal clones the snapshot of the source volume. The clone keeps
+// depending on its origin snapshot, which is held as long as the clone is
+// present, so the restored volume has to be destroyed before the source.
+func cloneLocal(rstr *apis.ZFSRestore, src *apis.ZFSVolume) error {
+	if src.Spec.PoolName != rstr.VolSpec.PoolName {
+		return fmt.Errorf("zfs: restore source %s is in the pool %s, the clone can only be restored in the same pool, not %s",
+			src.Name, src.Spec.PoolName, rstr.VolSpec.PoolName)
+	}
+
+	vol := &apis.ZFSVolume{}
+	vol.Name = rstr.Spec.VolumeName
+	vol.Spec = rstr.VolSpec
+	volume := vol.Spec.PoolName + "/" + vol.Name
+
+	if err := getVolume(volume); err != nil {
+		snapName, _, err := localSourceSnapshot(rstr, src)
+		if err != nil {
+			return err
+		}
+		vol.Spec.SnapName = src.Name + "@" + snapName
+		if err := backend.CreateClone(vol); err != nil {
+			return err
+		}
+		klog.Infof("created clone %s of %s for the restore %s", volume, vol.Spec.SnapName, rstr.Name)
+	}
+
+	// the volume has already been cloned if the restore is retried
+	origin, err := backend.GetProperty(volume, "origin")
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(origin, src.Spec.PoolName+"/"+src.Name+"@") {
+		return fmt.Errorf("zfs: restore volume %s already exists and is not a clone of %s", volume, src.Name)
+	}
+	return holdSnapshot(origin, CloneHoldPrefix+vol.Name)
+}
+
+// destroyLocalSnapshot destroys the snapshot of the source volume taken
+// by the local restore in send mode, it is a no-op if it is not present
+func destroyLocalSnapshot(rstr *apis.ZFSRestore, src *apis.ZFSVolume) error {
+	snap := &apis.ZFSSnapshot{}
+	snap.Name = rstr.Name
+	snap.Spec.PoolName = src.Spec.PoolName
+	snap.Labels = map[string]string{ZFSVolKey: src.Name}
+	return DestroySnapshot(snap)
+}
+
+// discardLocalSnapshot destroys the snapshot of the source volume taken by
+// the local restore in send mode once the restore has failed for good. The
+// snapshot taken in clone mode is the origin of the clone, it is destroyed
+// along with the source volume.
+func discardLocalSnapshot(rstr *apis.ZFSRestore) error {
+	if !isLocalSource(rstr.Spec.RestoreSrc) || rstr.Spec.LocalMode == LocalRestoreClone {
+		return nil
+	}
+	volName, snapName := parseLocalSource(rstr.Spec.RestoreSrc)
+	if len(snapName) > 0 {
+		return nil
+	}
+	src, err := GetZFSVolume(volName)
+	if k8serror.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return destroyLocalSnapshot(rstr, src)
+}
@@ -0,0 +1,225 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	"github.com/openebs/zfs-localpv/pkg/zfs/zfstest"
+)
+
+func TestIsLocalSource(t *testing.T) {
+	tests := map[string]bool{
+		"pvc-1":                    true,
+		"pvc-1@snap-1":             true,
+		"10.0.0.1:9000":            false,
+		"file:///backups/pvc-1/s1": false,
+		"s3://bucket/pvc-1/s1":     false,
+		"":                         false,
+	}
+	for addr, want := range tests {
+		if got := isLocalSource(addr); got != want {
+			t.Errorf("isLocalSource(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestLocalRestore(t *testing.T) {
+	fake := useFakeZFS(t)
+
+	src := testVolume("pvc-1", VolTypeDataset, "1073741824", "yes")
+	src.Spec.OwnerNodeID = "node-1"
+	if err := CreateVolume(src); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	if err := fake.Write("pool/pvc-1", 4096); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	snap := &apis.ZFSSnapshot{}
+	snap.Name = "snap-1"
+	snap.Spec.PoolName = src.Spec.PoolName
+	snap.Labels = map[string]string{ZFSVolKey: src.Name}
+	if err := CreateSnapshot(snap); err != nil {
+		t.Fatalf("CreateSnapshot() error = %v", err)
+	}
+	if err := fake.Write("pool/pvc-1", 8192); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	newRestore := func(name, volName, restoreSrc, mode string) *apis.ZFSRestore {
+		rstr := &apis.ZFSRestore{}
+		rstr.Name = name
+		rstr.Spec.VolumeName = volName
+		rstr.Spec.OwnerNodeID = "node-1"
+		rstr.Spec.RestoreSrc = restoreSrc
+		rstr.Spec.LocalMode = mode
+		rstr.VolSpec = src.Spec
+		return rstr
+	}
+
+	// the volume is restored from the snapshot taken by the restore
+	rstr := newRestore("rstr-1", "pvc-2", "pvc-1", "")
+	if err := recvLocal(context.Background(), rstr, src, nil); err != nil {
+		t.Fatalf("recvLocal() error = %v", err)
+	}
+	if ds, ok := fake.Dataset("pool/pvc-2"); !ok || ds.Written != 12288 {
+		t.Errorf("restored volume = %+v, want 12288 bytes written", ds)
+	}
+	if _, ok := fake.Dataset("pool/pvc-2@rstr-1"); !ok {
+		t.Errorf("restored snapshot rstr-1 is not present")
+	}
+	if _, ok := fake.Dataset("pool/pvc-1@rstr-1"); ok {
+		t.Errorf("snapshot taken by the restore has not been destroyed")
+	}
+	if rstr.Transfer.CompletionTime == nil || rstr.Transfer.BytesTransferred == 0 {
+		t.Errorf("local restore transfer = %+v, want completed", rstr.Transfer)
+	}
+
+	// the volume is restored from the given snapshot
+	if err := recvLocal(context.Background(), newRestore("rstr-2", "pvc-3", "pvc-1@snap-1", LocalRestoreSend), src, nil); err != nil {
+		t.Fatalf("recvLocal(snap-1) error = %v", err)
+	}
+	if ds, ok := fake.Dataset("pool/pvc-3"); !ok || ds.Written != 4096 {
+		t.Errorf("volume restored from snap-1 = %+v, want 4096 bytes written", ds)
+	}
+	if _, ok := fake.Dataset("pool/pvc-1@snap-1"); !ok {
+		t.Errorf("source snapshot snap-1 has been destroyed")
+	}
+
+	if err := recvLocal(context.Background(), newRestore("rstr-3", "pvc-4", "pvc-1@snap-x", ""), src, nil); err == nil {
+		t.Errorf("recvLocal() of missing snapshot should fail")
+	}
+	other := newRestore("rstr-3", "pvc-4", "pvc-1", "")
+	other.Spec.OwnerNodeID = "node-2"
+	if err := recvLocal(context.Background(), other, src, nil); err == nil {
+		t.Errorf("recvLocal() of volume present on other node should fail")
+	}
+
+	// the clone keeps depending on its origin, which is held by it
+	rstr = newRestore("rstr-4", "pvc-4", "pvc-1@snap-1", LocalRestoreClone)
+	if err := recvLocal(context.Background(), rstr, src, nil); err != nil {
+		t.Fatalf("recvLocal(clone) error = %v", err)
+	}
+	if origin, _ := backend.GetProperty("pool/pvc-4", "origin"); origin != "pool/pvc-1@snap-1" {
+		t.Errorf("restored clone origin = %s, want pool/pvc-1@snap-1", origin)
+	}
+	if holds, _ := backend.Holds("pool/pvc-1@snap-1"); !reflect.DeepEqual(holds, []string{CloneHoldPrefix + "pvc-4"}) {
+		t.Errorf("holds on the origin = %v, want %s", holds, CloneHoldPrefix+"pvc-4")
+	}
+	if err := recvLocal(context.Background(), rstr, src, nil); err != nil {
+		t.Errorf("recvLocal(clone) retry error = %v", err)
+	}
+
+	if err := recvLocal(context.Background(), newRestore("rstr-5", "pvc-2", "pvc-1", LocalRestoreClone), src, nil); err == nil {
+		t.Errorf("recvLocal(clone) over existing volume should fail")
+	}
+	rstr = newRestore("rstr-6", "pvc-5", "pvc-1", LocalRestoreClone)
+	rstr.VolSpec.PoolName = "pool-2"
+	if err := recvLocal(context.Background(), rstr, src, nil); err == nil {
+		t.Errorf("recvLocal(clone) into other pool should fail")
+	}
+}
+
+// cutSendRunner cuts the first stream sent after its header, as a send
+// killed in between would, and records the resume tokens it is sent from
+type cutSendRunner struct {
+	*zfstest.ZFS
+	cut    bool
+	tokens []string
+}
+
+func (r *cutSendRunner) Transfer(ctx context.Context, stdin io.Reader, stdout io.Writer, stderr io.Writer, name string, args ...string) error {
+	if args[0] != ZFSSendArg {
+		return r.ZFS.Transfer(ctx, stdin, stdout, stderr, name, args...)
+	}
+	var token string
+	if len(args) > 2 && args[1] == "-t" {
+		token = args[2]
+	}
+	r.tokens = append(r.tokens, token)
+	if !r.cut {
+		return r.ZFS.Transfer(ctx, stdin, stdout, stderr, name, args...)
+	}
+	r.cut = false
+
+	var buf bytes.Buffer
+	if err := r.ZFS.Transfer(ctx, stdin, &buf, stderr, name, args...); err != nil {
+		return err
+	}
+	hdr, _ := buf.ReadBytes('\n')
+	if _, err := stdout.Write(hdr); err != nil {
+		return err
+	}
+	return errors.New("signal: killed")
+}
+
+func TestLocalRestoreResume(t *testing.T) {
+	fake := useFakeZFS(t)
+	cut := &cutSendRunner{ZFS: fake, cut: true}
+	SetCommandRunner(cut)
+
+	src := testVolume("pvc-1", VolTypeDataset, "1073741824", "yes")
+	src.Spec.OwnerNodeID = "node-1"
+	if err := CreateVolume(src); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	if err := fake.Write("pool/pvc-1", 4096); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	rstr := &apis.ZFSRestore{}
+	rstr.Name = "rstr-1"
+	rstr.Spec.VolumeName = "pvc-2"
+	rstr.Spec.OwnerNodeID = "node-1"
+	rstr.Spec.RestoreSrc = "pvc-1"
+	rstr.VolSpec = src.Spec
+
+	// the interrupted receive keeps its partial state
+	if err := recvLocal(context.Background(), rstr, src, nil); err == nil {
+		t.Fatalf("recvLocal() of cut stream should fail")
+	}
+	if !RetryRestore(rstr) || len(rstr.Transfer.ResumeToken) == 0 {
+		t.Fatalf("resume token of the local restore is not recorded")
+	}
+	token := rstr.Transfer.ResumeToken
+
+	// the retry resumes the stream from the recorded token
+	if err := recvLocal(context.Background(), rstr, src, nil); err != nil {
+		t.Fatalf("recvLocal() resumed error = %v", err)
+	}
+	if n := len(cut.tokens); n == 0 || cut.tokens[n-1] != token {
+		t.Errorf("resumed send tokens = %v, want last %s", cut.tokens, token)
+	}
+	if ds, ok := fake.Dataset("pool/pvc-2"); !ok || ds.Written != 4096 {
+		t.Errorf("resumed volume = %+v, want 4096 bytes written", ds)
+	}
+	if _, ok := fake.Dataset("pool/pvc-2@rstr-1"); !ok {
+		t.Errorf("restored snapshot rstr-1 is not present")
+	}
+	if val, _ := backend.GetProperty("pool/pvc-2", "receive_resume_token"); val != "-" {
+		t.Errorf("receive_resume_token = %q after the resumed receive", val)
+	}
+	if _, ok := fake.Dataset("pool/pvc-1@rstr-1"); ok {
+		t.Errorf("snapshot taken by the restore has not been destroyed")
+	}
+}
@@ -0,0 +1,145 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	"github.com/openebs/zfs-localpv/pkg/builder/volbuilder"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+)
+
+// overridableFields returns the fields of the volume spec
+// which can be overridden by the restore, by their name
+func overridableFields(spec *apis.VolumeInfo) map[string]*string {
+	return map[string]*string{
+		"poolName":      &spec.PoolName,
+		"compression":   &spec.Compression,
+		"recordsize":    &spec.RecordSize,
+		"quotaType":     &spec.QuotaType,
+		"thinProvision": &spec.ThinProvision,
+	}
+}
+
+// retargetVolume sets the overridden pool and properties of the restored
+// volume in its ZFSVolume, so that the volume is used from the pool it has
+// been restored into with the overridden properties. The ZFSVolume which
+// is not present is left alone, it is to be created with them.
+func retargetVolume(rstr *apis.ZFSRestore) error {
+	if len(rstr.Overridden) == 0 {
+		return nil
+	}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		vol, err := GetZFSVolume(rstr.Spec.VolumeName)
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		changed := false
+		fields := overridableFields(&vol.Spec)
+		for _, p := range rstr.Overridden {
+			if field, ok := fields[p.Name]; ok && *field != p.Value {
+				*field = p.Value
+				changed = true
+			}
+		}
+		if !changed {
+			return nil
+		}
+		_, err = volbuilder.NewKubeclient().WithNamespace(OpenEBSNamespace).Update(vol)
+		if err == nil {
+			klog.Infof("restore %s moved the volume %s to the pool %s", rstr.Name, vol.Name, vol.Spec.PoolName)
+		}
+		return err
+	})
+}
+
+// applyRestoreOverrides sets the properties of rstr.Spec.Overrides in the
+// VolSpec of the restore and records their backed up values. The properties
+// overridden by an earlier attempt keep the values recorded by it, as the
+// VolSpec has already been overridden.
+func applyRestoreOverrides(rstr *apis.ZFSRestore) {
+	o := rstr.Spec.Overrides
+	if o == nil {
+		return
+	}
+	props := []struct {
+		name  string
+		value string
+		field *string
+	}{
+		{"poolName", o.PoolName, &rstr.VolSpec.PoolName},
+		{"compression", o.Compression, &rstr.VolSpec.Compression},
+		{"recordsize", o.RecordSize, &rstr.VolSpec.RecordSize},
+		{"quotaType", o.QuotaType, &rstr.VolSpec.QuotaType},
+		{"thinProvision", o.ThinProvision, &rstr.VolSpec.ThinProvision},
+	}
+	for _, p := range props {
+		if len(p.value) == 0 || restoreOverridden(rstr, p.name) {
+			continue
+		}
+		rstr.Overridden = append(rstr.Overridden, apis.OverriddenProperty{
+			Name:     p.name,
+			Value:    p.value,
+			Original: *p.field,
+		})
+		klog.Infof("restore of %s overrides %s %q with %q", rstr.Spec.VolumeName, p.name, *p.field, p.value)
+		*p.field = p.value
+	}
+}
+
+// restoreOverridden returns true if the property of VolSpec has been
+// overridden by the restore
+func restoreOverridden(rstr *apis.ZFSRestore, name string) bool {
+	for _, p := range rstr.Overridden {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// buildRestoreOverrideArgs returns the zfs recv arguments for the overridden
+// quota type and thin provisioning, which exclude the properties received
+// with the stream contradicting them. The other overridden properties are
+// set with -o as per the VolSpec.
+func buildRestoreOverrideArgs(rstr *apis.ZFSRestore) []string {
+	var args []string
+	if rstr.VolSpec.VolumeType == VolTypeDataset {
+		switch {
+		case !restoreOverridden(rstr, "quotaType"):
+		case rstr.VolSpec.QuotaType == "refquota":
+			args = append(args, "-x", "quota")
+		default:
+			args = append(args, "-x", "refquota")
+		}
+	}
+	if restoreOverridden(rstr, "thinProvision") {
+		switch {
+		case rstr.VolSpec.ThinProvision == "no" && rstr.VolSpec.VolumeType != VolTypeDataset:
+			args = append(args, "-o", "refreservation=auto")
+		case rstr.VolSpec.ThinProvision == "yes" && rstr.VolSpec.VolumeType == VolTypeDataset:
+			args = append(args, "-x", "reservation")
+		case rstr.VolSpec.ThinProvision == "yes":
+			args = append(args, "-x", "refreservation")
+		}
+	}
+	return args
+}
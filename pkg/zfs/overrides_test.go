@@ -0,0 +1,134 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRestoreOverrides(t *testing.T) {
+	fake := useFakeZFS(t)
+	fake.AddPool("dr-pool", 10*testGi)
+
+	vol := testVolume("pvc-1", VolTypeDataset, "1073741824", "no")
+	vol.Spec.Compression = "lz4"
+	if err := CreateVolume(vol); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	snap := &apis.ZFSSnapshot{}
+	snap.Name = "bkp-1"
+	snap.Spec.PoolName = vol.Spec.PoolName
+	snap.Labels = map[string]string{ZFSVolKey: vol.Name}
+	if err := CreateSnapshot(snap); err != nil {
+		t.Fatalf("CreateSnapshot() error = %v", err)
+	}
+	bkp := &apis.ZFSBackup{}
+	bkp.Spec.VolumeName = vol.Name
+	bkp.Spec.SnapName = snap.Name
+	bkp.Spec.SendOptions = &apis.SendOptions{Props: true}
+	addr, recvd := backupServer(t, nil)
+	bkp.Spec.BackupDest = addr
+	if err := sendBackup(bkp, vol); err != nil {
+		t.Fatalf("sendBackup() error = %v", err)
+	}
+
+	rstr := &apis.ZFSRestore{}
+	rstr.Spec.VolumeName = "pvc-2"
+	rstr.Spec.RestoreSrc = restoreServer(t, nil, <-recvd)
+	rstr.Spec.Overrides = &apis.RestoreOverrides{
+		PoolName:      "dr-pool",
+		Compression:   "zstd",
+		RecordSize:    "64k",
+		QuotaType:     "quota",
+		ThinProvision: "yes",
+	}
+	rstr.VolSpec = vol.Spec
+	applyRestoreOverrides(rstr)
+	if err := recvRestore(rstr); err != nil {
+		t.Fatalf("recvRestore() error = %v", err)
+	}
+
+	ds, ok := fake.Dataset("dr-pool/pvc-2")
+	if !ok {
+		t.Fatalf("volume has not been restored into the overridden pool")
+	}
+	for prop, want := range map[string]string{
+		"compression": "zstd",
+		"recordsize":  "64k",
+		"quota":       "1073741824",
+		"refquota":    "",
+		"reservation": "",
+	} {
+		if got := ds.Props[prop]; got != want {
+			t.Errorf("restored volume %s = %q, want %q", prop, got, want)
+		}
+	}
+	want := []apis.OverriddenProperty{
+		{Name: "poolName", Value: "dr-pool", Original: "pool"},
+		{Name: "compression", Value: "zstd", Original: "lz4"},
+		{Name: "recordsize", Value: "64k"},
+		{Name: "quotaType", Value: "quota", Original: "refquota"},
+		{Name: "thinProvision", Value: "yes", Original: "no"},
+	}
+	if !reflect.DeepEqual(rstr.Overridden, want) {
+		t.Errorf("overridden = %+v, want %+v", rstr.Overridden, want)
+	}
+
+	// the retried restore keeps the recorded backed up values
+	applyRestoreOverrides(rstr)
+	if !reflect.DeepEqual(rstr.Overridden, want) {
+		t.Errorf("overridden after the retry = %+v, want %+v", rstr.Overridden, want)
+	}
+}
+
+func TestRetargetVolume(t *testing.T) {
+	cs := useAPIServer(t)
+
+	rstr := &apis.ZFSRestore{}
+	rstr.Name = "rstr-1"
+	rstr.Spec.VolumeName = "pvc-1"
+	rstr.Overridden = []apis.OverriddenProperty{
+		{Name: "poolName", Value: "dr-pool", Original: "pool"},
+		{Name: "compression", Value: "zstd", Original: "lz4"},
+	}
+	// the ZFSVolume yet to be created is left alone
+	if err := retargetVolume(rstr); err != nil {
+		t.Fatalf("retargetVolume() of missing volume error = %v", err)
+	}
+
+	vol := testVolume("pvc-1", VolTypeDataset, "1073741824", "yes")
+	vol.Namespace = OpenEBSNamespace
+	vol.Spec.Compression = "lz4"
+	if _, err := cs.ZfsV1().ZFSVolumes(OpenEBSNamespace).Create(context.TODO(), vol, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := retargetVolume(rstr); err != nil {
+		t.Fatalf("retargetVolume() error = %v", err)
+	}
+	got, err := GetZFSVolume("pvc-1")
+	if err != nil {
+		t.Fatalf("GetZFSVolume() error = %v", err)
+	}
+	if got.Spec.PoolName != "dr-pool" || got.Spec.Compression != "zstd" || got.Spec.QuotaType != "refquota" {
+		t.Errorf("retargeted volume spec = %+v, want dr-pool with zstd", got.Spec)
+	}
+}
@@ -0,0 +1,198 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	"github.com/openebs/zfs-localpv/pkg/builder/bkpbuilder"
+	"github.com/openebs/zfs-localpv/pkg/builder/restorebuilder"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// TransferProgressIntervalKey is the environment variable to set the
+// interval at which the progress of the backup and the restore stream
+// is updated on the ZFSBackup and the ZFSRestore, e.g. "10s"
+const TransferProgressIntervalKey string = "OPENEBS_IO_BACKUP_PROGRESS_INTERVAL"
+
+// TransferProgressInterval is the interval at which the progress of
+// the backup and the restore stream is updated
+var TransferProgressInterval = 10 * time.Second
+
+func init() {
+	TransferProgressInterval = getEnvDuration(TransferProgressIntervalKey, TransferProgressInterval)
+}
+
+// countWriter counts the bytes written to w
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	atomic.AddInt64(&c.n, int64(n))
+	return n, err
+}
+
+func (c *countWriter) count() int64 {
+	return atomic.LoadInt64(&c.n)
+}
+
+// countReader counts the bytes read from r
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	atomic.AddInt64(&c.n, int64(n))
+	return n, err
+}
+
+func (c *countReader) count() int64 {
+	return atomic.LoadInt64(&c.n)
+}
+
+// progressReporter persists the progress of the transfer, it is called
+// from the progress goroutine with a copy of the progress fields only
+type progressReporter func(progress apis.TransferInfo) error
+
+// transferProgress keeps track of the bytes transferred while the stream
+// runs and reports them periodically. The transfer info is owned by the
+// transfer goroutine and only gets updated once the stream is over, the
+// progress goroutine reports from a copy guarded by mu.
+type transferProgress struct {
+	info   *apis.TransferInfo
+	count  func() int64
+	report progressReporter
+
+	mu       sync.Mutex
+	progress apis.TransferInfo
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// startProgress marks the start of the transfer and starts reporting
+// the bytes counted by count every TransferProgressInterval
+func startProgress(info *apis.TransferInfo, count func() int64, report progressReporter) *transferProgress {
+	now := metav1.Now()
+	info.StartTime = &now
+	info.CompletionTime = nil
+	info.BytesTransferred = 0
+	info.Throughput = 0
+
+	p := &transferProgress{
+		info:   info,
+		count:  count,
+		report: report,
+		progress: apis.TransferInfo{
+			StartTime:     info.StartTime.DeepCopy(),
+			EstimatedSize: info.EstimatedSize,
+		},
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+func (p *transferProgress) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(TransferProgressInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			progress := p.update()
+			if p.report == nil {
+				continue
+			}
+			if err := p.report(progress); err != nil {
+				klog.Warningf("zfs: could not update the transfer progress, err: %v", err)
+			}
+		}
+	}
+}
+
+// update sets the bytes transferred so far and the average throughput,
+// and returns a copy of the progress
+func (p *transferProgress) update() apis.TransferInfo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.progress.BytesTransferred = p.count()
+	elapsed := time.Since(p.progress.StartTime.Time)
+	if elapsed > 0 {
+		p.progress.Throughput = int64(float64(p.progress.BytesTransferred) / elapsed.Seconds())
+	}
+	return *p.progress.DeepCopy()
+}
+
+// finish stops the reporting and records the final progress of the
+// transfer, the completion time is set if the transfer succeeded
+func (p *transferProgress) finish(err error) {
+	close(p.stop)
+	<-p.done
+
+	progress := p.update()
+	p.info.BytesTransferred = progress.BytesTransferred
+	p.info.Throughput = progress.Throughput
+	if err == nil {
+		now := metav1.Now()
+		p.info.CompletionTime = &now
+	}
+}
+
+// progressPatch returns the merge patch of the transfer progress, the
+// other fields of the transfer and the spec are left untouched
+func progressPatch(progress apis.TransferInfo) ([]byte, error) {
+	return json.Marshal(map[string]apis.TransferInfo{"transfer": progress})
+}
+
+// patchBkpProgress patches the ZFSBackup with its transfer progress
+func patchBkpProgress(name string, progress apis.TransferInfo) (*apis.ZFSBackup, error) {
+	data, err := progressPatch(progress)
+	if err != nil {
+		return nil, err
+	}
+	return bkpbuilder.NewKubeclient().WithNamespace(OpenEBSNamespace).
+		Patch(name, types.MergePatchType, data)
+}
+
+// patchRestoreProgress patches the ZFSRestore with its transfer progress
+func patchRestoreProgress(name string, progress apis.TransferInfo) (*apis.ZFSRestore, error) {
+	data, err := progressPatch(progress)
+	if err != nil {
+		return nil, err
+	}
+	return restorebuilder.NewKubeclient().WithNamespace(OpenEBSNamespace).
+		Patch(name, types.MergePatchType, data)
+}
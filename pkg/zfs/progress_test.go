@@ -0,0 +1,42 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"testing"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+)
+
+func TestProgressPatch(t *testing.T) {
+	info := &apis.TransferInfo{EstimatedSize: 100, Checksum: "sha256:abc", ResumeToken: "1-abc"}
+	p := startProgress(info, func() int64 { return 40 }, nil)
+	progress := p.update()
+	p.finish(nil)
+
+	data, err := progressPatch(progress)
+	if err != nil {
+		t.Fatalf("progressPatch() error = %v", err)
+	}
+	want := `{"transfer":{"estimatedSize":100,"bytesTransferred":40,`
+	if got := string(data); len(got) < len(want) || got[:len(want)] != want {
+		t.Errorf("progressPatch() = %s, want prefix %s", got, want)
+	}
+	if info.BytesTransferred != 40 || info.Checksum != "sha256:abc" || info.CompletionTime == nil {
+		t.Errorf("finished transfer = %+v", info)
+	}
+}
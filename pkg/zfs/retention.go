@@ -0,0 +1,107 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"errors"
+	"time"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	"k8s.io/klog/v2"
+)
+
+// PruneBackupSnapshots destroys the snapshots of the done backups of the
+// volume which are not kept by its backup retention, bkps being the
+// ZFSBackups known to the node. The snapshot of the most recent done backup,
+// which the next incremental backup is sent from, and the snapshots used by
+// the backups yet to be sent are always kept. The pruned snapshots are
+// bookmarked first, and the held ones are skipped. It returns the names of
+// the pruned snapshots.
+func PruneBackupSnapshots(vol *apis.ZFSVolume, bkps []*apis.ZFSBackup) ([]string, error) {
+	retention := vol.Spec.BackupRetention
+	if retention == nil || (retention.KeepLast == 0 && retention.KeepWithin == nil) {
+		return nil, nil
+	}
+
+	done := map[string]*apis.ZFSBackup{}
+	keep := map[string]bool{}
+	for _, bkp := range bkps {
+		if bkp.Spec.VolumeName != vol.Name || bkp.DeletionTimestamp != nil {
+			continue
+		}
+		switch bkp.Status {
+		case apis.BKPZFSStatusDone:
+			done[bkp.Spec.SnapName] = bkp
+		case apis.BKPZFSStatusFailed, apis.BKPZFSStatusCancelled:
+		default:
+			keep[bkp.Spec.SnapName] = true
+			keep[bkp.Spec.PrevSnapName] = true
+		}
+	}
+
+	// the snapshots are listed in the order they have been taken
+	snaps, err := backend.ListSnapshots(vol.Spec.PoolName + "/" + vol.Name)
+	if err != nil {
+		return nil, err
+	}
+	var backups []*apis.ZFSBackup
+	for _, name := range snaps {
+		if bkp, ok := done[name]; ok {
+			backups = append(backups, bkp)
+		}
+	}
+
+	var pruned []string
+	for i, bkp := range backups {
+		switch {
+		case i == len(backups)-1 || keep[bkp.Spec.SnapName]:
+		case retention.KeepLast > 0 && len(backups)-i <= retention.KeepLast:
+		case retention.KeepWithin != nil && time.Since(bkp.CreationTimestamp.Time) < retention.KeepWithin.Duration:
+		default:
+			var held *SnapshotHeldError
+			err := pruneBackupSnapshot(bkp, vol)
+			if errors.As(err, &held) {
+				klog.Infof("zfs: not pruning the backup snapshot %s, %v", held.Snapshot, err)
+				continue
+			}
+			if err != nil {
+				return pruned, err
+			}
+			pruned = append(pruned, bkp.Spec.SnapName)
+		}
+	}
+	return pruned, nil
+}
+
+// pruneBackupSnapshot bookmarks the snapshot of the done backup, so that the
+// incremental backups can still be sent from it, and destroys the snapshot
+func pruneBackupSnapshot(bkp *apis.ZFSBackup, vol *apis.ZFSVolume) error {
+	snapshot := vol.Spec.PoolName + "/" + vol.Name + "@" + bkp.Spec.SnapName
+	bookmark := backupBookmark(vol, bkp.Spec.SnapName)
+	if !bookmarkExists(bookmark) {
+		if err := backend.CreateBookmark(snapshot, bookmark); err != nil {
+			return err
+		}
+		klog.Infof("created bookmark %s", bookmark)
+	}
+
+	snap := &apis.ZFSSnapshot{}
+	snap.Name = bkp.Spec.SnapName
+	snap.Spec.PoolName = vol.Spec.PoolName
+	snap.Labels = map[string]string{ZFSVolKey: vol.Name}
+	return DestroySnapshot(snap)
+}
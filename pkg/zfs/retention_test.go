@@ -0,0 +1,104 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPruneBackupSnapshots(t *testing.T) {
+	fake := useFakeZFS(t)
+
+	vol := testVolume("pvc-1", VolTypeDataset, "1073741824", "yes")
+	if err := CreateVolume(vol); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	newSnap := func(name string) {
+		snap := &apis.ZFSSnapshot{}
+		snap.Name = name
+		snap.Spec.PoolName = vol.Spec.PoolName
+		snap.Labels = map[string]string{ZFSVolKey: vol.Name}
+		if err := CreateSnapshot(snap); err != nil {
+			t.Fatalf("CreateSnapshot(%s) error = %v", name, err)
+		}
+	}
+
+	var bkps []*apis.ZFSBackup
+	for i, name := range []string{"bkp-1", "bkp-2", "bkp-3", "bkp-4", "bkp-5"} {
+		newSnap(name)
+		bkp := &apis.ZFSBackup{}
+		bkp.Name = name
+		bkp.CreationTimestamp = metav1.NewTime(time.Now().Add(time.Duration(i-4) * 48 * time.Hour))
+		bkp.Spec.VolumeName = vol.Name
+		bkp.Spec.SnapName = name
+		bkp.Status = apis.BKPZFSStatusDone
+		bkps = append(bkps, bkp)
+	}
+	// the snapshots which are not of the backups are not pruned
+	newSnap("snapshot-1")
+
+	// the pending backup is sent from bkp-1, the clone holds bkp-2
+	pending := &apis.ZFSBackup{}
+	pending.Spec.VolumeName = vol.Name
+	pending.Spec.SnapName = "bkp-6"
+	pending.Spec.PrevSnapName = "bkp-1"
+	pending.Status = apis.BKPZFSStatusPending
+	clone := testVolume("pvc-2", VolTypeDataset, "1073741824", "yes")
+	clone.Spec.SnapName = "pvc-1@bkp-2"
+	if err := CreateClone(clone); err != nil {
+		t.Fatalf("CreateClone() error = %v", err)
+	}
+
+	vol.Spec.BackupRetention = &apis.BackupRetention{KeepLast: 2}
+	pruned, err := PruneBackupSnapshots(vol, append(bkps, pending))
+	if err != nil {
+		t.Fatalf("PruneBackupSnapshots() error = %v", err)
+	}
+	if !reflect.DeepEqual(pruned, []string{"bkp-3"}) {
+		t.Errorf("pruned = %v, want [bkp-3]", pruned)
+	}
+	if _, ok := fake.Dataset("pool/pvc-1@bkp-3"); ok || !bookmarkExists("pool/pvc-1#bkp-3") {
+		t.Errorf("pruned snapshot bkp-3 has not been replaced by its bookmark")
+	}
+
+	// the most recent backup snapshot is kept even if it is not within the duration
+	vol.Spec.BackupRetention = &apis.BackupRetention{KeepWithin: &metav1.Duration{Duration: time.Hour}}
+	bkps[4].CreationTimestamp = metav1.NewTime(time.Now().Add(-2 * time.Hour))
+	pruned, err = PruneBackupSnapshots(vol, bkps)
+	if err != nil {
+		t.Fatalf("PruneBackupSnapshots() error = %v", err)
+	}
+	if !reflect.DeepEqual(pruned, []string{"bkp-1", "bkp-4"}) {
+		t.Errorf("pruned = %v, want [bkp-1 bkp-4]", pruned)
+	}
+	for _, name := range []string{"bkp-2", "bkp-5", "snapshot-1"} {
+		if _, ok := fake.Dataset("pool/pvc-1@" + name); !ok {
+			t.Errorf("snapshot %s has been pruned", name)
+		}
+	}
+
+	// no retention, nothing is pruned
+	vol.Spec.BackupRetention = nil
+	if pruned, err := PruneBackupSnapshots(vol, bkps); err != nil || len(pruned) > 0 {
+		t.Errorf("PruneBackupSnapshots() without retention = %v, %v", pruned, err)
+	}
+}
@@ -0,0 +1,246 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// S3TargetScheme is the scheme of the backup destination and the
+	// restore source which are present in an S3 compatible object storage
+	S3TargetScheme = "s3://"
+
+	// S3AccessKeyIDKey, S3SecretAccessKeyKey and S3SessionTokenKey are the
+	// keys of the object storage credentials in the S3Target secret
+	S3AccessKeyIDKey     = "accessKeyID"
+	S3SecretAccessKeyKey = "secretAccessKey"
+	S3SessionTokenKey    = "sessionToken"
+
+	// s3PartSize is the size of the parts of the multipart upload of the
+	// backup stream, it allows the streams of up to 640GiB in 10000 parts
+	s3PartSize = 64 * 1024 * 1024
+)
+
+// s3Target is the bucket and the prefix under which the backups are stored
+type s3Target struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+// parseS3Target returns the bucket and the object key of the s3:// address
+func parseS3Target(addr string) (string, string, error) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(addr, S3TargetScheme), "/")
+	if len(bucket) == 0 {
+		return "", "", fmt.Errorf("zfs: s3 target %s has no bucket", addr)
+	}
+	key = strings.Trim(path.Clean("/"+key), "/")
+	return bucket, key, nil
+}
+
+// isS3Target returns true if the address is an s3:// target
+func isS3Target(addr string) bool {
+	return strings.HasPrefix(addr, S3TargetScheme)
+}
+
+// newS3Target returns the target for the s3:// address using the object
+// storage configured by the spec and the credentials from its secret
+func newS3Target(addr string, spec *apis.S3Target) (*s3Target, error) {
+	if spec == nil {
+		return nil, fmt.Errorf("zfs: s3 target %s needs the s3 configuration", addr)
+	}
+	secret, err := getStreamSecret(spec.SecretName)
+	if err != nil {
+		return nil, fmt.Errorf("zfs: could not get the s3 secret %s: %v", spec.SecretName, err)
+	}
+	return buildS3Target(addr, spec, secret.Data)
+}
+
+// buildS3Target returns the target for the s3:// address using the
+// credentials present in the secret data
+func buildS3Target(addr string, spec *apis.S3Target, data map[string][]byte) (*s3Target, error) {
+	bucket, prefix, err := parseS3Target(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	accessKey, secretKey := data[S3AccessKeyIDKey], data[S3SecretAccessKeyKey]
+	if len(accessKey) == 0 || len(secretKey) == 0 {
+		return nil, fmt.Errorf("zfs: s3 secret %s needs %s and %s",
+			spec.SecretName, S3AccessKeyIDKey, S3SecretAccessKeyKey)
+	}
+
+	transport, err := minio.DefaultTransport(!spec.Insecure)
+	if err != nil {
+		return nil, err
+	}
+	transport.DialContext = (&net.Dialer{Timeout: TransportDialTimeout}).DialContext
+	transport.ResponseHeaderTimeout = TransportIdleTimeout
+
+	client, err := minio.New(spec.Endpoint, &minio.Options{
+		Creds: credentials.NewStaticV4(string(accessKey), string(secretKey),
+			string(data[S3SessionTokenKey])),
+		Secure:    !spec.Insecure,
+		Region:    spec.Region,
+		Transport: transport,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("zfs: invalid s3 endpoint %s: %v", spec.Endpoint, err)
+	}
+	return &s3Target{client: client, bucket: bucket, prefix: prefix}, nil
+}
+
+// key returns the object key of the file of the volume backup
+func (t *s3Target) key(volName, file string) string {
+	return path.Join(t.prefix, volName, file)
+}
+
+// getManifest downloads the backup manifest stored at the key
+func (t *s3Target) getManifest(key string) (*BackupManifest, error) {
+	obj, err := t.client.GetObject(context.TODO(), t.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		return nil, fmt.Errorf("zfs: could not download the backup manifest s3://%s/%s: %v", t.bucket, key, err)
+	}
+	return parseBackupManifest(data, S3TargetScheme+t.bucket+"/"+key)
+}
+
+// sendToS3 uploads the backup snapshot as the stream object along with its
+// manifest under <prefix>/<volume>/ in the bucket. The stream is uploaded in
+// parts as it is sent, so it is never stored on the node.
+func sendToS3(ctx context.Context, bkp *apis.ZFSBackup, vol *apis.ZFSVolume, t *s3Target, codec *streamCodec, report progressReporter) error {
+	var prev *BackupManifest
+	if len(incrementalFrom(bkp)) > 0 {
+		location := S3TargetScheme + t.bucket + "/" + t.key(vol.Name, "")
+		key := t.key(vol.Name, bkp.Spec.PrevSnapName+backupManifestExt)
+		if _, err := t.client.StatObject(ctx, t.bucket, key, minio.StatObjectOptions{}); err == nil {
+			prev, err = t.getManifest(key)
+			if err != nil {
+				return fmt.Errorf("zfs: incremental backup needs the backup of %s in %s: %v",
+					bkp.Spec.PrevSnapName, location, err)
+			}
+		} else if resp := minio.ToErrorResponse(err); resp.StatusCode != http.StatusNotFound {
+			return fmt.Errorf("zfs: could not check the backup of %s in %s: %v", bkp.Spec.PrevSnapName, location, err)
+		}
+		prev = checkTargetChain(bkp, vol, prev, location)
+	}
+	m := newBackupManifest(bkp, vol, prev, codec)
+
+	size, err := backend.EstimateSend(bkp, vol)
+	if err != nil {
+		klog.Warningf("zfs: could not estimate the size of backup %s, err: %v", bkp.Name, err)
+	}
+	bkp.Transfer.EstimatedSize = size
+
+	key := t.key(vol.Name, m.StreamFile)
+	pr, pw := io.Pipe()
+	counter := &countWriter{w: pw}
+
+	progress := startProgress(&bkp.Transfer, counter.count, report)
+	go func() {
+		pw.CloseWithError(sendEncoded(ctx, bkp, vol, counter, codec))
+	}()
+	_, err = t.client.PutObject(ctx, t.bucket, key, pr, -1, minio.PutObjectOptions{
+		ContentType: "application/octet-stream",
+		PartSize:    s3PartSize,
+	})
+	// unblock the send if the upload has failed
+	pr.CloseWithError(err)
+	progress.finish(err)
+	if err != nil {
+		return fmt.Errorf("zfs: could not upload the backup stream to s3://%s/%s: %v", t.bucket, key, err)
+	}
+
+	m.Size = bkp.Transfer.BytesTransferred
+	m.Checksum = bkp.Transfer.Checksum
+	m.SnapGUID = bkp.Transfer.SnapGUID
+	m.CreationTime = metav1.Now()
+
+	var buf bytes.Buffer
+	if err := writeBackupManifest(&buf, m); err != nil {
+		return err
+	}
+	key = t.key(vol.Name, m.SnapName+backupManifestExt)
+	_, err = t.client.PutObject(ctx, t.bucket, key, &buf, int64(buf.Len()), minio.PutObjectOptions{
+		ContentType: "application/json",
+	})
+	if err != nil {
+		return fmt.Errorf("zfs: could not upload the backup manifest to s3://%s/%s: %v", t.bucket, key, err)
+	}
+	return nil
+}
+
+// recvFromS3 receives the volume from the stream object described by the
+// manifest at rstr.Spec.RestoreSrc, the size and the checksum of the stream
+// are verified while it is read
+func recvFromS3(ctx context.Context, rstr *apis.ZFSRestore, t *s3Target, codec *streamCodec, report progressReporter) error {
+	m, err := t.getManifest(t.prefix)
+	if err != nil {
+		return err
+	}
+	recv, err := checkBackupManifest(rstr, m, codec)
+	if err != nil {
+		return err
+	}
+
+	key := path.Join(path.Dir(t.prefix), m.StreamFile)
+	obj, err := t.client.GetObject(ctx, t.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("zfs: could not download the backup stream s3://%s/%s: %v", t.bucket, key, err)
+	}
+	defer obj.Close()
+
+	if info, err := obj.Stat(); err != nil {
+		if resp := minio.ToErrorResponse(err); resp.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("zfs: backup stream s3://%s/%s not found", t.bucket, key)
+		}
+		return fmt.Errorf("zfs: could not download the backup stream s3://%s/%s: %v", t.bucket, key, err)
+	} else if info.Size != m.Size {
+		return fmt.Errorf("zfs: backup stream s3://%s/%s is %d bytes, expected %d", t.bucket, key, info.Size, m.Size)
+	}
+
+	recv.Transfer.EstimatedSize = m.Size
+	counter := &countReader{r: newChecksumReader(obj, m.SnapName, m.Size, "")}
+	progress := startProgress(&recv.Transfer, counter.count, report)
+	err = recvDecoded(ctx, recv, counter, codec)
+	if err == nil {
+		// the stream is verified once it has been read till the end
+		_, err = io.Copy(io.Discard, counter)
+	}
+	progress.finish(err)
+	rstr.Transfer = recv.Transfer
+	return err
+}
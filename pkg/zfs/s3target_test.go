@@ -0,0 +1,301 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+)
+
+// s3Server is a minimal in-memory stand-in for the S3 compatible object
+// storage, it supports the object and the multipart upload requests
+type s3Server struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	uploads map[string]map[int][]byte
+	// multipart is the number of the completed multipart uploads
+	multipart int
+}
+
+func newS3Server(t *testing.T) (*s3Server, *httptest.Server) {
+	s := &s3Server{objects: map[string][]byte{}, uploads: map[string]map[int][]byte{}}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return s, srv
+}
+
+func (s *s3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !strings.Contains(r.Header.Get("Authorization"), "Credential=test-access/") {
+		s3Error(w, http.StatusForbidden, "AccessDenied")
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		id := strconv.Itoa(len(s.uploads) + 1)
+		s.uploads[id] = map[int][]byte{}
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		parts, ok := s.uploads[query.Get("uploadId")]
+		if !ok {
+			s3Error(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		part, _ := strconv.Atoi(query.Get("partNumber"))
+		parts[part] = readS3Body(r)
+		w.Header().Set("ETag", etag(parts[part]))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		parts, ok := s.uploads[query.Get("uploadId")]
+		if !ok {
+			s3Error(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		var nums []int
+		for n := range parts {
+			nums = append(nums, n)
+		}
+		sort.Ints(nums)
+		var data []byte
+		for _, n := range nums {
+			data = append(data, parts[n]...)
+		}
+		s.objects[key] = data
+		s.multipart++
+		delete(s.uploads, query.Get("uploadId"))
+		fmt.Fprintf(w, "<CompleteMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><ETag>%s</ETag>"+
+			"</CompleteMultipartUploadResult>", bucket, key, etag(data))
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(s.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		s.objects[key] = readS3Body(r)
+		w.Header().Set("ETag", etag(s.objects[key]))
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		data, ok := s.objects[key]
+		if !ok {
+			s3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("ETag", etag(data))
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	default:
+		s3Error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func s3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code></Error>", code)
+}
+
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// readS3Body reads the request body, decoding the aws-chunked
+// encoding used by the streaming signature
+func readS3Body(r *http.Request) []byte {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		data, _ := io.ReadAll(r.Body)
+		return data
+	}
+	var data []byte
+	br := bufio.NewReader(r.Body)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return data
+		}
+		size, _ := strconv.ParseInt(strings.Split(strings.TrimSpace(line), ";")[0], 16, 64)
+		if size == 0 {
+			return data
+		}
+		chunk := make([]byte, size+2)
+		if _, err := io.ReadFull(br, chunk); err != nil {
+			return data
+		}
+		data = append(data, chunk[:size]...)
+	}
+}
+
+func testS3Target(t *testing.T, addr, endpoint string) *s3Target {
+	t.Helper()
+	spec := &apis.S3Target{
+		Endpoint:   strings.TrimPrefix(endpoint, "http://"),
+		Region:     "us-east-1",
+		SecretName: "s3-creds",
+		Insecure:   true,
+	}
+	target, err := buildS3Target(addr, spec, map[string][]byte{
+		S3AccessKeyIDKey:     []byte("test-access"),
+		S3SecretAccessKeyKey: []byte("test-secret"),
+	})
+	if err != nil {
+		t.Fatalf("buildS3Target(%s) error = %v", addr, err)
+	}
+	return target
+}
+
+func TestParseS3Target(t *testing.T) {
+	tests := map[string][2]string{
+		"s3://bucket":                   {"bucket", ""},
+		"s3://bucket/":                  {"bucket", ""},
+		"s3://bucket/backups/":          {"bucket", "backups"},
+		"s3://bucket//a/../backups/x/y": {"bucket", "backups/x/y"},
+	}
+	for addr, want := range tests {
+		bucket, key, err := parseS3Target(addr)
+		if err != nil || bucket != want[0] || key != want[1] {
+			t.Errorf("parseS3Target(%s) = %s, %s, %v, want %s, %s", addr, bucket, key, err, want[0], want[1])
+		}
+	}
+	if _, _, err := parseS3Target("s3:///backups"); err == nil {
+		t.Errorf("parseS3Target() without bucket should fail")
+	}
+
+	if _, err := buildS3Target("s3://bucket", &apis.S3Target{Endpoint: "minio:9000", SecretName: "s3-creds"},
+		map[string][]byte{S3AccessKeyIDKey: []byte("key")}); err == nil {
+		t.Errorf("buildS3Target() without the secret access key should fail")
+	}
+}
+
+func TestS3TargetBackupRestore(t *testing.T) {
+	fake := useFakeZFS(t)
+	s3, srv := newS3Server(t)
+
+	vol := testVolume("pvc-1", VolTypeDataset, "1073741824", "yes")
+	if err := CreateVolume(vol); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+
+	target := testS3Target(t, "s3://backups/cluster-1", srv.URL)
+	backup := func(snapName, prevSnapName string, written int64) {
+		t.Helper()
+		if err := fake.Write("pool/pvc-1", written); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		snap := &apis.ZFSSnapshot{}
+		snap.Name = snapName
+		snap.Spec.PoolName = vol.Spec.PoolName
+		snap.Labels = map[string]string{ZFSVolKey: vol.Name}
+		if err := CreateSnapshot(snap); err != nil {
+			t.Fatalf("CreateSnapshot() error = %v", err)
+		}
+		bkp := &apis.ZFSBackup{}
+		bkp.Name = snapName
+		bkp.Spec.VolumeName = vol.Name
+		bkp.Spec.SnapName = snapName
+		bkp.Spec.PrevSnapName = prevSnapName
+		bkp.Spec.BackupDest = "s3://backups/cluster-1"
+		if err := sendToS3(context.Background(), bkp, vol, target, nil, nil); err != nil {
+			t.Fatalf("sendToS3(%s) error = %v", snapName, err)
+		}
+		if bkp.Transfer.CompletionTime == nil || bkp.Transfer.BytesTransferred == 0 {
+			t.Errorf("backup %s transfer = %+v, want completed", snapName, bkp.Transfer)
+		}
+	}
+	backup("bkp-1", "", 4096)
+	backup("bkp-2", "bkp-1", 8192)
+
+	if s3.multipart != 2 {
+		t.Errorf("multipart uploads = %d, want 2", s3.multipart)
+	}
+	m, err := target.getManifest("cluster-1/pvc-1/bkp-2.json")
+	if err != nil {
+		t.Fatalf("getManifest() error = %v", err)
+	}
+	if !reflect.DeepEqual(m.Chain, []string{"bkp-1", "bkp-2"}) || m.PrevSnapName != "bkp-1" {
+		t.Errorf("manifest chain %v prev %s, want [bkp-1 bkp-2] from bkp-1", m.Chain, m.PrevSnapName)
+	}
+	if int64(len(s3.objects["cluster-1/pvc-1/bkp-2.zstream"])) != m.Size {
+		t.Errorf("stream object is %d bytes, manifest size %d", len(s3.objects["cluster-1/pvc-1/bkp-2.zstream"]), m.Size)
+	}
+
+	rstr := &apis.ZFSRestore{}
+	rstr.Spec.VolumeName = "pvc-2"
+	rstr.VolSpec = vol.Spec
+
+	restore := func(snapName string) error {
+		addr := "s3://backups/cluster-1/pvc-1/" + snapName + ".json"
+		rstr.Spec.RestoreSrc = addr
+		rstr.Spec.Verify = nil
+		return recvFromS3(context.Background(), rstr, testS3Target(t, addr, srv.URL), nil, nil)
+	}
+
+	// the incremental backup can not be restored before the full one
+	if err := restore("bkp-2"); err == nil {
+		t.Errorf("recvFromS3() of incremental backup before the full one should fail")
+	}
+	for _, snapName := range []string{"bkp-1", "bkp-2"} {
+		if err := restore(snapName); err != nil {
+			t.Fatalf("recvFromS3(%s) error = %v", snapName, err)
+		}
+	}
+	if ds, _ := fake.Dataset("pool/pvc-2"); ds.Written != 12288 {
+		t.Errorf("restored volume written = %d, want 12288", ds.Written)
+	}
+	if _, ok := fake.Dataset("pool/pvc-2@bkp-2"); !ok {
+		t.Errorf("restored snapshot bkp-2 is not present")
+	}
+	if err := restore("bkp-3"); err == nil {
+		t.Errorf("recvFromS3() of missing backup should fail")
+	}
+
+	// a corrupted stream must fail the restore
+	data := s3.objects["cluster-1/pvc-1/bkp-1.zstream"]
+	data[len(data)-1] = ' '
+	rstr.Spec.VolumeName = "pvc-3"
+	if err := restore("bkp-1"); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("recvFromS3() of corrupted stream error = %v, want checksum mismatch", err)
+	}
+
+	// the upload is aborted if the send fails
+	bkp := &apis.ZFSBackup{}
+	bkp.Name = "bkp-x"
+	bkp.Spec.SnapName = "bkp-x"
+	if err := sendToS3(context.Background(), bkp, vol, target, nil, nil); err == nil {
+		t.Errorf("sendToS3() of missing snapshot should fail")
+	}
+	if _, ok := s3.objects["cluster-1/pvc-1/bkp-x.zstream"]; ok || len(s3.uploads) != 0 {
+		t.Errorf("failed upload has not been aborted")
+	}
+}
@@ -0,0 +1,176 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// ZFSSnapScheduleKey is the label of the ZFSSnapshot taken by the
+	// ZFSSnapshotSchedule, set to the name of the ZFSSnapshotSchedule
+	ZFSSnapScheduleKey string = "openebs.io/snapshot-schedule"
+	// ZFSSnapScheduleNameKey is the label of the ZFSSnapshot taken by the
+	// ZFSSnapshotSchedule, set to the name of the schedule in its spec
+	ZFSSnapScheduleNameKey string = "openebs.io/snapshot-schedule-name"
+
+	// minScheduleInterval is the shortest interval of the snapshot schedule
+	minScheduleInterval = time.Minute
+
+	// scheduledSnapshotTimeFormat is the format of the time the scheduled
+	// snapshot has been due at in its name
+	scheduledSnapshotTimeFormat = "20060102-150405"
+)
+
+// ValidateSnapshotSchedule checks the name of the snapshot schedule, which is
+// the label value of its snapshots, the intervals and the names of the
+// schedules, and the volume selector of the snapshot schedule
+func ValidateSnapshotSchedule(sched *apis.ZFSSnapshotSchedule) error {
+	if errs := validation.IsValidLabelValue(sched.Name); len(errs) > 0 {
+		return fmt.Errorf("zfs: invalid name of snapshot schedule %s: %s", sched.Name, strings.Join(errs, ", "))
+	}
+	if sched.Spec.Selector == nil && len(sched.Spec.StorageClassName) == 0 {
+		return fmt.Errorf("zfs: snapshot schedule %s selects no volumes, the selector or the storage class is required", sched.Name)
+	}
+	if sched.Spec.Selector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(sched.Spec.Selector); err != nil {
+			return fmt.Errorf("zfs: invalid volume selector of snapshot schedule %s: %v", sched.Name, err)
+		}
+	}
+	names := map[string]bool{}
+	for _, s := range sched.Spec.Schedules {
+		if names[s.Name] {
+			return fmt.Errorf("zfs: snapshot schedule %s has the schedule %s more than once", sched.Name, s.Name)
+		}
+		names[s.Name] = true
+		if s.Interval.Duration < minScheduleInterval {
+			return fmt.Errorf("zfs: interval %v of schedule %s is shorter than %v", s.Interval.Duration, s.Name, minScheduleInterval)
+		}
+	}
+	return nil
+}
+
+// ScheduleSelectsVolume returns true if the snapshot schedule selects the
+// volume, storageClass being the storage class it has been provisioned using
+func ScheduleSelectsVolume(sched *apis.ZFSSnapshotSchedule, vol *apis.ZFSVolume, storageClass string) bool {
+	if sched.Spec.Selector == nil && len(sched.Spec.StorageClassName) == 0 {
+		return false
+	}
+	if len(sched.Spec.StorageClassName) > 0 && sched.Spec.StorageClassName != storageClass {
+		return false
+	}
+	if sched.Spec.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(sched.Spec.Selector)
+		if err != nil || !selector.Matches(labels.Set(vol.Labels)) {
+			return false
+		}
+	}
+	return true
+}
+
+// ScheduleSlot returns the time the last snapshot of the schedule has been
+// due at, the multiple of its interval, so that the hourly snapshots are
+// taken at the top of the hour and the daily ones at midnight UTC
+func ScheduleSlot(s apis.SnapshotSchedule, now time.Time) time.Time {
+	return now.UTC().Truncate(s.Interval.Duration)
+}
+
+// NextScheduleRun returns the time until the next snapshot of any of the
+// schedules of the snapshot schedule is due
+func NextScheduleRun(sched *apis.ZFSSnapshotSchedule, now time.Time) time.Duration {
+	var next time.Duration
+	for _, s := range sched.Spec.Schedules {
+		if s.Interval.Duration < minScheduleInterval {
+			continue
+		}
+		d := ScheduleSlot(s, now).Add(s.Interval.Duration).Sub(now)
+		if next == 0 || d < next {
+			next = d
+		}
+	}
+	return next
+}
+
+// ScheduledSnapshotLabels returns the labels of the snapshots of the volume
+// taken by the schedule of the snapshot schedule
+func ScheduledSnapshotLabels(sched *apis.ZFSSnapshotSchedule, s apis.SnapshotSchedule, volName string) map[string]string {
+	return map[string]string{
+		ZFSVolKey:              volName,
+		ZFSSnapScheduleKey:     sched.Name,
+		ZFSSnapScheduleNameKey: s.Name,
+	}
+}
+
+// NewScheduledSnapshot returns the ZFSSnapshot of the volume to be taken by
+// the schedule of the snapshot schedule for the slot it is due at, named as
+// <volume>-<snapshot schedule>-<schedule>-<slot>
+func NewScheduledSnapshot(sched *apis.ZFSSnapshotSchedule, s apis.SnapshotSchedule, vol *apis.ZFSVolume, slot time.Time) *apis.ZFSSnapshot {
+	snap := &apis.ZFSSnapshot{}
+	snap.Name = fmt.Sprintf("%s-%s-%s-%s", vol.Name, sched.Name, s.Name, slot.UTC().Format(scheduledSnapshotTimeFormat))
+	snap.Labels = ScheduledSnapshotLabels(sched, s, vol.Name)
+	snap.Spec = vol.Spec
+	snap.Status.State = ZFSStatusPending
+	return snap
+}
+
+// ScheduledSnapshotsToPrune returns the snapshots taken by the schedule
+// which are not among the Keep most recent ones, snaps being the snapshots
+// of the volume taken by the schedule
+func ScheduledSnapshotsToPrune(s apis.SnapshotSchedule, snaps []*apis.ZFSSnapshot) []*apis.ZFSSnapshot {
+	var live []*apis.ZFSSnapshot
+	for _, snap := range snaps {
+		if snap.DeletionTimestamp == nil {
+			live = append(live, snap)
+		}
+	}
+	if len(live) <= s.Keep {
+		return nil
+	}
+	// the names end with the time the snapshots have been due at
+	sort.Slice(live, func(i, j int) bool { return live[i].Name < live[j].Name })
+	return live[:len(live)-s.Keep]
+}
+
+// UnscheduledSnapshots returns the snapshots taken by the snapshot schedule
+// on the node which are not pruned by any of its schedules anymore, as their
+// volume is not among the selected ones, selected being the names of the
+// volumes the snapshot schedule still selects, or as their schedule has been
+// removed from the spec
+func UnscheduledSnapshots(sched *apis.ZFSSnapshotSchedule, snaps []*apis.ZFSSnapshot, selected map[string]bool) []*apis.ZFSSnapshot {
+	schedules := make(map[string]bool, len(sched.Spec.Schedules))
+	for _, s := range sched.Spec.Schedules {
+		schedules[s.Name] = true
+	}
+	var unscheduled []*apis.ZFSSnapshot
+	for _, snap := range snaps {
+		if snap.Spec.OwnerNodeID != NodeID || snap.DeletionTimestamp != nil {
+			continue
+		}
+		if !selected[snap.Labels[ZFSVolKey]] || !schedules[snap.Labels[ZFSSnapScheduleNameKey]] {
+			unscheduled = append(unscheduled, snap)
+		}
+	}
+	return unscheduled
+}
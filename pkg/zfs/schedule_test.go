@@ -0,0 +1,181 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testSchedule() *apis.ZFSSnapshotSchedule {
+	sched := &apis.ZFSSnapshotSchedule{}
+	sched.Name = "default"
+	sched.Spec.StorageClassName = "openebs-zfspv"
+	sched.Spec.Schedules = []apis.SnapshotSchedule{
+		{Name: "hourly", Interval: metav1.Duration{Duration: time.Hour}, Keep: 24},
+		{Name: "daily", Interval: metav1.Duration{Duration: 24 * time.Hour}, Keep: 7},
+	}
+	return sched
+}
+
+func TestValidateSnapshotSchedule(t *testing.T) {
+	if err := ValidateSnapshotSchedule(testSchedule()); err != nil {
+		t.Errorf("ValidateSnapshotSchedule() error = %v", err)
+	}
+
+	tests := map[string]func(*apis.ZFSSnapshotSchedule){
+		"no volumes selected": func(s *apis.ZFSSnapshotSchedule) { s.Spec.StorageClassName = "" },
+		"duplicate schedule":  func(s *apis.ZFSSnapshotSchedule) { s.Spec.Schedules[1].Name = "hourly" },
+		"long name":           func(s *apis.ZFSSnapshotSchedule) { s.Name = strings.Repeat("a", 64) },
+		"short interval": func(s *apis.ZFSSnapshotSchedule) {
+			s.Spec.Schedules[0].Interval.Duration = time.Second
+		},
+		"invalid selector": func(s *apis.ZFSSnapshotSchedule) {
+			s.Spec.Selector = &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "app", Operator: "Like"},
+			}}
+		},
+	}
+	for name, mutate := range tests {
+		sched := testSchedule()
+		mutate(sched)
+		if err := ValidateSnapshotSchedule(sched); err == nil {
+			t.Errorf("ValidateSnapshotSchedule() %s: error = nil", name)
+		}
+	}
+}
+
+func TestScheduleSelectsVolume(t *testing.T) {
+	vol := testVolume("pvc-1", VolTypeDataset, "1073741824", "yes")
+	vol.Labels = map[string]string{"app": "db"}
+
+	sched := testSchedule()
+	if !ScheduleSelectsVolume(sched, vol, "openebs-zfspv") {
+		t.Errorf("volume of the storage class is not selected")
+	}
+	if ScheduleSelectsVolume(sched, vol, "other") {
+		t.Errorf("volume of other storage class is selected")
+	}
+
+	// the selector and the storage class both have to match
+	sched.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+	if ScheduleSelectsVolume(sched, vol, "openebs-zfspv") {
+		t.Errorf("volume not matching the selector is selected")
+	}
+	sched.Spec.StorageClassName = ""
+	sched.Spec.Selector.MatchLabels["app"] = "db"
+	if !ScheduleSelectsVolume(sched, vol, "") {
+		t.Errorf("volume matching the selector is not selected")
+	}
+}
+
+func TestScheduleSlots(t *testing.T) {
+	sched := testSchedule()
+	now := time.Date(2024, 5, 10, 13, 42, 10, 0, time.UTC)
+
+	if slot := ScheduleSlot(sched.Spec.Schedules[0], now); !slot.Equal(time.Date(2024, 5, 10, 13, 0, 0, 0, time.UTC)) {
+		t.Errorf("hourly slot = %v", slot)
+	}
+	if slot := ScheduleSlot(sched.Spec.Schedules[1], now); !slot.Equal(time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("daily slot = %v", slot)
+	}
+	if next := NextScheduleRun(sched, now); next != 17*time.Minute+50*time.Second {
+		t.Errorf("NextScheduleRun() = %v, want 17m50s", next)
+	}
+
+	vol := testVolume("pvc-1", VolTypeDataset, "1073741824", "yes")
+	snap := NewScheduledSnapshot(sched, sched.Spec.Schedules[0], vol, ScheduleSlot(sched.Spec.Schedules[0], now))
+	if snap.Name != "pvc-1-default-hourly-20240510-130000" {
+		t.Errorf("scheduled snapshot name = %s", snap.Name)
+	}
+	if snap.Labels[ZFSVolKey] != "pvc-1" || snap.Labels[ZFSSnapScheduleKey] != "default" ||
+		snap.Labels[ZFSSnapScheduleNameKey] != "hourly" {
+		t.Errorf("scheduled snapshot labels = %v", snap.Labels)
+	}
+	if snap.Spec.PoolName != vol.Spec.PoolName || snap.Status.State != ZFSStatusPending {
+		t.Errorf("scheduled snapshot spec = %v, state = %s", snap.Spec, snap.Status.State)
+	}
+}
+
+func TestScheduledSnapshotsToPrune(t *testing.T) {
+	sched := testSchedule()
+	s := sched.Spec.Schedules[1]
+	s.Keep = 2
+	vol := testVolume("pvc-1", VolTypeDataset, "1073741824", "yes")
+
+	var snaps []*apis.ZFSSnapshot
+	for _, day := range []int{3, 1, 4, 2} {
+		slot := time.Date(2024, 5, day, 0, 0, 0, 0, time.UTC)
+		snaps = append(snaps, NewScheduledSnapshot(sched, s, vol, slot))
+	}
+	prune := ScheduledSnapshotsToPrune(s, snaps)
+	if len(prune) != 2 || prune[0].Name != "pvc-1-default-daily-20240501-000000" ||
+		prune[1].Name != "pvc-1-default-daily-20240502-000000" {
+		t.Errorf("ScheduledSnapshotsToPrune() = %v, want the two oldest", prune)
+	}
+
+	// the snapshots being deleted are not counted
+	snaps[1].DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	prune = ScheduledSnapshotsToPrune(s, snaps)
+	if len(prune) != 1 || prune[0].Name != "pvc-1-default-daily-20240502-000000" {
+		t.Errorf("ScheduledSnapshotsToPrune() with deleted snapshot = %v", prune)
+	}
+}
+
+func TestUnscheduledSnapshots(t *testing.T) {
+	sched := testSchedule()
+	slot := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	var snaps []*apis.ZFSSnapshot
+	for _, name := range []string{"pvc-1", "pvc-2", "pvc-3"} {
+		vol := testVolume(name, VolTypeDataset, "1073741824", "yes")
+		vol.Spec.OwnerNodeID = NodeID
+		for _, s := range sched.Spec.Schedules {
+			snaps = append(snaps, NewScheduledSnapshot(sched, s, vol, slot))
+		}
+	}
+	// pvc-2 is not selected anymore, the daily schedule has been removed
+	// and the snapshots of pvc-3 are owned by another node
+	selected := map[string]bool{"pvc-1": true, "pvc-3": true}
+	sched.Spec.Schedules = sched.Spec.Schedules[:1]
+	snaps[4].Spec.OwnerNodeID = "other-node"
+	snaps[5].Spec.OwnerNodeID = "other-node"
+
+	names := func(snaps []*apis.ZFSSnapshot) []string {
+		var names []string
+		for _, snap := range snaps {
+			names = append(names, snap.Labels[ZFSVolKey]+"/"+snap.Labels[ZFSSnapScheduleNameKey])
+		}
+		return names
+	}
+	got := names(UnscheduledSnapshots(sched, snaps, selected))
+	if want := []string{"pvc-1/daily", "pvc-2/hourly", "pvc-2/daily"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("UnscheduledSnapshots() = %v, want %v", got, want)
+	}
+
+	// the snapshots being deleted are skipped
+	snaps[2].DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	got = names(UnscheduledSnapshots(sched, snaps, selected))
+	if want := []string{"pvc-1/daily", "pvc-2/daily"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("UnscheduledSnapshots() with deleted snapshot = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,189 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"fmt"
+	"strings"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// restoreStagingPrefix prefixes the hidden dataset the restore
+	// stream is received into before it is renamed to the volume
+	restoreStagingPrefix = ".restore-"
+
+	// restoreReplacedPrefix prefixes the volume replaced by the
+	// restored one until it is destroyed
+	restoreReplacedPrefix = ".replaced-"
+)
+
+// recvDataset returns the dataset the restore stream is received into,
+// the staging dataset if the restore is staged, else the volume
+func recvDataset(rstr *apis.ZFSRestore) string {
+	if len(rstr.Transfer.StagingDataset) > 0 {
+		return rstr.Transfer.StagingDataset
+	}
+	return rstr.VolSpec.PoolName + "/" + rstr.Spec.VolumeName
+}
+
+// destroyDataset destroys the dataset of the pool along with its snapshots
+func destroyDataset(pool, name string) error {
+	vol := &apis.ZFSVolume{}
+	vol.Name = strings.TrimPrefix(name, pool+"/")
+	vol.Spec.PoolName = pool
+	return backend.DestroyVolume(vol)
+}
+
+// stageRestore decides if the restore stream is received into the hidden
+// staging dataset <pool>/.restore-<volume>, so that the volume is not
+// touched until the restore has been verified. If the volume has snapshots,
+// the staging dataset is received as the clone of the most recent one, as
+// the incremental stream is received on top of it. It is decided on the
+// first attempt and recorded in the transfer info, the resumed stream is
+// received where it was.
+func stageRestore(rstr *apis.ZFSRestore) error {
+	if len(rstr.Transfer.StagingDataset) > 0 || len(rstr.Transfer.ResumeToken) > 0 {
+		return nil
+	}
+	if isLocalSource(rstr.Spec.RestoreSrc) && rstr.Spec.LocalMode == LocalRestoreClone {
+		return nil
+	}
+
+	var origin string
+	volume := rstr.VolSpec.PoolName + "/" + rstr.Spec.VolumeName
+	if backend.GetDataset(volume) == nil {
+		snaps, err := backend.ListSnapshots(volume)
+		if err != nil {
+			return err
+		}
+		if len(snaps) > 0 {
+			origin = volume + "@" + snaps[len(snaps)-1]
+		}
+	}
+
+	staging := rstr.VolSpec.PoolName + "/" + restoreStagingPrefix + rstr.Spec.VolumeName
+	if backend.GetDataset(staging) == nil {
+		// left over by an earlier restore of the volume
+		if err := destroyDataset(rstr.VolSpec.PoolName, staging); err != nil {
+			return fmt.Errorf("zfs: could not destroy the stale staging dataset %s: %v", staging, err)
+		}
+	}
+	rstr.Transfer.StagingDataset = staging
+	rstr.Transfer.StagingOrigin = origin
+	return nil
+}
+
+// commitRestore renames the verified staging dataset to the volume. The
+// volume being replaced is renamed aside first, and renamed back if the
+// staging dataset could not be renamed or promoted, so that it is never
+// lost. It is destroyed once the restored volume is in place.
+func commitRestore(rstr *apis.ZFSRestore) error {
+	staging := rstr.Transfer.StagingDataset
+	if len(staging) == 0 {
+		return nil
+	}
+	pool := rstr.VolSpec.PoolName
+	volume := pool + "/" + rstr.Spec.VolumeName
+	replaced := pool + "/" + restoreReplacedPrefix + rstr.Spec.VolumeName
+
+	if backend.GetDataset(staging) != nil {
+		if backend.GetDataset(volume) != nil {
+			return fmt.Errorf("zfs: staging dataset %s of the restore %s is not present", staging, rstr.Name)
+		}
+		// renamed by the earlier attempt
+		if err := promoteRestored(rstr, volume); err != nil {
+			return err
+		}
+	} else {
+		if backend.GetDataset(volume) == nil {
+			if backend.GetDataset(replaced) == nil {
+				if err := destroyDataset(pool, replaced); err != nil {
+					return err
+				}
+			}
+			if err := backend.Rename(volume, replaced); err != nil {
+				return err
+			}
+		}
+		err := backend.Rename(staging, volume)
+		if err == nil {
+			if err = promoteRestored(rstr, volume); err != nil {
+				if rerr := backend.Rename(volume, staging); rerr != nil {
+					klog.Errorf("zfs: could not rename %s back to %s, err: %v", volume, staging, rerr)
+				}
+			}
+		}
+		if err != nil {
+			if backend.GetDataset(replaced) == nil {
+				if rerr := backend.Rename(replaced, volume); rerr != nil {
+					klog.Errorf("zfs: could not rename %s back to %s, err: %v", replaced, volume, rerr)
+				}
+			}
+			return err
+		}
+		klog.Infof("restored %s from the staging dataset %s", volume, staging)
+	}
+	rstr.Transfer.StagingDataset = ""
+	rstr.Transfer.StagingOrigin = ""
+
+	if backend.GetDataset(replaced) == nil {
+		if err := destroyDataset(pool, replaced); err != nil {
+			klog.Errorf("zfs: could not destroy the replaced volume %s, err: %v", replaced, err)
+		}
+	}
+	return nil
+}
+
+// promoteRestored promotes the restored volume received as the clone of
+// StagingOrigin, so that it takes over the snapshots of the replaced volume
+// it depends on and the replaced volume can be destroyed
+func promoteRestored(rstr *apis.ZFSRestore, volume string) error {
+	if len(rstr.Transfer.StagingOrigin) == 0 {
+		return nil
+	}
+	origin, err := backend.GetProperty(volume, "origin")
+	if err != nil {
+		return err
+	}
+	if origin == "-" || origin == "" {
+		// promoted by the earlier attempt
+		return nil
+	}
+	return backend.Promote(volume)
+}
+
+// discardStaging destroys the staging dataset of the restore which has
+// failed, along with what has been received into it
+func discardStaging(rstr *apis.ZFSRestore) error {
+	staging := rstr.Transfer.StagingDataset
+	if len(staging) == 0 {
+		return nil
+	}
+	if backend.GetDataset(staging) == nil {
+		if err := destroyDataset(rstr.VolSpec.PoolName, staging); err != nil {
+			return err
+		}
+		klog.Infof("destroyed the staging dataset %s of the restore %s", staging, rstr.Name)
+	}
+	rstr.Transfer.StagingDataset = ""
+	rstr.Transfer.StagingOrigin = ""
+	rstr.Transfer.ResumeToken = ""
+	return nil
+}
@@ -0,0 +1,179 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"testing"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+)
+
+func TestStagedRestore(t *testing.T) {
+	fake := useFakeZFS(t)
+
+	src := testVolume("pvc-1", VolTypeDataset, "1073741824", "yes")
+	if err := CreateVolume(src); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	if err := fake.Write("pool/pvc-1", 4096); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	backup := func(snapName, prevSnapName string) []byte {
+		t.Helper()
+		snap := &apis.ZFSSnapshot{}
+		snap.Name = snapName
+		snap.Spec.PoolName = src.Spec.PoolName
+		snap.Labels = map[string]string{ZFSVolKey: src.Name}
+		if err := CreateSnapshot(snap); err != nil {
+			t.Fatalf("CreateSnapshot(%s) error = %v", snapName, err)
+		}
+		bkp := &apis.ZFSBackup{}
+		bkp.Spec.VolumeName = src.Name
+		bkp.Spec.SnapName = snapName
+		bkp.Spec.PrevSnapName = prevSnapName
+		addr, recvd := backupServer(t, nil)
+		bkp.Spec.BackupDest = addr
+		if err := sendBackup(bkp, src); err != nil {
+			t.Fatalf("sendBackup(%s) error = %v", snapName, err)
+		}
+		return <-recvd
+	}
+	full := backup("bkp-1", "")
+
+	// the volume created for the restore is in use until it is replaced
+	dst := testVolume("pvc-2", VolTypeDataset, "1073741824", "yes")
+	if err := CreateVolume(dst); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	if err := fake.Write("pool/pvc-2", 1024); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	rstr := &apis.ZFSRestore{}
+	rstr.Name = "rstr-1"
+	rstr.Spec.VolumeName = dst.Name
+	rstr.VolSpec = dst.Spec
+
+	// the cut stream leaves the volume untouched
+	rstr.Spec.RestoreSrc = restoreServer(t, nil, full[:len(full)-10])
+	if err := recvRestore(rstr); err == nil {
+		t.Fatalf("recvRestore() of truncated stream should fail")
+	}
+	if rstr.Transfer.StagingDataset != "pool/.restore-pvc-2" {
+		t.Errorf("staging dataset = %q, want pool/.restore-pvc-2", rstr.Transfer.StagingDataset)
+	}
+	if ds, _ := fake.Dataset("pool/pvc-2"); ds.Written != 1024 {
+		t.Errorf("volume written = %d after the failed restore, want 1024", ds.Written)
+	}
+	if !RetryRestore(rstr) || len(rstr.Transfer.ResumeToken) > 0 {
+		t.Fatalf("partial state of the staging dataset is kept for the remote restore")
+	}
+
+	// the restore which has failed for good discards the staging dataset
+	if err := AbortRestore(rstr); err != nil {
+		t.Fatalf("AbortRestore() error = %v", err)
+	}
+	if _, ok := fake.Dataset("pool/.restore-pvc-2"); ok || rstr.Transfer.StagingDataset != "" {
+		t.Errorf("staging dataset is present after AbortRestore()")
+	}
+
+	// the verified restore replaces the volume
+	rstr.Spec.RestoreSrc = restoreServer(t, nil, full)
+	if err := recvRestore(rstr); err != nil {
+		t.Fatalf("recvRestore() error = %v", err)
+	}
+	if ds, _ := fake.Dataset("pool/pvc-2"); ds.Written != 4096 {
+		t.Errorf("restored volume written = %d, want 4096", ds.Written)
+	}
+	if _, ok := fake.Dataset("pool/pvc-2@bkp-1"); !ok {
+		t.Errorf("restored snapshot is not present")
+	}
+	for _, name := range []string{"pool/.restore-pvc-2", "pool/.replaced-pvc-2"} {
+		if _, ok := fake.Dataset(name); ok {
+			t.Errorf("%s is present after the restore", name)
+		}
+	}
+	if rstr.Transfer.StagingDataset != "" {
+		t.Errorf("staging dataset = %q after the restore", rstr.Transfer.StagingDataset)
+	}
+
+	// the incremental stream is staged as the clone of the restored snapshot,
+	// the cut stream leaves the volume written since then untouched
+	if err := fake.Write("pool/pvc-1", 4096); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := fake.Write("pool/pvc-2", 1024); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	incr := backup("bkp-2", "bkp-1")
+	rstr = &apis.ZFSRestore{}
+	rstr.Spec.VolumeName = dst.Name
+	rstr.VolSpec = dst.Spec
+	rstr.Spec.RestoreSrc = restoreServer(t, nil, incr[:len(incr)-10])
+	if err := recvRestore(rstr); err == nil {
+		t.Fatalf("recvRestore() of truncated incremental stream should fail")
+	}
+	if rstr.Transfer.StagingOrigin != "pool/pvc-2@bkp-1" {
+		t.Errorf("staging origin = %q, want pool/pvc-2@bkp-1", rstr.Transfer.StagingOrigin)
+	}
+	if ds, _ := fake.Dataset("pool/pvc-2"); ds.Written != 5120 {
+		t.Errorf("volume written = %d after the failed restore, want 5120", ds.Written)
+	}
+	if err := AbortRestore(rstr); err != nil {
+		t.Fatalf("AbortRestore() error = %v", err)
+	}
+	if _, ok := fake.Dataset("pool/.restore-pvc-2"); ok || rstr.Transfer.StagingOrigin != "" {
+		t.Errorf("staging dataset is present after AbortRestore()")
+	}
+
+	// the restored volume takes over the snapshots of the replaced one
+	rstr.Spec.RestoreSrc = restoreServer(t, nil, incr)
+	if err := recvRestore(rstr); err != nil {
+		t.Fatalf("recvRestore() of incremental stream error = %v", err)
+	}
+	ds, _ := fake.Dataset("pool/pvc-2")
+	if ds.Written != 8192 {
+		t.Errorf("restored volume written = %d, want 8192", ds.Written)
+	}
+	if ds.Origin != "" {
+		t.Errorf("restored volume origin = %q, want it promoted", ds.Origin)
+	}
+	for _, snap := range []string{"pool/pvc-2@bkp-1", "pool/pvc-2@bkp-2"} {
+		if _, ok := fake.Dataset(snap); !ok {
+			t.Errorf("%s is not present after the incremental restore", snap)
+		}
+	}
+	for _, name := range []string{"pool/.restore-pvc-2", "pool/.replaced-pvc-2"} {
+		if _, ok := fake.Dataset(name); ok {
+			t.Errorf("%s is present after the incremental restore", name)
+		}
+	}
+
+	// the unverified restore is discarded and leaves the volume untouched
+	rstr = &apis.ZFSRestore{}
+	rstr.Spec.VolumeName = "pvc-3"
+	rstr.VolSpec = dst.Spec
+	rstr.Spec.Verify = &apis.BackupVerification{SnapName: "bkp-1", SnapGUID: "1"}
+	rstr.Spec.RestoreSrc = restoreServer(t, nil, full)
+	if err := recvRestore(rstr); err == nil {
+		t.Fatalf("recvRestore() of the unverified snapshot should fail")
+	}
+	for _, name := range []string{"pool/pvc-3", "pool/.restore-pvc-3"} {
+		if _, ok := fake.Dataset(name); ok {
+			t.Errorf("%s is present after the unverified restore", name)
+		}
+	}
+}
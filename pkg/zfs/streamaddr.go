@@ -0,0 +1,84 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// streamResolver resolves the host names of the stream servers, it uses
+// the cluster DNS as the node agent runs with ClusterFirstWithHostNet
+var streamResolver = net.DefaultResolver
+
+// parseStreamAddr returns the host and the port of the stream server address,
+// which can be ipv4:port, [ipv6]:port, host:port or the in-cluster Service
+// name like <service>.<namespace>:port
+func parseStreamAddr(addr string) (string, string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", "", fmt.Errorf("zfs: invalid server address %s: %v", addr, err)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return "", "", fmt.Errorf("zfs: invalid server address %s: invalid port %q", addr, port)
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return host, port, nil
+	}
+	if strings.Contains(host, ":") {
+		return "", "", fmt.Errorf("zfs: invalid server address %s: invalid ipv6 address %s", addr, host)
+	}
+	if strings.Trim(host, "0123456789.") == "" {
+		return "", "", fmt.Errorf("zfs: invalid server address %s: invalid ipv4 address %s", addr, host)
+	}
+	name := strings.ToLower(strings.TrimSuffix(host, "."))
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return "", "", fmt.Errorf("zfs: invalid server address %s: invalid host name %s: %s",
+			addr, host, strings.Join(errs, ", "))
+	}
+	return host, port, nil
+}
+
+// resolveStreamAddr returns the ip:port addresses of the stream server to
+// connect to in order, the host name is resolved to all of its addresses
+func resolveStreamAddr(host, port string) ([]string, error) {
+	if _, err := netip.ParseAddr(host); err == nil {
+		return []string{net.JoinHostPort(host, port)}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), TransportDialTimeout)
+	defer cancel()
+	ips, err := streamResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("zfs: could not resolve the server address %s: %v", host, err)
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("zfs: server address %s has no ip address", host)
+	}
+
+	addrs := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, net.JoinHostPort(ip.String(), port))
+	}
+	return addrs, nil
+}
@@ -0,0 +1,99 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParseStreamAddr(t *testing.T) {
+	valid := map[string]string{
+		"10.0.0.1:9000":                              "10.0.0.1",
+		"[fd00::1]:9000":                             "fd00::1",
+		"[fe80::1%eth0]:9000":                        "fe80::1%eth0",
+		"[::ffff:10.0.0.1]:9000":                     "::ffff:10.0.0.1",
+		"backup-server:9000":                         "backup-server",
+		"backup-server.velero:9000":                  "backup-server.velero",
+		"backup-server.velero.svc.cluster.local.:80": "backup-server.velero.svc.cluster.local.",
+		"Backup.Example.com:443":                     "Backup.Example.com",
+	}
+	for addr, want := range valid {
+		host, _, err := parseStreamAddr(addr)
+		if err != nil || host != want {
+			t.Errorf("parseStreamAddr(%s) = %s, %v, want %s", addr, host, err, want)
+		}
+	}
+
+	for _, addr := range []string{
+		"10.0.0.1",
+		"fd00::1:9000",
+		"[fd00::zz]:9000",
+		"10.0.0.256:9000",
+		"backup_server:9000",
+		"-backup:9000",
+		"backup-server:0",
+		"backup-server:65536",
+		"backup-server:http",
+		":9000",
+	} {
+		if _, _, err := parseStreamAddr(addr); err == nil {
+			t.Errorf("parseStreamAddr(%s) should fail", addr)
+		}
+	}
+}
+
+func TestDialStreamServer(t *testing.T) {
+	l := streamListener(t, nil)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+
+	// localhost may resolve to ::1 first, which is not listened on
+	conn, err := dialStreamServer(net.JoinHostPort("localhost", port), nil)
+	if err != nil {
+		t.Fatalf("dialStreamServer(localhost) error = %v", err)
+	}
+	conn.Close()
+
+	if _, err := dialStreamServer("backup-server.invalid:"+port, nil); err == nil {
+		t.Errorf("dialStreamServer() of unresolvable host should fail")
+	}
+
+	l6, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("ipv6 is not available: %v", err)
+	}
+	defer l6.Close()
+	go func() {
+		if conn, err := l6.Accept(); err == nil {
+			conn.Close()
+		}
+	}()
+	conn, err = dialStreamServer(l6.Addr().String(), nil)
+	if err != nil {
+		t.Fatalf("dialStreamServer(%s) error = %v", l6.Addr(), err)
+	}
+	conn.Close()
+}
@@ -0,0 +1,210 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// TransferMaxRateKey is the environment variable to set the maximum
+	// rate of every backup and restore stream in bytes per second, e.g. "50Mi"
+	TransferMaxRateKey string = "OPENEBS_IO_BACKUP_MAX_RATE"
+	// TransferMaxConcurrentKey is the environment variable to set the maximum
+	// number of the backup and the restore streams running on the node
+	TransferMaxConcurrentKey string = "OPENEBS_IO_BACKUP_MAX_CONCURRENT"
+
+	// transferRateBurst is the maximum number of bytes
+	// passed to the stream at once when it is throttled
+	transferRateBurst = 256 * 1024
+)
+
+var (
+	// TransferMaxRate is the maximum rate of every backup and
+	// restore stream in bytes per second, 0 means unlimited
+	TransferMaxRate int64
+
+	// TransferMaxConcurrent is the maximum number of the backup and the
+	// restore streams running on the node at once, 0 means unlimited
+	TransferMaxConcurrent int
+
+	// TransferQueueInterval is the interval at which the backups and
+	// the restores waiting for a transfer slot are retried
+	TransferQueueInterval = 10 * time.Second
+
+	// ErrTransferQueued is returned when the transfer can not start
+	// because of the concurrency limit, it has to be retried later
+	ErrTransferQueued = errors.New("zfs: maximum number of concurrent transfers are running")
+)
+
+// transfers counts the backup and the restore streams running on the node
+var transfers struct {
+	sync.Mutex
+	running int
+}
+
+func init() {
+	if val := os.Getenv(TransferMaxRateKey); val != "" {
+		q, err := resource.ParseQuantity(val)
+		if err != nil || q.Sign() < 0 {
+			klog.Warningf("zfs: invalid value %q for %s, the transfer rate is not limited", val, TransferMaxRateKey)
+		} else {
+			TransferMaxRate = q.Value()
+		}
+	}
+	if val := os.Getenv(TransferMaxConcurrentKey); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 0 {
+			klog.Warningf("zfs: invalid value %q for %s, the transfers are not limited", val, TransferMaxConcurrentKey)
+		} else {
+			TransferMaxConcurrent = n
+		}
+	}
+}
+
+// TransferWorkers returns the number of workers to be run by the backup and
+// the restore controllers, so that the transfers allowed on the node can run
+func TransferWorkers() int {
+	if TransferMaxConcurrent > 2 {
+		return TransferMaxConcurrent
+	}
+	return 2
+}
+
+// startTransfer takes a transfer slot on the node if the number of the
+// running transfers is below the limit, which is the lower of the limit of
+// the node and the one of the limits, so that a backup or a restore can not
+// exceed the limit set for the node. The slot has to be released using
+// endTransfer.
+func startTransfer(limits *apis.TransferLimits) bool {
+	limit := TransferMaxConcurrent
+	if limits != nil && limits.MaxConcurrent > 0 && (limit == 0 || limits.MaxConcurrent < limit) {
+		limit = limits.MaxConcurrent
+	}
+
+	transfers.Lock()
+	defer transfers.Unlock()
+	if limit > 0 && transfers.running >= limit {
+		return false
+	}
+	transfers.running++
+	return true
+}
+
+// endTransfer releases the transfer slot taken by startTransfer
+func endTransfer() {
+	transfers.Lock()
+	defer transfers.Unlock()
+	transfers.running--
+}
+
+// transferLimiter returns the rate limiter of the stream, limited to the
+// lower of the rate of the node and the one of the limits, so that a backup
+// or a restore can not exceed the rate set for the node. It returns nil if
+// it is not limited.
+func transferLimiter(limits *apis.TransferLimits) *rate.Limiter {
+	maxRate := TransferMaxRate
+	if limits != nil && limits.MaxRate != nil {
+		if r := limits.MaxRate.Value(); r > 0 && (maxRate <= 0 || r < maxRate) {
+			maxRate = r
+		}
+	}
+	if maxRate <= 0 {
+		return nil
+	}
+	burst := transferRateBurst
+	if maxRate < transferRateBurst {
+		burst = int(maxRate)
+	}
+	return rate.NewLimiter(rate.Limit(maxRate), burst)
+}
+
+// throttleWriter limits the rate of the bytes written to w,
+// the wait is given up once ctx is done
+type throttleWriter struct {
+	ctx     context.Context
+	w       io.Writer
+	limiter *rate.Limiter
+}
+
+func (t *throttleWriter) Write(p []byte) (int, error) {
+	var n int
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > t.limiter.Burst() {
+			chunk = chunk[:t.limiter.Burst()]
+		}
+		if err := t.limiter.WaitN(t.ctx, len(chunk)); err != nil {
+			return n, err
+		}
+		m, err := t.w.Write(chunk)
+		n += m
+		if err != nil {
+			return n, err
+		}
+		p = p[m:]
+	}
+	return n, nil
+}
+
+// throttleReader limits the rate of the bytes read from r,
+// the wait is given up once ctx is done
+type throttleReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rate.Limiter
+}
+
+func (t *throttleReader) Read(p []byte) (int, error) {
+	if len(p) > t.limiter.Burst() {
+		p = p[:t.limiter.Burst()]
+	}
+	n, err := t.r.Read(p)
+	if n > 0 {
+		if werr := t.limiter.WaitN(t.ctx, n); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return n, err
+}
+
+// throttledWriter returns w limited to the transfer rate, the wait is given up once ctx is done
+func throttledWriter(ctx context.Context, w io.Writer, limits *apis.TransferLimits) io.Writer {
+	if limiter := transferLimiter(limits); limiter != nil {
+		return &throttleWriter{ctx: ctx, w: w, limiter: limiter}
+	}
+	return w
+}
+
+// throttledReader returns r limited to the transfer rate, the wait is given up once ctx is done
+func throttledReader(ctx context.Context, r io.Reader, limits *apis.TransferLimits) io.Reader {
+	if limiter := transferLimiter(limits); limiter != nil {
+		return &throttleReader{ctx: ctx, r: r, limiter: limiter}
+	}
+	return r
+}
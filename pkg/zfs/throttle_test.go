@@ -0,0 +1,127 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestTransferConcurrency(t *testing.T) {
+	useFakeZFS(t)
+	useFileTargetDir(t)
+	old := TransferMaxConcurrent
+	TransferMaxConcurrent = 1
+	t.Cleanup(func() { TransferMaxConcurrent = old })
+
+	if !startTransfer(nil) {
+		t.Fatalf("startTransfer() should start the first transfer")
+	}
+	if startTransfer(nil) {
+		t.Errorf("startTransfer() should not start more than %d transfers", TransferMaxConcurrent)
+	}
+	// the limits can not exceed the limit of the node
+	if startTransfer(&apis.TransferLimits{MaxConcurrent: 2}) {
+		t.Errorf("startTransfer() should not start more than %d transfers with higher limits", TransferMaxConcurrent)
+	}
+
+	bkp := &apis.ZFSBackup{}
+	bkp.Spec.SnapName = "bkp-1"
+	bkp.Spec.BackupDest = "file://" + FileTargetDir
+	if err := sendBackup(bkp, testVolume("pvc-1", VolTypeDataset, "1073741824", "yes")); err != ErrTransferQueued {
+		t.Errorf("sendBackup() error = %v, want %v", err, ErrTransferQueued)
+	}
+	rstr := &apis.ZFSRestore{}
+	rstr.Spec.RestoreSrc = "file://" + FileTargetDir + "/pvc-1/bkp-1.json"
+	if err := recvRestore(rstr); err != ErrTransferQueued {
+		t.Errorf("recvRestore() error = %v, want %v", err, ErrTransferQueued)
+	}
+
+	endTransfer()
+	if !startTransfer(nil) {
+		t.Errorf("startTransfer() should start the transfer once the running one is over")
+	}
+
+	// the limits lower the limit of the node
+	TransferMaxConcurrent = 0
+	if startTransfer(&apis.TransferLimits{MaxConcurrent: 1}) {
+		t.Errorf("startTransfer() should not start more transfers than allowed by its limits")
+	}
+	if !startTransfer(nil) {
+		t.Errorf("startTransfer() should start the transfer if the node is not limited")
+	}
+	endTransfer()
+	endTransfer()
+}
+
+func TestTransferRate(t *testing.T) {
+	old := TransferMaxRate
+	TransferMaxRate = 0
+	t.Cleanup(func() { TransferMaxRate = old })
+
+	var buf bytes.Buffer
+	if w := throttledWriter(context.Background(), &buf, nil); w != &buf {
+		t.Errorf("throttledWriter() should not limit the rate if it is not set")
+	}
+
+	// the first burst is not delayed, the rest is limited to 64Ki per second
+	maxRate := resource.MustParse("64Ki")
+	limits := &apis.TransferLimits{MaxRate: &maxRate}
+	data := make([]byte, 128*1024)
+
+	start := time.Now()
+	w := throttledWriter(context.Background(), &buf, limits)
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Errorf("writing 128Ki at 64Ki/s took %v", elapsed)
+	}
+
+	TransferMaxRate = 64 * 1024
+	start = time.Now()
+	n, err := io.Copy(io.Discard, throttledReader(context.Background(), &buf, nil))
+	if err != nil || n != int64(len(data)) {
+		t.Fatalf("read %d bytes, error = %v", n, err)
+	}
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Errorf("reading 128Ki at 64Ki/s took %v", elapsed)
+	}
+
+	// the limits can not raise the rate of the node
+	fast := resource.MustParse("1Gi")
+	if l := transferLimiter(&apis.TransferLimits{MaxRate: &fast}); l == nil || l.Limit() != 64*1024 {
+		t.Errorf("transferLimiter() with a higher rate than the node = %v, want the rate of the node", l)
+	}
+	slow := resource.MustParse("16Ki")
+	if l := transferLimiter(&apis.TransferLimits{MaxRate: &slow}); l == nil || l.Limit() != 16*1024 {
+		t.Errorf("transferLimiter() with a lower rate than the node = %v, want 16Ki", l)
+	}
+
+	// the wait is given up once the transfer is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := throttledWriter(ctx, &buf, nil).Write(data); err == nil {
+		t.Errorf("Write() of the cancelled transfer should fail")
+	}
+}
@@ -0,0 +1,565 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	k8sapi "github.com/openebs/lib-csi/pkg/client/k8s"
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// TransportDialTimeoutKey is the environment variable to set the time
+	// allowed to connect to the backup or the restore server, e.g. "30s"
+	TransportDialTimeoutKey string = "OPENEBS_IO_BACKUP_DIAL_TIMEOUT"
+	// TransportIdleTimeoutKey is the environment variable to set the time
+	// for which the backup or restore stream can stay idle, e.g. "60s"
+	TransportIdleTimeoutKey string = "OPENEBS_IO_BACKUP_IDLE_TIMEOUT"
+	// TransferMaxRetriesKey is the environment variable to set the number
+	// of times a failed backup or restore transfer is retried
+	TransferMaxRetriesKey string = "OPENEBS_IO_BACKUP_MAX_RETRIES"
+
+	// transportCloseWait is the time to wait for the backup server to
+	// close the connection once the complete stream has been sent
+	transportCloseWait = 3 * time.Second
+)
+
+var (
+	// TransportDialTimeout is the time allowed to connect
+	// to the backup or the restore server
+	TransportDialTimeout = 30 * time.Second
+
+	// TransportIdleTimeout is the time for which the backup
+	// or the restore stream can stay idle
+	TransportIdleTimeout = 60 * time.Second
+
+	// TransferMaxRetries is the number of times a failed
+	// backup or restore transfer is retried
+	TransferMaxRetries = 3
+)
+
+func init() {
+	TransportDialTimeout = getEnvDuration(TransportDialTimeoutKey, TransportDialTimeout)
+	TransportIdleTimeout = getEnvDuration(TransportIdleTimeoutKey, TransportIdleTimeout)
+
+	if val := os.Getenv(TransferMaxRetriesKey); val != "" {
+		retries, err := strconv.Atoi(val)
+		if err != nil || retries < 0 {
+			klog.Warningf("zfs: invalid value %q for %s, using default %d", val, TransferMaxRetriesKey, TransferMaxRetries)
+		} else {
+			TransferMaxRetries = retries
+		}
+	}
+}
+
+// getEnvDuration returns the duration set in the environment
+// variable or the default if it is not set or not valid
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	val := os.Getenv(key)
+	if val == "" {
+		return def
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil || d <= 0 {
+		klog.Warningf("zfs: invalid duration %q for %s, using default %v", val, key, def)
+		return def
+	}
+	return d
+}
+
+// idleTimeoutConn extends the read and write deadlines of the
+// connection on every transfer, so that only a stalled stream times out
+type idleTimeoutConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *idleTimeoutConn) Read(p []byte) (int, error) {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(p)
+}
+
+func (c *idleTimeoutConn) Write(p []byte) (int, error) {
+	if err := c.Conn.SetWriteDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(p)
+}
+
+// streamErr remembers the first error seen on the network side of the
+// stream, as the zfs command only reports that its stream got cut
+type streamErr struct {
+	rw  io.ReadWriter
+	err error
+}
+
+func (s *streamErr) Read(p []byte) (int, error) {
+	n, err := s.rw.Read(p)
+	if err != nil && err != io.EOF && s.err == nil {
+		s.err = err
+	}
+	return n, err
+}
+
+func (s *streamErr) Write(p []byte) (int, error) {
+	n, err := s.rw.Write(p)
+	if err != nil && s.err == nil {
+		s.err = err
+	}
+	return n, err
+}
+
+// getStreamSecret returns the secret holding the certificates or the
+// credentials for the stream
+func getStreamSecret(name string) (*corev1.Secret, error) {
+	client, err := k8sapi.Clientset().Get()
+	if err != nil {
+		return nil, err
+	}
+	return client.CoreV1().Secrets(OpenEBSNamespace).
+		Get(context.TODO(), name, metav1.GetOptions{})
+}
+
+// streamTLSConfig returns the tls config for the stream to or from addr,
+// it returns nil if TLS is not enabled for the stream
+func streamTLSConfig(spec *apis.StreamTLS, addr string) (*tls.Config, error) {
+	if spec == nil {
+		return nil, nil
+	}
+
+	serverName := spec.ServerName
+	if len(serverName) == 0 {
+		host, _, err := parseStreamAddr(addr)
+		if err != nil {
+			return nil, err
+		}
+		serverName = strings.TrimSuffix(host, ".")
+	}
+
+	secret, err := getStreamSecret(spec.SecretName)
+	if err != nil {
+		return nil, fmt.Errorf("zfs: could not get the tls secret %s: %v", spec.SecretName, err)
+	}
+
+	conf, err := buildStreamTLSConfig(secret.Data, serverName)
+	if err != nil {
+		return nil, fmt.Errorf("zfs: invalid tls secret %s: %v", spec.SecretName, err)
+	}
+	return conf, nil
+}
+
+// buildStreamTLSConfig builds the tls config from the ca.crt, tls.crt and
+// tls.key present in the secret data. The server is verified using the CA
+// if present, otherwise using the system roots, and the client certificate
+// is presented to the server if present.
+func buildStreamTLSConfig(data map[string][]byte, serverName string) (*tls.Config, error) {
+	conf := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+	}
+
+	if ca, ok := data[corev1.ServiceAccountRootCAKey]; ok {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no valid certificate found in %s", corev1.ServiceAccountRootCAKey)
+		}
+		conf.RootCAs = pool
+	}
+
+	cert, hasCert := data[corev1.TLSCertKey]
+	key, hasKey := data[corev1.TLSPrivateKeyKey]
+	if hasCert != hasKey {
+		return nil, fmt.Errorf("both %s and %s are required for the client certificate",
+			corev1.TLSCertKey, corev1.TLSPrivateKeyKey)
+	}
+	if hasCert {
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{pair}
+	}
+	return conf, nil
+}
+
+// dialStreamServer connects to the backup or restore server, trying all the
+// addresses its host name resolves to in order. The connection is secured
+// with TLS if conf is not nil.
+func dialStreamServer(addr string, conf *tls.Config) (net.Conn, error) {
+	host, port, err := parseStreamAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := resolveStreamAddr(host, port)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: TransportDialTimeout}
+	var errs []string
+	for _, ipAddr := range addrs {
+		conn, err := dialer.Dial("tcp", ipAddr)
+		if err != nil {
+			klog.Warningf("zfs: could not connect to %s at %s, err: %v", addr, ipAddr, err)
+			errs = append(errs, err.Error())
+			continue
+		}
+		if conf == nil {
+			return conn, nil
+		}
+
+		tlsConn := tls.Client(conn, conf)
+		_ = tlsConn.SetDeadline(time.Now().Add(TransportDialTimeout))
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("zfs: could not establish tls connection to %s: %v", addr, err)
+		}
+		_ = tlsConn.SetDeadline(time.Time{})
+		return tlsConn, nil
+	}
+	return nil, fmt.Errorf("zfs: could not connect to %s: %s", addr, strings.Join(errs, "; "))
+}
+
+// sendBackup streams the backup snapshot to the backup server, writes it
+// to the file target or uploads it to the s3 target. It returns
+// ErrTransferQueued if the transfer can not start yet, and
+// ErrTransferCancelled or ErrTransferDeadline if the backup has been
+// cancelled or has exceeded its deadline.
+func sendBackup(bkp *apis.ZFSBackup, vol *apis.ZFSVolume) error {
+	ctx, done, err := transferContext(bkp.UID, &bkp.Transfer, bkp.Spec.Cancel, bkp.Spec.Deadline)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if !startTransfer(bkp.Spec.Limits) {
+		return ErrTransferQueued
+	}
+	defer endTransfer()
+
+	return transferError(ctx, sendTarget(ctx, bkp, vol))
+}
+
+// sendTarget sends the backup snapshot to bkp.Spec.BackupDest
+func sendTarget(ctx context.Context, bkp *apis.ZFSBackup, vol *apis.ZFSVolume) error {
+
+	// the backup gets updated once the transfer is over, from the version
+	// of its last progress patch
+	var version string
+	defer func() {
+		if len(version) > 0 {
+			bkp.ResourceVersion = version
+		}
+	}()
+	report := func(progress apis.TransferInfo) error {
+		newBkp, err := patchBkpProgress(bkp.Name, progress)
+		if err != nil {
+			return err
+		}
+		version = newBkp.ResourceVersion
+		return nil
+	}
+	codec, err := backupCodec(bkp)
+	if err != nil {
+		return err
+	}
+	if isFileTarget(bkp.Spec.BackupDest) {
+		return sendToFile(ctx, bkp, vol, codec, report)
+	}
+	if isS3Target(bkp.Spec.BackupDest) {
+		target, err := newS3Target(bkp.Spec.BackupDest, bkp.Spec.S3)
+		if err != nil {
+			return err
+		}
+		return sendToS3(ctx, bkp, vol, target, codec, report)
+	}
+
+	conf, err := streamTLSConfig(bkp.Spec.TLS, bkp.Spec.BackupDest)
+	if err != nil {
+		return err
+	}
+	return sendStream(ctx, bkp, vol, conf, codec, report)
+}
+
+// sendStream sends the backup snapshot encoded by the codec over the connection to
+// bkp.Spec.BackupDest, the progress of the transfer is kept in bkp.Transfer and
+// reported using report
+func sendStream(ctx context.Context, bkp *apis.ZFSBackup, vol *apis.ZFSVolume, conf *tls.Config, codec *streamCodec, report progressReporter) error {
+	addr := bkp.Spec.BackupDest
+
+	size, err := backend.EstimateSend(bkp, vol)
+	if err != nil {
+		klog.Warningf("zfs: could not estimate the size of backup %s, err: %v", bkp.Name, err)
+	}
+	bkp.Transfer.EstimatedSize = size
+
+	conn, err := dialStreamServer(addr, conf)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// unblock the stream once the transfer is stopped
+	defer context.AfterFunc(ctx, func() { conn.Close() })()
+
+	stream := &streamErr{rw: &idleTimeoutConn{Conn: conn, timeout: TransportIdleTimeout}}
+	counter := &countWriter{w: stream}
+
+	progress := startProgress(&bkp.Transfer, counter.count, report)
+	err = sendEncoded(ctx, bkp, vol, counter, codec)
+	if err != nil && stream.err != nil {
+		err = fmt.Errorf("%v, stream to %s failed: %v", err, addr, stream.err)
+	}
+	if err == nil {
+		err = closeStream(conn, addr)
+	}
+	progress.finish(err)
+	return err
+}
+
+// closeStream lets the server know that the stream is complete and gives
+// it a chance to close the connection once it has read all of it
+func closeStream(conn net.Conn, addr string) error {
+	cw, ok := conn.(interface{ CloseWrite() error })
+	if !ok {
+		return nil
+	}
+	if err := cw.CloseWrite(); err != nil {
+		return fmt.Errorf("zfs: could not complete the stream to %s: %v", addr, err)
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(transportCloseWait)); err != nil {
+		return err
+	}
+	_, err := io.Copy(io.Discard, conn)
+	var nerr net.Error
+	if err != nil && !(errors.As(err, &nerr) && nerr.Timeout()) {
+		return fmt.Errorf("zfs: backup server %s failed to receive the stream: %v", addr, err)
+	}
+	return nil
+}
+
+// recvRestore receives the volume from the stream sent by the restore
+// server, from the stream file or from the stream object, or restores it
+// from the volume present on the node. It returns ErrTransferQueued if the
+// transfer can not start yet, and ErrTransferCancelled or ErrTransferDeadline
+// if the restore has been cancelled or has exceeded its deadline, in which
+// case the partially received state is discarded. The full stream is
+// received into the staging dataset, which replaces the volume once the
+// restore has been verified.
+func recvRestore(rstr *apis.ZFSRestore) error {
+	ctx, done, err := transferContext(rstr.UID, &rstr.Transfer, rstr.Spec.Cancel, rstr.Spec.Deadline)
+	if err != nil {
+		abortPartialRestore(rstr)
+		return err
+	}
+	defer done()
+
+	if !startTransfer(rstr.Spec.Limits) {
+		return ErrTransferQueued
+	}
+	defer endTransfer()
+
+	if err := stageRestore(rstr); err != nil {
+		return err
+	}
+	err = transferError(ctx, recvSource(ctx, rstr))
+	if err == nil {
+		err = commitRestore(rstr)
+	}
+	if TransferStopped(err) {
+		abortPartialRestore(rstr)
+	}
+	return err
+}
+
+// recvSource receives the volume from rstr.Spec.RestoreSrc
+func recvSource(ctx context.Context, rstr *apis.ZFSRestore) error {
+
+	// the restore gets updated once the transfer is over, from the version
+	// of its last progress patch
+	var version string
+	defer func() {
+		if len(version) > 0 {
+			rstr.ResourceVersion = version
+		}
+	}()
+	report := func(progress apis.TransferInfo) error {
+		newRstr, err := patchRestoreProgress(rstr.Name, progress)
+		if err != nil {
+			return err
+		}
+		version = newRstr.ResourceVersion
+		return nil
+	}
+	codec, err := restoreCodec(rstr)
+	if err != nil {
+		return err
+	}
+	if isLocalSource(rstr.Spec.RestoreSrc) {
+		volName, _ := parseLocalSource(rstr.Spec.RestoreSrc)
+		src, err := GetZFSVolume(volName)
+		if err != nil {
+			return fmt.Errorf("zfs: could not get the restore source volume %s: %v", volName, err)
+		}
+		return recvLocal(ctx, rstr, src, report)
+	}
+	if isFileTarget(rstr.Spec.RestoreSrc) {
+		return recvFromFile(ctx, rstr, codec, report)
+	}
+	if isS3Target(rstr.Spec.RestoreSrc) {
+		target, err := newS3Target(rstr.Spec.RestoreSrc, rstr.Spec.S3)
+		if err != nil {
+			return err
+		}
+		return recvFromS3(ctx, rstr, target, codec, report)
+	}
+
+	conf, err := streamTLSConfig(rstr.Spec.TLS, rstr.Spec.RestoreSrc)
+	if err != nil {
+		return err
+	}
+	return recvStream(ctx, rstr, conf, codec, report)
+}
+
+// recvStream receives the volume over the connection to rstr.Spec.RestoreSrc, the
+// stream is decoded by the codec if it has been encoded on backup. The progress
+// of the transfer is kept in rstr.Transfer and reported using report.
+func recvStream(ctx context.Context, rstr *apis.ZFSRestore, conf *tls.Config, codec *streamCodec, report progressReporter) error {
+	addr := rstr.Spec.RestoreSrc
+
+	conn, err := dialStreamServer(addr, conf)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// unblock the stream once the transfer is stopped
+	defer context.AfterFunc(ctx, func() { conn.Close() })()
+
+	stream := &streamErr{rw: &idleTimeoutConn{Conn: conn, timeout: TransportIdleTimeout}}
+	counter := &countReader{r: stream}
+
+	progress := startProgress(&rstr.Transfer, counter.count, report)
+	err = recvDecoded(ctx, rstr, counter, codec)
+	if err != nil && stream.err != nil {
+		err = fmt.Errorf("%v, stream from %s failed: %v", err, addr, stream.err)
+	}
+	progress.finish(err)
+	return err
+}
+
+// RetryBackup records the failed attempt of the backup and returns true if
+// it should be retried. The backup server keeps no resumable state of the
+// stream, so the retry sends the stream from the beginning.
+func RetryBackup(bkp *apis.ZFSBackup) bool {
+	if bkp.Transfer.Retries >= TransferMaxRetries {
+		return false
+	}
+	bkp.Transfer.Retries++
+	return true
+}
+
+// RetryRestore records the failed attempt of the restore along with the
+// resume token of the partially received volume and returns true if it
+// should be retried. The local restore resumes the stream from the
+// recorded token using zfs send -t, the restore server sends the whole
+// stream again, so the partially received state is discarded for it.
+func RetryRestore(rstr *apis.ZFSRestore) bool {
+	volume := recvDataset(rstr)
+
+	rstr.Transfer.ResumeToken = ""
+	if err := backend.GetDataset(volume); err == nil {
+		token, err := backend.GetProperty(volume, "receive_resume_token")
+		if err == nil && token != "-" {
+			if isLocalSource(rstr.Spec.RestoreSrc) {
+				rstr.Transfer.ResumeToken = token
+			} else if err := backend.AbortRecv(volume); err != nil {
+				klog.Warningf("zfs: could not discard the partial state of the restore %s, err: %v", rstr.Name, err)
+			}
+		}
+	}
+
+	if rstr.Transfer.Retries >= TransferMaxRetries {
+		return false
+	}
+	rstr.Transfer.Retries++
+	return true
+}
+
+// abortPartialRestore discards the partially received state of the stopped
+// restore, which would otherwise hold the space of the pool
+func abortPartialRestore(rstr *apis.ZFSRestore) {
+	if err := discardLocalSnapshot(rstr); err != nil {
+		klog.Errorf("zfs: could not destroy the snapshot taken by the restore %s, err: %v", rstr.Name, err)
+	}
+	if len(rstr.Transfer.StagingDataset) > 0 {
+		if err := discardStaging(rstr); err != nil {
+			klog.Errorf("zfs: could not discard the partial state of the restore %s, err: %v", rstr.Name, err)
+		}
+		return
+	}
+	volume := recvDataset(rstr)
+	if backend.GetDataset(volume) != nil {
+		return
+	}
+	token, err := backend.GetProperty(volume, "receive_resume_token")
+	if err != nil || token == "-" || token == "" {
+		return
+	}
+	if err := backend.AbortRecv(volume); err != nil {
+		klog.Errorf("zfs: could not discard the partial state of the restore %s, err: %v", rstr.Name, err)
+		return
+	}
+	rstr.Transfer.ResumeToken = ""
+	klog.Infof("discarded the partial state of the restore %s on %s", rstr.Name, volume)
+}
+
+// AbortRestore discards the partially received state of the restore volume
+// so that it does not hold any space once the restore has failed, the
+// staging dataset and the snapshot taken by the local restore are destroyed
+// along with it
+func AbortRestore(rstr *apis.ZFSRestore) error {
+	if err := discardLocalSnapshot(rstr); err != nil {
+		return err
+	}
+	if len(rstr.Transfer.StagingDataset) > 0 {
+		return discardStaging(rstr)
+	}
+	if len(rstr.Transfer.ResumeToken) == 0 {
+		return nil
+	}
+	volume := recvDataset(rstr)
+	if err := backend.AbortRecv(volume); err != nil {
+		return err
+	}
+	rstr.Transfer.ResumeToken = ""
+	return nil
+}
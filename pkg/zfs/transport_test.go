@@ -0,0 +1,468 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+)
+
+// streamListener listens on a local port, with TLS if conf is not nil
+func streamListener(t *testing.T, conf *tls.Config) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error = %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	if conf != nil {
+		return tls.NewListener(l, conf)
+	}
+	return l
+}
+
+// backupServer accepts one connection and returns the stream read from it
+func backupServer(t *testing.T, conf *tls.Config) (string, <-chan []byte) {
+	l := streamListener(t, conf)
+
+	ch := make(chan []byte, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			close(ch)
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		ch <- data
+	}()
+	return l.Addr().String(), ch
+}
+
+// restoreServer accepts one connection and writes the stream to it
+func restoreServer(t *testing.T, conf *tls.Config, data []byte) string {
+	l := streamListener(t, conf)
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write(data)
+	}()
+	return l.Addr().String()
+}
+
+func TestBackupRestoreStream(t *testing.T) {
+	fake := useFakeZFS(t)
+
+	vol := testVolume("pvc-1", VolTypeDataset, "1073741824", "yes")
+	if err := CreateVolume(vol); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	if err := fake.Write("pool/pvc-1", 4096); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	bkp := &apis.ZFSBackup{}
+	bkp.Spec.VolumeName = vol.Name
+	bkp.Spec.SnapName = "bkp-1"
+
+	// sending a snapshot which is not present must fail
+	addr, recvd := backupServer(t, nil)
+	bkp.Spec.BackupDest = addr
+	if err := sendBackup(bkp, vol); err == nil {
+		t.Errorf("sendBackup() of missing snapshot should fail")
+	}
+	<-recvd
+
+	snap := &apis.ZFSSnapshot{}
+	snap.Name = bkp.Spec.SnapName
+	snap.Spec.PoolName = vol.Spec.PoolName
+	snap.Labels = map[string]string{ZFSVolKey: vol.Name}
+	if err := CreateSnapshot(snap); err != nil {
+		t.Fatalf("CreateSnapshot() error = %v", err)
+	}
+
+	addr, recvd = backupServer(t, nil)
+	bkp.Spec.BackupDest = addr
+	if err := sendBackup(bkp, vol); err != nil {
+		t.Fatalf("sendBackup() error = %v", err)
+	}
+	data := <-recvd
+	if len(data) == 0 {
+		t.Fatalf("backup server received an empty stream")
+	}
+
+	rstr := &apis.ZFSRestore{}
+	rstr.Spec.VolumeName = "pvc-2"
+	rstr.Spec.RestoreSrc = restoreServer(t, nil, data)
+	rstr.VolSpec = vol.Spec
+	if err := recvRestore(rstr); err != nil {
+		t.Fatalf("recvRestore() error = %v", err)
+	}
+	ds, ok := fake.Dataset("pool/pvc-2")
+	if !ok || ds.Written != 4096 {
+		t.Errorf("restored volume = %+v, want 4096 bytes written", ds)
+	}
+	if _, ok := fake.Dataset("pool/pvc-2@bkp-1"); !ok {
+		t.Errorf("restored snapshot is not present")
+	}
+
+	// a truncated stream must fail the restore
+	rstr.Spec.VolumeName = "pvc-3"
+	rstr.Spec.RestoreSrc = restoreServer(t, nil, data[:len(data)/2])
+	if err := recvRestore(rstr); err == nil {
+		t.Errorf("recvRestore() of truncated stream should fail")
+	}
+
+	rstr.Spec.RestoreSrc = "pvc-1"
+	if err := recvRestore(rstr); err == nil {
+		t.Errorf("recvRestore() from invalid address should fail")
+	}
+}
+
+// testCert is a certificate along with its key, in PEM
+type testCert struct {
+	cert, key []byte
+	x509      *x509.Certificate
+	priv      *ecdsa.PrivateKey
+}
+
+// newTestCert returns a certificate signed by the parent,
+// or a self signed CA certificate if parent is nil
+func newTestCert(t *testing.T, name string, parent *testCert) *testCert {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := tmpl, priv
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.x509, parent.priv
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &priv.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey() error = %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCert{
+		cert: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		key:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+		x509: cert,
+		priv: priv,
+	}
+}
+
+// serverTLSConfig returns the tls config of the server requiring client certificates
+func serverTLSConfig(t *testing.T, ca, server *testCert) *tls.Config {
+	pair, err := tls.X509KeyPair(server.cert, server.key)
+	if err != nil {
+		t.Fatalf("X509KeyPair() error = %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca.cert)
+	return &tls.Config{
+		Certificates: []tls.Certificate{pair},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}
+}
+
+func TestBackupRestoreStreamTLS(t *testing.T) {
+	fake := useFakeZFS(t)
+
+	ca := newTestCert(t, "ca", nil)
+	srvConf := serverTLSConfig(t, ca, newTestCert(t, "server", ca))
+	client := newTestCert(t, "client", ca)
+
+	vol := testVolume("pvc-1", VolTypeZVol, "1073741824", "yes")
+	if err := CreateVolume(vol); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	snap := &apis.ZFSSnapshot{}
+	snap.Name = "bkp-1"
+	snap.Spec.PoolName = vol.Spec.PoolName
+	snap.Labels = map[string]string{ZFSVolKey: vol.Name}
+	if err := CreateSnapshot(snap); err != nil {
+		t.Fatalf("CreateSnapshot() error = %v", err)
+	}
+
+	bkp := &apis.ZFSBackup{}
+	bkp.Spec.VolumeName = vol.Name
+	bkp.Spec.SnapName = snap.Name
+
+	if _, err := buildStreamTLSConfig(map[string][]byte{
+		"ca.crt": ca.cert, "tls.crt": client.cert,
+	}, ""); err == nil {
+		t.Errorf("buildStreamTLSConfig() without the client key should fail")
+	}
+
+	conf, err := buildStreamTLSConfig(map[string][]byte{
+		"ca.crt": ca.cert, "tls.crt": client.cert, "tls.key": client.key,
+	}, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("buildStreamTLSConfig() error = %v", err)
+	}
+
+	addr, recvd := backupServer(t, srvConf)
+	bkp.Spec.BackupDest = addr
+	if err := sendStream(context.Background(), bkp, vol, conf, nil, nil); err != nil {
+		t.Fatalf("sendStream() error = %v", err)
+	}
+	data := <-recvd
+	if len(data) == 0 {
+		t.Fatalf("backup server received an empty stream")
+	}
+
+	// the server must reject a client without a certificate
+	noCert, _ := buildStreamTLSConfig(map[string][]byte{"ca.crt": ca.cert}, "127.0.0.1")
+	addr, recvd = backupServer(t, srvConf)
+	bkp.Spec.BackupDest = addr
+	if err := sendStream(context.Background(), bkp, vol, noCert, nil, nil); err == nil {
+		t.Errorf("sendStream() without client certificate should fail")
+	}
+	if got := <-recvd; len(got) != 0 {
+		t.Errorf("backup server received %d bytes from an unverified client", len(got))
+	}
+
+	// the client must reject a server not signed by its CA
+	other := newTestCert(t, "other", nil)
+	otherConf, _ := buildStreamTLSConfig(map[string][]byte{
+		"ca.crt": other.cert, "tls.crt": client.cert, "tls.key": client.key,
+	}, "127.0.0.1")
+	addr, _ = backupServer(t, srvConf)
+	bkp.Spec.BackupDest = addr
+	if err := sendStream(context.Background(), bkp, vol, otherConf, nil, nil); err == nil {
+		t.Errorf("sendStream() to an unverified server should fail")
+	}
+
+	rstr := &apis.ZFSRestore{}
+	rstr.Spec.VolumeName = "pvc-2"
+	rstr.Spec.RestoreSrc = restoreServer(t, srvConf, data)
+	rstr.VolSpec = vol.Spec
+	if err := recvStream(context.Background(), rstr, conf, nil, nil); err != nil {
+		t.Fatalf("recvStream() error = %v", err)
+	}
+	if _, ok := fake.Dataset("pool/pvc-2@bkp-1"); !ok {
+		t.Errorf("restored snapshot is not present")
+	}
+}
+
+func TestRestoreResume(t *testing.T) {
+	fake := useFakeZFS(t)
+
+	vol := testVolume("pvc-1", VolTypeDataset, "1073741824", "yes")
+	if err := CreateVolume(vol); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	if err := fake.Write("pool/pvc-1", 4096); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	snap := &apis.ZFSSnapshot{}
+	snap.Name = "bkp-1"
+	snap.Spec.PoolName = vol.Spec.PoolName
+	snap.Labels = map[string]string{ZFSVolKey: vol.Name}
+	if err := CreateSnapshot(snap); err != nil {
+		t.Fatalf("CreateSnapshot() error = %v", err)
+	}
+
+	bkp := &apis.ZFSBackup{}
+	bkp.Spec.VolumeName = vol.Name
+	bkp.Spec.SnapName = snap.Name
+	addr, recvd := backupServer(t, nil)
+	bkp.Spec.BackupDest = addr
+	if err := sendBackup(bkp, vol); err != nil {
+		t.Fatalf("sendBackup() error = %v", err)
+	}
+	data := <-recvd
+
+	// the stream gets cut in between
+	rstr := &apis.ZFSRestore{}
+	rstr.Spec.VolumeName = "pvc-2"
+	rstr.Spec.RestoreSrc = restoreServer(t, nil, data[:len(data)-10])
+	rstr.VolSpec = vol.Spec
+	if err := recvRestore(rstr); err == nil {
+		t.Fatalf("recvRestore() of truncated stream should fail")
+	}
+	if !RetryRestore(rstr) || rstr.Transfer.Retries != 1 {
+		t.Fatalf("RetryRestore() should allow the retry, retries = %d", rstr.Transfer.Retries)
+	}
+	// the restore server sends the whole stream again on retry
+	if len(rstr.Transfer.ResumeToken) > 0 {
+		t.Errorf("resume token = %q recorded for the remote restore", rstr.Transfer.ResumeToken)
+	}
+	if _, ok := fake.Dataset("pool/pvc-2"); ok {
+		t.Errorf("partially received volume is present after RetryRestore()")
+	}
+	rstr.Spec.RestoreSrc = restoreServer(t, nil, data)
+	if err := recvRestore(rstr); err != nil {
+		t.Fatalf("recvRestore() of full stream on retry error = %v", err)
+	}
+	if _, ok := fake.Dataset("pool/pvc-2@bkp-1"); !ok {
+		t.Errorf("restored snapshot is not present")
+	}
+	if val, _ := backend.GetProperty("pool/pvc-2", "receive_resume_token"); val != "-" {
+		t.Errorf("receive_resume_token = %q after the retried receive", val)
+	}
+
+	// the partial state is discarded once the retries are exhausted
+	rstr.Spec.VolumeName = "pvc-3"
+	rstr.Spec.RestoreSrc = restoreServer(t, nil, data[:len(data)-10])
+	rstr.Transfer = apis.TransferInfo{Retries: TransferMaxRetries}
+	if err := recvRestore(rstr); err == nil {
+		t.Fatalf("recvRestore() of truncated stream should fail")
+	}
+	if RetryRestore(rstr) {
+		t.Errorf("RetryRestore() should fail once the retries are exhausted")
+	}
+	if err := AbortRestore(rstr); err != nil {
+		t.Fatalf("AbortRestore() error = %v", err)
+	}
+	if _, ok := fake.Dataset("pool/pvc-3"); ok {
+		t.Errorf("partially received volume is present after AbortRestore()")
+	}
+}
+
+func TestTransferProgress(t *testing.T) {
+	fake := useFakeZFS(t)
+
+	old := TransferProgressInterval
+	TransferProgressInterval = time.Millisecond
+	t.Cleanup(func() { TransferProgressInterval = old })
+
+	vol := testVolume("pvc-1", VolTypeZVol, "1073741824", "yes")
+	if err := CreateVolume(vol); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	if err := fake.Write("pool/pvc-1", 4096); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	snap := &apis.ZFSSnapshot{}
+	snap.Name = "bkp-1"
+	snap.Spec.PoolName = vol.Spec.PoolName
+	snap.Labels = map[string]string{ZFSVolKey: vol.Name}
+	if err := CreateSnapshot(snap); err != nil {
+		t.Fatalf("CreateSnapshot() error = %v", err)
+	}
+
+	bkp := &apis.ZFSBackup{}
+	bkp.Spec.VolumeName = vol.Name
+	bkp.Spec.SnapName = snap.Name
+	addr, recvd := backupServer(t, nil)
+	bkp.Spec.BackupDest = addr
+	if err := sendStream(context.Background(), bkp, vol, nil, nil, nil); err != nil {
+		t.Fatalf("sendStream() error = %v", err)
+	}
+	data := <-recvd
+
+	info := bkp.Transfer
+	if info.EstimatedSize != int64(len(data)) || info.BytesTransferred != int64(len(data)) {
+		t.Errorf("estimated %d transferred %d, want %d", info.EstimatedSize, info.BytesTransferred, len(data))
+	}
+	if info.StartTime == nil || info.CompletionTime == nil || info.CompletionTime.Before(info.StartTime) {
+		t.Errorf("start time %v completion time %v", info.StartTime, info.CompletionTime)
+	}
+
+	// the progress is reported while the stream is running
+	l := streamListener(t, nil)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write(data[:10])
+		time.Sleep(50 * time.Millisecond)
+		conn.Write(data[10:])
+	}()
+	var reports []apis.TransferInfo
+	rstr := &apis.ZFSRestore{}
+	rstr.Spec.VolumeName = "pvc-2"
+	rstr.Spec.RestoreSrc = l.Addr().String()
+	rstr.VolSpec = vol.Spec
+	err := recvStream(context.Background(), rstr, nil, nil, func(progress apis.TransferInfo) error {
+		reports = append(reports, progress)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("recvStream() error = %v", err)
+	}
+	if len(reports) == 0 {
+		t.Errorf("progress is not reported while receiving")
+	}
+	for _, progress := range reports {
+		if progress.StartTime == nil || progress.BytesTransferred > int64(len(data)) || len(progress.Checksum) > 0 {
+			t.Errorf("reported progress = %+v, want only the progress fields", progress)
+		}
+	}
+	if rstr.Transfer.BytesTransferred != int64(len(data)) || rstr.Transfer.CompletionTime == nil {
+		t.Errorf("restore transfer = %+v, want %d bytes completed", rstr.Transfer, len(data))
+	}
+
+	// a failed transfer is not completed
+	rstr.Spec.VolumeName = "pvc-3"
+	rstr.Spec.RestoreSrc = restoreServer(t, nil, data[:10])
+	if err := recvStream(context.Background(), rstr, nil, nil, nil); err == nil {
+		t.Fatalf("recvStream() of truncated stream should fail")
+	}
+	if rstr.Transfer.BytesTransferred != 10 || rstr.Transfer.CompletionTime != nil {
+		t.Errorf("restore transfer = %+v, want 10 bytes not completed", rstr.Transfer)
+	}
+}
+
+func TestParseSendSize(t *testing.T) {
+	full := "full\tpool/pvc-1@bkp-1\t5343560\nsize\t5343560\n"
+	incr := "incremental\tbkp-1\tpool/pvc-1@bkp-2\t312\nsize\t312\n"
+	if size, err := parseSendSize([]byte(full)); err != nil || size != 5343560 {
+		t.Errorf("parseSendSize(full) = %d, %v", size, err)
+	}
+	if size, err := parseSendSize([]byte(incr)); err != nil || size != 312 {
+		t.Errorf("parseSendSize(incremental) = %d, %v", size, err)
+	}
+	if _, err := parseSendSize([]byte("cannot open 'pool/pvc-1@bkp-1'\n")); err == nil {
+		t.Errorf("parseSendSize() without the size should fail")
+	}
+}
@@ -0,0 +1,163 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	"k8s.io/klog/v2"
+)
+
+// streamChecksumPrefix is the prefix of the stream checksum
+const streamChecksumPrefix = "sha256:"
+
+// streamChecksum returns the checksum of the stream hashed by h
+func streamChecksum(h hash.Hash) string {
+	return streamChecksumPrefix + hex.EncodeToString(h.Sum(nil))
+}
+
+// streamMismatchError is returned by the checksumReader when the stream
+// does not have the size or the checksum recorded at backup
+type streamMismatchError struct {
+	error
+}
+
+// checksumReader verifies the size and the checksum of the stream once all
+// of it has been read, the size is not verified if it is negative and the
+// checksum is not verified if it is empty. It records if the stream, or
+// the one it reads from, has failed the verification.
+type checksumReader struct {
+	r        io.Reader
+	hash     hash.Hash
+	n        int64
+	name     string
+	size     int64
+	checksum string
+	mismatch bool
+}
+
+func newChecksumReader(r io.Reader, name string, size int64, checksum string) *checksumReader {
+	return &checksumReader{r: r, hash: sha256.New(), name: name, size: size, checksum: checksum}
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.hash.Write(p[:n])
+	c.n += int64(n)
+	if err != io.EOF {
+		var mismatch streamMismatchError
+		if errors.As(err, &mismatch) {
+			c.mismatch = true
+		}
+		return n, err
+	}
+	if c.size >= 0 && c.n != c.size {
+		c.mismatch = true
+		return n, streamMismatchError{fmt.Errorf("zfs: backup stream of %s is %d bytes, expected %d",
+			c.name, c.n, c.size)}
+	}
+	if sum := c.sum(); len(c.checksum) > 0 && sum != c.checksum {
+		c.mismatch = true
+		return n, streamMismatchError{fmt.Errorf("zfs: backup stream of %s checksum %s does not match %s recorded at backup",
+			c.name, sum, c.checksum)}
+	}
+	return n, io.EOF
+}
+
+// sum returns the checksum of the stream read so far
+func (c *checksumReader) sum() string {
+	return streamChecksum(c.hash)
+}
+
+// snapshotGUID returns the guid of the backup snapshot of the volume
+func snapshotGUID(bkp *apis.ZFSBackup, vol *apis.ZFSVolume) (string, error) {
+	snapshot := vol.Spec.PoolName + "/" + vol.Name + "@" + bkp.Spec.SnapName
+	guid, err := backend.GetProperty(snapshot, "guid")
+	if err != nil {
+		return "", fmt.Errorf("zfs: could not get the guid of the backup snapshot %s: %v", snapshot, err)
+	}
+	return guid, nil
+}
+
+// restoreChecksum returns the checksum the restore stream is verified
+// against. The resumed stream is not the one sent at backup, so only the
+// guid of its snapshot is verified.
+func restoreChecksum(rstr *apis.ZFSRestore) string {
+	if rstr.Spec.Verify == nil || len(rstr.Transfer.ResumeToken) > 0 {
+		return ""
+	}
+	return rstr.Spec.Verify.Checksum
+}
+
+// verifyRestoreSnapshot checks that the received snapshot has the guid of
+// the backup snapshot and records it in the transfer status
+func verifyRestoreSnapshot(rstr *apis.ZFSRestore) error {
+	if rstr.Spec.Verify == nil || len(rstr.Spec.Verify.SnapName) == 0 {
+		return nil
+	}
+	snapshot := recvDataset(rstr) + "@" + rstr.Spec.Verify.SnapName
+	guid, err := backend.GetProperty(snapshot, "guid")
+	if err != nil {
+		return fmt.Errorf("zfs: could not get the guid of the restored snapshot %s: %v", snapshot, err)
+	}
+	rstr.Transfer.SnapGUID = guid
+	if len(rstr.Spec.Verify.SnapGUID) > 0 && guid != rstr.Spec.Verify.SnapGUID {
+		return fmt.Errorf("zfs: restored snapshot %s guid %s does not match %s recorded at backup",
+			snapshot, guid, rstr.Spec.Verify.SnapGUID)
+	}
+	return nil
+}
+
+// discardRestore destroys what has been received by the restore which
+// failed the verification, so that it can be retried. The staging dataset
+// or the volume is destroyed if it did not exist before, else the partially
+// received state and the received snapshot.
+func discardRestore(rstr *apis.ZFSRestore, existed bool) {
+	if len(rstr.Transfer.StagingDataset) > 0 {
+		if err := discardStaging(rstr); err != nil {
+			klog.Errorf("zfs: could not discard the unverified restore of %s, err: %v", rstr.Spec.VolumeName, err)
+		}
+		return
+	}
+
+	vol := &apis.ZFSVolume{}
+	vol.Name = rstr.Spec.VolumeName
+	vol.Spec = rstr.VolSpec
+
+	var err error
+	volume := vol.Spec.PoolName + "/" + vol.Name
+	if !existed {
+		err = backend.DestroyVolume(vol)
+	} else if token, _ := backend.GetProperty(volume, "receive_resume_token"); len(token) > 0 && token != "-" {
+		err = backend.AbortRecv(volume)
+	} else if rstr.Spec.Verify != nil && len(rstr.Spec.Verify.SnapName) > 0 {
+		snap := &apis.ZFSSnapshot{}
+		snap.Name = rstr.Spec.Verify.SnapName
+		snap.Spec.PoolName = vol.Spec.PoolName
+		snap.Labels = map[string]string{ZFSVolKey: vol.Name}
+		err = backend.DestroySnapshot(snap)
+	}
+	if err != nil {
+		klog.Errorf("zfs: could not discard the unverified restore of %s, err: %v", vol.Name, err)
+	}
+}
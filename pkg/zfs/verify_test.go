@@ -0,0 +1,125 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+)
+
+func TestBackupVerification(t *testing.T) {
+	fake := useFakeZFS(t)
+
+	vol := testVolume("pvc-1", VolTypeDataset, "1073741824", "yes")
+	if err := CreateVolume(vol); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	if err := fake.Write("pool/pvc-1", 4096); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	snap := &apis.ZFSSnapshot{}
+	snap.Name = "bkp-1"
+	snap.Spec.PoolName = vol.Spec.PoolName
+	snap.Labels = map[string]string{ZFSVolKey: vol.Name}
+	if err := CreateSnapshot(snap); err != nil {
+		t.Fatalf("CreateSnapshot() error = %v", err)
+	}
+
+	bkp := &apis.ZFSBackup{}
+	bkp.Name = "bkp-1"
+	bkp.Spec.VolumeName = vol.Name
+	bkp.Spec.SnapName = "bkp-1"
+	addr, recvd := backupServer(t, nil)
+	bkp.Spec.BackupDest = addr
+	if err := sendStream(context.Background(), bkp, vol, nil, nil, nil); err != nil {
+		t.Fatalf("sendStream() error = %v", err)
+	}
+	data := <-recvd
+
+	sum := sha256.Sum256(data)
+	if want := "sha256:" + hex.EncodeToString(sum[:]); bkp.Transfer.Checksum != want {
+		t.Errorf("backup checksum = %s, want %s", bkp.Transfer.Checksum, want)
+	}
+	guid, _ := backend.GetProperty("pool/pvc-1@bkp-1", "guid")
+	if bkp.Transfer.SnapGUID != guid {
+		t.Errorf("backup snapshot guid = %s, want %s", bkp.Transfer.SnapGUID, guid)
+	}
+
+	restore := func(volName string, stream []byte, verify apis.BackupVerification) (*apis.ZFSRestore, error) {
+		rstr := &apis.ZFSRestore{}
+		rstr.Spec.VolumeName = volName
+		rstr.Spec.RestoreSrc = restoreServer(t, nil, stream)
+		rstr.Spec.Verify = &verify
+		rstr.VolSpec = vol.Spec
+		return rstr, recvStream(context.Background(), rstr, nil, nil, nil)
+	}
+	verify := apis.BackupVerification{
+		Checksum: bkp.Transfer.Checksum,
+		SnapName: bkp.Spec.SnapName,
+		SnapGUID: bkp.Transfer.SnapGUID,
+	}
+
+	rstr, err := restore("pvc-2", data, verify)
+	if err != nil {
+		t.Fatalf("recvStream() error = %v", err)
+	}
+	if rstr.Transfer.Checksum != verify.Checksum || rstr.Transfer.SnapGUID != guid {
+		t.Errorf("restore checksum %s guid %s, want %s %s",
+			rstr.Transfer.Checksum, rstr.Transfer.SnapGUID, verify.Checksum, guid)
+	}
+
+	// the stream which is not the one sent at backup is discarded
+	tampered := append(append([]byte{}, data...), '\n')
+	if _, err := restore("pvc-3", tampered, verify); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("recvStream() of tampered stream error = %v, want checksum mismatch", err)
+	}
+	if _, ok := fake.Dataset("pool/pvc-3"); ok {
+		t.Errorf("volume received from tampered stream has not been discarded")
+	}
+
+	wrong := verify
+	wrong.SnapGUID = "1"
+	if _, err := restore("pvc-4", data, wrong); err == nil || !strings.Contains(err.Error(), "guid") {
+		t.Errorf("recvStream() of other snapshot error = %v, want guid mismatch", err)
+	}
+	if _, ok := fake.Dataset("pool/pvc-4"); ok {
+		t.Errorf("volume received with other snapshot guid has not been discarded")
+	}
+	if _, err := restore("pvc-4", data, verify); err != nil {
+		t.Errorf("recvStream() after discarded restore error = %v", err)
+	}
+
+	// the stream failing the verification while it is being received does
+	// not leave the partially received state to be resumed
+	rstr = &apis.ZFSRestore{}
+	rstr.Spec.VolumeName = "pvc-5"
+	rstr.VolSpec = vol.Spec
+	cut := bytes.IndexByte(data, '\n') + 1
+	short := newChecksumReader(bytes.NewReader(data[:cut]), "bkp-1", int64(len(data)), "")
+	if err := recvDecoded(context.Background(), rstr, short, nil); err == nil {
+		t.Fatalf("recvDecoded() of short stream should fail")
+	}
+	if _, ok := fake.Dataset("pool/pvc-5"); ok {
+		t.Errorf("partially received volume of short stream has not been discarded")
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"reflect"
 	"strconv"
 	"time"
 
@@ -26,8 +27,10 @@ import (
 	"github.com/openebs/zfs-localpv/pkg/builder/bkpbuilder"
 	"github.com/openebs/zfs-localpv/pkg/builder/restorebuilder"
 	"github.com/openebs/zfs-localpv/pkg/builder/snapbuilder"
+	"github.com/openebs/zfs-localpv/pkg/builder/snapgroupbuilder"
 	"github.com/openebs/zfs-localpv/pkg/builder/volbuilder"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2"
 )
 
@@ -283,6 +286,37 @@ func UpdateZvolInfo(vol *apis.ZFSVolume, status string) error {
 	return err
 }
 
+// SetVolumePublished records on the ZFSVolume that the volume has been
+// published at the target path on the node, or removes the record once it
+// has been unpublished from there. The update is retried on conflict, as
+// the volume may be published at several paths at the same time.
+func SetVolumePublished(volID, nodeID, targetPath string, published bool) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		vol, err := GetZFSVolume(volID)
+		if err != nil {
+			return err
+		}
+		paths := []apis.PublishedPath{}
+		found := false
+		for _, p := range vol.Status.PublishedPaths {
+			if p.NodeID == nodeID && p.TargetPath == targetPath {
+				found = true
+				continue
+			}
+			paths = append(paths, p)
+		}
+		if found == published {
+			return nil
+		}
+		if published {
+			paths = append(paths, apis.PublishedPath{NodeID: nodeID, TargetPath: targetPath})
+		}
+		vol.Status.PublishedPaths = paths
+		_, err = volbuilder.NewKubeclient().WithNamespace(OpenEBSNamespace).Update(vol)
+		return err
+	})
+}
+
 // RemoveVolumeFinalizer removes finalizer from ZFSVolume CR
 func RemoveVolumeFinalizer(vol *apis.ZFSVolume) error {
 	vol.Finalizers = nil
@@ -299,6 +333,57 @@ func GetZFSSnapshot(snapID string) (*apis.ZFSSnapshot, error) {
 	return snap, err
 }
 
+// ProvisionSnapshotGroup creates a ZFSSnapshotGroup CR,
+// watcher for the group is present in CSI agent
+func ProvisionSnapshotGroup(grp *apis.ZFSSnapshotGroup) error {
+	_, err := snapgroupbuilder.NewKubeclient().WithNamespace(OpenEBSNamespace).Create(grp)
+	if err == nil {
+		klog.Infof("provisioned snapshot group %s", grp.Name)
+	}
+	return err
+}
+
+// DeleteSnapshotGroup deletes the corresponding ZFSSnapshotGroup CR
+func DeleteSnapshotGroup(grpName string) error {
+	err := snapgroupbuilder.NewKubeclient().WithNamespace(OpenEBSNamespace).Delete(grpName)
+	if err == nil {
+		klog.Infof("deprovisioned snapshot group %s", grpName)
+	}
+	return err
+}
+
+// GetZFSSnapshotGroup fetches the given ZFSSnapshotGroup
+func GetZFSSnapshotGroup(grpName string) (*apis.ZFSSnapshotGroup, error) {
+	getOptions := metav1.GetOptions{}
+	return snapgroupbuilder.NewKubeclient().
+		WithNamespace(OpenEBSNamespace).Get(grpName, getOptions)
+}
+
+// UpdateSnapGroupInfo updates ZFSSnapshotGroup CR with the state and the
+// finalizer, which is removed once its ZFSSnapshots have been deleted
+func UpdateSnapGroupInfo(grp *apis.ZFSSnapshotGroup, state string, reason error) error {
+	grp.Finalizers = append(GetUserFinalizers(grp.Finalizers), ZFSFinalizer)
+	grp.Status.State = state
+	grp.Status.Error = ""
+	if reason != nil {
+		grp.Status.Error = reason.Error()
+	}
+	if state == ZFSStatusReady && grp.Status.CreationTime == nil {
+		grp.Status.CreationTime = &metav1.Time{Time: time.Now()}
+	}
+
+	_, err := snapgroupbuilder.NewKubeclient().WithNamespace(OpenEBSNamespace).Update(grp)
+	return err
+}
+
+// RemoveSnapGroupFinalizer removes finalizer from ZFSSnapshotGroup CR
+func RemoveSnapGroupFinalizer(grp *apis.ZFSSnapshotGroup) error {
+	grp.Finalizers = GetUserFinalizers(grp.Finalizers)
+
+	_, err := snapgroupbuilder.NewKubeclient().WithNamespace(OpenEBSNamespace).Update(grp)
+	return err
+}
+
 // GetZFSSnapshotStatus returns ZFSSnapshot status
 func GetZFSSnapshotStatus(snapID string) (string, error) {
 	getOptions := metav1.GetOptions{}
@@ -352,6 +437,31 @@ func UpdateSnapInfo(snap *apis.ZFSSnapshot) error {
 	return err
 }
 
+// UpdateSnapDeletionBlocked records the user holds which block the destroy
+// of the deleted snapshot in the ZFSSnapshot CR status
+func UpdateSnapDeletionBlocked(snap *apis.ZFSSnapshot, holds []string) error {
+	if reflect.DeepEqual(snap.Status.DeletionBlockedBy, holds) {
+		return nil
+	}
+	snap.Status.DeletionBlockedBy = holds
+
+	_, err := snapbuilder.NewKubeclient().WithNamespace(OpenEBSNamespace).Update(snap)
+	return err
+}
+
+// UpdateBkpDeletionBlocked records the error due to which the backup
+// snapshot of the deleted backup could not be destroyed yet in the
+// ZFSBackup CR transfer info
+func UpdateBkpDeletionBlocked(bkp *apis.ZFSBackup, reason error) error {
+	if bkp.Transfer.LastError == reason.Error() {
+		return nil
+	}
+	bkp.Transfer.LastError = reason.Error()
+
+	_, err := bkpbuilder.NewKubeclient().WithNamespace(OpenEBSNamespace).Update(bkp)
+	return err
+}
+
 // RemoveSnapFinalizer removes finalizer from ZFSSnapshot CR
 func RemoveSnapFinalizer(snap *apis.ZFSSnapshot) error {
 	snap.Finalizers = nil
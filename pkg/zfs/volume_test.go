@@ -1,9 +1,12 @@
 package zfs
 
 import (
+	"context"
+	"reflect"
 	"testing"
 
 	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestIsVolumeReady(t *testing.T) {
@@ -38,3 +41,45 @@ func TestIsVolumeReady(t *testing.T) {
 		})
 	}
 }
+
+func TestSetVolumePublished(t *testing.T) {
+	cs := useAPIServer(t)
+
+	vol := testVolume("pvc-1", VolTypeDataset, "1073741824", "yes")
+	vol.Namespace = OpenEBSNamespace
+	if _, err := cs.ZfsV1().ZFSVolumes(OpenEBSNamespace).Create(context.TODO(), vol, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	published := func() []apis.PublishedPath {
+		t.Helper()
+		vol, err := GetZFSVolume("pvc-1")
+		if err != nil {
+			t.Fatalf("GetZFSVolume() error = %v", err)
+		}
+		return vol.Status.PublishedPaths
+	}
+
+	// publishing again at the same path records it once
+	for _, path := range []string{"/mnt/1", "/mnt/2", "/mnt/1"} {
+		if err := SetVolumePublished("pvc-1", "node-1", path, true); err != nil {
+			t.Fatalf("SetVolumePublished(%s) error = %v", path, err)
+		}
+	}
+	want := []apis.PublishedPath{{NodeID: "node-1", TargetPath: "/mnt/1"}, {NodeID: "node-1", TargetPath: "/mnt/2"}}
+	if got := published(); !reflect.DeepEqual(got, want) {
+		t.Errorf("published paths = %v, want %v", got, want)
+	}
+
+	for _, path := range []string{"/mnt/1", "/mnt/1", "/mnt/2"} {
+		if err := SetVolumePublished("pvc-1", "node-1", path, false); err != nil {
+			t.Fatalf("SetVolumePublished(%s) unpublished error = %v", path, err)
+		}
+	}
+	if got := published(); len(got) != 0 {
+		t.Errorf("published paths after unpublish = %v, want none", got)
+	}
+
+	if err := SetVolumePublished("pvc-2", "node-1", "/mnt/1", true); err == nil {
+		t.Errorf("SetVolumePublished() of missing volume should fail")
+	}
+}
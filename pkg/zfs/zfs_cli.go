@@ -0,0 +1,499 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	"k8s.io/klog/v2"
+)
+
+// CommandRunner runs the zfs and the zpool commands. The commands are run
+// on the node unless the runner is replaced using SetCommandRunner, so that
+// the volume, snapshot, backup and zfsnode flows can be run against the
+// in-memory pools of zfstest without zfs.
+type CommandRunner interface {
+	// Output runs the command and returns its combined output
+	Output(name string, args ...string) ([]byte, error)
+
+	// Transfer runs zfs send or recv with its input read from stdin and
+	// its output written to stdout, the command is killed once ctx is
+	// done. The error output of the command is written to stderr.
+	Transfer(ctx context.Context, stdin io.Reader, stdout io.Writer, stderr io.Writer, name string, args ...string) error
+}
+
+// execRunner runs the commands on the node
+type execRunner struct{}
+
+// Output runs the command and returns its combined output
+func (execRunner) Output(name string, args ...string) ([]byte, error) {
+	return exec.Command(name, args...).CombinedOutput()
+}
+
+// Transfer runs the zfs send or recv command in its own process group
+func (execRunner) Transfer(ctx context.Context, stdin io.Reader, stdout io.Writer, stderr io.Writer, name string, args ...string) error {
+	cmd := transferCommand(ctx, name, args)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
+
+// runner runs the zfs and the zpool commands of the backend
+var runner CommandRunner = execRunner{}
+
+// SetCommandRunner replaces the runner of the zfs and the zpool commands
+// and returns the one which was in use. It is meant to be called before
+// any of the controllers are started, typically from tests.
+func SetCommandRunner(r CommandRunner) CommandRunner {
+	old := runner
+	runner = r
+	return old
+}
+
+// cliBackend performs the zfs operations of the driver on the node
+// by running the zfs commands using the runner
+type cliBackend struct{}
+
+// backend is used for all the zfs operations
+var backend = &cliBackend{}
+
+// GetDataset runs zfs list for the given dataset
+func (c *cliBackend) GetDataset(name string) error {
+	var ZFSVolArg []string
+
+	ZFSVolArg = append(ZFSVolArg, ZFSListArg, name)
+
+	_, err := runner.Output(ZFSVolCmd, ZFSVolArg...)
+	return err
+}
+
+// CreateVolume runs zfs create for the dataset or the zvol
+func (c *cliBackend) CreateVolume(vol *apis.ZFSVolume) error {
+	var args []string
+	volume := vol.Spec.PoolName + "/" + vol.Name
+
+	if vol.Spec.VolumeType == VolTypeDataset {
+		args = buildDatasetCreateArgs(vol)
+	} else {
+		args = buildZvolCreateArgs(vol)
+	}
+	out, err := runner.Output(ZFSVolCmd, args...)
+
+	if err != nil {
+		klog.Errorf(
+			"zfs: could not create volume %v cmd %v error: %s", volume, args, string(out),
+		)
+	}
+	return err
+}
+
+// CreateClone runs zfs clone for the volume
+func (c *cliBackend) CreateClone(vol *apis.ZFSVolume) error {
+	volume := vol.Spec.PoolName + "/" + vol.Name
+
+	args := buildCloneCreateArgs(vol)
+	out, err := runner.Output(ZFSVolCmd, args...)
+
+	if err != nil {
+		klog.Errorf(
+			"zfs: could not clone volume %v cmd %v error: %s", volume, args, string(out),
+		)
+	}
+	return err
+}
+
+// Rename runs zfs rename for the dataset
+func (c *cliBackend) Rename(from string, to string) error {
+	var ZFSVolArg []string
+
+	ZFSVolArg = append(ZFSVolArg, ZFSRenameArg, from, to)
+
+	out, err := runner.Output(ZFSVolCmd, ZFSVolArg...)
+	if err != nil {
+		klog.Errorf("zfs: could not rename %v cmd %v error: %s",
+			from, ZFSVolArg, string(out))
+		return fmt.Errorf("zfs rename failed, %s", string(out))
+	}
+	return nil
+}
+
+// Promote runs zfs promote for the cloned dataset
+func (c *cliBackend) Promote(name string) error {
+	var ZFSVolArg []string
+
+	ZFSVolArg = append(ZFSVolArg, ZFSPromoteArg, name)
+
+	out, err := runner.Output(ZFSVolCmd, ZFSVolArg...)
+	if err != nil {
+		klog.Errorf("zfs: could not promote %v cmd %v error: %s",
+			name, ZFSVolArg, string(out))
+		return fmt.Errorf("zfs promote failed, %s", string(out))
+	}
+	return nil
+}
+
+// ListSnapshots runs zfs list for the snapshots of the dataset
+func (c *cliBackend) ListSnapshots(name string) ([]string, error) {
+	var ZFSVolArg []string
+
+	ZFSVolArg = append(ZFSVolArg, ZFSListArg, "-H", "-o", "name",
+		"-t", "snapshot", "-s", "createtxg", "-d", "1", name)
+
+	out, err := runner.Output(ZFSVolCmd, ZFSVolArg...)
+	if err != nil {
+		klog.Errorf("zfs: could not list the snapshots of %v cmd %v error: %s",
+			name, ZFSVolArg, string(out))
+		return nil, fmt.Errorf("zfs list failed, %s", string(out))
+	}
+
+	var snaps []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if i := strings.Index(line, "@"); i >= 0 {
+			snaps = append(snaps, line[i+1:])
+		}
+	}
+	return snaps, nil
+}
+
+// CreateBookmark runs zfs bookmark for the snapshot
+func (c *cliBackend) CreateBookmark(snapshot string, bookmark string) error {
+	var ZFSVolArg []string
+
+	ZFSVolArg = append(ZFSVolArg, ZFSBookmarkArg, snapshot, bookmark)
+
+	out, err := runner.Output(ZFSVolCmd, ZFSVolArg...)
+	if err != nil {
+		klog.Errorf("zfs: could not create the bookmark %v cmd %v error: %s",
+			bookmark, ZFSVolArg, string(out))
+		return fmt.Errorf("zfs bookmark failed, %s", string(out))
+	}
+	return nil
+}
+
+// DestroyBookmark runs zfs destroy for the bookmark
+func (c *cliBackend) DestroyBookmark(name string) error {
+	var ZFSVolArg []string
+
+	ZFSVolArg = append(ZFSVolArg, ZFSDestroyArg, name)
+
+	out, err := runner.Output(ZFSVolCmd, ZFSVolArg...)
+	if err != nil {
+		klog.Errorf("zfs: could not destroy the bookmark %v cmd %v error: %s",
+			name, ZFSVolArg, string(out))
+		return fmt.Errorf("zfs destroy failed, %s", string(out))
+	}
+	return nil
+}
+
+// Hold runs zfs hold for the snapshot
+func (c *cliBackend) Hold(snapshot string, tag string) error {
+	var ZFSVolArg []string
+
+	ZFSVolArg = append(ZFSVolArg, ZFSHoldArg, tag, snapshot)
+
+	out, err := runner.Output(ZFSVolCmd, ZFSVolArg...)
+	if err != nil {
+		klog.Errorf("zfs: could not hold the snapshot %v cmd %v error: %s",
+			snapshot, ZFSVolArg, string(out))
+		return fmt.Errorf("zfs hold failed, %s", string(out))
+	}
+	return nil
+}
+
+// Release runs zfs release for the snapshot
+func (c *cliBackend) Release(snapshot string, tag string) error {
+	var ZFSVolArg []string
+
+	ZFSVolArg = append(ZFSVolArg, ZFSReleaseArg, tag, snapshot)
+
+	out, err := runner.Output(ZFSVolCmd, ZFSVolArg...)
+	if err != nil {
+		klog.Errorf("zfs: could not release the snapshot %v cmd %v error: %s",
+			snapshot, ZFSVolArg, string(out))
+		return fmt.Errorf("zfs release failed, %s", string(out))
+	}
+	return nil
+}
+
+// Holds runs zfs holds for the snapshot and returns the tags
+func (c *cliBackend) Holds(snapshot string) ([]string, error) {
+	var ZFSVolArg []string
+
+	ZFSVolArg = append(ZFSVolArg, ZFSHoldsArg, "-H", snapshot)
+
+	out, err := runner.Output(ZFSVolCmd, ZFSVolArg...)
+	if err != nil {
+		klog.Errorf("zfs: could not get the holds of the snapshot %v cmd %v error: %s",
+			snapshot, ZFSVolArg, string(out))
+		return nil, fmt.Errorf("zfs holds failed, %s", string(out))
+	}
+
+	// each line is <snapshot>\t<tag>\t<timestamp>
+	var tags []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) >= 2 {
+			tags = append(tags, fields[1])
+		}
+	}
+	return tags, nil
+}
+
+// SetVolumeProp runs zfs set for the volume properties
+func (c *cliBackend) SetVolumeProp(vol *apis.ZFSVolume) error {
+	volume := vol.Spec.PoolName + "/" + vol.Name
+
+	args := buildVolumeSetArgs(vol)
+	out, err := runner.Output(ZFSVolCmd, args...)
+
+	if err != nil {
+		klog.Errorf(
+			"zfs: could not set property on volume %v cmd %v error: %s", volume, args, string(out),
+		)
+	}
+	return err
+}
+
+// ResizeVolume runs zfs set for the quota or the volsize
+func (c *cliBackend) ResizeVolume(vol *apis.ZFSVolume) error {
+	volume := vol.Spec.PoolName + "/" + vol.Name
+
+	args := buildVolumeResizeArgs(vol)
+	out, err := runner.Output(ZFSVolCmd, args...)
+
+	if err != nil {
+		klog.Errorf(
+			"zfs: could not resize the volume %v cmd %v error: %s", volume, args, string(out),
+		)
+	}
+	return err
+}
+
+// DestroyVolume runs zfs destroy -r for the volume
+func (c *cliBackend) DestroyVolume(vol *apis.ZFSVolume) error {
+	volume := vol.Spec.PoolName + "/" + vol.Name
+
+	args := buildVolumeDestroyArgs(vol)
+	out, err := runner.Output(ZFSVolCmd, args...)
+
+	if err != nil {
+		klog.Errorf(
+			"zfs: could not destroy volume %v cmd %v error: %s", volume, args, string(out),
+		)
+	}
+	return err
+}
+
+// CreateSnapshot runs zfs snapshot
+func (c *cliBackend) CreateSnapshot(snap *apis.ZFSSnapshot) error {
+	volume := snap.Labels[ZFSVolKey]
+
+	args := buildZFSSnapCreateArgs(snap)
+	out, err := runner.Output(ZFSVolCmd, args...)
+
+	if err != nil {
+		klog.Errorf(
+			"zfs: could not create snapshot %v@%v cmd %v error: %s", volume, snap.Name, args, string(out),
+		)
+	}
+	return err
+}
+
+// CreateSnapshots runs a single zfs snapshot for all the snapshots
+func (c *cliBackend) CreateSnapshots(snaps []*apis.ZFSSnapshot) error {
+	args := buildZFSSnapGroupCreateArgs(snaps)
+	out, err := runner.Output(ZFSVolCmd, args...)
+
+	if err != nil {
+		klog.Errorf(
+			"zfs: could not create snapshots cmd %v error: %s", args, string(out),
+		)
+	}
+	return err
+}
+
+// DestroySnapshot runs zfs destroy for the snapshot
+func (c *cliBackend) DestroySnapshot(snap *apis.ZFSSnapshot) error {
+	volume := snap.Labels[ZFSVolKey]
+
+	args := buildZFSSnapDestroyArgs(snap)
+	out, err := runner.Output(ZFSVolCmd, args...)
+
+	if err != nil {
+		klog.Errorf(
+			"zfs: could not destroy snapshot %v@%v cmd %v error: %s", volume, snap.Name, args, string(out),
+		)
+	}
+	return err
+}
+
+// GetProperty runs zfs get for the property
+func (c *cliBackend) GetProperty(name string, prop string) (string, error) {
+	var ZFSVolArg []string
+
+	ZFSVolArg = append(ZFSVolArg, ZFSGetArg, "-pH", "-o", "value", prop, name)
+
+	out, err := runner.Output(ZFSVolCmd, ZFSVolArg...)
+	if err != nil {
+		klog.Errorf("zfs: could not get %s on dataset %v cmd %v error: %s",
+			prop, name, ZFSVolArg, string(out))
+		return "", fmt.Errorf("zfs get %s failed, %s", prop, string(out))
+	}
+	val := out[:len(out)-1]
+	return string(val), nil
+}
+
+// SetProperty runs zfs set for the property
+func (c *cliBackend) SetProperty(name string, prop string, value string) error {
+	var ZFSVolArg []string
+
+	ZFSVolArg = append(ZFSVolArg, ZFSSetArg, prop+"="+value, name)
+
+	out, err := runner.Output(ZFSVolCmd, ZFSVolArg...)
+	if err != nil {
+		klog.Errorf("zfs: could not set %s on dataset %v cmd %v error: %s",
+			prop, name, ZFSVolArg, string(out))
+		return fmt.Errorf("zfs set %s failed, %s", prop, string(out))
+	}
+	return nil
+}
+
+// Mount runs zfs mount for the dataset
+func (c *cliBackend) Mount(name string) error {
+	var MountVolArg []string
+
+	MountVolArg = append(MountVolArg, "mount", name)
+
+	out, err := runner.Output(ZFSVolCmd, MountVolArg...)
+	if err != nil {
+		klog.Errorf("zfs: could not mount the dataset %v cmd %v error: %s",
+			name, MountVolArg, string(out))
+		return fmt.Errorf("not able to mount, %s", string(out))
+	}
+	return nil
+}
+
+// EstimateSend runs zfs send -nvP and returns the size of the stream
+func (c *cliBackend) EstimateSend(bkp *apis.ZFSBackup, vol *apis.ZFSVolume) (int64, error) {
+	sendArgs := buildVolumeBackupArgs(bkp, vol)
+	args := append([]string{sendArgs[0], "-nvP"}, sendArgs[1:]...)
+
+	out, err := runner.Output(ZFSVolCmd, args...)
+	if err != nil {
+		klog.Errorf("zfs: could not estimate the send size cmd %v error: %s", args, string(out))
+		return 0, fmt.Errorf("zfs send -nvP failed, %s", string(out))
+	}
+	return parseSendSize(out)
+}
+
+// Send runs zfs send with its output going to w
+func (c *cliBackend) Send(ctx context.Context, bkp *apis.ZFSBackup, vol *apis.ZFSVolume, w io.Writer) error {
+	volume := vol.Spec.PoolName + "/" + vol.Name
+
+	var stderr bytes.Buffer
+	args := buildVolumeBackupArgs(bkp, vol)
+	if err := runner.Transfer(ctx, nil, w, &stderr, ZFSVolCmd, args...); err != nil {
+		klog.Errorf(
+			"zfs: could not backup the volume %v cmd %v error: %s", volume, args, stderr.String(),
+		)
+		return fmt.Errorf("zfs send failed, %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// Recv runs zfs recv with its input read from r
+func (c *cliBackend) Recv(ctx context.Context, rstr *apis.ZFSRestore, r io.Reader) error {
+	volume := recvDataset(rstr)
+
+	var stderr bytes.Buffer
+	args := buildVolumeRestoreArgs(rstr)
+	if err := runner.Transfer(ctx, r, nil, &stderr, ZFSVolCmd, args...); err != nil {
+		klog.Errorf(
+			"zfs: could not restore the volume %v cmd %v error: %s", volume, args, stderr.String(),
+		)
+		return fmt.Errorf("zfs recv failed, %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// transferCommand returns the zfs send or recv command run in its own
+// process group, the whole group is killed once ctx is done. The stream
+// copied to or from the command is given transferKillWait to stop after it.
+func transferCommand(ctx context.Context, name string, args []string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = transferKillWait
+	return cmd
+}
+
+// AbortRecv runs zfs recv -A for the dataset
+func (c *cliBackend) AbortRecv(name string) error {
+	var ZFSVolArg []string
+
+	ZFSVolArg = append(ZFSVolArg, ZFSRecvArg, "-A", name)
+
+	out, err := runner.Output(ZFSVolCmd, ZFSVolArg...)
+	if err != nil {
+		klog.Errorf("zfs: could not abort the receive on dataset %v cmd %v error: %s",
+			name, ZFSVolArg, string(out))
+		return fmt.Errorf("zfs recv -A failed, %s", string(out))
+	}
+	return nil
+}
+
+// ListPools runs zfs list for the top level datasets
+// and zpool list for the health of the pools
+func (c *cliBackend) ListPools() ([]apis.Pool, error) {
+	args := []string{
+		ZFSListArg, "-d", "1", "-s", "name",
+		"-o", "name,guid,available,used",
+		"-H", "-p",
+	}
+	output, err := runner.Output(ZFSVolCmd, args...)
+	if err != nil {
+		klog.Errorf("zfs: could not list zpool cmd %v: %v", args, err)
+		return nil, err
+	}
+	pools, err := decodeListOutput(output)
+	if err != nil {
+		return pools, err
+	}
+
+	// the health of the pools is left unknown if zpool is not available
+	args = []string{ZFSListArg, "-H", "-o", "name,health"}
+	output, err = runner.Output(ZPoolCmd, args...)
+	if err != nil {
+		klog.V(2).Infof("zfs: could not get the pool health cmd %v: %v", args, err)
+		return pools, nil
+	}
+	health := decodeHealthOutput(output)
+	for i := range pools {
+		pools[i].Health = health[pools[i].Name]
+	}
+	return pools, nil
+}
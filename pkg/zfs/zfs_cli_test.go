@@ -0,0 +1,209 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"testing"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+	clientset "github.com/openebs/zfs-localpv/pkg/generated/clientset/internalclientset"
+	"github.com/openebs/zfs-localpv/pkg/zfs/zfstest"
+)
+
+const testGi = 1024 * 1024 * 1024
+
+// useFakeZFS runs the zfs commands of the test against the in-memory
+// pool "pool" of 10Gi
+func useFakeZFS(t *testing.T) *zfstest.ZFS {
+	fake := zfstest.NewZFS()
+	fake.AddPool("pool", 10*testGi)
+	old := SetCommandRunner(fake)
+	resetPoolHealthCache()
+	t.Cleanup(func() {
+		SetCommandRunner(old)
+		resetPoolHealthCache()
+	})
+	return fake
+}
+
+// useAPIServer runs the kubernetes API calls of the test against the
+// in-memory API server, with the objects in the namespace "openebs"
+func useAPIServer(t *testing.T) clientset.Interface {
+	old := OpenEBSNamespace
+	OpenEBSNamespace = "openebs"
+	t.Cleanup(func() { OpenEBSNamespace = old })
+	return zfstest.NewAPIServer(t).Clientset()
+}
+
+func testVolume(name, volType, capacity, thin string) *apis.ZFSVolume {
+	vol := &apis.ZFSVolume{}
+	vol.Name = name
+	vol.Spec.PoolName = "pool"
+	vol.Spec.VolumeType = volType
+	vol.Spec.Capacity = capacity
+	vol.Spec.ThinProvision = thin
+	vol.Spec.FsType = "ext4"
+	if volType == VolTypeDataset {
+		vol.Spec.FsType = "zfs"
+		vol.Spec.QuotaType = "refquota"
+	}
+	return vol
+}
+
+func TestCLIBackendVolumeLifecycle(t *testing.T) {
+	fake := useFakeZFS(t)
+
+	vol := testVolume("pvc-1", VolTypeDataset, "1073741824", "yes")
+	vol.Spec.Compression = "lz4"
+	if err := CreateVolume(vol); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	// creating it again should use the existing volume
+	if err := CreateVolume(vol); err != nil {
+		t.Fatalf("CreateVolume() on existing volume error = %v", err)
+	}
+	if val, _ := GetVolumeProperty(vol, "compression"); val != "lz4" {
+		t.Errorf("compression = %q, want lz4", val)
+	}
+	if val, _ := GetVolumeProperty(vol, "mountpoint"); val != "legacy" {
+		t.Errorf("mountpoint = %q, want legacy", val)
+	}
+
+	if err := fake.Write("pool/pvc-1", 2*testGi); err == nil {
+		t.Errorf("Write() beyond the refquota should fail")
+	}
+
+	vol.Spec.Capacity = "3221225472"
+	if err := ResizeZFSVolume(vol, "", false); err != nil {
+		t.Fatalf("ResizeZFSVolume() error = %v", err)
+	}
+	if err := fake.Write("pool/pvc-1", 2*testGi); err != nil {
+		t.Errorf("Write() after resize error = %v", err)
+	}
+	pools, _ := ListZFSPool()
+	if free := pools[0].Free.Value(); free != 8*testGi {
+		t.Errorf("pool free = %d, want %d", free, 8*testGi)
+	}
+
+	vol.Spec.Compression = "zstd"
+	if err := SetVolumeProp(vol); err != nil {
+		t.Fatalf("SetVolumeProp() error = %v", err)
+	}
+	if val, _ := GetVolumeProperty(vol, "compression"); val != "zstd" {
+		t.Errorf("compression = %q, want zstd", val)
+	}
+
+	if err := DestroyVolume(vol); err != nil {
+		t.Fatalf("DestroyVolume() error = %v", err)
+	}
+	if _, ok := fake.Dataset("pool/pvc-1"); ok {
+		t.Errorf("volume is present after DestroyVolume()")
+	}
+	// destroying the deleted volume is not an error
+	if err := DestroyVolume(vol); err != nil {
+		t.Errorf("DestroyVolume() on deleted volume error = %v", err)
+	}
+}
+
+func TestCLIBackendSnapshotAndClone(t *testing.T) {
+	fake := useFakeZFS(t)
+
+	src := testVolume("pvc-src", VolTypeZVol, "1073741824", "yes")
+	if err := CreateVolume(src); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+	if err := fake.Write("pool/pvc-src", 1024); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	snap := &apis.ZFSSnapshot{}
+	snap.Name = "snap-1"
+	snap.Spec = src.Spec
+	snap.Labels = map[string]string{ZFSVolKey: src.Name}
+	if err := CreateSnapshot(snap); err != nil {
+		t.Fatalf("CreateSnapshot() error = %v", err)
+	}
+
+	clone := testVolume("pvc-clone", VolTypeZVol, "1073741824", "yes")
+	clone.Spec.SnapName = "pvc-src@snap-1"
+	if err := CreateClone(clone); err != nil {
+		t.Fatalf("CreateClone() error = %v", err)
+	}
+	ds, _ := fake.Dataset("pool/pvc-clone")
+	if ds.Origin != "pool/pvc-src@snap-1" || ds.Written != 1024 || ds.Type != zfstest.DatasetTypeVolume {
+		t.Errorf("clone = %+v, want zvol cloned from pool/pvc-src@snap-1", ds)
+	}
+
+	// volume clone takes the snapshot with the clone name
+	volClone := testVolume("pvc-volclone", VolTypeZVol, "1073741824", "yes")
+	volClone.Spec.SnapName = "pvc-src@pvc-volclone"
+	volClone.Labels = map[string]string{ZFSSrcVolKey: src.Name}
+	if err := CreateClone(volClone); err != nil {
+		t.Fatalf("CreateClone() from volume error = %v", err)
+	}
+	if _, ok := fake.Dataset("pool/pvc-src@pvc-volclone"); !ok {
+		t.Errorf("snapshot for the volume clone is not present")
+	}
+
+	if err := DestroySnapshot(snap); err == nil {
+		t.Errorf("DestroySnapshot() with dependent clone should fail")
+	}
+	if err := DestroyVolume(src); err == nil {
+		t.Errorf("DestroyVolume() with dependent clones should fail")
+	}
+
+	if err := DestroyVolume(clone); err != nil {
+		t.Fatalf("DestroyVolume() clone error = %v", err)
+	}
+	if err := DestroyVolume(volClone); err != nil {
+		t.Fatalf("DestroyVolume() volume clone error = %v", err)
+	}
+	if _, ok := fake.Dataset("pool/pvc-src@pvc-volclone"); ok {
+		t.Errorf("snapshot for the volume clone is present after deleting the clone")
+	}
+	if err := DestroySnapshot(snap); err != nil {
+		t.Fatalf("DestroySnapshot() error = %v", err)
+	}
+	if err := DestroyVolume(src); err != nil {
+		t.Fatalf("DestroyVolume() error = %v", err)
+	}
+}
+
+func TestCLIBackendReservation(t *testing.T) {
+	useFakeZFS(t)
+
+	thick := testVolume("pvc-thick", VolTypeZVol, "8589934592", "no")
+	if err := CreateVolume(thick); err != nil {
+		t.Fatalf("CreateVolume() error = %v", err)
+	}
+
+	tooBig := testVolume("pvc-big", VolTypeZVol, "4294967296", "no")
+	if err := CreateVolume(tooBig); err == nil {
+		t.Errorf("CreateVolume() beyond the pool free space should fail")
+	}
+
+	// thin volumes do not reserve any space
+	thin := testVolume("pvc-thin", VolTypeZVol, "4294967296", "yes")
+	if err := CreateVolume(thin); err != nil {
+		t.Errorf("CreateVolume() thin volume error = %v", err)
+	}
+
+	pools, _ := ListZFSPool()
+	if free := pools[0].Free.Value(); free != 2*testGi {
+		t.Errorf("pool free = %d, want %d", free, 2*testGi)
+	}
+}
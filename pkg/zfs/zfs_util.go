@@ -18,7 +18,6 @@ package zfs
 
 import (
 	"bufio"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 
@@ -54,6 +53,14 @@ const (
 	ZFSSnapshotArg = "snapshot"
 	ZFSSendArg     = "send"
 	ZFSRecvArg     = "recv"
+	ZFSBookmarkArg = "bookmark"
+	ZFSHoldArg     = "hold"
+	ZFSReleaseArg  = "release"
+	ZFSHoldsArg    = "holds"
+	ZFSRenameArg   = "rename"
+	ZFSPromoteArg  = "promote"
+
+	ZPoolCmd = "zpool"
 )
 
 // constants to define volume type
@@ -193,6 +200,20 @@ func buildZFSSnapCreateArgs(snap *apis.ZFSSnapshot) []string {
 	return ZFSSnapArg
 }
 
+// buildZFSSnapGroupCreateArgs returns zfs snapshot command taking all the
+// snapshots at the same instant
+// zfs snapshot <poolname>/<volname>@<snapname> <poolname>/<volname>@<snapname> ...
+func buildZFSSnapGroupCreateArgs(snaps []*apis.ZFSSnapshot) []string {
+	ZFSSnapArg := []string{ZFSSnapshotArg}
+
+	for _, snap := range snaps {
+		volname := snap.Labels[ZFSVolKey]
+		ZFSSnapArg = append(ZFSSnapArg, snap.Spec.PoolName+"/"+volname+"@"+snap.Name)
+	}
+
+	return ZFSSnapArg
+}
+
 // builldZFSSnapDestroyArgs returns zfs destroy command for zfs snapshot
 // zfs destroy <poolname>/<volname>@<snapname>
 func buildZFSSnapDestroyArgs(snap *apis.ZFSSnapshot) []string {
@@ -307,83 +328,134 @@ func buildVolumeResizeArgs(vol *apis.ZFSVolume) []string {
 }
 
 // builldVolumeBackupArgs returns volume send command for sending the zfs volume
-func buildVolumeBackupArgs(bkp *apis.ZFSBackup, vol *apis.ZFSVolume) ([]string, error) {
+func buildVolumeBackupArgs(bkp *apis.ZFSBackup, vol *apis.ZFSVolume) []string {
 	var ZFSVolArg []string
-	backupDest := bkp.Spec.BackupDest
-
-	bkpAddr := strings.Split(backupDest, ":")
-	if len(bkpAddr) != 2 {
-		return ZFSVolArg, fmt.Errorf("zfs: invalid backup server address %s", backupDest)
-	}
 
 	curSnap := vol.Spec.PoolName + "/" + vol.Name + "@" + bkp.Spec.SnapName
 
-	remote := " | nc -w 3 " + bkpAddr[0] + " " + bkpAddr[1]
+	ZFSVolArg = append(ZFSVolArg, ZFSSendArg)
 
-	cmd := ZFSVolCmd + " "
+	if len(bkp.Transfer.ResumeToken) > 0 {
+		// resume the stream partially received by the local restore
+		ZFSVolArg = append(ZFSVolArg, "-t", bkp.Transfer.ResumeToken)
+		return ZFSVolArg
+	}
 
-	if len(bkp.Spec.PrevSnapName) > 0 {
-		prevSnap := vol.Spec.PoolName + "/" + vol.Name + "@" + bkp.Spec.PrevSnapName
-		// do incremental send
-		cmd += ZFSSendArg + " -i " + prevSnap + " " + curSnap + " " + remote
-	} else {
-		cmd += ZFSSendArg + " " + curSnap + remote
+	opts := bkp.Spec.SendOptions
+	if opts != nil {
+		if opts.Raw {
+			ZFSVolArg = append(ZFSVolArg, "-w")
+		}
+		if opts.Compressed {
+			ZFSVolArg = append(ZFSVolArg, "-c")
+		}
+		if opts.LargeBlock {
+			ZFSVolArg = append(ZFSVolArg, "-L")
+		}
+		if opts.Embedded {
+			ZFSVolArg = append(ZFSVolArg, "-e")
+		}
+		if opts.Replicate {
+			ZFSVolArg = append(ZFSVolArg, "-R")
+		} else if opts.Props {
+			ZFSVolArg = append(ZFSVolArg, "-p")
+		}
 	}
 
-	ZFSVolArg = append(ZFSVolArg, "-c", cmd)
+	if from := incrementalFrom(bkp); len(from) > 0 {
+		// send from the bookmark if the snapshot has been destroyed
+		base := bkp.Transfer.IncrementalBase
+		if len(base) == 0 {
+			base = "@" + from
+		}
+		prevSnap := vol.Spec.PoolName + "/" + vol.Name + base
+		if opts != nil && opts.Replicate {
+			// replicate the intermediate snapshots as well
+			ZFSVolArg = append(ZFSVolArg, "-I", prevSnap)
+		} else {
+			// do incremental send
+			ZFSVolArg = append(ZFSVolArg, "-i", prevSnap)
+		}
+	}
+
+	ZFSVolArg = append(ZFSVolArg, curSnap)
 
-	return ZFSVolArg, nil
+	return ZFSVolArg
+}
+
+// parseSendSize returns the stream size from the zfs send -nvP output, which
+// ends with the total size line "size	<bytes>"
+func parseSendSize(out []byte) (int64, error) {
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "size" {
+			return strconv.ParseInt(fields[1], 10, 64)
+		}
+	}
+	return 0, fmt.Errorf("zfs: size not found in the send estimate %q", string(out))
 }
 
 // builldVolumeRestoreArgs returns volume recv command for receiving the zfs volume
-func buildVolumeRestoreArgs(rstr *apis.ZFSRestore) ([]string, error) {
+func buildVolumeRestoreArgs(rstr *apis.ZFSRestore) []string {
 	var ZFSVolArg []string
-	var ZFSRecvParam string
-	restoreSrc := rstr.Spec.RestoreSrc
 
-	volume := rstr.VolSpec.PoolName + "/" + rstr.Spec.VolumeName
+	volume := recvDataset(rstr)
 
-	rstrAddr := strings.Split(restoreSrc, ":")
-	if len(rstrAddr) != 2 {
-		return ZFSVolArg, fmt.Errorf("zfs: invalid restore server address %s", restoreSrc)
-	}
+	// save the partially received state, so that an interrupted
+	// stream can be resumed using the receive_resume_token
+	ZFSVolArg = append(ZFSVolArg, ZFSRecvArg, "-s")
 
-	source := "nc -w 3 " + rstrAddr[0] + " " + rstrAddr[1] + " | "
+	// the staging dataset of the incremental stream is received as the
+	// clone of the volume, the resumed stream is received where it was
+	if len(rstr.Transfer.StagingOrigin) > 0 && len(rstr.Transfer.ResumeToken) == 0 {
+		ZFSVolArg = append(ZFSVolArg, "-o", "origin="+rstr.Transfer.StagingOrigin)
+	}
 
 	if rstr.VolSpec.VolumeType == VolTypeDataset {
 		if len(rstr.VolSpec.Capacity) != 0 {
-			ZFSRecvParam += " -o " + rstr.VolSpec.QuotaType + "=" + rstr.VolSpec.Capacity
+			quotaProperty := rstr.VolSpec.QuotaType + "=" + rstr.VolSpec.Capacity
+			ZFSVolArg = append(ZFSVolArg, "-o", quotaProperty)
 		}
 		if len(rstr.VolSpec.RecordSize) != 0 {
-			ZFSRecvParam += " -o recordsize=" + rstr.VolSpec.RecordSize
+			recordsizeProperty := "recordsize=" + rstr.VolSpec.RecordSize
+			ZFSVolArg = append(ZFSVolArg, "-o", recordsizeProperty)
 		}
 		if rstr.VolSpec.ThinProvision == "no" {
-			ZFSRecvParam += " -o reservation=" + rstr.VolSpec.Capacity
+			ZFSVolArg = append(ZFSVolArg, "-o", "reservation="+rstr.VolSpec.Capacity)
 		}
-		ZFSRecvParam += " -o mountpoint=legacy"
+		ZFSVolArg = append(ZFSVolArg, "-o", "mountpoint=legacy")
 	}
 
 	if len(rstr.VolSpec.Dedup) != 0 {
-		ZFSRecvParam += " -o dedup=" + rstr.VolSpec.Dedup
+		dedupProperty := "dedup=" + rstr.VolSpec.Dedup
+		ZFSVolArg = append(ZFSVolArg, "-o", dedupProperty)
 	}
 	if len(rstr.VolSpec.Compression) != 0 {
-		ZFSRecvParam += " -o compression=" + rstr.VolSpec.Compression
-	}
-	if len(rstr.VolSpec.Encryption) != 0 {
-		ZFSRecvParam += " -o encryption=" + rstr.VolSpec.Encryption
-	}
-	if len(rstr.VolSpec.KeyLocation) != 0 {
-		ZFSRecvParam += " -o keylocation=" + rstr.VolSpec.KeyLocation
-	}
-	if len(rstr.VolSpec.KeyFormat) != 0 {
-		ZFSRecvParam += " -o keyformat=" + rstr.VolSpec.KeyFormat
+		compressionProperty := "compression=" + rstr.VolSpec.Compression
+		ZFSVolArg = append(ZFSVolArg, "-o", compressionProperty)
 	}
+	ZFSVolArg = append(ZFSVolArg, buildRestoreOverrideArgs(rstr)...)
 
-	cmd := source + ZFSVolCmd + " " + ZFSRecvArg + ZFSRecvParam + " -F " + volume
+	// the raw stream carries the encryption properties of the volume,
+	// which can not be changed while receiving it
+	if opts := rstr.Spec.SendOptions; opts == nil || !opts.Raw {
+		if len(rstr.VolSpec.Encryption) != 0 {
+			encryptionProperty := "encryption=" + rstr.VolSpec.Encryption
+			ZFSVolArg = append(ZFSVolArg, "-o", encryptionProperty)
+		}
+		if len(rstr.VolSpec.KeyLocation) != 0 {
+			keyLocation := "keylocation=" + rstr.VolSpec.KeyLocation
+			ZFSVolArg = append(ZFSVolArg, "-o", keyLocation)
+		}
+		if len(rstr.VolSpec.KeyFormat) != 0 {
+			keyFormat := "keyformat=" + rstr.VolSpec.KeyFormat
+			ZFSVolArg = append(ZFSVolArg, "-o", keyFormat)
+		}
+	}
 
-	ZFSVolArg = append(ZFSVolArg, "-c", cmd)
+	ZFSVolArg = append(ZFSVolArg, "-F", volume)
 
-	return ZFSVolArg, nil
+	return ZFSVolArg
 }
 
 // builldVolumeDestroyArgs returns volume destroy command along with attributes as a string array
@@ -398,13 +470,7 @@ func buildVolumeDestroyArgs(vol *apis.ZFSVolume) []string {
 }
 
 func getVolume(volume string) error {
-	var ZFSVolArg []string
-
-	ZFSVolArg = append(ZFSVolArg, ZFSListArg, volume)
-
-	cmd := exec.Command(ZFSVolCmd, ZFSVolArg...)
-	_, err := cmd.CombinedOutput()
-	return err
+	return backend.GetDataset(volume)
 }
 
 // CreateVolume creates the zvol/dataset as per
@@ -413,19 +479,7 @@ func CreateVolume(vol *apis.ZFSVolume) error {
 	volume := vol.Spec.PoolName + "/" + vol.Name
 
 	if err := getVolume(volume); err != nil {
-		var args []string
-		if vol.Spec.VolumeType == VolTypeDataset {
-			args = buildDatasetCreateArgs(vol)
-		} else {
-			args = buildZvolCreateArgs(vol)
-		}
-		cmd := exec.Command(ZFSVolCmd, args...)
-		out, err := cmd.CombinedOutput()
-
-		if err != nil {
-			klog.Errorf(
-				"zfs: could not create volume %v cmd %v error: %s", volume, args, string(out),
-			)
+		if err := backend.CreateVolume(vol); err != nil {
 			return err
 		}
 		klog.Infof("created volume %s", volume)
@@ -462,14 +516,7 @@ func CreateClone(vol *apis.ZFSVolume) error {
 	}
 
 	if err := getVolume(volume); err != nil {
-		args := buildCloneCreateArgs(vol)
-		cmd := exec.Command(ZFSVolCmd, args...)
-		out, err := cmd.CombinedOutput()
-
-		if err != nil {
-			klog.Errorf(
-				"zfs: could not clone volume %v cmd %v error: %s", volume, args, string(out),
-			)
+		if err := backend.CreateClone(vol); err != nil {
 			return err
 		}
 		klog.Infof("created clone %s", volume)
@@ -477,6 +524,11 @@ func CreateClone(vol *apis.ZFSVolume) error {
 		klog.Infof("using existing clone volume %v", volume)
 	}
 
+	// hold the origin snapshot as long as the clone depends on it
+	if err := holdSnapshot(cloneOrigin(vol), CloneHoldPrefix+vol.Name); err != nil {
+		return err
+	}
+
 	if vol.Spec.FsType == "xfs" {
 		device := ZFSDevPath + volume
 		return xfs.GenerateUUID(device)
@@ -490,17 +542,9 @@ func CreateClone(vol *apis.ZFSVolume) error {
 
 // SetDatasetMountProp sets mountpoint for the volume
 func SetDatasetMountProp(volume string, mountpath string) error {
-	var ZFSVolArg []string
-
-	mountProperty := "mountpoint=" + mountpath
-	ZFSVolArg = append(ZFSVolArg, ZFSSetArg, mountProperty, volume)
-
-	cmd := exec.Command(ZFSVolCmd, ZFSVolArg...)
-	out, err := cmd.CombinedOutput()
+	err := backend.SetProperty(volume, "mountpoint", mountpath)
 	if err != nil {
-		klog.Errorf("zfs: could not set mountpoint on dataset %v cmd %v error: %s",
-			volume, ZFSVolArg, string(out))
-		return fmt.Errorf("could not set the mountpoint, %s", string(out))
+		return fmt.Errorf("could not set the mountpoint, %v", err)
 	}
 	return nil
 }
@@ -527,15 +571,7 @@ func MountZFSDataset(vol *apis.ZFSVolume, mountpath string) error {
 	}
 
 	if mounted == "no" {
-		var MountVolArg []string
-		MountVolArg = append(MountVolArg, "mount", volume)
-		cmd := exec.Command(ZFSVolCmd, MountVolArg...)
-		out, err := cmd.CombinedOutput()
-		if err != nil {
-			klog.Errorf("zfs: could not mount the dataset %v cmd %v error: %s",
-				volume, MountVolArg, string(out))
-			return fmt.Errorf("not able to mount, %s", string(out))
-		}
+		return backend.Mount(volume)
 	}
 
 	return nil
@@ -563,20 +599,9 @@ func SetDatasetLegacyMount(vol *apis.ZFSVolume) error {
 
 // GetVolumeProperty gets zfs properties for the volume
 func GetVolumeProperty(vol *apis.ZFSVolume, prop string) (string, error) {
-	var ZFSVolArg []string
 	volume := vol.Spec.PoolName + "/" + vol.Name
 
-	ZFSVolArg = append(ZFSVolArg, ZFSGetArg, "-pH", "-o", "value", prop, volume)
-
-	cmd := exec.Command(ZFSVolCmd, ZFSVolArg...)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		klog.Errorf("zfs: could not get %s on dataset %v cmd %v error: %s",
-			prop, volume, ZFSVolArg, string(out))
-		return "", fmt.Errorf("zfs get %s failed, %s", prop, string(out))
-	}
-	val := out[:len(out)-1]
-	return string(val), nil
+	return backend.GetProperty(volume, prop)
 }
 
 // SetVolumeProp sets the volume property
@@ -605,14 +630,8 @@ func SetVolumeProp(vol *apis.ZFSVolume) error {
 	 * it is guaranteed that at least one property has changed.
 	 */
 
-	args := buildVolumeSetArgs(vol)
-	cmd := exec.Command(ZFSVolCmd, args...)
-	out, err := cmd.CombinedOutput()
-
+	err = backend.SetVolumeProp(vol)
 	if err != nil {
-		klog.Errorf(
-			"zfs: could not set property on volume %v cmd %v error: %s", volume, args, string(out),
-		)
 		return err
 	}
 	klog.Infof("property set on volume %s", volume)
@@ -640,17 +659,24 @@ func DestroyVolume(vol *apis.ZFSVolume) error {
 		return nil
 	}
 
-	args := buildVolumeDestroyArgs(vol)
-	cmd := exec.Command(ZFSVolCmd, args...)
-	out, err := cmd.CombinedOutput()
-
+	origin, err := backend.GetProperty(volume, "origin")
 	if err != nil {
-		klog.Errorf(
-			"zfs: could not destroy volume %v cmd %v error: %s", volume, args, string(out),
-		)
 		return err
 	}
 
+	if err := backend.DestroyVolume(vol); err != nil {
+		return err
+	}
+
+	if origin != "" && origin != "-" {
+		// the clone is gone, release the hold on its origin snapshot
+		if err := releaseSnapshot(origin, CloneHoldPrefix+vol.Name); err != nil {
+			klog.Errorf(
+				"zfs: could not release the origin snapshot %s of the clone vol %s err %v", origin, volume, err,
+			)
+		}
+	}
+
 	if srcVol, ok := vol.Labels[ZFSSrcVolKey]; ok {
 		// datasource is volume, delete the dependent snapshot
 		snap := &apis.ZFSSnapshot{}
@@ -688,14 +714,7 @@ func CreateSnapshot(snap *apis.ZFSSnapshot) error {
 		return nil
 	}
 
-	args := buildZFSSnapCreateArgs(snap)
-	cmd := exec.Command(ZFSVolCmd, args...)
-	out, err := cmd.CombinedOutput()
-
-	if err != nil {
-		klog.Errorf(
-			"zfs: could not create snapshot %v@%v cmd %v error: %s", volume, snap.Name, args, string(out),
-		)
+	if err := backend.CreateSnapshot(snap); err != nil {
 		return err
 	}
 	klog.Infof("created snapshot %s@%s", volume, snap.Name)
@@ -726,14 +745,11 @@ func DestroySnapshot(snap *apis.ZFSSnapshot) error {
 		return nil
 	}
 
-	args := buildZFSSnapDestroyArgs(snap)
-	cmd := exec.Command(ZFSVolCmd, args...)
-	out, err := cmd.CombinedOutput()
+	if err := checkSnapshotHolds(snapDataset); err != nil {
+		return err
+	}
 
-	if err != nil {
-		klog.Errorf(
-			"zfs: could not destroy snapshot %v@%v cmd %v error: %s", volume, snap.Name, args, string(out),
-		)
+	if err := backend.DestroySnapshot(snap); err != nil {
 		return err
 	}
 	klog.Infof("deleted snapshot %s@%s", volume, snap.Name)
@@ -761,15 +777,8 @@ func GetVolumeDevPath(vol *apis.ZFSVolume) (string, error) {
 // ResizeZFSVolume resize volume
 func ResizeZFSVolume(vol *apis.ZFSVolume, mountpath string, resizefs bool) error {
 
-	volume := vol.Spec.PoolName + "/" + vol.Name
-	args := buildVolumeResizeArgs(vol)
-	cmd := exec.Command(ZFSVolCmd, args...)
-	out, err := cmd.CombinedOutput()
-
+	err := backend.ResizeVolume(vol)
 	if err != nil {
-		klog.Errorf(
-			"zfs: could not resize the volume %v cmd %v error: %s", volume, args, string(out),
-		)
 		return err
 	}
 
@@ -790,6 +799,11 @@ func CreateBackup(bkp *apis.ZFSBackup) error {
 
 	volume := vol.Spec.PoolName + "/" + vol.Name
 
+	if backupBookmarked(bkp, vol) {
+		klog.Infof("backup %s has already been done, %s@%s is bookmarked", bkp.Name, volume, bkp.Spec.SnapName)
+		return nil
+	}
+
 	/* create the snapshot for the backup */
 	snap := &apis.ZFSSnapshot{}
 	snap.Name = bkp.Spec.SnapName
@@ -805,20 +819,21 @@ func CreateBackup(bkp *apis.ZFSBackup) error {
 		return err
 	}
 
-	args, err := buildVolumeBackupArgs(bkp, vol)
-	if err != nil {
+	// the backup is never resumed, none of its targets keeps a resumable
+	// state, so the stream is sent from the beginning on every attempt
+	bkp.Transfer.ResumeToken = ""
+	setIncrementalBase(bkp, vol)
+	if err := holdBackup(bkp, vol); err != nil {
 		return err
 	}
-	cmd := exec.Command("bash", args...)
-	out, err := cmd.CombinedOutput()
-
-	if err != nil {
-		klog.Errorf(
-			"zfs: could not backup the volume %v cmd %v error: %s", volume, args, string(out),
-		)
+	if err := sendBackup(bkp, vol); err != nil {
+		return err
 	}
-
-	return err
+	if err := releaseBackup(bkp, vol); err != nil {
+		klog.Warningf("zfs: could not release the snapshots of the backup %s, err: %v", bkp.Name, err)
+	}
+	bookmarkBackup(bkp, vol)
+	return nil
 }
 
 // DestoryBackup deletes the snapshot created
@@ -840,12 +855,24 @@ func DestoryBackup(bkp *apis.ZFSBackup) error {
 	snap.Spec.PoolName = vol.Spec.PoolName
 	snap.Labels = map[string]string{ZFSVolKey: vol.Name}
 
+	if err = releaseBackup(bkp, vol); err != nil {
+		return err
+	}
+
 	err = DestroySnapshot(snap)
 
 	if err != nil {
 		klog.Errorf(
 			"zfs: could not destroy snapshot for the backup vol %s snap %s err %v", volume, snap.Name, err,
 		)
+		return err
+	}
+
+	err = destroyBackupBookmark(bkp, vol)
+	if err != nil {
+		klog.Errorf(
+			"zfs: could not destroy bookmark for the backup vol %s snap %s err %v", volume, snap.Name, err,
+		)
 	}
 	return err
 }
@@ -880,20 +907,11 @@ func CreateRestore(rstr *apis.ZFSRestore) error {
 		}
 		rstr.VolSpec = vol.Spec
 	}
-	args, err := buildVolumeRestoreArgs(rstr)
-	if err != nil {
-		return err
-	}
+	applyRestoreOverrides(rstr)
 
 	volume := rstr.VolSpec.PoolName + "/" + rstr.Spec.VolumeName
 
-	cmd := exec.Command("bash", args...)
-	out, err := cmd.CombinedOutput()
-
-	if err != nil {
-		klog.Errorf(
-			"zfs: could not restore the volume %v cmd %v error: %s", volume, args, string(out),
-		)
+	if err := recvRestore(rstr); err != nil {
 		return err
 	}
 
@@ -906,34 +924,27 @@ func CreateRestore(rstr *apis.ZFSRestore) error {
 		if err != nil {
 			return err
 		}
-		return xfs.GenerateUUID(device)
+		if err := xfs.GenerateUUID(device); err != nil {
+			return err
+		}
 	}
 	if rstr.VolSpec.FsType == "btrfs" {
 		device, err := getDevice(volume)
 		if err != nil {
 			return err
 		}
-		return btrfs.GenerateUUID(device)
+		if err := btrfs.GenerateUUID(device); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	// the volume is used from where it has been restored to
+	return retargetVolume(rstr)
 }
 
-// ListZFSPool invokes `zfs list` to list all the available
-// pools in the node.
+// ListZFSPool returns all the available pools in the node.
 func ListZFSPool() ([]apis.Pool, error) {
-	args := []string{
-		ZFSListArg, "-d", "1", "-s", "name",
-		"-o", "name,guid,available,used",
-		"-H", "-p",
-	}
-	cmd := exec.Command(ZFSVolCmd, args...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		klog.Errorf("zfs: could not list zpool cmd %v: %v", args, err)
-		return nil, err
-	}
-	return decodeListOutput(output)
+	return backend.ListPools()
 }
 
 // The `zfs list` command will list down all the resources including
@@ -971,6 +982,22 @@ func decodeListOutput(raw []byte) ([]apis.Pool, error) {
 	return pools, nil
 }
 
+// decodeHealthOutput returns the health of the pools listed by
+// the zpool list command. Sample output of command:
+// $ zpool list -H -o name,health
+// zfspv-pool	ONLINE
+func decodeHealthOutput(raw []byte) map[string]string {
+	health := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(string(raw)))
+	for scanner.Scan() {
+		items := strings.Split(strings.TrimSpace(scanner.Text()), "\t")
+		if len(items) == 2 {
+			health[items[0]] = items[1]
+		}
+	}
+	return health
+}
+
 // get the reservation property based on the quota type
 func reservationProperty(quotaType string, capacity string) string {
 	var reservationProperties = map[string]string{
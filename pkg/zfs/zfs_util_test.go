@@ -0,0 +1,92 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfs
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	apis "github.com/openebs/zfs-localpv/pkg/apis/openebs.io/zfs/v1"
+)
+
+func TestBuildVolumeBackupArgs(t *testing.T) {
+	vol := testVolume("pvc-1", VolTypeDataset, "1073741824", "yes")
+
+	tests := []struct {
+		name string
+		prev string
+		base string
+		opts *apis.SendOptions
+		want string
+	}{
+		{"full", "", "", nil, "send pool/pvc-1@s2"},
+		{"incremental", "s1", "", nil, "send -i pool/pvc-1@s1 pool/pvc-1@s2"},
+		{"incremental from bookmark", "s1", "#s1", nil, "send -i pool/pvc-1#s1 pool/pvc-1@s2"},
+		{"raw compressed", "", "", &apis.SendOptions{Raw: true, Compressed: true},
+			"send -w -c pool/pvc-1@s2"},
+		{"large embedded props", "s1", "@s1", &apis.SendOptions{LargeBlock: true, Embedded: true, Props: true},
+			"send -L -e -p -i pool/pvc-1@s1 pool/pvc-1@s2"},
+		{"replicate", "s1", "", &apis.SendOptions{Replicate: true, Props: true},
+			"send -R -I pool/pvc-1@s1 pool/pvc-1@s2"},
+	}
+	for _, tt := range tests {
+		bkp := &apis.ZFSBackup{}
+		bkp.Spec.SnapName = "s2"
+		bkp.Spec.PrevSnapName = tt.prev
+		bkp.Transfer.IncrementalBase = tt.base
+		bkp.Spec.SendOptions = tt.opts
+		if got := strings.Join(buildVolumeBackupArgs(bkp, vol), " "); got != tt.want {
+			t.Errorf("%s: buildVolumeBackupArgs() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBuildVolumeRestoreArgs(t *testing.T) {
+	rstr := &apis.ZFSRestore{}
+	rstr.Spec.VolumeName = "pvc-2"
+	rstr.VolSpec = testVolume("pvc-1", VolTypeZVol, "1073741824", "yes").Spec
+	rstr.VolSpec.Encryption = "aes-256-gcm"
+	rstr.VolSpec.KeyFormat = "raw"
+	rstr.VolSpec.KeyLocation = "file:///key"
+
+	want := []string{"recv", "-s", "-o", "encryption=aes-256-gcm", "-o", "keylocation=file:///key",
+		"-o", "keyformat=raw", "-F", "pool/pvc-2"}
+	if got := buildVolumeRestoreArgs(rstr); !reflect.DeepEqual(got, want) {
+		t.Errorf("buildVolumeRestoreArgs() = %v, want %v", got, want)
+	}
+
+	// the raw stream is received with its own encryption properties
+	rstr.Spec.SendOptions = &apis.SendOptions{Raw: true}
+	want = []string{"recv", "-s", "-F", "pool/pvc-2"}
+	if got := buildVolumeRestoreArgs(rstr); !reflect.DeepEqual(got, want) {
+		t.Errorf("buildVolumeRestoreArgs() of raw stream = %v, want %v", got, want)
+	}
+
+	// the incremental stream is staged as the clone of the volume
+	rstr.Transfer.StagingDataset = "pool/.restore-pvc-2"
+	rstr.Transfer.StagingOrigin = "pool/pvc-2@s1"
+	want = []string{"recv", "-s", "-o", "origin=pool/pvc-2@s1", "-F", "pool/.restore-pvc-2"}
+	if got := buildVolumeRestoreArgs(rstr); !reflect.DeepEqual(got, want) {
+		t.Errorf("buildVolumeRestoreArgs() of staged incremental stream = %v, want %v", got, want)
+	}
+	rstr.Transfer.ResumeToken = "token"
+	want = []string{"recv", "-s", "-F", "pool/.restore-pvc-2"}
+	if got := buildVolumeRestoreArgs(rstr); !reflect.DeepEqual(got, want) {
+		t.Errorf("buildVolumeRestoreArgs() of resumed stream = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,448 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zfstest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	clientset "github.com/openebs/zfs-localpv/pkg/generated/clientset/internalclientset"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+)
+
+// groupPath is the path the zfs.openebs.io resources are served under,
+// the core kubernetes resources are served under corePath
+const (
+	groupPath = "/apis/zfs.openebs.io/v1/"
+	corePath  = "/api/v1/"
+)
+
+// coreKinds maps the core kubernetes resources served to their kind
+var coreKinds = map[string]string{
+	"nodes": "Node",
+}
+
+// kinds maps the zfs.openebs.io resources to their kind
+var kinds = map[string]string{
+	"zfsbackups":           "ZFSBackup",
+	"zfsnodes":             "ZFSNode",
+	"zfsrestores":          "ZFSRestore",
+	"zfssnapshotgroups":    "ZFSSnapshotGroup",
+	"zfssnapshots":         "ZFSSnapshot",
+	"zfssnapshotschedules": "ZFSSnapshotSchedule",
+	"zfsvolumes":           "ZFSVolume",
+}
+
+// APIServer is an in-memory kubernetes API server which serves the
+// zfs.openebs.io resources along with the kubernetes nodes. It supports get,
+// list, create, update, merge patch and delete, which is what the zfs
+// package, its controllers and the driver use.
+type APIServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	objects map[string]map[string]interface{}
+	version int
+}
+
+// NewAPIServer starts the API server and points the kubernetes clients
+// built from the environment at it for the duration of the test.
+func NewAPIServer(t *testing.T) *APIServer {
+	s := &APIServer{objects: map[string]map[string]interface{}{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	t.Setenv("OPENEBS_IO_K8S_MASTER", s.URL)
+	t.Setenv("OPENEBS_IO_KUBE_CONFIG", "")
+	return s
+}
+
+// Clientset returns the zfs.openebs.io clientset of the API server, which
+// is not throttled as the tests make many requests in a row.
+func (s *APIServer) Clientset() clientset.Interface {
+	return clientset.NewForConfigOrDie(&rest.Config{Host: s.URL, QPS: 1000, Burst: 1000})
+}
+
+// AddNode adds the kubernetes node with the labels
+func (s *APIServer) AddNode(name string, labels map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version++
+	meta := map[string]interface{}{"name": name, "uid": "uid-" + name}
+	if len(labels) > 0 {
+		l := map[string]interface{}{}
+		for k, v := range labels {
+			l[k] = v
+		}
+		meta["labels"] = l
+	}
+	s.store(resourcePath{core: true, resource: "nodes"}, name, map[string]interface{}{"metadata": meta})
+}
+
+// resourcePath is the parsed path of a request
+type resourcePath struct {
+	// core is set for the core kubernetes resources
+	core      bool
+	namespace string
+	resource  string
+	name      string
+}
+
+// apiVersion returns the api version of the resource
+func (p resourcePath) apiVersion() string {
+	if p.core {
+		return "v1"
+	}
+	return "zfs.openebs.io/v1"
+}
+
+// kind returns the kind of the resource
+func (p resourcePath) kind() string {
+	if p.core {
+		return coreKinds[p.resource]
+	}
+	return kinds[p.resource]
+}
+
+// key returns the key the object is stored at
+func (p resourcePath) key(name string) string {
+	return p.resource + "/" + p.namespace + "/" + name
+}
+
+// parsePath parses /apis/zfs.openebs.io/v1/[namespaces/{ns}/]{resource}[/{name}[/status]]
+// and /api/v1/{resource}[/{name}] for the core kubernetes resources
+func parsePath(path string) (resourcePath, bool) {
+	var p resourcePath
+	prefix := groupPath
+	if strings.HasPrefix(path, corePath) {
+		p.core, prefix = true, corePath
+	} else if !strings.HasPrefix(path, groupPath) {
+		return p, false
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, prefix), "/"), "/")
+	if len(parts) >= 2 && parts[0] == "namespaces" {
+		p.namespace = parts[1]
+		parts = parts[2:]
+	}
+	if len(parts) == 0 || len(parts) > 3 || (len(parts) == 3 && parts[2] != "status") {
+		return p, false
+	}
+	p.resource = parts[0]
+	if len(p.kind()) == 0 {
+		return p, false
+	}
+	if len(parts) > 1 {
+		p.name = parts[1]
+	}
+	return p, true
+}
+
+func (s *APIServer) serve(w http.ResponseWriter, r *http.Request) {
+	p, ok := parsePath(r.URL.Path)
+	if !ok {
+		writeStatus(w, http.StatusNotFound, metav1.StatusReasonNotFound, "the server could not find the requested resource")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && len(p.name) == 0:
+		s.list(w, r, p)
+	case r.Method == http.MethodGet:
+		s.get(w, p)
+	case r.Method == http.MethodPost && len(p.name) == 0:
+		s.create(w, r, p)
+	case r.Method == http.MethodPut && len(p.name) > 0:
+		s.update(w, r, p)
+	case r.Method == http.MethodPatch && len(p.name) > 0:
+		s.patch(w, r, p)
+	case r.Method == http.MethodDelete && len(p.name) > 0:
+		s.delete(w, p)
+	default:
+		writeStatus(w, http.StatusMethodNotAllowed, metav1.StatusReasonMethodNotAllowed,
+			fmt.Sprintf("%s is not supported on %s", r.Method, r.URL.Path))
+	}
+}
+
+func (s *APIServer) get(w http.ResponseWriter, p resourcePath) {
+	obj, ok := s.objects[p.key(p.name)]
+	if !ok {
+		writeNotFound(w, p)
+		return
+	}
+	writeJSON(w, http.StatusOK, obj)
+}
+
+func (s *APIServer) list(w http.ResponseWriter, r *http.Request, p resourcePath) {
+	selector := parseSelector(r.URL.Query().Get("labelSelector"))
+	var keys []string
+	for key := range s.objects {
+		if strings.HasPrefix(key, p.resource+"/") && (len(p.namespace) == 0 || strings.HasPrefix(key, p.key(""))) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	items := []interface{}{}
+	for _, key := range keys {
+		obj := s.objects[key]
+		if matchesSelector(obj, selector) {
+			items = append(items, obj)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"apiVersion": p.apiVersion(),
+		"kind":       p.kind() + "List",
+		"metadata":   map[string]interface{}{"resourceVersion": strconv.Itoa(s.version)},
+		"items":      items,
+	})
+}
+
+func (s *APIServer) create(w http.ResponseWriter, r *http.Request, p resourcePath) {
+	obj, ok := readObject(w, r)
+	if !ok {
+		return
+	}
+	meta := metadata(obj)
+	name, _ := meta["name"].(string)
+	if len(name) == 0 {
+		writeStatus(w, http.StatusUnprocessableEntity, metav1.StatusReasonInvalid, "metadata.name: Required value")
+		return
+	}
+	if _, ok := s.objects[p.key(name)]; ok {
+		writeStatus(w, http.StatusConflict, metav1.StatusReasonAlreadyExists,
+			fmt.Sprintf("%s %q already exists", p.resource, name))
+		return
+	}
+	s.version++
+	meta["namespace"] = p.namespace
+	meta["uid"] = fmt.Sprintf("00000000-0000-0000-0000-%012d", s.version)
+	meta["creationTimestamp"] = time.Now().UTC().Format(time.RFC3339)
+	delete(meta, "deletionTimestamp")
+	s.store(p, name, obj)
+	writeJSON(w, http.StatusCreated, obj)
+}
+
+func (s *APIServer) update(w http.ResponseWriter, r *http.Request, p resourcePath) {
+	old, ok := s.objects[p.key(p.name)]
+	if !ok {
+		writeNotFound(w, p)
+		return
+	}
+	obj, ok := readObject(w, r)
+	if !ok {
+		return
+	}
+	meta, oldMeta := metadata(obj), metadata(old)
+	if version, _ := meta["resourceVersion"].(string); len(version) > 0 && version != oldMeta["resourceVersion"] {
+		writeStatus(w, http.StatusConflict, metav1.StatusReasonConflict,
+			fmt.Sprintf("Operation cannot be fulfilled on %s %q: the object has been modified", p.resource, p.name))
+		return
+	}
+	s.replace(w, p, old, obj)
+}
+
+func (s *APIServer) patch(w http.ResponseWriter, r *http.Request, p resourcePath) {
+	old, ok := s.objects[p.key(p.name)]
+	if !ok {
+		writeNotFound(w, p)
+		return
+	}
+	if r.Header.Get("Content-Type") != string(types.MergePatchType) {
+		writeStatus(w, http.StatusUnsupportedMediaType, metav1.StatusReasonUnsupportedMediaType,
+			fmt.Sprintf("the patch type %s is not supported", r.Header.Get("Content-Type")))
+		return
+	}
+	patch, ok := readObject(w, r)
+	if !ok {
+		return
+	}
+	doc, err := deepCopy(old)
+	if err != nil {
+		writeStatus(w, http.StatusInternalServerError, metav1.StatusReasonInternalError, err.Error())
+		return
+	}
+	obj, _ := mergePatch(doc, patch).(map[string]interface{})
+	s.replace(w, p, old, obj)
+}
+
+// replace stores the updated object, keeping the fields the server owns.
+// The object which is being deleted goes away with its last finalizer.
+func (s *APIServer) replace(w http.ResponseWriter, p resourcePath, old, obj map[string]interface{}) {
+	meta, oldMeta := metadata(obj), metadata(old)
+	for _, field := range []string{"namespace", "uid", "creationTimestamp", "deletionTimestamp"} {
+		if v, ok := oldMeta[field]; ok {
+			meta[field] = v
+		} else {
+			delete(meta, field)
+		}
+	}
+	meta["name"] = p.name
+	s.version++
+	if _, deleting := meta["deletionTimestamp"]; deleting && len(finalizers(meta)) == 0 {
+		delete(s.objects, p.key(p.name))
+		meta["resourceVersion"] = strconv.Itoa(s.version)
+		writeJSON(w, http.StatusOK, obj)
+		return
+	}
+	s.store(p, p.name, obj)
+	writeJSON(w, http.StatusOK, obj)
+}
+
+func (s *APIServer) delete(w http.ResponseWriter, p resourcePath) {
+	obj, ok := s.objects[p.key(p.name)]
+	if !ok {
+		writeNotFound(w, p)
+		return
+	}
+	meta := metadata(obj)
+	if len(finalizers(meta)) == 0 {
+		delete(s.objects, p.key(p.name))
+		writeStatus(w, http.StatusOK, "", "")
+		return
+	}
+	if _, ok := meta["deletionTimestamp"]; !ok {
+		s.version++
+		meta["deletionTimestamp"] = time.Now().UTC().Format(time.RFC3339)
+		meta["resourceVersion"] = strconv.Itoa(s.version)
+	}
+	writeJSON(w, http.StatusOK, obj)
+}
+
+// store saves the object under the current resource version
+func (s *APIServer) store(p resourcePath, name string, obj map[string]interface{}) {
+	obj["apiVersion"] = p.apiVersion()
+	obj["kind"] = p.kind()
+	metadata(obj)["resourceVersion"] = strconv.Itoa(s.version)
+	s.objects[p.key(name)] = obj
+}
+
+// metadata returns the metadata of the object, adding it if it is missing
+func metadata(obj map[string]interface{}) map[string]interface{} {
+	meta, ok := obj["metadata"].(map[string]interface{})
+	if !ok {
+		meta = map[string]interface{}{}
+		obj["metadata"] = meta
+	}
+	return meta
+}
+
+func finalizers(meta map[string]interface{}) []interface{} {
+	list, _ := meta["finalizers"].([]interface{})
+	return list
+}
+
+// mergePatch applies the json merge patch (RFC 7386) to the document
+func mergePatch(doc, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	d, ok := doc.(map[string]interface{})
+	if !ok {
+		d = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(d, k)
+			continue
+		}
+		d[k] = mergePatch(d[k], v)
+	}
+	return d
+}
+
+// parseSelector parses the equality based label selector
+func parseSelector(selector string) map[string]string {
+	labels := map[string]string{}
+	for _, req := range strings.Split(selector, ",") {
+		if k, v, ok := strings.Cut(req, "="); ok {
+			labels[strings.TrimSpace(k)] = strings.TrimSpace(strings.TrimPrefix(v, "="))
+		}
+	}
+	return labels
+}
+
+func matchesSelector(obj map[string]interface{}, selector map[string]string) bool {
+	labels, _ := metadata(obj)["labels"].(map[string]interface{})
+	for k, v := range selector {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func deepCopy(obj map[string]interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var out map[string]interface{}
+	return out, json.Unmarshal(data, &out)
+}
+
+func readObject(w http.ResponseWriter, r *http.Request) (map[string]interface{}, bool) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeStatus(w, http.StatusBadRequest, metav1.StatusReasonBadRequest, err.Error())
+		return nil, false
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(data, &obj); err != nil || obj == nil {
+		writeStatus(w, http.StatusBadRequest, metav1.StatusReasonBadRequest, fmt.Sprintf("invalid object: %v", err))
+		return nil, false
+	}
+	return obj, true
+}
+
+func writeNotFound(w http.ResponseWriter, p resourcePath) {
+	writeStatus(w, http.StatusNotFound, metav1.StatusReasonNotFound,
+		fmt.Sprintf("%s %q not found", p.resource, p.name))
+}
+
+func writeStatus(w http.ResponseWriter, code int, reason metav1.StatusReason, message string) {
+	status := metav1.Status{
+		TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+		Status:   metav1.StatusSuccess,
+		Reason:   reason,
+		Message:  message,
+		Code:     int32(code),
+	}
+	if code >= http.StatusBadRequest {
+		status.Status = metav1.StatusFailure
+	}
+	writeJSON(w, code, status)
+}
+
+func writeJSON(w http.ResponseWriter, code int, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(obj)
+}
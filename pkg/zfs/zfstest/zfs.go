@@ -0,0 +1,1220 @@
+/*
+Copyright © 2024 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package zfstest provides the in-memory zfs pools and the kubernetes API
+// server the volume, snapshot, backup and restore flows are tested against.
+// It does not depend on the zfs package so that its own tests can use it.
+package zfstest
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// zfs dataset types as reported by the type property
+const (
+	DatasetTypeFilesystem = "filesystem"
+	DatasetTypeVolume     = "volume"
+	DatasetTypeSnapshot   = "snapshot"
+)
+
+// errExit is returned by the failed commands, as it is by the zfs commands
+// exiting with 1, the error message is in their output
+var errExit = errors.New("exit status 1")
+
+// Dataset is a dataset, zvol or snapshot kept by the ZFS
+type Dataset struct {
+	Name string
+	Type string
+	// Origin is the snapshot this dataset was cloned from
+	Origin string
+	GUID   uint64
+	// CreateTxg orders the datasets by their creation, the received
+	// snapshot keeps the guid of the sent one
+	CreateTxg uint64
+	// Written is the amount of data referenced by the dataset
+	Written int64
+	Props   map[string]string
+}
+
+// streamHeader begins the send stream written by the ZFS,
+// it is followed by the json encoded snapshot being sent
+type streamHeader struct {
+	// ToName is the name of the snapshot being sent
+	ToName string `json:"toname"`
+	// From is the snapshot name the stream is incremental from
+	From string `json:"from,omitempty"`
+	// Resume is set if the stream resumes a partially received stream
+	Resume bool `json:"resume,omitempty"`
+	// Raw is set if the stream has been sent with -w
+	Raw bool `json:"raw,omitempty"`
+	// Props is set if the stream carries the properties, -p, -R or -w
+	Props bool `json:"props,omitempty"`
+	// Snapshots are the intermediate snapshots replicated with -R
+	Snapshots []string `json:"snapshots,omitempty"`
+}
+
+// ZFS runs the zfs and the zpool commands against the pools it keeps in
+// memory, it is plugged in using zfs.SetCommandRunner. It models the dataset
+// tree of the pools along with the snapshots, clones, quotas and reservations.
+// The send stream it writes is the json encoded snapshot, which can be
+// received by another ZFS.
+type ZFS struct {
+	mu       sync.Mutex
+	pools    map[string]int64
+	datasets map[string]*Dataset
+	// bookmarks maps the bookmarks to the guid of their snapshot
+	bookmarks map[string]uint64
+	// holds maps the snapshots to the tags of their user holds
+	holds map[string]map[string]bool
+	// health maps the pools to their health, ONLINE if not set
+	health map[string]string
+	guid   uint64
+}
+
+// NewZFS returns the ZFS with no pools
+func NewZFS() *ZFS {
+	return &ZFS{
+		pools:     map[string]int64{},
+		health:    map[string]string{},
+		datasets:  map[string]*Dataset{},
+		bookmarks: map[string]uint64{},
+		holds:     map[string]map[string]bool{},
+	}
+}
+
+// AddPool adds a pool of the given size in bytes
+func (f *ZFS) AddPool(name string, size int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.pools[name] = size
+	f.datasets[name] = f.newDataset(name, DatasetTypeFilesystem)
+}
+
+// SetPoolHealth sets the health of the pool reported by zpool list
+func (f *ZFS) SetPoolHealth(name string, health string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.health[name] = health
+}
+
+// Dataset returns a copy of the dataset, zvol or snapshot
+func (f *ZFS) Dataset(name string) (Dataset, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ds, ok := f.datasets[name]
+	if !ok {
+		return Dataset{}, false
+	}
+	cp := *ds
+	cp.Props = copyProps(ds.Props)
+	return cp, true
+}
+
+// Write accounts size bytes of data written to the dataset or the zvol,
+// it fails the same way zfs does if the quota or the pool space is exceeded
+func (f *ZFS) Write(name string, size int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ds, ok := f.datasets[name]
+	if !ok || ds.Type == DatasetTypeSnapshot {
+		return fmt.Errorf("cannot open '%s': dataset does not exist", name)
+	}
+
+	limit := parseSize(ds.Props["quota"])
+	if q := parseSize(ds.Props["refquota"]); q != 0 && (limit == 0 || q < limit) {
+		limit = q
+	}
+	if ds.Type == DatasetTypeVolume {
+		limit = parseSize(ds.Props["volsize"])
+	}
+	if limit != 0 && ds.Written+size > limit {
+		return fmt.Errorf("write %s: Disk quota exceeded", name)
+	}
+
+	// the data beyond the reservation has to come from the free space of the pool
+	grow := maxInt64(ds.Written+size, reserved(ds)) - maxInt64(ds.Written, reserved(ds))
+	if grow > f.available(poolName(name)) {
+		return fmt.Errorf("write %s: No space left on device", name)
+	}
+	ds.Written += size
+	return nil
+}
+
+// Output runs the zfs or the zpool command and returns its output, which
+// is the error message if the command fails
+func (f *ZFS) Output(name string, args ...string) ([]byte, error) {
+	var out bytes.Buffer
+	var err error
+	switch {
+	case name == "zpool" && len(args) > 0 && args[0] == "list":
+		err = f.zpoolList(&out)
+	case name != "zfs" || len(args) == 0:
+		err = fmt.Errorf("%s %s: command not supported", name, strings.Join(args, " "))
+	case args[0] == "send":
+		err = f.send(context.Background(), &out, args[1:])
+	default:
+		err = f.run(&out, args[0], args[1:])
+	}
+	if err != nil {
+		return []byte(err.Error() + "\n"), errExit
+	}
+	return out.Bytes(), nil
+}
+
+// Transfer runs zfs send writing the stream to stdout or zfs recv reading
+// it from stdin, the command fails once ctx is done
+func (f *ZFS) Transfer(ctx context.Context, stdin io.Reader, stdout io.Writer, stderr io.Writer, name string, args ...string) error {
+	var err error
+	switch {
+	case name != "zfs" || len(args) == 0:
+		err = fmt.Errorf("%s %s: command not supported", name, strings.Join(args, " "))
+	case args[0] == "send":
+		err = f.send(ctx, stdout, args[1:])
+	case args[0] == "recv":
+		err = f.recv(ctx, stdin, args[1:])
+	default:
+		err = fmt.Errorf("zfs %s: not a transfer command", args[0])
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err.Error())
+		return errExit
+	}
+	return nil
+}
+
+// run runs the zfs subcommand other than send
+func (f *ZFS) run(out io.Writer, cmd string, args []string) error {
+	if cmd == "list" {
+		return f.list(out, args)
+	}
+	flags, props, names := parseArgs(args)
+	if len(names) == 0 {
+		return fmt.Errorf("missing dataset argument")
+	}
+	switch cmd {
+	case "get":
+		val, err := f.getProperty(names[1], names[0])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, val)
+		return nil
+	case "create":
+		return f.createVolume(flags, props, names[0])
+	case "clone":
+		return f.createClone(props, names[0], names[1])
+	case "rename":
+		return f.rename(names[0], names[1])
+	case "promote":
+		return f.promote(names[0])
+	case "bookmark":
+		return f.createBookmark(names[0], names[1])
+	case "destroy":
+		name := names[0]
+		switch {
+		case strings.Contains(name, "#"):
+			return f.destroyBookmark(name)
+		case strings.Contains(name, "@"):
+			return f.destroySnapshot(name)
+		}
+		return f.destroyVolume(name)
+	case "snapshot":
+		return f.createSnapshots(names)
+	case "hold":
+		return f.hold(names[1], names[0])
+	case "release":
+		return f.release(names[1], names[0])
+	case "holds":
+		tags, err := f.holdsOf(names[0])
+		for _, tag := range tags {
+			fmt.Fprintf(out, "%s\t%s\t%s\n", names[0], tag, time.Now().Format("Mon Jan 2 15:04 2006"))
+		}
+		return err
+	case "set":
+		return f.setProps(args)
+	case "mount":
+		return f.mount(names[0])
+	case "recv":
+		if _, ok := flags["-A"]; ok {
+			return f.abortRecv(names[0])
+		}
+	}
+	return fmt.Errorf("zfs %s: command not supported", cmd)
+}
+
+// list handles zfs list of the dataset, of its snapshots with -t snapshot
+// and of the pools with -d 1 and no dataset
+func (f *ZFS) list(out io.Writer, args []string) error {
+	flags, _, names := parseArgs(args, "-t", "-s", "-d")
+	if len(names) == 0 {
+		f.listPools(out)
+		return nil
+	}
+	name := names[0]
+	if flags["-t"] == "snapshot" {
+		snaps, err := f.listSnapshots(name)
+		for _, snap := range snaps {
+			fmt.Fprintln(out, name+"@"+snap)
+		}
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.datasets[name]; !ok {
+		return fmt.Errorf("cannot open '%s': dataset does not exist", name)
+	}
+	fmt.Fprintln(out, name)
+	return nil
+}
+
+// listPools writes the name, guid, available and used columns of the pools
+func (f *ZFS) listPools(out io.Writer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, name := range f.poolNames() {
+		free := f.available(name)
+		fmt.Fprintf(out, "%s\t%d\t%d\t%d\n", name, f.datasets[name].GUID, free, f.pools[name]-free)
+	}
+}
+
+// zpoolList writes the name and the health columns of the pools
+func (f *ZFS) zpoolList(out io.Writer) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, name := range f.poolNames() {
+		health := f.health[name]
+		if health == "" {
+			health = "ONLINE"
+		}
+		fmt.Fprintf(out, "%s\t%s\n", name, health)
+	}
+	return nil
+}
+
+func (f *ZFS) poolNames() []string {
+	var names []string
+	for name := range f.pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// createVolume creates the dataset or the zvol
+func (f *ZFS) createVolume(flags, props map[string]string, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.checkCreate(name); err != nil {
+		return err
+	}
+
+	ds := f.newDataset(name, DatasetTypeFilesystem)
+	if size, ok := flags["-V"]; ok {
+		ds.Type = DatasetTypeVolume
+		ds.Props["volsize"] = strconv.FormatInt(parseSize(size), 10)
+		if _, sparse := flags["-s"]; !sparse {
+			ds.Props["refreservation"] = ds.Props["volsize"]
+		}
+		if bs, ok := flags["-b"]; ok {
+			ds.Props["volblocksize"] = bs
+		}
+	}
+	return f.addDataset(ds, props)
+}
+
+// createClone clones the volume from the snapshot
+func (f *ZFS) createClone(props map[string]string, snapshot, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	origin, ok := f.datasets[snapshot]
+	if !ok || origin.Type != DatasetTypeSnapshot {
+		return fmt.Errorf("cannot open '%s': dataset does not exist", snapshot)
+	}
+	if err := f.checkCreate(name); err != nil {
+		return err
+	}
+
+	ds := f.newDataset(name, DatasetTypeFilesystem)
+	if len(origin.Props["volsize"]) > 0 {
+		ds.Type = DatasetTypeVolume
+		ds.Props["volsize"] = origin.Props["volsize"]
+	}
+	ds.Origin = snapshot
+	ds.Written = origin.Written
+	return f.addDataset(ds, props)
+}
+
+// rename renames the dataset along with its snapshots and bookmarks
+func (f *ZFS) rename(from string, to string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ds, ok := f.datasets[from]
+	if !ok {
+		return fmt.Errorf("cannot open '%s': dataset does not exist", from)
+	}
+	if ds.Type == DatasetTypeSnapshot {
+		return fmt.Errorf("cannot rename '%s': snapshots can not be renamed to other datasets", from)
+	}
+	if err := f.checkCreate(to); err != nil {
+		return fmt.Errorf("cannot rename to '%s': %v", to, err)
+	}
+	if poolName(from) != poolName(to) {
+		return fmt.Errorf("cannot rename to '%s': datasets must be within same pool", to)
+	}
+
+	moved := map[string]string{}
+	for n := range f.datasets {
+		if n == from || strings.HasPrefix(n, from+"@") {
+			moved[n] = to + strings.TrimPrefix(n, from)
+		}
+	}
+	for old, name := range moved {
+		d := f.datasets[old]
+		delete(f.datasets, old)
+		d.Name = name
+		f.datasets[name] = d
+		if tags, ok := f.holds[old]; ok {
+			delete(f.holds, old)
+			f.holds[name] = tags
+		}
+	}
+	for _, d := range f.datasets {
+		if name, ok := moved[d.Origin]; ok {
+			d.Origin = name
+		}
+	}
+	for n, guid := range f.bookmarks {
+		if strings.HasPrefix(n, from+"#") {
+			delete(f.bookmarks, n)
+			f.bookmarks[to+strings.TrimPrefix(n, from)] = guid
+		}
+	}
+	return nil
+}
+
+// promote promotes the clone, the snapshots of its origin dataset up to
+// the origin snapshot are moved to it along with their clones and holds,
+// and the origin dataset becomes the clone of the moved origin snapshot
+func (f *ZFS) promote(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ds, ok := f.datasets[name]
+	if !ok || ds.Type == DatasetTypeSnapshot {
+		return fmt.Errorf("cannot open '%s': dataset does not exist", name)
+	}
+	if ds.Origin == "" {
+		return fmt.Errorf("cannot promote '%s': not a cloned filesystem", name)
+	}
+	from := strings.Split(ds.Origin, "@")[0]
+	origin := f.datasets[ds.Origin]
+
+	moved := map[string]string{}
+	for n, snap := range f.datasets {
+		if !strings.HasPrefix(n, from+"@") || snap.CreateTxg > origin.CreateTxg {
+			continue
+		}
+		to := name + strings.TrimPrefix(n, from)
+		if _, ok := f.datasets[to]; ok {
+			return fmt.Errorf("cannot promote '%s': snapshot name '%s' from origin conflicts with '%s' from target",
+				name, n, to)
+		}
+		moved[n] = to
+	}
+	for old, to := range moved {
+		snap := f.datasets[old]
+		delete(f.datasets, old)
+		snap.Name = to
+		f.datasets[to] = snap
+		if tags, ok := f.holds[old]; ok {
+			delete(f.holds, old)
+			f.holds[to] = tags
+		}
+	}
+
+	parent := f.datasets[from]
+	ds.Origin, parent.Origin = parent.Origin, moved[ds.Origin]
+	for _, d := range f.datasets {
+		if to, ok := moved[d.Origin]; ok && d != parent {
+			d.Origin = to
+		}
+	}
+	return nil
+}
+
+// listSnapshots returns the snapshots of the dataset ordered by creation
+func (f *ZFS) listSnapshots(name string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.datasets[name]; !ok {
+		return nil, fmt.Errorf("cannot open '%s': dataset does not exist", name)
+	}
+	var snaps []*Dataset
+	for n, ds := range f.datasets {
+		if strings.HasPrefix(n, name+"@") {
+			snaps = append(snaps, ds)
+		}
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].CreateTxg < snaps[j].CreateTxg })
+
+	var names []string
+	for _, ds := range snaps {
+		names = append(names, strings.Split(ds.Name, "@")[1])
+	}
+	return names, nil
+}
+
+// createBookmark creates the bookmark of the snapshot
+func (f *ZFS) createBookmark(snapshot string, bookmark string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	snap, ok := f.datasets[snapshot]
+	if !ok || snap.Type != DatasetTypeSnapshot {
+		return fmt.Errorf("cannot create bookmark '%s': snapshot '%s' does not exist", bookmark, snapshot)
+	}
+	if _, ok := f.bookmarks[bookmark]; ok {
+		return fmt.Errorf("cannot create bookmark '%s': bookmark exists", bookmark)
+	}
+	f.bookmarks[bookmark] = snap.GUID
+	return nil
+}
+
+// destroyBookmark destroys the bookmark
+func (f *ZFS) destroyBookmark(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.bookmarks[name]; !ok {
+		return fmt.Errorf("cannot destroy '%s': bookmark does not exist", name)
+	}
+	delete(f.bookmarks, name)
+	return nil
+}
+
+// hold places the user hold with the tag on the snapshot
+func (f *ZFS) hold(snapshot string, tag string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if snap, ok := f.datasets[snapshot]; !ok || snap.Type != DatasetTypeSnapshot {
+		return fmt.Errorf("cannot hold snapshot '%s': dataset does not exist", snapshot)
+	}
+	if f.holds[snapshot][tag] {
+		return fmt.Errorf("cannot hold snapshot '%s': tag already exists on this dataset", snapshot)
+	}
+	if f.holds[snapshot] == nil {
+		f.holds[snapshot] = map[string]bool{}
+	}
+	f.holds[snapshot][tag] = true
+	return nil
+}
+
+// release releases the user hold with the tag from the snapshot
+func (f *ZFS) release(snapshot string, tag string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.holds[snapshot][tag] {
+		return fmt.Errorf("cannot release hold from snapshot '%s': no such tag on this dataset", snapshot)
+	}
+	delete(f.holds[snapshot], tag)
+	if len(f.holds[snapshot]) == 0 {
+		delete(f.holds, snapshot)
+	}
+	return nil
+}
+
+// holdsOf returns the sorted tags of the user holds on the snapshot
+func (f *ZFS) holdsOf(snapshot string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.datasets[snapshot]; !ok {
+		return nil, fmt.Errorf("cannot open '%s': dataset does not exist", snapshot)
+	}
+	var tags []string
+	for tag := range f.holds[snapshot] {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+// destroyVolume destroys the volume and its snapshots, it fails
+// if any of the snapshots has dependent clones
+func (f *ZFS) destroyVolume(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.datasets[name]; !ok {
+		return fmt.Errorf("cannot open '%s': dataset does not exist", name)
+	}
+	for _, ds := range f.datasets {
+		if ds.Name != name && !strings.HasPrefix(ds.Name, name+"@") {
+			continue
+		}
+		if clone := f.cloneOf(ds.Name); clone != "" {
+			return fmt.Errorf("cannot destroy '%s': filesystem has dependent clones %s", name, clone)
+		}
+		if len(f.holds[ds.Name]) > 0 {
+			return fmt.Errorf("cannot destroy snapshot %s: dataset is busy", ds.Name)
+		}
+	}
+	for n := range f.datasets {
+		if n == name || strings.HasPrefix(n, name+"@") {
+			delete(f.datasets, n)
+		}
+	}
+	for n := range f.bookmarks {
+		if strings.HasPrefix(n, name+"#") {
+			delete(f.bookmarks, n)
+		}
+	}
+	return nil
+}
+
+// createSnapshots creates all the snapshots in the same txg, none of them
+// are created if any of them can not be
+func (f *ZFS) createSnapshots(names []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	pool := poolName(names[0])
+	for _, name := range names {
+		if poolName(name) != pool {
+			return fmt.Errorf("cannot create snapshots : operation not supported across pools")
+		}
+		if _, ok := f.datasets[name]; ok {
+			return fmt.Errorf("cannot create snapshot '%s': dataset already exists", name)
+		}
+		if _, ok := f.datasets[strings.Split(name, "@")[0]]; !ok {
+			return fmt.Errorf("cannot open '%s': dataset does not exist", name)
+		}
+	}
+	var txg uint64
+	for _, name := range names {
+		if err := f.snapshot(name); err != nil {
+			return err
+		}
+		if txg == 0 {
+			txg = f.datasets[name].CreateTxg
+		}
+		f.datasets[name].CreateTxg = txg
+	}
+	return nil
+}
+
+// destroySnapshot destroys the snapshot, it fails
+// if the snapshot has dependent clones
+func (f *ZFS) destroySnapshot(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.datasets[name]; !ok {
+		return fmt.Errorf("could not find any snapshots to destroy; check snapshot names")
+	}
+	if clone := f.cloneOf(name); clone != "" {
+		return fmt.Errorf("cannot destroy '%s': snapshot has dependent clones %s", name, clone)
+	}
+	if len(f.holds[name]) > 0 {
+		return fmt.Errorf("cannot destroy snapshot %s: dataset is busy", name)
+	}
+	delete(f.datasets, name)
+	return nil
+}
+
+// getProperty returns the parsable value of the property of the dataset
+func (f *ZFS) getProperty(name string, prop string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if guid, ok := f.bookmarks[name]; ok {
+		if prop == "guid" {
+			return strconv.FormatUint(guid, 10), nil
+		}
+		return "-", nil
+	}
+	ds, ok := f.datasets[name]
+	if !ok {
+		return "", fmt.Errorf("cannot open '%s': dataset does not exist", name)
+	}
+
+	switch prop {
+	case "type":
+		return ds.Type, nil
+	case "guid":
+		return strconv.FormatUint(ds.GUID, 10), nil
+	case "origin":
+		if ds.Origin == "" {
+			return "-", nil
+		}
+		return ds.Origin, nil
+	case "used", "referenced", "written":
+		return strconv.FormatInt(ds.Written, 10), nil
+	case "available":
+		return strconv.FormatInt(f.available(poolName(name)), 10), nil
+	}
+	if val, ok := ds.Props[prop]; ok {
+		return val, nil
+	}
+	return "-", nil
+}
+
+// mount mounts the dataset
+func (f *ZFS) mount(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ds, ok := f.datasets[name]
+	if !ok || ds.Type != DatasetTypeFilesystem {
+		return fmt.Errorf("cannot open '%s': dataset does not exist", name)
+	}
+	ds.Props["mounted"] = "yes"
+	return nil
+}
+
+// send writes the snapshot as the stream to w, or the size of the stream
+// with -nvP
+func (f *ZFS) send(ctx context.Context, w io.Writer, args []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	flags, _, names := parseArgs(args)
+	if _, ok := flags["-nvP"]; ok {
+		delete(flags, "-nvP")
+		counter := &countWriter{}
+		if err := f.sendStream(counter, flags, names); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintf(w, "full\t%d\nsize\t%d\n", counter.n, counter.n)
+		return err
+	}
+	return f.sendStream(w, flags, names)
+}
+
+// sendStream writes the stream of the snapshot, or the one resuming the
+// partially received stream from the token passed with -t
+func (f *ZFS) sendStream(w io.Writer, flags map[string]string, names []string) error {
+	_, raw := flags["-w"]
+	_, replicate := flags["-R"]
+	_, props := flags["-p"]
+	var hdr streamHeader
+	if token, ok := flags["-t"]; ok {
+		data, err := base64.StdEncoding.DecodeString(token)
+		if err != nil || json.Unmarshal(data, &hdr) != nil {
+			return fmt.Errorf("cannot resume send: invalid resume token")
+		}
+		hdr.Resume = true
+	} else {
+		if len(names) == 0 {
+			return fmt.Errorf("missing snapshot argument")
+		}
+		hdr = streamHeader{
+			ToName: names[0],
+			Raw:    raw,
+			Props:  raw || replicate || props,
+		}
+	}
+	volume := strings.Split(hdr.ToName, "@")[0]
+
+	// the incremental stream can be sent from the bookmark, except with -I
+	base, incremental := flags["-i"]
+	if !incremental {
+		base, incremental = flags["-I"]
+	}
+	if incremental {
+		hdr.From = base[strings.IndexAny(base, "@#")+1:]
+	}
+
+	f.mu.Lock()
+	snap, ok := f.datasets[hdr.ToName]
+	if !ok {
+		f.mu.Unlock()
+		return fmt.Errorf("cannot open '%s': dataset does not exist", hdr.ToName)
+	}
+	if len(hdr.From) > 0 {
+		if !incremental {
+			base = volume + "@" + hdr.From
+		}
+		_, isSnap := f.datasets[base]
+		_, isBookmark := f.bookmarks[base]
+		if hdr.Resume && !isSnap {
+			_, isBookmark = f.bookmarks[volume+"#"+hdr.From]
+		}
+		if !isSnap && (!isBookmark || replicate) {
+			f.mu.Unlock()
+			return fmt.Errorf("cannot open '%s': dataset does not exist", base)
+		}
+	}
+	if replicate && !hdr.Resume {
+		hdr.Snapshots = f.snapshotsBetween(volume, hdr.From, snap)
+	}
+	body := *snap
+	body.Props = copyProps(snap.Props)
+	f.mu.Unlock()
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(&hdr); err != nil {
+		return err
+	}
+	return enc.Encode(&body)
+}
+
+// recv reads the stream written by send from r and receives it as the
+// dataset. If the stream gets cut after its header, the partially received
+// state is kept along with the receive_resume_token with -s, as it is if
+// the receive is killed once ctx is done.
+func (f *ZFS) recv(ctx context.Context, r io.Reader, args []string) error {
+	flags, props, names := parseArgs(args)
+
+	dec := json.NewDecoder(&ctxReader{ctx: ctx, r: r})
+	var hdr streamHeader
+	if err := dec.Decode(&hdr); err != nil {
+		return fmt.Errorf("cannot receive: failed to read from stream, %v", err)
+	}
+
+	if hdr.Raw {
+		for _, prop := range []string{"encryption", "keylocation", "keyformat"} {
+			if _, ok := props[prop]; ok {
+				return fmt.Errorf("cannot receive: property '%s' can not be set on a raw stream", prop)
+			}
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name := names[0]
+	snapName := strings.Split(hdr.ToName, "@")[1]
+	target, exists := f.datasets[name]
+	partial := exists && len(target.Props["receive_resume_token"]) > 0
+	// the stream is received as a clone of the origin set with -o origin=
+	origin, cloned := props["origin"]
+	delete(props, "origin")
+	switch {
+	case hdr.Resume:
+		if !partial {
+			return fmt.Errorf("cannot receive resume stream: destination '%s' has no partially received state", name)
+		}
+	case partial:
+		return fmt.Errorf("destination %s contains partially-complete state from \"zfs receive -s\"", name)
+	case cloned:
+		base, ok := f.datasets[origin]
+		if !ok || base.Type != DatasetTypeSnapshot {
+			return fmt.Errorf("cannot receive: origin snapshot '%s' does not exist", origin)
+		}
+		if len(hdr.From) > 0 && strings.Split(origin, "@")[1] != hdr.From {
+			return fmt.Errorf("cannot receive incremental stream: origin %s does not match incremental source", origin)
+		}
+		if err := f.checkCreate(name); err != nil {
+			return err
+		}
+		target = f.newDataset(name, DatasetTypeFilesystem)
+		if len(base.Props["volsize"]) > 0 {
+			target.Type = DatasetTypeVolume
+			target.Props["volsize"] = base.Props["volsize"]
+		}
+		target.Origin = origin
+		target.Written = base.Written
+		if err := f.addDataset(target, props); err != nil {
+			return err
+		}
+	case len(hdr.From) > 0:
+		if !exists {
+			return fmt.Errorf("cannot receive incremental stream: destination '%s' does not exist", name)
+		}
+		if _, ok := f.datasets[name+"@"+hdr.From]; !ok {
+			return fmt.Errorf("cannot receive incremental stream: destination %s has been modified", name)
+		}
+	default:
+		if exists {
+			if f.hasSnapshots(name) {
+				return fmt.Errorf("cannot receive new filesystem stream: destination has snapshots")
+			}
+			delete(f.datasets, name)
+		}
+		if err := f.checkCreate(name); err != nil {
+			return err
+		}
+		target = f.newDataset(name, DatasetTypeFilesystem)
+		if err := f.addDataset(target, props); err != nil {
+			return err
+		}
+	}
+
+	var snap Dataset
+	if err := dec.Decode(&snap); err != nil {
+		if _, ok := flags["-s"]; ok {
+			hdr.Resume = false
+			data, _ := json.Marshal(&hdr)
+			target.Props["receive_resume_token"] = base64.StdEncoding.EncodeToString(data)
+		} else if !exists || len(hdr.From) == 0 {
+			delete(f.datasets, name)
+		}
+		return fmt.Errorf("cannot receive: failed to read from stream, %v", err)
+	}
+
+	delete(target.Props, "receive_resume_token")
+	if hdr.Props {
+		// the received properties do not override the ones set with -o,
+		// the ones excluded with -x are not received
+		for k, v := range snap.Props {
+			if _, excluded := flags["-x "+k]; excluded {
+				continue
+			}
+			if _, ok := props[k]; !ok && k != "mounted" && k != "receive_resume_token" {
+				target.Props[k] = v
+			}
+		}
+	}
+	for _, s := range hdr.Snapshots {
+		if err := f.snapshot(name + "@" + s); err != nil {
+			return err
+		}
+	}
+	if len(snap.Props["volsize"]) > 0 {
+		target.Type = DatasetTypeVolume
+		target.Props["volsize"] = snap.Props["volsize"]
+		delete(target.Props, "mounted")
+	}
+	target.Written = snap.Written
+	if err := f.snapshot(name + "@" + snapName); err != nil {
+		return err
+	}
+	// the received snapshot keeps the guid of the sent one
+	f.datasets[name+"@"+snapName].GUID = snap.GUID
+	return nil
+}
+
+// ctxReader fails the reads once ctx is done, as the killed zfs recv
+// stops reading its stream
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// countWriter counts the bytes of the stream estimated with -nvP
+type countWriter struct {
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+// abortRecv discards the partially received state of the dataset
+func (f *ZFS) abortRecv(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ds, ok := f.datasets[name]
+	if !ok || len(ds.Props["receive_resume_token"]) == 0 {
+		return fmt.Errorf("'%s' does not have any resumable receive state to abort", name)
+	}
+	if f.hasSnapshots(name) {
+		delete(ds.Props, "receive_resume_token")
+	} else {
+		delete(f.datasets, name)
+	}
+	return nil
+}
+
+func (f *ZFS) newDataset(name string, dsType string) *Dataset {
+	f.guid++
+	return &Dataset{
+		Name:      name,
+		Type:      dsType,
+		GUID:      f.guid,
+		CreateTxg: f.guid,
+		Props:     map[string]string{},
+	}
+}
+
+// checkCreate verifies that the dataset can be created
+func (f *ZFS) checkCreate(name string) error {
+	if _, ok := f.datasets[name]; ok {
+		return fmt.Errorf("cannot create '%s': dataset already exists", name)
+	}
+	idx := strings.LastIndex(name, "/")
+	if idx < 0 {
+		return fmt.Errorf("cannot create '%s': missing dataset name", name)
+	}
+	if _, ok := f.datasets[name[:idx]]; !ok {
+		return fmt.Errorf("cannot create '%s': parent does not exist", name)
+	}
+	return nil
+}
+
+// addDataset sets the properties on the dataset and adds it
+// if the reservations can be satisfied by the pool
+func (f *ZFS) addDataset(ds *Dataset, props map[string]string) error {
+	for k, v := range props {
+		ds.Props[k] = normalizeProp(k, v)
+	}
+	if ds.Type == DatasetTypeFilesystem && ds.Props["mounted"] == "" {
+		ds.Props["mounted"] = "no"
+	}
+	if reserved(ds) > f.available(poolName(ds.Name)) {
+		return fmt.Errorf("cannot create '%s': out of space", ds.Name)
+	}
+	f.datasets[ds.Name] = ds
+	return nil
+}
+
+// setProps handles the zfs set arguments: <prop=value>... <dataset>
+func (f *ZFS) setProps(args []string) error {
+	props := map[string]string{}
+	for _, arg := range args[:len(args)-1] {
+		if strings.HasPrefix(arg, "-") {
+			return fmt.Errorf("invalid option '%s'", strings.TrimPrefix(arg, "-"))
+		}
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("bad property list: invalid property '%s'", arg)
+		}
+		props[kv[0]] = kv[1]
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name := args[len(args)-1]
+	ds, ok := f.datasets[name]
+	if !ok {
+		return fmt.Errorf("cannot open '%s': dataset does not exist", name)
+	}
+
+	updated := *ds
+	updated.Props = copyProps(ds.Props)
+	for k, v := range props {
+		updated.Props[k] = normalizeProp(k, v)
+	}
+	if ds.Type == DatasetTypeVolume {
+		if _, ok := props["volsize"]; ok {
+			if parseSize(updated.Props["volsize"]) < ds.Written {
+				return fmt.Errorf("cannot set property for '%s': size is less than current used data", name)
+			}
+			if _, thick := ds.Props["refreservation"]; thick {
+				updated.Props["refreservation"] = updated.Props["volsize"]
+			}
+		}
+	}
+	for _, q := range []string{"quota", "refquota"} {
+		if _, ok := props[q]; ok && parseSize(updated.Props[q]) != 0 && parseSize(updated.Props[q]) < ds.Written {
+			return fmt.Errorf("cannot set property for '%s': size is less than current used or reserved space", name)
+		}
+	}
+	if reserved(&updated)-reserved(ds) > f.available(poolName(name)) {
+		return fmt.Errorf("cannot set property for '%s': size is greater than available space", name)
+	}
+	*ds = updated
+	return nil
+}
+
+// snapshot creates the snapshot of the dataset
+func (f *ZFS) snapshot(name string) error {
+	if _, ok := f.datasets[name]; ok {
+		return fmt.Errorf("cannot create snapshot '%s': dataset already exists", name)
+	}
+	parent, ok := f.datasets[strings.Split(name, "@")[0]]
+	if !ok {
+		return fmt.Errorf("cannot open '%s': dataset does not exist", name)
+	}
+	snap := f.newDataset(name, DatasetTypeSnapshot)
+	snap.Written = parent.Written
+	snap.Props = copyProps(parent.Props)
+	delete(snap.Props, "mounted")
+	f.datasets[name] = snap
+	return nil
+}
+
+// cloneOf returns a clone of the snapshot, if there is any
+func (f *ZFS) cloneOf(snapshot string) string {
+	for _, ds := range f.datasets {
+		if ds.Origin == snapshot {
+			return ds.Name
+		}
+	}
+	return ""
+}
+
+// snapshotsBetween returns the names of the snapshots of the dataset taken
+// after the from snapshot, or from the first one, and before the snap
+func (f *ZFS) snapshotsBetween(name, from string, snap *Dataset) []string {
+	var after uint64
+	if len(from) > 0 {
+		after = f.datasets[name+"@"+from].GUID
+	}
+	var snaps []*Dataset
+	for n, ds := range f.datasets {
+		if strings.HasPrefix(n, name+"@") && ds.GUID > after && ds.GUID < snap.GUID {
+			snaps = append(snaps, ds)
+		}
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].GUID < snaps[j].GUID })
+
+	var names []string
+	for _, ds := range snaps {
+		names = append(names, strings.Split(ds.Name, "@")[1])
+	}
+	return names
+}
+
+func (f *ZFS) hasSnapshots(name string) bool {
+	for n := range f.datasets {
+		if strings.HasPrefix(n, name+"@") {
+			return true
+		}
+	}
+	return false
+}
+
+// available returns the space left in the pool after accounting
+// the data written and the space reserved by the datasets
+func (f *ZFS) available(pool string) int64 {
+	var used int64
+	for _, ds := range f.datasets {
+		if ds.Type == DatasetTypeSnapshot || poolName(ds.Name) != pool {
+			continue
+		}
+		used += maxInt64(ds.Written, reserved(ds))
+	}
+	return maxInt64(f.pools[pool]-used, 0)
+}
+
+// reserved returns the space reserved by the dataset
+func reserved(ds *Dataset) int64 {
+	return maxInt64(parseSize(ds.Props["reservation"]), parseSize(ds.Props["refreservation"]))
+}
+
+func poolName(name string) string {
+	return strings.SplitN(strings.SplitN(name, "@", 2)[0], "/", 2)[0]
+}
+
+// parseArgs splits the zfs arguments into the flags with values, the
+// properties passed with -o and the positional arguments. Along with -V,
+// -b, -i, -I and -t, the extra flags are taken to have a value.
+func parseArgs(args []string, extra ...string) (map[string]string, map[string]string, []string) {
+	valueFlags := append([]string{"-V", "-b", "-i", "-I", "-t"}, extra...)
+	flags := map[string]string{}
+	props := map[string]string{}
+	var names []string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-o":
+			// the columns of zfs get and list are not properties
+			i++
+			if strings.Contains(args[i], "=") {
+				kv := strings.SplitN(args[i], "=", 2)
+				props[kv[0]] = kv[1]
+			} else {
+				flags["-o"] = args[i]
+			}
+		case "-x":
+			// the properties excluded with -x are kept as the -x <prop> flags
+			i++
+			flags["-x "+args[i]] = ""
+		default:
+			if isValueFlag(args[i], valueFlags) && i+1 < len(args) {
+				flags[args[i]] = args[i+1]
+				i++
+			} else if strings.HasPrefix(args[i], "-") {
+				flags[args[i]] = ""
+			} else {
+				names = append(names, args[i])
+			}
+		}
+	}
+	return flags, props, names
+}
+
+func isValueFlag(arg string, valueFlags []string) bool {
+	for _, flag := range valueFlags {
+		if arg == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeProp converts the sizes to bytes, as zfs get -p reports them
+func normalizeProp(prop string, val string) string {
+	switch prop {
+	case "quota", "refquota", "reservation", "refreservation", "volsize":
+		return strconv.FormatInt(parseSize(val), 10)
+	}
+	return val
+}
+
+func parseSize(val string) int64 {
+	if val == "" || val == "none" {
+		return 0
+	}
+	q, err := resource.ParseQuantity(val)
+	if err != nil {
+		return 0
+	}
+	return q.Value()
+}
+
+func copyProps(props map[string]string) map[string]string {
+	cp := make(map[string]string, len(props))
+	for k, v := range props {
+		cp[k] = v
+	}
+	return cp
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}